@@ -0,0 +1,374 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// The versions cannot represent each other exactly. v1beta1 has no place for the order and comments of the v1alpha1 config,
+// and v1alpha1 has no place for the storage and auth of v1beta1.
+// When a conversion loses anything, we keep the original spec in an annotation on the converted object.
+// Converting back restores the original spec, as long as the converted spec was not changed in the meantime.
+const (
+	alphaSpecAnnotation = "cache.container-solutions.com/v1alpha1-spec"
+	betaSpecAnnotation  = "cache.container-solutions.com/v1beta1-spec"
+)
+
+// ConvertTo converts this RedisCluster to the hub version v1beta1
+func (src *RedisCluster) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.RedisCluster)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	delete(dst.Annotations, alphaSpecAnnotation)
+	dst.Spec = convertSpecToBeta(&src.Spec)
+	dst.Status = convertStatusToBeta(&src.Status)
+
+	betaSpec := &v1beta1.RedisClusterSpec{}
+	found, err := popSpecAnnotation(&dst.ObjectMeta, betaSpecAnnotation, betaSpec)
+	if err != nil {
+		return err
+	}
+	if found {
+		if equality.Semantic.DeepEqual(convertSpecToAlpha(betaSpec), src.Spec) {
+			dst.Spec = *betaSpec
+			return nil
+		}
+		// The spec was changed through v1alpha1, so we only restore the fields v1alpha1 cannot represent
+		dst.Spec.Mode = betaSpec.Mode
+		dst.Spec.Storage = betaSpec.Storage
+		dst.Spec.Auth = betaSpec.Auth
+		dst.Spec.Monitoring = betaSpec.Monitoring
+		dst.Spec.SlotRanges = betaSpec.SlotRanges
+		dst.Spec.Autoscaling = betaSpec.Autoscaling
+		dst.Spec.RoleServices = betaSpec.RoleServices
+		dst.Spec.Rebalance.Strategy = betaSpec.Rebalance.Strategy
+		dst.Spec.Rebalance.MemorySampleSize = betaSpec.Rebalance.MemorySampleSize
+	}
+	if !equality.Semantic.DeepEqual(convertSpecToAlpha(&dst.Spec), src.Spec) {
+		return setSpecAnnotation(&dst.ObjectMeta, alphaSpecAnnotation, &src.Spec)
+	}
+	return nil
+}
+
+// ConvertFrom converts the hub version v1beta1 to this RedisCluster
+func (dst *RedisCluster) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.RedisCluster)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	delete(dst.Annotations, betaSpecAnnotation)
+	dst.Spec = convertSpecToAlpha(&src.Spec)
+	dst.Status = convertStatusToAlpha(&src.Status)
+
+	alphaSpec := &RedisClusterSpec{}
+	found, err := popSpecAnnotation(&dst.ObjectMeta, alphaSpecAnnotation, alphaSpec)
+	if err != nil {
+		return err
+	}
+	if found && equality.Semantic.DeepEqual(convertSpecToBeta(alphaSpec), src.Spec) {
+		dst.Spec = *alphaSpec
+		return nil
+	}
+	if !equality.Semantic.DeepEqual(convertSpecToBeta(&dst.Spec), src.Spec) {
+		return setSpecAnnotation(&dst.ObjectMeta, betaSpecAnnotation, &src.Spec)
+	}
+	return nil
+}
+
+func popSpecAnnotation(meta *metav1.ObjectMeta, annotation string, spec interface{}) (bool, error) {
+	data, ok := meta.Annotations[annotation]
+	if !ok {
+		return false, nil
+	}
+	delete(meta.Annotations, annotation)
+	err := json.Unmarshal([]byte(data), spec)
+	if err != nil {
+		return false, fmt.Errorf("could not restore spec from annotation %s: %w", annotation, err)
+	}
+	return true, nil
+}
+
+func setSpecAnnotation(meta *metav1.ObjectMeta, annotation string, spec interface{}) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[annotation] = string(data)
+	return nil
+}
+
+//region Spec
+
+func convertSpecToBeta(src *RedisClusterSpec) v1beta1.RedisClusterSpec {
+	dst := v1beta1.RedisClusterSpec{
+		Masters:           src.Masters,
+		ReplicasPerMaster: src.ReplicasPerMaster,
+		Redis: v1beta1.RedisSettings{
+			Config: parseConfig(src.Config),
+		},
+		PodSpec:        *src.PodSpec.DeepCopy(),
+		Rebalance:      convertRebalancePolicyToBeta(&src.Rebalance),
+		DeletionPolicy: v1beta1.DeletionPolicy(src.DeletionPolicy),
+	}
+	for _, slotWeight := range src.SlotWeights {
+		dst.SlotWeights = append(dst.SlotWeights, v1beta1.SlotWeight{
+			Ordinal:      copyInt32(slotWeight.Ordinal),
+			NodeSelector: copyStringMap(slotWeight.NodeSelector),
+			Weight:       slotWeight.Weight,
+		})
+	}
+	if src.ExternalAccess != nil {
+		dst.ExternalAccess = &v1beta1.ExternalAccess{
+			Type:        src.ExternalAccess.Type,
+			Annotations: copyStringMap(src.ExternalAccess.Annotations),
+		}
+	}
+
+	// The image and resources of the Redis container have their own fields in v1beta1.
+	// We drop the container from the pod spec when nothing else is overridden for it.
+	for i, container := range dst.PodSpec.Containers {
+		if container.Name != "redis" {
+			continue
+		}
+		dst.Redis.Image = container.Image
+		dst.Resources = container.Resources
+		container.Image = ""
+		container.Resources = v1.ResourceRequirements{}
+		dst.PodSpec.Containers[i] = container
+		if equality.Semantic.DeepEqual(container, v1.Container{Name: "redis"}) {
+			dst.PodSpec.Containers = append(dst.PodSpec.Containers[:i], dst.PodSpec.Containers[i+1:]...)
+		}
+		break
+	}
+	return dst
+}
+
+func convertSpecToAlpha(src *v1beta1.RedisClusterSpec) RedisClusterSpec {
+	dst := RedisClusterSpec{
+		Masters:           src.Masters,
+		ReplicasPerMaster: src.ReplicasPerMaster,
+		Config:            formatConfig(src.Redis.Config),
+		PodSpec:           *src.PodSpec.DeepCopy(),
+		Rebalance:         convertRebalancePolicyToAlpha(&src.Rebalance),
+		DeletionPolicy:    DeletionPolicy(src.DeletionPolicy),
+	}
+	for _, slotWeight := range src.SlotWeights {
+		dst.SlotWeights = append(dst.SlotWeights, SlotWeight{
+			Ordinal:      copyInt32(slotWeight.Ordinal),
+			NodeSelector: copyStringMap(slotWeight.NodeSelector),
+			Weight:       slotWeight.Weight,
+		})
+	}
+	if src.ExternalAccess != nil {
+		dst.ExternalAccess = &ExternalAccess{
+			Type:        src.ExternalAccess.Type,
+			Annotations: copyStringMap(src.ExternalAccess.Annotations),
+		}
+	}
+
+	// Overrides in the pod spec take precedence over the image and resources, the same as they do in the Statefulset.
+	if src.Redis.Image == "" && equality.Semantic.DeepEqual(src.Resources, v1.ResourceRequirements{}) {
+		return dst
+	}
+	redisIndex := -1
+	for i, container := range dst.PodSpec.Containers {
+		if container.Name == "redis" {
+			redisIndex = i
+			break
+		}
+	}
+	if redisIndex < 0 {
+		dst.PodSpec.Containers = append([]v1.Container{{Name: "redis"}}, dst.PodSpec.Containers...)
+		redisIndex = 0
+	}
+	if dst.PodSpec.Containers[redisIndex].Image == "" {
+		dst.PodSpec.Containers[redisIndex].Image = src.Redis.Image
+	}
+	if equality.Semantic.DeepEqual(dst.PodSpec.Containers[redisIndex].Resources, v1.ResourceRequirements{}) {
+		dst.PodSpec.Containers[redisIndex].Resources = *src.Resources.DeepCopy()
+	}
+	return dst
+}
+
+// parseConfig reads the redis.conf format of v1alpha1 into settings. Later lines win, the same as in Redis.
+func parseConfig(config string) map[string]string {
+	var result map[string]string
+	for _, settingLine := range strings.Split(config, "\n") {
+		settingLine = strings.TrimSpace(settingLine)
+		if settingLine == "" || strings.HasPrefix(settingLine, "#") {
+			continue
+		}
+		if result == nil {
+			result = map[string]string{}
+		}
+		settingParts := strings.SplitN(settingLine, " ", 2)
+		if len(settingParts) == 1 {
+			result[settingParts[0]] = ""
+			continue
+		}
+		result[settingParts[0]] = strings.TrimSpace(settingParts[1])
+	}
+	return result
+}
+
+func formatConfig(config map[string]string) string {
+	var settings []string
+	for setting := range config {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+	result := ""
+	for _, setting := range settings {
+		result += strings.TrimSpace(fmt.Sprintf("%s %s", setting, config[setting])) + "\n"
+	}
+	return result
+}
+
+func convertRebalancePolicyToBeta(src *RebalancePolicy) v1beta1.RebalancePolicy {
+	dst := v1beta1.RebalancePolicy{
+		DryRun:               src.DryRun,
+		ThresholdPercentage:  src.ThresholdPercentage,
+		MaxSlotsPerReconcile: src.MaxSlotsPerReconcile,
+	}
+	if src.Enabled != nil {
+		enabled := *src.Enabled
+		dst.Enabled = &enabled
+	}
+	if src.MaintenanceWindow != nil {
+		dst.MaintenanceWindow = &v1beta1.MaintenanceWindow{
+			Start:    src.MaintenanceWindow.Start,
+			Duration: src.MaintenanceWindow.Duration,
+		}
+	}
+	return dst
+}
+
+func convertRebalancePolicyToAlpha(src *v1beta1.RebalancePolicy) RebalancePolicy {
+	dst := RebalancePolicy{
+		DryRun:               src.DryRun,
+		ThresholdPercentage:  src.ThresholdPercentage,
+		MaxSlotsPerReconcile: src.MaxSlotsPerReconcile,
+	}
+	if src.Enabled != nil {
+		enabled := *src.Enabled
+		dst.Enabled = &enabled
+	}
+	if src.MaintenanceWindow != nil {
+		dst.MaintenanceWindow = &MaintenanceWindow{
+			Start:    src.MaintenanceWindow.Start,
+			Duration: src.MaintenanceWindow.Duration,
+		}
+	}
+	return dst
+}
+
+//endregion
+
+//region Status
+
+func convertStatusToBeta(src *RedisClusterStatus) v1beta1.RedisClusterStatus {
+	dst := v1beta1.RedisClusterStatus{}
+	if src.ClusterCheck != nil {
+		dst.ClusterCheck = &v1beta1.ClusterCheckStatus{
+			Consistent:    src.ClusterCheck.Consistent,
+			LastCheckTime: src.ClusterCheck.LastCheckTime,
+		}
+		for _, issue := range src.ClusterCheck.Issues {
+			dst.ClusterCheck.Issues = append(dst.ClusterCheck.Issues, v1beta1.ClusterCheckIssue(issue))
+		}
+	}
+	if src.Rebalance != nil {
+		dst.Rebalance = &v1beta1.RebalanceStatus{
+			State:        v1beta1.RebalanceState(src.Rebalance.State),
+			LastPlanTime: src.Rebalance.LastPlanTime,
+			SlotsToMove:  src.Rebalance.SlotsToMove,
+		}
+		for _, move := range src.Rebalance.Moves {
+			dst.Rebalance.Moves = append(dst.Rebalance.Moves, v1beta1.RebalanceMove(move))
+		}
+	}
+	if src.Autoscaling != nil {
+		autoscaling := v1beta1.AutoscalingStatus(*src.Autoscaling.DeepCopy())
+		dst.Autoscaling = &autoscaling
+	}
+	for _, condition := range src.Conditions {
+		dst.Conditions = append(dst.Conditions, *condition.DeepCopy())
+	}
+	return dst
+}
+
+func convertStatusToAlpha(src *v1beta1.RedisClusterStatus) RedisClusterStatus {
+	dst := RedisClusterStatus{}
+	if src.ClusterCheck != nil {
+		dst.ClusterCheck = &ClusterCheckStatus{
+			Consistent:    src.ClusterCheck.Consistent,
+			LastCheckTime: src.ClusterCheck.LastCheckTime,
+		}
+		for _, issue := range src.ClusterCheck.Issues {
+			dst.ClusterCheck.Issues = append(dst.ClusterCheck.Issues, ClusterCheckIssue(issue))
+		}
+	}
+	if src.Rebalance != nil {
+		dst.Rebalance = &RebalanceStatus{
+			State:        RebalanceState(src.Rebalance.State),
+			LastPlanTime: src.Rebalance.LastPlanTime,
+			SlotsToMove:  src.Rebalance.SlotsToMove,
+		}
+		for _, move := range src.Rebalance.Moves {
+			dst.Rebalance.Moves = append(dst.Rebalance.Moves, RebalanceMove(move))
+		}
+	}
+	if src.Autoscaling != nil {
+		autoscaling := AutoscalingStatus(*src.Autoscaling.DeepCopy())
+		dst.Autoscaling = &autoscaling
+	}
+	for _, condition := range src.Conditions {
+		dst.Conditions = append(dst.Conditions, *condition.DeepCopy())
+	}
+	return dst
+}
+
+//endregion
+
+func copyInt32(value *int32) *int32 {
+	if value == nil {
+		return nil
+	}
+	result := *value
+	return &result
+}
+
+func copyStringMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		result[key] = value
+	}
+	return result
+}
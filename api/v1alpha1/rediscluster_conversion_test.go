@@ -0,0 +1,245 @@
+package v1alpha1
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	fuzz "github.com/google/gofuzz"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"math/rand"
+	"testing"
+)
+
+func conversionFuzzer(t *testing.T) *fuzz.Fuzzer {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Could not add v1alpha1 to scheme: %v", err)
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Could not add v1beta1 to scheme: %v", err)
+	}
+	return fuzzer.FuzzerFor(fuzzer.MergeFuzzerFuncs(metafuzzer.Funcs, redisClusterFuzzerFuncs), rand.NewSource(rand.Int63()), serializer.NewCodecFactory(scheme))
+}
+
+// redisClusterFuzzerFuncs makes sure the fuzzed clusters regularly contain the parts which are converted between fields,
+// like a Redis container in the pod spec and redis.conf lines, as completely random values would rarely hit them.
+func redisClusterFuzzerFuncs(_ serializer.CodecFactory) []interface{} {
+	configLines := []string{"maxmemory 100mb", "maxmemory 200mb", "maxmemory-policy allkeys-lru", "save 900 1", "# keep it small", "appendonly", "", "  "}
+	return []interface{}{
+		func(spec *RedisClusterSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			spec.Config = ""
+			for i := c.Intn(5); i > 0; i-- {
+				spec.Config += configLines[c.Intn(len(configLines))] + "\n"
+			}
+		},
+		func(spec *v1.PodSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			if len(spec.Containers) > 0 && c.RandBool() {
+				spec.Containers[c.Intn(len(spec.Containers))].Name = "redis"
+			}
+		},
+		func(quantity *resource.Quantity, c fuzz.Continue) {
+			*quantity = *resource.NewQuantity(c.Int63n(1000), resource.DecimalSI)
+		},
+	}
+}
+
+func TestRedisCluster_ConvertRoundTripFromV1alpha1(t *testing.T) {
+	f := conversionFuzzer(t)
+	for i := 0; i < 500; i++ {
+		original := &RedisCluster{}
+		f.Fuzz(original)
+		hub := &v1beta1.RedisCluster{}
+		if err := original.DeepCopy().ConvertTo(hub); err != nil {
+			t.Fatalf("Could not convert to v1beta1: %v", err)
+		}
+		result := &RedisCluster{}
+		if err := result.ConvertFrom(hub); err != nil {
+			t.Fatalf("Could not convert from v1beta1: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(original, result) {
+			t.Fatalf("RedisCluster changed after converting to v1beta1 and back:\n%s", diff.ObjectReflectDiff(original, result))
+		}
+	}
+}
+
+func TestRedisCluster_ConvertRoundTripFromV1beta1(t *testing.T) {
+	f := conversionFuzzer(t)
+	for i := 0; i < 500; i++ {
+		original := &v1beta1.RedisCluster{}
+		f.Fuzz(original)
+		spoke := &RedisCluster{}
+		if err := spoke.ConvertFrom(original.DeepCopy()); err != nil {
+			t.Fatalf("Could not convert from v1beta1: %v", err)
+		}
+		result := &v1beta1.RedisCluster{}
+		if err := spoke.ConvertTo(result); err != nil {
+			t.Fatalf("Could not convert to v1beta1: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(original, result) {
+			t.Fatalf("RedisCluster changed after converting to v1alpha1 and back:\n%s", diff.ObjectReflectDiff(original, result))
+		}
+	}
+}
+
+func TestRedisCluster_ConvertTo(t *testing.T) {
+	cluster := &RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+			Config: `maxmemory 200mb
+maxmemory-policy allkeys-lru
+`,
+			PodSpec: v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name:  "redis",
+						Image: "redis:6.2",
+						Resources: v1.ResourceRequirements{
+							Requests: v1.ResourceList{
+								v1.ResourceMemory: resource.MustParse("512Mi"),
+							},
+						},
+					},
+					{
+						Name:  "redis-exporter",
+						Image: "oliver006/redis_exporter:latest",
+					},
+				},
+			},
+		},
+	}
+	hub := &v1beta1.RedisCluster{}
+	err := cluster.ConvertTo(hub)
+	if err != nil {
+		t.Fatalf("Could not convert to v1beta1: %v", err)
+	}
+	expectedSpec := v1beta1.RedisClusterSpec{
+		Masters:           3,
+		ReplicasPerMaster: 1,
+		Redis: v1beta1.RedisSettings{
+			Image: "redis:6.2",
+			Config: map[string]string{
+				"maxmemory":        "200mb",
+				"maxmemory-policy": "allkeys-lru",
+			},
+		},
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+		},
+		PodSpec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:  "redis-exporter",
+					Image: "oliver006/redis_exporter:latest",
+				},
+			},
+		},
+	}
+	if !equality.Semantic.DeepEqual(hub.Spec, expectedSpec) {
+		t.Fatalf("Unexpected v1beta1 spec:\n%s", diff.ObjectReflectDiff(expectedSpec, hub.Spec))
+	}
+	if _, ok := hub.Annotations[alphaSpecAnnotation]; ok {
+		t.Fatalf("Expected no annotation when the conversion is lossless, got %s", hub.Annotations[alphaSpecAnnotation])
+	}
+}
+
+func TestRedisCluster_ConvertToKeepsConfigFormatting(t *testing.T) {
+	config := `# Keep the dataset small
+maxmemory 200mb
+maxmemory 100mb
+`
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+			Config:  config,
+		},
+	}
+	hub := &v1beta1.RedisCluster{}
+	err := cluster.ConvertTo(hub)
+	if err != nil {
+		t.Fatalf("Could not convert to v1beta1: %v", err)
+	}
+	if hub.Spec.Redis.Config["maxmemory"] != "100mb" {
+		t.Fatalf("Expected the last maxmemory setting to win, got %s", hub.Spec.Redis.Config["maxmemory"])
+	}
+
+	result := &RedisCluster{}
+	err = result.ConvertFrom(hub)
+	if err != nil {
+		t.Fatalf("Could not convert from v1beta1: %v", err)
+	}
+	if result.Spec.Config != config {
+		t.Fatalf("Expected config to be restored exactly. Expected:\n%s\nGot:\n%s", config, result.Spec.Config)
+	}
+
+	// Once the settings change in v1beta1, the old formatting no longer applies
+	hub.Spec.Redis.Config["maxmemory"] = "300mb"
+	err = result.ConvertFrom(hub)
+	if err != nil {
+		t.Fatalf("Could not convert from v1beta1: %v", err)
+	}
+	if result.Spec.Config != "maxmemory 300mb\n" {
+		t.Fatalf("Expected config to be generated from the v1beta1 settings, got:\n%s", result.Spec.Config)
+	}
+}
+
+func TestRedisCluster_ConvertFromKeepsStorageWhenChangedThroughV1alpha1(t *testing.T) {
+	storageClass := "fast"
+	hub := &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Mode:    v1beta1.ModeReplication,
+			Masters: 3,
+			Storage: &v1beta1.Storage{
+				Size:             resource.MustParse("10Gi"),
+				StorageClassName: &storageClass,
+			},
+			Auth: &v1beta1.Auth{
+				PasswordSecret: v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "redis-password"},
+					Key:                  "password",
+				},
+			},
+		},
+	}
+	cluster := &RedisCluster{}
+	err := cluster.ConvertFrom(hub)
+	if err != nil {
+		t.Fatalf("Could not convert from v1beta1: %v", err)
+	}
+	if _, ok := cluster.Annotations[betaSpecAnnotation]; !ok {
+		t.Fatalf("Expected the v1beta1 spec to be kept in an annotation")
+	}
+
+	cluster.Spec.Masters = 5
+	result := &v1beta1.RedisCluster{}
+	err = cluster.ConvertTo(result)
+	if err != nil {
+		t.Fatalf("Could not convert to v1beta1: %v", err)
+	}
+	if result.Spec.Masters != 5 {
+		t.Fatalf("Expected the change made through v1alpha1 to be kept, got %d masters", result.Spec.Masters)
+	}
+	if !equality.Semantic.DeepEqual(result.Spec.Storage, hub.Spec.Storage) || !equality.Semantic.DeepEqual(result.Spec.Auth, hub.Spec.Auth) {
+		t.Fatalf("Expected storage and auth to be restored, got %v and %v", result.Spec.Storage, result.Spec.Auth)
+	}
+	if result.Spec.Mode != v1beta1.ModeReplication {
+		t.Fatalf("Expected the mode to be restored, got %s", result.Spec.Mode)
+	}
+	if _, ok := result.Annotations[betaSpecAnnotation]; ok {
+		t.Fatalf("Expected the annotation to be removed from the v1beta1 object")
+	}
+}
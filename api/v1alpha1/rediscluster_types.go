@@ -19,6 +19,8 @@ package v1alpha1
 import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"time"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -43,12 +45,262 @@ type RedisClusterSpec struct {
 
 	// PodSpec specifies the overrides or additions necessary for the redis pods. This allows you to override any pod settings necessary
 	PodSpec v1.PodSpec `json:"podSpec,omitempty"`
+
+	// SlotWeights specifies the relative share of slots for masters. Masters which are not matched by any entry get a weight of 1.
+	// This allows masters on bigger nodes to own proportionally more slots. The first matching entry is used.
+	// +optional
+	SlotWeights []SlotWeight `json:"slotWeights,omitempty"`
+
+	// Rebalance specifies when and how slots are moved between masters to balance the cluster.
+	// +optional
+	Rebalance RebalancePolicy `json:"rebalance,omitempty"`
+
+	// ExternalAccess exposes every Redis node through its own Service, so clients outside of Kubernetes can connect to the cluster.
+	// +optional
+	ExternalAccess *ExternalAccess `json:"externalAccess,omitempty"`
+
+	// DeletionPolicy specifies what happens to the Redis nodes and their data when the RedisCluster is deleted.
+	// Defaults to Retain, so the data is only deleted when asked for.
+	// +kubebuilder:validation:Enum=Delete;Retain;SnapshotThenDelete
+	// +kubebuilder:default:=Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// DeletionPolicy specifies what happens to the Redis nodes and their data when the RedisCluster is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes all the resources of the cluster, including its persistent volume claims
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the persistent volume claims of the cluster, while its other resources are deleted
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicySnapshotThenDelete saves a snapshot on every master, and keeps the persistent volume claims holding the snapshots.
+	// This needs the storage of the cluster, as the snapshots are lost with the pods otherwise.
+	DeletionPolicySnapshotThenDelete DeletionPolicy = "SnapshotThenDelete"
+)
+
+// ExternalAccess specifies how Redis nodes are exposed outside of Kubernetes.
+// Every node announces the address of its own Service, so redirects from the cluster point at reachable endpoints.
+type ExternalAccess struct {
+	// Type is the type of the Service created for every Redis pod.
+	// +kubebuilder:validation:Enum=NodePort;LoadBalancer
+	// +kubebuilder:default:=LoadBalancer
+	Type v1.ServiceType `json:"type,omitempty"`
+
+	// Annotations are added to every external Service, for example to configure the load balancer of your cloud provider.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SlotWeight assigns a weight to the masters matched by the ordinal and/or node selector.
+// If both the ordinal and node selector are set, a master needs to match both.
+type SlotWeight struct {
+	// Ordinal matches the master running in the pod with this StatefulSet ordinal.
+	// +optional
+	Ordinal *int32 `json:"ordinal,omitempty"`
+
+	// NodeSelector matches masters running on Kubernetes nodes with all of these labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Weight is the share of slots for the matched masters, relative to the weights of the other masters.
+	// +kubebuilder:validation:Minimum=1
+	Weight int32 `json:"weight"`
+}
+
+// RebalancePolicy specifies when and how slots are moved between masters.
+type RebalancePolicy struct {
+	// Enabled specifies whether the operator moves slots between masters. Defaults to true.
+	// Missing slots are always assigned, even when rebalancing is disabled.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// DryRun only publishes the rebalance plan in the status and as Events, without moving any slots.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// ThresholdPercentage only moves slots when a master owns more or less slots than it should,
+	// by more than this percentage of the amount of slots it should own.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ThresholdPercentage int32 `json:"thresholdPercentage,omitempty"`
+
+	// MaxSlotsPerReconcile limits the amount of slots moved in a single reconcile. 0 means no limit.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSlotsPerReconcile int32 `json:"maxSlotsPerReconcile,omitempty"`
+
+	// MaintenanceWindow restricts moving slots to a daily window.
+	// Outside the window the plan is only published in the status.
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+}
+
+// MaintenanceWindow is a daily window of time.
+type MaintenanceWindow struct {
+	// Start is the time of day the window opens, in UTC, in the format HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// Duration is how long the window stays open, for example 2h30m.
+	Duration metav1.Duration `json:"duration"`
+}
+
+func (policy *RebalancePolicy) IsEnabled() bool {
+	return policy.Enabled == nil || *policy.Enabled
+}
+
+// Contains returns whether the given time falls in the window.
+// Windows can span midnight, so we check both the window which opened today, and the one which opened yesterday.
+func (window *MaintenanceWindow) Contains(now time.Time) bool {
+	start, err := time.Parse("15:04", window.Start)
+	if err != nil {
+		return false
+	}
+	now = now.UTC()
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, time.UTC)
+	for _, windowStart := range []time.Time{todayStart, todayStart.AddDate(0, 0, -1)} {
+		if !now.Before(windowStart) && now.Before(windowStart.Add(window.Duration.Duration)) {
+			return true
+		}
+	}
+	return false
 }
 
 // RedisClusterStatus defines the observed state of RedisCluster
 type RedisClusterStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// ClusterCheck contains the result of the last consistency check of the Redis cluster.
+	// +optional
+	ClusterCheck *ClusterCheckStatus `json:"clusterCheck,omitempty"`
+
+	// Rebalance contains the last rebalance plan calculated for the cluster.
+	// +optional
+	Rebalance *RebalanceStatus `json:"rebalance,omitempty"`
+
+	// Autoscaling contains the last memory measurement and scaling decision of the autoscaler.
+	// Autoscaling can only be configured through v1beta1.
+	// +optional
+	Autoscaling *AutoscalingStatus `json:"autoscaling,omitempty"`
+
+	// Conditions represent the latest available observations of the cluster's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// PausedAnnotation can be set to "true" on a RedisCluster to stop the Operator from making any changes to it.
+	// The Operator still observes the cluster and reports its status while it is paused.
+	PausedAnnotation = "cache.container-solutions.com/paused"
+
+	// ConditionPaused is true while the Operator is not making any changes to the cluster
+	ConditionPaused = "Paused"
+
+	// Finalizer allows the Operator to execute the deletion policy before the RedisCluster is removed
+	Finalizer = "cache.container-solutions.com/finalizer"
+
+	// ConditionDeleting is true while the Operator executes the deletion policy. The reason shows the progress.
+	ConditionDeleting = "Deleting"
+)
+
+// RebalanceState describes what the operator did with the last rebalance plan.
+type RebalanceState string
+
+const (
+	// RebalanceBalanced means the cluster is balanced within the threshold, and no slots need to move.
+	RebalanceBalanced RebalanceState = "Balanced"
+	// RebalanceMoving means the operator is moving the planned slots.
+	RebalanceMoving RebalanceState = "Moving"
+	// RebalanceDryRun means the plan is only published, as the rebalance policy is in dry run mode.
+	RebalanceDryRun RebalanceState = "DryRun"
+	// RebalanceWaitingForWindow means the plan will be executed when the maintenance window opens.
+	RebalanceWaitingForWindow RebalanceState = "WaitingForMaintenanceWindow"
+	// RebalanceDisabled means rebalancing is disabled in the rebalance policy.
+	RebalanceDisabled RebalanceState = "Disabled"
+)
+
+// AutoscalingStatus describes the last memory measurement and scaling decision of the autoscaler.
+type AutoscalingStatus struct {
+	// MemoryUtilization is the percentage of maxmemory used by the masters on average.
+	MemoryUtilization int32 `json:"memoryUtilization"`
+
+	// DesiredMasters is the amount of masters the autoscaler wants for the measured memory.
+	DesiredMasters int32 `json:"desiredMasters"`
+
+	// LastMeasureTime is the time the memory of the masters was measured.
+	// +optional
+	LastMeasureTime metav1.Time `json:"lastMeasureTime,omitempty"`
+
+	// LastScaleTime is the time the autoscaler last changed the amount of masters.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+}
+
+// RebalanceStatus describes the last rebalance plan.
+type RebalanceStatus struct {
+	// State describes what the operator did with the plan.
+	State RebalanceState `json:"state"`
+
+	// LastPlanTime is the time the plan was calculated. It is only updated when the plan or its state changes.
+	// +optional
+	LastPlanTime metav1.Time `json:"lastPlanTime,omitempty"`
+
+	// SlotsToMove is the total amount of slots in the plan.
+	SlotsToMove int32 `json:"slotsToMove"`
+
+	// Moves lists the planned slot moves between masters.
+	// +optional
+	Moves []RebalanceMove `json:"moves,omitempty"`
+}
+
+// RebalanceMove is a set of slots to move from one master to another.
+type RebalanceMove struct {
+	// Source is the ID of the master the slots are moved from.
+	Source string `json:"source"`
+
+	// Destination is the ID of the master the slots are moved to.
+	Destination string `json:"destination"`
+
+	// Slots are the slot ranges to move, in the same format as CLUSTER NODES, for example 0-100,200.
+	Slots string `json:"slots"`
+
+	// SlotCount is the amount of slots to move.
+	SlotCount int32 `json:"slotCount"`
+}
+
+// ClusterCheckStatus reports whether all Redis nodes share the same view of the cluster,
+// similar to the output of `redis-cli --cluster check`.
+type ClusterCheckStatus struct {
+	// Consistent is true when all nodes agree on slot ownership, config epochs and the nodes in the cluster.
+	// Slots are not rebalanced while the nodes disagree.
+	Consistent bool `json:"consistent"`
+
+	// LastCheckTime is the time the cluster was last checked.
+	// +optional
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// Issues lists all problems found during the last check.
+	// +optional
+	Issues []ClusterCheckIssue `json:"issues,omitempty"`
+}
+
+// ClusterCheckIssue describes a single problem found while checking the cluster.
+type ClusterCheckIssue struct {
+	// Type is the kind of problem, for example SlotOwnershipMismatch or OpenSlot.
+	Type string `json:"type"`
+
+	// NodeID is the ID of the Redis node which reported the problem, if the problem is specific to one node.
+	// +optional
+	NodeID string `json:"nodeID,omitempty"`
+
+	// Message is a human readable description of the problem.
+	Message string `json:"message"`
 }
 
 //+kubebuilder:object:root=true
@@ -63,10 +315,40 @@ type RedisCluster struct {
 	Status RedisClusterStatus `json:"status,omitempty"`
 }
 
+// IsPaused returns whether the Operator should refrain from changing the cluster
+func (cluster *RedisCluster) IsPaused() bool {
+	return cluster.GetAnnotations()[PausedAnnotation] == "true"
+}
+
 func (cluster *RedisCluster) NodesNeeded() int32 {
 	return cluster.Spec.Masters + (cluster.Spec.Masters * cluster.Spec.ReplicasPerMaster)
 }
 
+// GetSlotWeight returns the weight for a master running in the pod with the given ordinal,
+// on a Kubernetes node with the given labels.
+func (cluster *RedisCluster) GetSlotWeight(ordinal int32, nodeLabels map[string]string) int32 {
+	for _, slotWeight := range cluster.Spec.SlotWeights {
+		if slotWeight.Ordinal != nil && *slotWeight.Ordinal != ordinal {
+			continue
+		}
+		if len(slotWeight.NodeSelector) > 0 && !labels.SelectorFromSet(slotWeight.NodeSelector).Matches(labels.Set(nodeLabels)) {
+			continue
+		}
+		return slotWeight.Weight
+	}
+	return 1
+}
+
+// SlotWeightsSelectNodes returns whether any of the slot weights depend on the labels of Kubernetes nodes
+func (cluster *RedisCluster) SlotWeightsSelectNodes() bool {
+	for _, slotWeight := range cluster.Spec.SlotWeights {
+		if len(slotWeight.NodeSelector) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 //+kubebuilder:object:root=true
 
 // RedisClusterList contains a list of RedisCluster
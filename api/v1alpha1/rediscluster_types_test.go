@@ -2,8 +2,10 @@ package v1alpha1
 
 import (
 	"bytes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"testing"
+	"time"
 )
 
 func TestRedisCluster_NodesNeeded(t *testing.T) {
@@ -104,3 +106,97 @@ port 6379
 %v`, expectedConfig, redisCluster.Spec.Config)
 	}
 }
+
+func TestRedisCluster_GetSlotWeight(t *testing.T) {
+	largeOrdinal := int32(2)
+	cluster := RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+			SlotWeights: []SlotWeight{
+				{
+					Ordinal: &largeOrdinal,
+					Weight:  4,
+				},
+				{
+					NodeSelector: map[string]string{
+						"node.kubernetes.io/instance-type": "m5.2xlarge",
+					},
+					Weight: 2,
+				},
+			},
+		},
+	}
+	testMap := map[string]struct {
+		ordinal        int32
+		nodeLabels     map[string]string
+		expectedWeight int32
+	}{
+		"matched by ordinal": {
+			ordinal:        2,
+			expectedWeight: 4,
+		},
+		"first match wins": {
+			ordinal: 2,
+			nodeLabels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.2xlarge",
+			},
+			expectedWeight: 4,
+		},
+		"matched by node selector": {
+			ordinal: 0,
+			nodeLabels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.2xlarge",
+				"kubernetes.io/os":                 "linux",
+			},
+			expectedWeight: 2,
+		},
+		"not matched": {
+			ordinal: 1,
+			nodeLabels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.large",
+			},
+			expectedWeight: 1,
+		},
+	}
+	for name, testCase := range testMap {
+		got := cluster.GetSlotWeight(testCase.ordinal, testCase.nodeLabels)
+		if got != testCase.expectedWeight {
+			t.Fatalf("Incorrect slot weight for testcase %s. Expected %d, got %d", name, testCase.expectedWeight, got)
+		}
+	}
+}
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	window := MaintenanceWindow{
+		Start:    "23:00",
+		Duration: metav1.Duration{Duration: 2 * time.Hour},
+	}
+	testMap := map[string]struct {
+		now      time.Time
+		expected bool
+	}{
+		"BeforeWindow": {
+			now:      time.Date(2022, 6, 1, 22, 59, 0, 0, time.UTC),
+			expected: false,
+		},
+		"StartOfWindow": {
+			now:      time.Date(2022, 6, 1, 23, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		"WindowOpenedYesterday": {
+			now:      time.Date(2022, 6, 2, 0, 30, 0, 0, time.UTC),
+			expected: true,
+		},
+		"AfterWindow": {
+			now:      time.Date(2022, 6, 2, 1, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+	}
+	for name, test := range testMap {
+		t.Run(name, func(t *testing.T) {
+			if window.Contains(test.now) != test.expected {
+				t.Fatalf("Expected window to contain %s to be %v", test.now, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,621 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"math"
+	"time"
+)
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// RedisClusterSpec defines the desired state of RedisCluster
+type RedisClusterSpec struct {
+	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// Mode specifies whether the nodes form a Redis Cluster, or a single master with replicas for clients which do not support Redis Cluster.
+	// Replication mode needs storage, so a restarted master keeps its data.
+	// +kubebuilder:validation:Enum=Cluster;Replication
+	// +kubebuilder:default:=Cluster
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+
+	// Masters specifies how many master nodes should be created in the Redis cluster.
+	// In Replication mode there is always a single master.
+	// +kubebuilder:validation:Required
+	Masters int32 `json:"masters"`
+
+	// ReplicasPerMaster specifies how many replicas should be attached to each master node in the Redis cluster.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=0
+	ReplicasPerMaster int32 `json:"replicasPerMaster,omitempty"`
+
+	// Redis specifies the image and configuration of the Redis server running in each node.
+	// +optional
+	Redis RedisSettings `json:"redis,omitempty"`
+
+	// Resources specifies the compute resources of the Redis container in each node.
+	// +optional
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Storage gives each Redis node a persistent volume for its data. Without storage, data only lives as long as the pod.
+	// The storage cannot be changed once the cluster is created.
+	// +optional
+	Storage *Storage `json:"storage,omitempty"`
+
+	// Auth protects the Redis nodes with a password.
+	// +optional
+	Auth *Auth `json:"auth,omitempty"`
+
+	// PodSpec specifies the overrides or additions necessary for the redis pods. This allows you to override any pod settings necessary
+	// +optional
+	PodSpec v1.PodSpec `json:"podSpec,omitempty"`
+
+	// SlotWeights specifies the relative share of slots for masters. Masters which are not matched by any entry get a weight of 1.
+	// This allows masters on bigger nodes to own proportionally more slots. The first matching entry is used.
+	// +optional
+	SlotWeights []SlotWeight `json:"slotWeights,omitempty"`
+
+	// SlotRanges pins slot ranges to the masters in specific pods, for example to keep the keys of a tenant on a dedicated master.
+	// Pinned slots are moved to their master, and are never moved away when rebalancing. The first entry pinning a slot is used.
+	// +optional
+	SlotRanges []SlotRange `json:"slotRanges,omitempty"`
+
+	// Rebalance specifies when and how slots are moved between masters to balance the cluster.
+	// +optional
+	Rebalance RebalancePolicy `json:"rebalance,omitempty"`
+
+	// RoleServices creates Services selecting only the masters, or only the replicas, of the cluster.
+	// The Operator labels every pod with the current role of its Redis node, so the Services follow failovers.
+	// +optional
+	RoleServices *RoleServices `json:"roleServices,omitempty"`
+
+	// Autoscaling adds and removes masters based on the memory used by the masters, by changing Masters.
+	// Autoscaling only applies in Cluster mode.
+	// +optional
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+
+	// ExternalAccess exposes every Redis node through its own Service, so clients outside of Kubernetes can connect to the cluster.
+	// +optional
+	ExternalAccess *ExternalAccess `json:"externalAccess,omitempty"`
+
+	// Monitoring runs a Prometheus exporter next to every Redis node, and optionally creates the monitors for the Prometheus Operator.
+	// +optional
+	Monitoring *Monitoring `json:"monitoring,omitempty"`
+
+	// DeletionPolicy specifies what happens to the Redis nodes and their data when the RedisCluster is deleted.
+	// Defaults to Retain, so the data is only deleted when asked for.
+	// +kubebuilder:validation:Enum=Delete;Retain;SnapshotThenDelete
+	// +kubebuilder:default:=Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// RedisSettings specifies the Redis server running in each node.
+type RedisSettings struct {
+	// Image is the Redis image to run. Defaults to redis:7.0.0.
+	// Cluster mode needs Redis 7 or later.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Config specifies the settings of redis.conf, mapped by the name of the setting.
+	// Redis always listens on port 6379, so the port cannot be changed.
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// Storage specifies the persistent volume claimed for each Redis node.
+type Storage struct {
+	// Size is the size of the volume claimed for each node.
+	Size resource.Quantity `json:"size"`
+
+	// StorageClassName is the storage class of the volumes. Uses the default storage class of the cluster when empty.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+// Auth specifies how clients and Redis nodes authenticate with each other.
+type Auth struct {
+	// PasswordSecret selects the key of a Secret in the namespace of the cluster, which holds the password of the Redis nodes.
+	// The Operator, the replicas and the probes authenticate with the same password.
+	PasswordSecret v1.SecretKeySelector `json:"passwordSecret"`
+}
+
+// Mode specifies how the Redis nodes are connected to each other.
+type Mode string
+
+const (
+	// ModeCluster runs the nodes as a Redis Cluster, with the slots divided over the masters
+	ModeCluster Mode = "Cluster"
+	// ModeReplication runs a single master with replicas, and the Operator promotes a replica when the master fails
+	ModeReplication Mode = "Replication"
+)
+
+// DeletionPolicy specifies what happens to the Redis nodes and their data when the RedisCluster is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes all the resources of the cluster, including its persistent volume claims
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the persistent volume claims of the cluster, while its other resources are deleted
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicySnapshotThenDelete saves a snapshot on every master, and keeps the persistent volume claims holding the snapshots.
+	// This needs the storage of the cluster, as the snapshots are lost with the pods otherwise.
+	DeletionPolicySnapshotThenDelete DeletionPolicy = "SnapshotThenDelete"
+)
+
+// Monitoring specifies the exporter which exposes the metrics of every Redis node, and how Prometheus scrapes them.
+type Monitoring struct {
+	// Image is the image of the redis_exporter container. Defaults to the exporter version the Operator is tested with.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Resources specifies the compute resources of the exporter container.
+	// +optional
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+
+	// PodMonitor creates a PodMonitor scraping every Redis pod. This needs the CRDs of the Prometheus Operator.
+	// +optional
+	PodMonitor *PrometheusMonitor `json:"podMonitor,omitempty"`
+
+	// ServiceMonitor creates a ServiceMonitor scraping the pods behind the Service of the cluster.
+	// This needs the CRDs of the Prometheus Operator.
+	// +optional
+	ServiceMonitor *PrometheusMonitor `json:"serviceMonitor,omitempty"`
+}
+
+// PrometheusMonitor specifies a PodMonitor or ServiceMonitor created for the cluster.
+type PrometheusMonitor struct {
+	// Labels are added to the monitor, so Prometheus instances can select it.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Interval is the interval at which Prometheus scrapes the metrics, for example 30s. Uses the interval of Prometheus when empty.
+	// +kubebuilder:validation:Pattern=`^(0|(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`
+	// +optional
+	Interval string `json:"interval,omitempty"`
+}
+
+// ExternalAccess specifies how Redis nodes are exposed outside of Kubernetes.
+// Every node announces the address of its own Service, so redirects from the cluster point at reachable endpoints.
+type ExternalAccess struct {
+	// Type is the type of the Service created for every Redis pod.
+	// +kubebuilder:validation:Enum=NodePort;LoadBalancer
+	// +kubebuilder:default:=LoadBalancer
+	Type v1.ServiceType `json:"type,omitempty"`
+
+	// Annotations are added to every external Service, for example to configure the load balancer of your cloud provider.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SlotWeight assigns a weight to the masters matched by the ordinal and/or node selector.
+// If both the ordinal and node selector are set, a master needs to match both.
+type SlotWeight struct {
+	// Ordinal matches the master running in the pod with this StatefulSet ordinal.
+	// +optional
+	Ordinal *int32 `json:"ordinal,omitempty"`
+
+	// NodeSelector matches masters running on Kubernetes nodes with all of these labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Weight is the share of slots for the matched masters, relative to the weights of the other masters.
+	// +kubebuilder:validation:Minimum=1
+	Weight int32 `json:"weight"`
+}
+
+// SlotRange pins slots to the master running in the pod with the given ordinal.
+// Slots pinned to a pod which does not run a master stay with the master which owns them.
+type SlotRange struct {
+	// Ordinal is the StatefulSet ordinal of the pod running the master the slots are pinned to.
+	// +kubebuilder:validation:Minimum=0
+	Ordinal int32 `json:"ordinal"`
+
+	// Slots are the pinned slot ranges, in the same format as CLUSTER NODES, for example 0-100,200.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`
+	Slots string `json:"slots"`
+}
+
+// RebalancePolicy specifies when and how slots are moved between masters.
+type RebalancePolicy struct {
+	// Enabled specifies whether the operator moves slots between masters. Defaults to true.
+	// Missing slots are always assigned, even when rebalancing is disabled.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// DryRun only publishes the rebalance plan in the status and as Events, without moving any slots.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// ThresholdPercentage only moves slots when a master owns more or less slots than it should,
+	// by more than this percentage of the amount of slots it should own.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ThresholdPercentage int32 `json:"thresholdPercentage,omitempty"`
+
+	// MaxSlotsPerReconcile limits the amount of slots moved in a single reconcile. 0 means no limit.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSlotsPerReconcile int32 `json:"maxSlotsPerReconcile,omitempty"`
+
+	// MaintenanceWindow restricts moving slots to a daily window.
+	// Outside the window the plan is only published in the status.
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+
+	// Strategy specifies what is balanced across the masters. Defaults to Slots.
+	// Slots balances the amount of slots, Keys the amount of keys and Memory the memory used by the keys in the slots.
+	// +kubebuilder:validation:Enum=Slots;Keys;Memory
+	// +optional
+	Strategy RebalanceStrategy `json:"strategy,omitempty"`
+
+	// MemorySampleSize is the amount of keys sampled with MEMORY USAGE to estimate the memory used by a slot,
+	// when balancing on memory. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MemorySampleSize int32 `json:"memorySampleSize,omitempty"`
+}
+
+// RoleServices specifies the Services which select the pods by the role of their Redis node.
+type RoleServices struct {
+	// Masters creates the <name>-masters Service, which selects the pods running a master.
+	// +optional
+	Masters bool `json:"masters,omitempty"`
+
+	// Replicas creates the <name>-replicas Service, which selects the pods running a replica.
+	// In Cluster mode clients need to send READONLY on their connections to read from replicas.
+	// +optional
+	Replicas bool `json:"replicas,omitempty"`
+}
+
+// Autoscaling specifies how the operator scales the amount of masters to the memory used by the cluster.
+type Autoscaling struct {
+	// MinMasters is the least amount of masters the cluster is scaled down to.
+	// +kubebuilder:validation:Minimum=3
+	MinMasters int32 `json:"minMasters"`
+
+	// MaxMasters is the most masters the cluster is scaled up to.
+	// +kubebuilder:validation:Minimum=3
+	MaxMasters int32 `json:"maxMasters"`
+
+	// TargetMemoryUtilization is the percentage of maxmemory the masters should use on average. Defaults to 70.
+	// Masters are added when they use more, and removed when the remaining masters would still use less.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	TargetMemoryUtilization int32 `json:"targetMemoryUtilization,omitempty"`
+
+	// Cooldown is the least amount of time between scaling the cluster, so slots can be moved to new masters
+	// before the memory is measured again. Defaults to 10m.
+	// +optional
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+}
+
+const (
+	// DefaultTargetMemoryUtilization is the percentage of maxmemory masters use on average when autoscaling
+	DefaultTargetMemoryUtilization = 70
+	// DefaultAutoscalingCooldown is the least amount of time between scaling the cluster
+	DefaultAutoscalingCooldown = 10 * time.Minute
+)
+
+func (autoscaling *Autoscaling) GetTargetMemoryUtilization() int32 {
+	if autoscaling.TargetMemoryUtilization <= 0 {
+		return DefaultTargetMemoryUtilization
+	}
+	return autoscaling.TargetMemoryUtilization
+}
+
+func (autoscaling *Autoscaling) GetCooldown() time.Duration {
+	if autoscaling.Cooldown == nil {
+		return DefaultAutoscalingCooldown
+	}
+	return autoscaling.Cooldown.Duration
+}
+
+// DesiredMasters returns the amount of masters needed for the used memory to match the target utilization,
+// within the minimum and maximum amount of masters.
+// Masters are only removed when the remaining masters stay below the target, so the cluster does not flap between sizes.
+func (autoscaling *Autoscaling) DesiredMasters(masters int32, usedMemory int64, maxMemory int64) int32 {
+	if maxMemory <= 0 {
+		return masters
+	}
+	targetPerMaster := float64(maxMemory) * float64(autoscaling.GetTargetMemoryUtilization()) / 100
+	desired := int32(math.Ceil(float64(usedMemory) / targetPerMaster))
+	if desired < masters {
+		// We remove one master at a time, as every removed master needs all its slots moved to the others
+		desired = masters - 1
+	}
+	if desired < autoscaling.MinMasters {
+		desired = autoscaling.MinMasters
+	}
+	if desired > autoscaling.MaxMasters {
+		desired = autoscaling.MaxMasters
+	}
+	return desired
+}
+
+// RebalanceStrategy specifies what the operator balances across the masters.
+type RebalanceStrategy string
+
+const (
+	// RebalanceStrategySlots balances the amount of slots each master owns.
+	RebalanceStrategySlots RebalanceStrategy = "Slots"
+	// RebalanceStrategyKeys balances the amount of keys in the slots each master owns.
+	RebalanceStrategyKeys RebalanceStrategy = "Keys"
+	// RebalanceStrategyMemory balances the memory used by the keys in the slots each master owns.
+	RebalanceStrategyMemory RebalanceStrategy = "Memory"
+)
+
+// DefaultMemorySampleSize is the amount of keys sampled per slot when balancing on memory
+const DefaultMemorySampleSize = 10
+
+// MaintenanceWindow is a daily window of time.
+type MaintenanceWindow struct {
+	// Start is the time of day the window opens, in UTC, in the format HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// Duration is how long the window stays open, for example 2h30m.
+	Duration metav1.Duration `json:"duration"`
+}
+
+func (policy *RebalancePolicy) IsEnabled() bool {
+	return policy.Enabled == nil || *policy.Enabled
+}
+
+func (policy *RebalancePolicy) GetStrategy() RebalanceStrategy {
+	if policy.Strategy == "" {
+		return RebalanceStrategySlots
+	}
+	return policy.Strategy
+}
+
+func (policy *RebalancePolicy) GetMemorySampleSize() int32 {
+	if policy.MemorySampleSize <= 0 {
+		return DefaultMemorySampleSize
+	}
+	return policy.MemorySampleSize
+}
+
+// Contains returns whether the given time falls in the window.
+// Windows can span midnight, so we check both the window which opened today, and the one which opened yesterday.
+func (window *MaintenanceWindow) Contains(now time.Time) bool {
+	start, err := time.Parse("15:04", window.Start)
+	if err != nil {
+		return false
+	}
+	now = now.UTC()
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, time.UTC)
+	for _, windowStart := range []time.Time{todayStart, todayStart.AddDate(0, 0, -1)} {
+		if !now.Before(windowStart) && now.Before(windowStart.Add(window.Duration.Duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedisClusterStatus defines the observed state of RedisCluster
+type RedisClusterStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// ClusterCheck contains the result of the last consistency check of the Redis cluster.
+	// +optional
+	ClusterCheck *ClusterCheckStatus `json:"clusterCheck,omitempty"`
+
+	// Rebalance contains the last rebalance plan calculated for the cluster.
+	// +optional
+	Rebalance *RebalanceStatus `json:"rebalance,omitempty"`
+
+	// Autoscaling contains the last memory measurement and scaling decision of the autoscaler.
+	// +optional
+	Autoscaling *AutoscalingStatus `json:"autoscaling,omitempty"`
+
+	// Conditions represent the latest available observations of the cluster's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// PausedAnnotation can be set to "true" on a RedisCluster to stop the Operator from making any changes to it.
+	// The Operator still observes the cluster and reports its status while it is paused.
+	PausedAnnotation = "cache.container-solutions.com/paused"
+
+	// ConditionPaused is true while the Operator is not making any changes to the cluster
+	ConditionPaused = "Paused"
+
+	// Finalizer allows the Operator to execute the deletion policy before the RedisCluster is removed
+	Finalizer = "cache.container-solutions.com/finalizer"
+
+	// ConditionDeleting is true while the Operator executes the deletion policy. The reason shows the progress.
+	ConditionDeleting = "Deleting"
+
+	// ConditionAutoscalingAvailable is false while autoscaling is enabled, but the masters have no maxmemory to measure the utilization against
+	ConditionAutoscalingAvailable = "AutoscalingAvailable"
+)
+
+// RebalanceState describes what the operator did with the last rebalance plan.
+type RebalanceState string
+
+const (
+	// RebalanceBalanced means the cluster is balanced within the threshold, and no slots need to move.
+	RebalanceBalanced RebalanceState = "Balanced"
+	// RebalanceMoving means the operator is moving the planned slots.
+	RebalanceMoving RebalanceState = "Moving"
+	// RebalanceDryRun means the plan is only published, as the rebalance policy is in dry run mode.
+	RebalanceDryRun RebalanceState = "DryRun"
+	// RebalanceWaitingForWindow means the plan will be executed when the maintenance window opens.
+	RebalanceWaitingForWindow RebalanceState = "WaitingForMaintenanceWindow"
+	// RebalanceDisabled means rebalancing is disabled in the rebalance policy.
+	RebalanceDisabled RebalanceState = "Disabled"
+)
+
+// AutoscalingStatus describes the last memory measurement and scaling decision of the autoscaler.
+type AutoscalingStatus struct {
+	// MemoryUtilization is the percentage of maxmemory used by the masters on average.
+	MemoryUtilization int32 `json:"memoryUtilization"`
+
+	// DesiredMasters is the amount of masters the autoscaler wants for the measured memory.
+	DesiredMasters int32 `json:"desiredMasters"`
+
+	// LastMeasureTime is the time the memory of the masters was measured.
+	// +optional
+	LastMeasureTime metav1.Time `json:"lastMeasureTime,omitempty"`
+
+	// LastScaleTime is the time the autoscaler last changed the amount of masters.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+}
+
+// RebalanceStatus describes the last rebalance plan.
+type RebalanceStatus struct {
+	// State describes what the operator did with the plan.
+	State RebalanceState `json:"state"`
+
+	// LastPlanTime is the time the plan was calculated. It is only updated when the plan or its state changes.
+	// +optional
+	LastPlanTime metav1.Time `json:"lastPlanTime,omitempty"`
+
+	// SlotsToMove is the total amount of slots in the plan.
+	SlotsToMove int32 `json:"slotsToMove"`
+
+	// Moves lists the planned slot moves between masters.
+	// +optional
+	Moves []RebalanceMove `json:"moves,omitempty"`
+}
+
+// RebalanceMove is a set of slots to move from one master to another.
+type RebalanceMove struct {
+	// Source is the ID of the master the slots are moved from.
+	Source string `json:"source"`
+
+	// Destination is the ID of the master the slots are moved to.
+	Destination string `json:"destination"`
+
+	// Slots are the slot ranges to move, in the same format as CLUSTER NODES, for example 0-100,200.
+	Slots string `json:"slots"`
+
+	// SlotCount is the amount of slots to move.
+	SlotCount int32 `json:"slotCount"`
+}
+
+// ClusterCheckStatus reports whether all Redis nodes share the same view of the cluster,
+// similar to the output of `redis-cli --cluster check`.
+type ClusterCheckStatus struct {
+	// Consistent is true when all nodes agree on slot ownership, config epochs and the nodes in the cluster.
+	// Slots are not rebalanced while the nodes disagree.
+	Consistent bool `json:"consistent"`
+
+	// LastCheckTime is the time the cluster was last checked.
+	// +optional
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// Issues lists all problems found during the last check.
+	// +optional
+	Issues []ClusterCheckIssue `json:"issues,omitempty"`
+}
+
+// ClusterCheckIssue describes a single problem found while checking the cluster.
+type ClusterCheckIssue struct {
+	// Type is the kind of problem, for example SlotOwnershipMismatch or OpenSlot.
+	Type string `json:"type"`
+
+	// NodeID is the ID of the Redis node which reported the problem, if the problem is specific to one node.
+	// +optional
+	NodeID string `json:"nodeID,omitempty"`
+
+	// Message is a human readable description of the problem.
+	Message string `json:"message"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+
+// RedisCluster is the Schema for the redisclusters API
+type RedisCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisClusterSpec   `json:"spec,omitempty"`
+	Status RedisClusterStatus `json:"status,omitempty"`
+}
+
+// IsPaused returns whether the Operator should refrain from changing the cluster
+func (cluster *RedisCluster) IsPaused() bool {
+	return cluster.GetAnnotations()[PausedAnnotation] == "true"
+}
+
+func (cluster *RedisCluster) NodesNeeded() int32 {
+	if cluster.IsReplicationMode() {
+		return 1 + cluster.Spec.ReplicasPerMaster
+	}
+	return cluster.Spec.Masters + (cluster.Spec.Masters * cluster.Spec.ReplicasPerMaster)
+}
+
+// IsReplicationMode returns whether the cluster runs a single master with replicas instead of a Redis Cluster
+func (cluster *RedisCluster) IsReplicationMode() bool {
+	return cluster.Spec.Mode == ModeReplication
+}
+
+// GetSlotWeight returns the weight for a master running in the pod with the given ordinal,
+// on a Kubernetes node with the given labels.
+func (cluster *RedisCluster) GetSlotWeight(ordinal int32, nodeLabels map[string]string) int32 {
+	for _, slotWeight := range cluster.Spec.SlotWeights {
+		if slotWeight.Ordinal != nil && *slotWeight.Ordinal != ordinal {
+			continue
+		}
+		if len(slotWeight.NodeSelector) > 0 && !labels.SelectorFromSet(slotWeight.NodeSelector).Matches(labels.Set(nodeLabels)) {
+			continue
+		}
+		return slotWeight.Weight
+	}
+	return 1
+}
+
+// SlotWeightsSelectNodes returns whether any of the slot weights depend on the labels of Kubernetes nodes
+func (cluster *RedisCluster) SlotWeightsSelectNodes() bool {
+	for _, slotWeight := range cluster.Spec.SlotWeights {
+		if len(slotWeight.NodeSelector) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+
+// RedisClusterList contains a list of RedisCluster
+type RedisClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RedisCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisCluster{}, &RedisClusterList{})
+}
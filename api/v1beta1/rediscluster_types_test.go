@@ -0,0 +1,264 @@
+package v1beta1
+
+import (
+	"bytes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRedisCluster_NodesNeeded(t *testing.T) {
+	testMap := map[string]struct {
+		cluster       RedisCluster
+		expectedNodes int32
+	}{
+		"3M0R": {
+			cluster: RedisCluster{
+				Spec: RedisClusterSpec{
+					Masters:           3,
+					ReplicasPerMaster: 0,
+				},
+			},
+			expectedNodes: 3,
+		},
+		"3M1R": {
+			cluster: RedisCluster{
+				Spec: RedisClusterSpec{
+					Masters:           3,
+					ReplicasPerMaster: 1,
+				},
+			},
+			expectedNodes: 6,
+		},
+		"3M2R": {
+			cluster: RedisCluster{
+				Spec: RedisClusterSpec{
+					Masters:           3,
+					ReplicasPerMaster: 2,
+				},
+			},
+			expectedNodes: 9,
+		},
+		"5M0R": {
+			cluster: RedisCluster{
+				Spec: RedisClusterSpec{
+					Masters:           5,
+					ReplicasPerMaster: 0,
+				},
+			},
+			expectedNodes: 5,
+		},
+		"5M1R": {
+			cluster: RedisCluster{
+				Spec: RedisClusterSpec{
+					Masters:           5,
+					ReplicasPerMaster: 1,
+				},
+			},
+			expectedNodes: 10,
+		},
+		"5M2R": {
+			cluster: RedisCluster{
+				Spec: RedisClusterSpec{
+					Masters:           5,
+					ReplicasPerMaster: 2,
+				},
+			},
+			expectedNodes: 15,
+		},
+		"Replication2R": {
+			cluster: RedisCluster{
+				Spec: RedisClusterSpec{
+					Mode:              ModeReplication,
+					Masters:           3,
+					ReplicasPerMaster: 2,
+				},
+			},
+			expectedNodes: 3,
+		},
+	}
+	for name, testCase := range testMap {
+		got := testCase.cluster.NodesNeeded()
+		if got != testCase.expectedNodes {
+			t.Fatalf("Inccorect amount of nodes received to fullfill cluster. Expected %d, got %d for testcase %s", testCase.expectedNodes, got, name)
+		}
+	}
+}
+
+func TestRedisConfigIsProcessedCorrectly(t *testing.T) {
+	redisClusterYaml := `---
+apiVersion: cache.container-solutions.com/v1beta1
+kind: RedisCluster
+metadata:
+  name: redis-cluster
+spec:
+  masters: 3
+  replicasPerMaster: 0
+  redis:
+    config:
+      maxmemory: 128mb
+      port: "6379"
+`
+	redisCluster := &RedisCluster{}
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader([]byte(redisClusterYaml)), 1000)
+	err := decoder.Decode(&redisCluster)
+	if err != nil {
+		t.Fatalf("Failed to load yaml into RedisCluster type")
+	}
+	expectedConfig := map[string]string{
+		"maxmemory": "128mb",
+		"port":      "6379",
+	}
+	if !reflect.DeepEqual(redisCluster.Spec.Redis.Config, expectedConfig) {
+		t.Fatalf("RedisCluster is not processing config correctly. Expected %v, got %v", expectedConfig, redisCluster.Spec.Redis.Config)
+	}
+}
+
+func TestRedisCluster_GetSlotWeight(t *testing.T) {
+	largeOrdinal := int32(2)
+	cluster := RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+			SlotWeights: []SlotWeight{
+				{
+					Ordinal: &largeOrdinal,
+					Weight:  4,
+				},
+				{
+					NodeSelector: map[string]string{
+						"node.kubernetes.io/instance-type": "m5.2xlarge",
+					},
+					Weight: 2,
+				},
+			},
+		},
+	}
+	testMap := map[string]struct {
+		ordinal        int32
+		nodeLabels     map[string]string
+		expectedWeight int32
+	}{
+		"matched by ordinal": {
+			ordinal:        2,
+			expectedWeight: 4,
+		},
+		"first match wins": {
+			ordinal: 2,
+			nodeLabels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.2xlarge",
+			},
+			expectedWeight: 4,
+		},
+		"matched by node selector": {
+			ordinal: 0,
+			nodeLabels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.2xlarge",
+				"kubernetes.io/os":                 "linux",
+			},
+			expectedWeight: 2,
+		},
+		"not matched": {
+			ordinal: 1,
+			nodeLabels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.large",
+			},
+			expectedWeight: 1,
+		},
+	}
+	for name, testCase := range testMap {
+		got := cluster.GetSlotWeight(testCase.ordinal, testCase.nodeLabels)
+		if got != testCase.expectedWeight {
+			t.Fatalf("Incorrect slot weight for testcase %s. Expected %d, got %d", name, testCase.expectedWeight, got)
+		}
+	}
+}
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	window := MaintenanceWindow{
+		Start:    "23:00",
+		Duration: metav1.Duration{Duration: 2 * time.Hour},
+	}
+	testMap := map[string]struct {
+		now      time.Time
+		expected bool
+	}{
+		"BeforeWindow": {
+			now:      time.Date(2022, 6, 1, 22, 59, 0, 0, time.UTC),
+			expected: false,
+		},
+		"StartOfWindow": {
+			now:      time.Date(2022, 6, 1, 23, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		"WindowOpenedYesterday": {
+			now:      time.Date(2022, 6, 2, 0, 30, 0, 0, time.UTC),
+			expected: true,
+		},
+		"AfterWindow": {
+			now:      time.Date(2022, 6, 2, 1, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+	}
+	for name, test := range testMap {
+		t.Run(name, func(t *testing.T) {
+			if window.Contains(test.now) != test.expected {
+				t.Fatalf("Expected window to contain %s to be %v", test.now, test.expected)
+			}
+		})
+	}
+}
+
+func TestAutoscaling_DesiredMasters(t *testing.T) {
+	autoscaling := Autoscaling{
+		MinMasters:              3,
+		MaxMasters:              6,
+		TargetMemoryUtilization: 50,
+	}
+	gb := int64(1024 * 1024 * 1024)
+	testMap := map[string]struct {
+		masters  int32
+		used     int64
+		expected int32
+	}{
+		"AtTarget": {
+			masters:  4,
+			used:     2 * gb,
+			expected: 4,
+		},
+		"AboveTarget": {
+			masters:  4,
+			used:     3 * gb,
+			expected: 6,
+		},
+		"AboveMaximum": {
+			masters:  4,
+			used:     10 * gb,
+			expected: 6,
+		},
+		"BelowTargetRemovesOneMaster": {
+			masters:  6,
+			used:     gb,
+			expected: 5,
+		},
+		"RemainingMastersWouldExceedTarget": {
+			masters:  4,
+			used:     gb + gb/2 + 1,
+			expected: 4,
+		},
+		"BelowMinimum": {
+			masters:  3,
+			used:     0,
+			expected: 3,
+		},
+	}
+	for name, test := range testMap {
+		t.Run(name, func(t *testing.T) {
+			desired := autoscaling.DesiredMasters(test.masters, test.used, gb)
+			if desired != test.expected {
+				t.Fatalf("Expected %d masters. Got %d", test.expected, desired)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultRedisImage is the image the Redis nodes run when the cluster does not specify one
+	DefaultRedisImage = "redis:7.0.0"
+
+	// RedisPort is the port Redis serves clients on. The cluster bus uses this port + 10000.
+	RedisPort = 6379
+
+	// DefaultExporterImage is the image of the exporter sidecar when monitoring does not specify one
+	DefaultExporterImage = "oliver006/redis_exporter:v1.43.0"
+
+	// DefaultClusterNodeTimeout is the time in milliseconds a node can be unreachable before it is considered failing
+	DefaultClusterNodeTimeout = "5000"
+
+	// MinimumClusterRedisVersion is the oldest major version of Redis which can announce the hostnames of the nodes in Cluster mode
+	MinimumClusterRedisVersion = 7
+)
+
+// DefaultRedisConfig returns the redis.conf settings every node runs with in the given mode, unless the cluster overrides them.
+func DefaultRedisConfig(mode Mode) map[string]string {
+	if mode == ModeReplication {
+		return map[string]string{
+			"port": fmt.Sprint(RedisPort),
+		}
+	}
+	return map[string]string{
+		"port":                 fmt.Sprint(RedisPort),
+		"cluster-enabled":      "yes",
+		"cluster-config-file":  "nodes.conf",
+		"cluster-node-timeout": DefaultClusterNodeTimeout,
+	}
+}
+
+// SetupWebhookWithManager registers the webhooks of RedisCluster, including the conversion webhook serving all versions.
+func (r *RedisCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-cache-container-solutions-com-v1beta1-rediscluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=cache.container-solutions.com,resources=redisclusters,verbs=create;update,versions=v1beta1,name=mrediscluster.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &RedisCluster{}
+
+// Default fills in the defaults of the Operator, so the RedisCluster shows the settings the Redis nodes run with.
+// The defaults are stored in the spec, so clusters keep them when a later version of the Operator changes them.
+// The Operator applies the same defaults itself when the webhook is not running.
+func (r *RedisCluster) Default() {
+	if r.Spec.Redis.Image == "" {
+		r.Spec.Redis.Image = DefaultRedisImage
+	}
+
+	if r.Spec.Mode == "" {
+		r.Spec.Mode = ModeCluster
+	}
+
+	config := DefaultRedisConfig(r.Spec.Mode)
+	for setting, value := range r.Spec.Redis.Config {
+		config[setting] = value
+	}
+	r.Spec.Redis.Config = config
+
+	// Kubernetes requests the limit of a resource when the container does not request it, so we show that request
+	for resourceName, limit := range r.Spec.Resources.Limits {
+		if _, ok := r.Spec.Resources.Requests[resourceName]; ok {
+			continue
+		}
+		if r.Spec.Resources.Requests == nil {
+			r.Spec.Resources.Requests = v1.ResourceList{}
+		}
+		r.Spec.Resources.Requests[resourceName] = limit.DeepCopy()
+	}
+
+	if r.Spec.Rebalance.Strategy == "" {
+		r.Spec.Rebalance.Strategy = RebalanceStrategySlots
+	}
+
+	if r.Spec.Autoscaling != nil {
+		r.Spec.Autoscaling.TargetMemoryUtilization = r.Spec.Autoscaling.GetTargetMemoryUtilization()
+		if r.Spec.Autoscaling.Cooldown == nil {
+			r.Spec.Autoscaling.Cooldown = &metav1.Duration{Duration: DefaultAutoscalingCooldown}
+		}
+	}
+
+	if r.Spec.Monitoring != nil && r.Spec.Monitoring.Image == "" {
+		r.Spec.Monitoring.Image = DefaultExporterImage
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-cache-container-solutions-com-v1beta1-rediscluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=cache.container-solutions.com,resources=redisclusters,verbs=create;update,versions=v1beta1,name=vrediscluster.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &RedisCluster{}
+
+// ValidateCreate rejects combinations of settings which the schema of the CRD cannot catch
+func (r *RedisCluster) ValidateCreate() error {
+	return r.invalid(r.validateSpec())
+}
+
+// ValidateUpdate rejects changes to the mode of the cluster, and the settings ValidateCreate rejects.
+// The nodes of a Redis Cluster cannot replicate a single master, and the data of a Redis Cluster cannot be moved onto one.
+func (r *RedisCluster) ValidateUpdate(old runtime.Object) error {
+	oldCluster, ok := old.(*RedisCluster)
+	if !ok {
+		return fmt.Errorf("expected a RedisCluster, got %T", old)
+	}
+	var errs field.ErrorList
+	// Clusters created before the mode existed run in Cluster mode
+	if oldCluster.IsReplicationMode() != r.IsReplicationMode() {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "mode"), "the mode of a cluster cannot be changed once it is created"))
+	}
+
+	// Clusters created before a check existed must stay updatable, for example so the Operator can remove its finalizer.
+	// We only reject the problems the update introduces.
+	existing := map[string]bool{}
+	for _, err := range oldCluster.validateSpec() {
+		existing[err.Error()] = true
+	}
+	for _, err := range r.validateSpec() {
+		if !existing[err.Error()] {
+			errs = append(errs, err)
+		}
+	}
+	return r.invalid(errs)
+}
+
+// validateSpec returns the combinations of settings the Operator cannot run
+func (r *RedisCluster) validateSpec() field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	// The pods, Services and probes all use the same port, so Redis has to listen on it
+	if port, ok := r.Spec.Redis.Config["port"]; ok && port != fmt.Sprint(RedisPort) {
+		errs = append(errs, field.Invalid(spec.Child("redis", "config").Key("port"), port, fmt.Sprintf("Redis always listens on port %d", RedisPort)))
+	}
+
+	// The nodes announce their hostnames to the cluster, which older versions of Redis fail to start with
+	if major, ok := redisMajorVersion(r.Spec.Redis.Image); ok && major < MinimumClusterRedisVersion && !r.IsReplicationMode() {
+		errs = append(errs, field.Invalid(spec.Child("redis", "image"), r.Spec.Redis.Image, fmt.Sprintf("Cluster mode needs Redis %d or later", MinimumClusterRedisVersion)))
+	}
+
+	// Replicas resync from a master which restarted, and would throw away their data if it came back empty
+	if r.IsReplicationMode() && r.Spec.Storage == nil {
+		errs = append(errs, field.Required(spec.Child("storage"), "Replication mode needs storage, or the replicas lose their data when the master restarts"))
+	}
+
+	if r.Spec.DeletionPolicy == DeletionPolicySnapshotThenDelete && r.Spec.Storage == nil {
+		errs = append(errs, field.Invalid(spec.Child("deletionPolicy"), r.Spec.DeletionPolicy, "snapshots are lost with the pods when the cluster has no storage"))
+	}
+	return errs
+}
+
+// redisMajorVersion reads the major version of Redis from the tag of the image, like 6 from redis:6.2.7-alpine.
+// Images without a version in their tag, like redis:latest or custom builds, are not recognised.
+func redisMajorVersion(image string) (int, bool) {
+	image = strings.SplitN(image, "@", 2)[0]
+	colon := strings.LastIndex(image, ":")
+	if colon < strings.LastIndex(image, "/") || colon < 0 {
+		return 0, false
+	}
+	tag := strings.TrimPrefix(image[colon+1:], "v")
+	end := strings.IndexFunc(tag, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(tag)
+	}
+	if end == 0 || (end < len(tag) && tag[end] != '.' && tag[end] != '-') {
+		return 0, false
+	}
+	major, err := strconv.Atoi(tag[:end])
+	return major, err == nil
+}
+
+func (r *RedisCluster) invalid(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("RedisCluster").GroupKind(), r.Name, errs)
+}
+
+// ValidateDelete accepts every deletion, as the deletion policy decides what happens to the data
+func (r *RedisCluster) ValidateDelete() error {
+	return nil
+}
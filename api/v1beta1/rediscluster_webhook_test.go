@@ -0,0 +1,326 @@
+package v1beta1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"reflect"
+	"testing"
+)
+
+func TestRedisCluster_DefaultFillsInOperatorDefaults(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+		},
+	}
+	cluster.Default()
+
+	if cluster.Spec.Redis.Image != DefaultRedisImage {
+		t.Fatalf("Expected default image %s. Got %s", DefaultRedisImage, cluster.Spec.Redis.Image)
+	}
+	expectedConfig := map[string]string{
+		"port":                 "6379",
+		"cluster-enabled":      "yes",
+		"cluster-config-file":  "nodes.conf",
+		"cluster-node-timeout": "5000",
+	}
+	if !reflect.DeepEqual(cluster.Spec.Redis.Config, expectedConfig) {
+		t.Fatalf("Expected default config %v. Got %v", expectedConfig, cluster.Spec.Redis.Config)
+	}
+	if cluster.Spec.Rebalance.Strategy != RebalanceStrategySlots {
+		t.Fatalf("Expected default rebalance strategy %s. Got %s", RebalanceStrategySlots, cluster.Spec.Rebalance.Strategy)
+	}
+}
+
+func TestRedisCluster_DefaultKeepsSettingsOfTheCluster(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+			Redis: RedisSettings{
+				Image: "redis:7.0.5",
+				Config: map[string]string{
+					"cluster-node-timeout": "15000",
+					"maxmemory":            "200mb",
+				},
+			},
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("1"),
+					v1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+				Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("500m"),
+				},
+			},
+		},
+	}
+	cluster.Default()
+
+	if cluster.Spec.Redis.Image != "redis:7.0.5" {
+		t.Fatalf("Expected image of the cluster to be kept. Got %s", cluster.Spec.Redis.Image)
+	}
+	if cluster.Spec.Redis.Config["cluster-node-timeout"] != "15000" || cluster.Spec.Redis.Config["maxmemory"] != "200mb" {
+		t.Fatalf("Expected config of the cluster to be kept. Got %v", cluster.Spec.Redis.Config)
+	}
+	if cluster.Spec.Redis.Config["cluster-enabled"] != "yes" {
+		t.Fatalf("Expected missing defaults to be added. Got %v", cluster.Spec.Redis.Config)
+	}
+	if !cluster.Spec.Resources.Requests.Cpu().Equal(resource.MustParse("500m")) {
+		t.Fatalf("Expected CPU request to be kept. Got %s", cluster.Spec.Resources.Requests.Cpu())
+	}
+	if !cluster.Spec.Resources.Requests.Memory().Equal(resource.MustParse("1Gi")) {
+		t.Fatalf("Expected memory request to default to the limit. Got %s", cluster.Spec.Resources.Requests.Memory())
+	}
+}
+
+func TestRedisCluster_DefaultIsIdempotent(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+		},
+	}
+	cluster.Default()
+	defaulted := cluster.DeepCopy()
+	cluster.Default()
+	if !reflect.DeepEqual(cluster, defaulted) {
+		t.Fatalf("Expected defaulting twice to make no further changes")
+	}
+}
+
+func TestRedisCluster_DefaultLeavesClusterSettingsOutInReplicationMode(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Mode:              ModeReplication,
+			ReplicasPerMaster: 2,
+		},
+	}
+	cluster.Default()
+
+	expectedConfig := map[string]string{
+		"port": "6379",
+	}
+	if !reflect.DeepEqual(cluster.Spec.Redis.Config, expectedConfig) {
+		t.Fatalf("Expected default config %v. Got %v", expectedConfig, cluster.Spec.Redis.Config)
+	}
+}
+
+func TestRedisCluster_DefaultFillsInExporterImage(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters:    3,
+			Monitoring: &Monitoring{},
+		},
+	}
+	cluster.Default()
+
+	if cluster.Spec.Monitoring.Image != DefaultExporterImage {
+		t.Fatalf("Expected default exporter image %s. Got %s", DefaultExporterImage, cluster.Spec.Monitoring.Image)
+	}
+}
+
+func TestRedisCluster_ValidateUpdateRejectsChangingTheMode(t *testing.T) {
+	old := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Mode:    ModeCluster,
+			Masters: 3,
+		},
+	}
+	// The defaulting webhook filled in the config of Cluster mode, which the nodes cannot replicate with
+	old.Default()
+	cluster := old.DeepCopy()
+	cluster.Spec.Mode = ModeReplication
+
+	err := cluster.ValidateUpdate(old)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected changing the mode from Cluster to Replication to be rejected. Got %v", err)
+	}
+	err = old.DeepCopy().ValidateUpdate(cluster)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected changing the mode from Replication to Cluster to be rejected. Got %v", err)
+	}
+}
+
+func TestRedisCluster_ValidateUpdateAcceptsOtherChanges(t *testing.T) {
+	// Clusters created before the mode existed have no mode, and run in Cluster mode
+	old := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+		},
+	}
+	cluster := old.DeepCopy()
+	cluster.Spec.Mode = ModeCluster
+	cluster.Spec.Masters = 5
+
+	err := cluster.ValidateUpdate(old)
+	if err != nil {
+		t.Fatalf("Expected changes which keep the mode to be accepted. Got %v", err)
+	}
+}
+
+func TestRedisCluster_ValidateCreateRejectsSnapshotsWithoutStorage(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters:        3,
+			DeletionPolicy: DeletionPolicySnapshotThenDelete,
+		},
+	}
+	err := cluster.ValidateCreate()
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected SnapshotThenDelete without storage to be rejected. Got %v", err)
+	}
+
+	cluster.Spec.Storage = &Storage{
+		Size: resource.MustParse("1Gi"),
+	}
+	err = cluster.ValidateCreate()
+	if err != nil {
+		t.Fatalf("Expected SnapshotThenDelete with storage to be accepted. Got %v", err)
+	}
+}
+
+func TestRedisCluster_ValidateUpdateOnlyRejectsNewProblems(t *testing.T) {
+	// Created before the webhook validated the deletion policy
+	old := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters:        3,
+			DeletionPolicy: DeletionPolicySnapshotThenDelete,
+		},
+	}
+	cluster := old.DeepCopy()
+	cluster.Finalizers = nil
+
+	err := cluster.ValidateUpdate(old)
+	if err != nil {
+		t.Fatalf("Expected existing clusters to stay updatable. Got %v", err)
+	}
+
+	old.Spec.DeletionPolicy = DeletionPolicyRetain
+	err = cluster.ValidateUpdate(old)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected changing to SnapshotThenDelete without storage to be rejected. Got %v", err)
+	}
+}
+
+func TestRedisCluster_ValidateCreateRejectsPortOverrides(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+			Redis: RedisSettings{
+				Config: map[string]string{
+					"port": "6380",
+				},
+			},
+		},
+	}
+	err := cluster.ValidateCreate()
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected a port Redis does not listen on to be rejected. Got %v", err)
+	}
+
+	// The defaulting webhook fills in the port Redis listens on
+	cluster.Spec.Redis.Config = nil
+	cluster.Default()
+	err = cluster.ValidateCreate()
+	if err != nil {
+		t.Fatalf("Expected the default port to be accepted. Got %v", err)
+	}
+}
+
+func TestRedisCluster_ValidateCreateRejectsRedis6InClusterMode(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Masters: 3,
+			Redis: RedisSettings{
+				Image: "redis:6.2.7-alpine",
+			},
+		},
+	}
+	err := cluster.ValidateCreate()
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected Redis 6 to be rejected in Cluster mode. Got %v", err)
+	}
+
+	// Replication mode does not announce hostnames
+	cluster.Spec.Mode = ModeReplication
+	cluster.Spec.Storage = &Storage{
+		Size: resource.MustParse("1Gi"),
+	}
+	err = cluster.ValidateCreate()
+	if err != nil {
+		t.Fatalf("Expected Redis 6 to be accepted in Replication mode. Got %v", err)
+	}
+
+	cluster.Spec.Mode = ModeCluster
+	for _, image := range []string{"redis:7.0.5", "redis:latest", "registry.local:5000/redis", "redis@sha256:0123456789abcdef"} {
+		cluster.Spec.Redis.Image = image
+		err = cluster.ValidateCreate()
+		if err != nil {
+			t.Fatalf("Expected image %s to be accepted. Got %v", image, err)
+		}
+	}
+}
+
+func TestRedisMajorVersion(t *testing.T) {
+	tests := map[string]int{
+		"redis:6":                      6,
+		"redis:6.2.7":                  6,
+		"redis:7.0.0-bullseye":         7,
+		"bitnami/redis:6.2-debian-11":  6,
+		"registry.local:5000/redis:v7": 7,
+	}
+	for image, expected := range tests {
+		major, ok := redisMajorVersion(image)
+		if !ok || major != expected {
+			t.Fatalf("Expected major version %d of %s. Got %d, %v", expected, image, major, ok)
+		}
+	}
+	for _, image := range []string{"redis", "redis:latest", "registry.local:5000/redis", "redis:alpine", "redis:6x"} {
+		if _, ok := redisMajorVersion(image); ok {
+			t.Fatalf("Expected no version to be read from %s", image)
+		}
+	}
+}
+
+func TestRedisCluster_ValidateCreateRequiresStorageInReplicationMode(t *testing.T) {
+	cluster := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Mode:              ModeReplication,
+			ReplicasPerMaster: 2,
+		},
+	}
+	err := cluster.ValidateCreate()
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected Replication mode without storage to be rejected. Got %v", err)
+	}
+
+	cluster.Spec.Storage = &Storage{
+		Size: resource.MustParse("1Gi"),
+	}
+	err = cluster.ValidateCreate()
+	if err != nil {
+		t.Fatalf("Expected Replication mode with storage to be accepted. Got %v", err)
+	}
+}
+
+func TestRedisCluster_ValidateUpdateKeepsReplicationWithoutStorageUpdatable(t *testing.T) {
+	// Created before the webhook required storage in Replication mode
+	old := &RedisCluster{
+		Spec: RedisClusterSpec{
+			Mode:              ModeReplication,
+			ReplicasPerMaster: 2,
+		},
+	}
+	cluster := old.DeepCopy()
+	cluster.Spec.ReplicasPerMaster = 3
+
+	err := cluster.ValidateUpdate(old)
+	if err != nil {
+		t.Fatalf("Expected existing clusters without storage to stay updatable. Got %v", err)
+	}
+}
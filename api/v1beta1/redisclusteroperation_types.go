@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RedisClusterOperationSpec defines a one-off action on a RedisCluster
+type RedisClusterOperationSpec struct {
+	// Cluster is the name of the RedisCluster to operate on. The cluster needs to be in the same namespace as the operation.
+	// +kubebuilder:validation:Required
+	Cluster string `json:"cluster"`
+
+	// Type is the action to execute. The parameters of the action are set in the field of the same name.
+	// +kubebuilder:validation:Enum=Failover;MoveSlots;ForgetNode;Rebalance
+	// +kubebuilder:validation:Required
+	Type OperationType `json:"type"`
+
+	// Failover promotes a replica to master.
+	// +optional
+	Failover *FailoverOperation `json:"failover,omitempty"`
+
+	// MoveSlots moves slots to a master, together with the keys in them.
+	// +optional
+	MoveSlots *MoveSlotsOperation `json:"moveSlots,omitempty"`
+
+	// ForgetNode removes a node which no longer runs in any pod from the cluster.
+	// +optional
+	ForgetNode *ForgetNodeOperation `json:"forgetNode,omitempty"`
+}
+
+type OperationType string
+
+const (
+	OperationFailover   OperationType = "Failover"
+	OperationMoveSlots  OperationType = "MoveSlots"
+	OperationForgetNode OperationType = "ForgetNode"
+	// OperationRebalance balances the slots straight away, even if the rebalance policy is in dry run mode,
+	// or the maintenance window is closed. It takes no parameters.
+	OperationRebalance OperationType = "Rebalance"
+)
+
+type FailoverOperation struct {
+	// Pod is the name of the pod running the replica to promote.
+	// +kubebuilder:validation:Required
+	Pod string `json:"pod"`
+}
+
+type MoveSlotsOperation struct {
+	// Slots are the slot ranges to move, in the same format as CLUSTER NODES, for example 0-100,200.
+	// +kubebuilder:validation:Required
+	Slots string `json:"slots"`
+
+	// Destination is the name of the pod running the master the slots are moved to.
+	// +kubebuilder:validation:Required
+	Destination string `json:"destination"`
+}
+
+type ForgetNodeOperation struct {
+	// NodeID is the ID of the Redis node to forget.
+	// +kubebuilder:validation:Required
+	NodeID string `json:"nodeID"`
+}
+
+// OperationPhase describes where an operation is in its lifecycle
+type OperationPhase string
+
+const (
+	// OperationPending means the operation waits for the cluster to be ready for it.
+	OperationPending OperationPhase = "Pending"
+	// OperationRunning means the Operator is executing the operation.
+	OperationRunning OperationPhase = "Running"
+	// OperationSucceeded means the operation has been executed.
+	OperationSucceeded OperationPhase = "Succeeded"
+	// OperationFailed means the operation could not be executed. The error is recorded in the message.
+	OperationFailed OperationPhase = "Failed"
+)
+
+// RedisClusterOperationStatus records the execution of the operation
+type RedisClusterOperationStatus struct {
+	// Phase is where the operation is in its lifecycle. Operations are executed once, and are never retried once they failed.
+	// +optional
+	Phase OperationPhase `json:"phase,omitempty"`
+
+	// StartTime is the time the Operator started executing the operation.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the operation succeeded or failed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message describes the outcome of the operation, or what it is waiting for while pending.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Error is the error the operation failed with.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.cluster`
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RedisClusterOperation is the Schema for the redisclusteroperations API.
+// It executes a one-off action on a RedisCluster through the Operator, and keeps a record of it.
+type RedisClusterOperation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisClusterOperationSpec   `json:"spec,omitempty"`
+	Status RedisClusterOperationStatus `json:"status,omitempty"`
+}
+
+// IsFinished returns whether the operation has been executed, successfully or not
+func (operation *RedisClusterOperation) IsFinished() bool {
+	return operation.Status.Phase == OperationSucceeded || operation.Status.Phase == OperationFailed
+}
+
+// ValidateParameters returns an error if the parameters needed by the type of the operation are not set
+func (operation *RedisClusterOperation) ValidateParameters() error {
+	spec := operation.Spec
+	switch spec.Type {
+	case OperationFailover:
+		if spec.Failover == nil || spec.Failover.Pod == "" {
+			return fmt.Errorf("%s operations need failover.pod", spec.Type)
+		}
+	case OperationMoveSlots:
+		if spec.MoveSlots == nil || spec.MoveSlots.Slots == "" || spec.MoveSlots.Destination == "" {
+			return fmt.Errorf("%s operations need moveSlots.slots and moveSlots.destination", spec.Type)
+		}
+	case OperationForgetNode:
+		if spec.ForgetNode == nil || spec.ForgetNode.NodeID == "" {
+			return fmt.Errorf("%s operations need forgetNode.nodeID", spec.Type)
+		}
+	case OperationRebalance:
+	default:
+		return fmt.Errorf("unknown operation type %q", spec.Type)
+	}
+	return nil
+}
+
+//+kubebuilder:object:root=true
+
+// RedisClusterOperationList contains a list of RedisClusterOperation
+type RedisClusterOperationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RedisClusterOperation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisClusterOperation{}, &RedisClusterOperationList{})
+}
@@ -0,0 +1,77 @@
+package v1beta1
+
+import (
+	"testing"
+)
+
+func TestRedisClusterOperation_ValidateParameters(t *testing.T) {
+	testMap := map[string]struct {
+		spec  RedisClusterOperationSpec
+		valid bool
+	}{
+		"Failover": {
+			spec: RedisClusterOperationSpec{
+				Type:     OperationFailover,
+				Failover: &FailoverOperation{Pod: "rediscluster-3"},
+			},
+			valid: true,
+		},
+		"FailoverWithoutPod": {
+			spec: RedisClusterOperationSpec{
+				Type: OperationFailover,
+			},
+			valid: false,
+		},
+		"MoveSlots": {
+			spec: RedisClusterOperationSpec{
+				Type: OperationMoveSlots,
+				MoveSlots: &MoveSlotsOperation{
+					Slots:       "0-100",
+					Destination: "rediscluster-1",
+				},
+			},
+			valid: true,
+		},
+		"MoveSlotsWithoutDestination": {
+			spec: RedisClusterOperationSpec{
+				Type: OperationMoveSlots,
+				MoveSlots: &MoveSlotsOperation{
+					Slots: "0-100",
+				},
+			},
+			valid: false,
+		},
+		"ForgetNodeWithParametersOfOtherType": {
+			spec: RedisClusterOperationSpec{
+				Type:     OperationForgetNode,
+				Failover: &FailoverOperation{Pod: "rediscluster-3"},
+			},
+			valid: false,
+		},
+		"Rebalance": {
+			spec: RedisClusterOperationSpec{
+				Type: OperationRebalance,
+			},
+			valid: true,
+		},
+		"UnknownType": {
+			spec: RedisClusterOperationSpec{
+				Type: "Restart",
+			},
+			valid: false,
+		},
+	}
+
+	for name, test := range testMap {
+		t.Run(name, func(t *testing.T) {
+			operation := &RedisClusterOperation{Spec: test.spec}
+			err := operation.ValidateParameters()
+			if test.valid && err != nil {
+				t.Fatalf("Expected operation to be valid, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatalf("Expected operation to be invalid")
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// connectToCluster parses the cluster name from the arguments, and connects to its Redis nodes
+func connectToCluster(ctx context.Context, options *globalOptions, command string, args []string) (*connection, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s needs the name of the cluster", command)
+	}
+	s, err := newSession(options)
+	if err != nil {
+		return nil, err
+	}
+	return s.connect(ctx, args[0])
+}
+
+func runStatus(ctx context.Context, options *globalOptions, args []string) error {
+	conn, err := connectToCluster(ctx, options, "status", args)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if conn.cluster.IsReplicationMode() {
+		replicationNodes, err := conn.replicationNodes(ctx)
+		if err != nil {
+			return err
+		}
+		printReplicationStatus(os.Stdout, replicationNodes, conn.notReady)
+		return nil
+	}
+	clusterNodes, err := conn.clusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	printStatus(os.Stdout, clusterNodes, conn.notReady)
+	return nil
+}
+
+func runCheck(ctx context.Context, options *globalOptions, args []string) error {
+	conn, err := connectToCluster(ctx, options, "check", args)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	clusterNodes, err := conn.clusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := clusterNodes.CheckCluster(ctx)
+	if err != nil {
+		return err
+	}
+	printCheck(os.Stdout, clusterNodes, result)
+	if !result.Healthy() {
+		return fmt.Errorf("found %d issues", len(result.Issues))
+	}
+	return nil
+}
+
+func runRebalance(ctx context.Context, options *globalOptions, args []string) error {
+	flags := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Only show the slots which would be moved")
+	// The flag package stops at the first argument, so we allow the flag on either side of the cluster name
+	clusterArgs := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			_ = flags.Parse([]string{arg})
+			continue
+		}
+		clusterArgs = append(clusterArgs, arg)
+	}
+	conn, err := connectToCluster(ctx, options, "rebalance", clusterArgs)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	clusterNodes, err := conn.clusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	if conn.cluster.SlotWeightsSelectNodes() {
+		for _, node := range clusterNodes.Nodes {
+			node.HostLabels, err = kubernetes.FetchPodHostLabels(ctx, conn.kubeClient, node.PodDetails)
+			if err != nil {
+				return fmt.Errorf("slot weights select Kubernetes nodes by label, but the labels could not be read: %w", err)
+			}
+		}
+	}
+	plan, err := clusterNodes.PlanRebalance(ctx, conn.cluster)
+	if err != nil {
+		return err
+	}
+	printRebalancePlan(os.Stdout, plan)
+	if *dryRun || len(plan.Moves) == 0 {
+		return nil
+	}
+	err = clusterNodes.ApplyRebalancePlan(ctx, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d slots\n", plan.SlotCount())
+	return nil
+}
+
+func runFailover(ctx context.Context, options *globalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.New("failover needs the name of the replica pod")
+	}
+	s, err := newSession(options)
+	if err != nil {
+		return err
+	}
+	pod := &v1.Pod{}
+	err = s.client.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: args[0]}, pod)
+	if err != nil {
+		return err
+	}
+	clusterName, ok := pod.Labels[kubernetes.RedisNodeNameStatefulsetLabel]
+	if !ok {
+		return fmt.Errorf("pod %s is not part of a RedisCluster", pod.Name)
+	}
+	conn, err := s.connect(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	clusterNodes, err := conn.clusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, node := range clusterNodes.Nodes {
+		if node.PodDetails.Name != pod.Name {
+			continue
+		}
+		if node.IsMaster() {
+			return fmt.Errorf("pod %s already runs a master. Fail over to one of its replicas instead", pod.Name)
+		}
+		err = node.ClusterFailover(ctx).Err()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Requested failover. %s takes over from its master once it has caught up\n", pod.Name)
+		return nil
+	}
+	return fmt.Errorf("pod %s is not ready", pod.Name)
+}
+
+func runForgetFailed(ctx context.Context, options *globalOptions, args []string) error {
+	conn, err := connectToCluster(ctx, options, "forget-failed", args)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	clusterNodes, err := conn.clusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	failingNodes, err := clusterNodes.GetFailingNodes(ctx)
+	if err != nil {
+		return err
+	}
+	if len(failingNodes) == 0 {
+		fmt.Println("No failed nodes")
+		return nil
+	}
+	for _, failingNode := range failingNodes {
+		err = clusterNodes.ForgetNode(ctx, failingNode)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Forgot node %s\n", failingNode.NodeAttributes.ID)
+	}
+	return nil
+}
+
+// podNames maps the IDs of the Redis nodes to the pods they run in
+func podNames(clusterNodes *redis_internal.ClusterNodes) map[string]string {
+	result := map[string]string{}
+	for _, node := range clusterNodes.Nodes {
+		result[node.NodeAttributes.ID] = node.PodDetails.Name
+	}
+	return result
+}
+
+func printStatus(w io.Writer, clusterNodes *redis_internal.ClusterNodes, notReady []string) {
+	names := podNames(clusterNodes)
+	nodes := make([]*redis_internal.Node, len(clusterNodes.Nodes))
+	copy(nodes, clusterNodes.Nodes)
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].PodDetails.Name < nodes[j].PodDetails.Name
+	})
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "POD\tNODE ID\tROLE\tMASTER\tSLOTS")
+	for _, node := range nodes {
+		role := "replica"
+		master := names[node.NodeAttributes.GetMasterID()]
+		if node.IsMaster() {
+			role = "master"
+			master = "-"
+		}
+		if master == "" {
+			master = node.NodeAttributes.GetMasterID()
+		}
+		slots := strings.Join(redis_internal.FormatSlotRanges(node.NodeAttributes.GetSlots()), ",")
+		if slots == "" {
+			slots = "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", node.PodDetails.Name, node.NodeAttributes.ID, role, master, slots)
+	}
+	for _, podName := range notReady {
+		fmt.Fprintf(table, "%s\t-\tnot ready\t-\t-\n", podName)
+	}
+	_ = table.Flush()
+}
+
+func printReplicationStatus(w io.Writer, replicationNodes *redis_internal.ReplicationNodes, notReady []string) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "POD\tROLE\tMASTER\tOFFSET")
+	for _, node := range replicationNodes.Nodes {
+		role := "replica"
+		master := node.Replication.MasterHost
+		if node.IsMaster() {
+			role = "master"
+			master = "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\n", node.PodDetails.Name, role, master, node.Replication.Offset)
+	}
+	for _, podName := range notReady {
+		fmt.Fprintf(table, "%s\tnot ready\t-\t-\n", podName)
+	}
+	_ = table.Flush()
+}
+
+func printCheck(w io.Writer, clusterNodes *redis_internal.ClusterNodes, result *redis_internal.ClusterCheckResult) {
+	if result.Healthy() {
+		fmt.Fprintln(w, "All nodes agree on the cluster, and all slots are covered")
+		return
+	}
+	names := podNames(clusterNodes)
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ISSUE\tNODE\tMESSAGE")
+	for _, issue := range result.Issues {
+		node := "-"
+		if issue.NodeID != "" {
+			node = issue.NodeID
+			if name, ok := names[issue.NodeID]; ok {
+				node = name
+			}
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", issue.Type, node, issue.Message)
+	}
+	_ = table.Flush()
+}
+
+func printRebalancePlan(w io.Writer, plan *redis_internal.RebalancePlan) {
+	if len(plan.Moves) == 0 {
+		fmt.Fprintf(w, "The cluster is balanced. The largest deviation from a quota is %.1f%%\n", plan.MaxDeviationPercentage)
+		return
+	}
+	fmt.Fprintf(w, "The largest deviation from a quota is %.1f%%. Moving %d slots:\n", plan.MaxDeviationPercentage, plan.SlotCount())
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "FROM\tTO\tSLOTS")
+	for _, move := range plan.Moves {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", move.Source.PodDetails.Name, move.Destination.PodDetails.Name, strings.Join(redis_internal.FormatSlotRanges(move.Slots), ","))
+	}
+	_ = table.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func newPodNode(podName string, nodeString string) *redis_internal.Node {
+	return &redis_internal.Node{
+		NodeAttributes: redis_internal.NewNodeAttributes(nodeString),
+		PodDetails: &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: podName,
+			},
+		},
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	clusterNodes := &redis_internal.ClusterNodes{
+		Nodes: []*redis_internal.Node{
+			newPodNode("redis-cluster-1", "bbb 10.0.0.2:6379@16379 myself,slave aaa 0 0 1 connected"),
+			newPodNode("redis-cluster-0", "aaa 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191 8193"),
+		},
+	}
+	output := &bytes.Buffer{}
+	printStatus(output, clusterNodes, []string{"redis-cluster-2"})
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header and a line per pod. Got:\n%s", output.String())
+	}
+	if strings.Join(strings.Fields(lines[1]), " ") != "redis-cluster-0 aaa master - 0-8191,8193" {
+		t.Fatalf("Unexpected line for master: %s", lines[1])
+	}
+	if strings.Join(strings.Fields(lines[2]), " ") != "redis-cluster-1 bbb replica redis-cluster-0 -" {
+		t.Fatalf("Unexpected line for replica: %s", lines[2])
+	}
+	if !strings.Contains(lines[3], "not ready") {
+		t.Fatalf("Expected pod which is not ready to be listed. Got: %s", lines[3])
+	}
+}
+
+func TestPrintRebalancePlan(t *testing.T) {
+	source := newPodNode("redis-cluster-0", "aaa 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-10000")
+	destination := newPodNode("redis-cluster-1", "bbb 10.0.0.2:6379@16379 myself,master - 0 0 1 connected 10001-16383")
+	plan := &redis_internal.RebalancePlan{
+		Moves: []redis_internal.SlotMove{
+			{
+				Source:      source,
+				Destination: destination,
+				Slots:       []int32{0, 1, 2, 5},
+			},
+		},
+		MaxDeviationPercentage: 22.1,
+	}
+	output := &bytes.Buffer{}
+	printRebalancePlan(output, plan)
+
+	if !strings.Contains(output.String(), "Moving 4 slots") {
+		t.Fatalf("Expected the amount of slots to be shown. Got:\n%s", output.String())
+	}
+	if !strings.Contains(output.String(), "redis-cluster-0  redis-cluster-1  0-2,5") {
+		t.Fatalf("Expected the move to be shown. Got:\n%s", output.String())
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	"github.com/containersolutions/redis-cluster-operator/internal/utils"
+	"github.com/go-redis/redis/v8"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"net/http"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type globalOptions struct {
+	kubeconfig string
+	namespace  string
+}
+
+// session holds the clients for the Kubernetes cluster the Redis clusters run in
+type session struct {
+	config    *rest.Config
+	client    client.Client
+	clientset clientset.Interface
+	namespace string
+}
+
+// newSession loads the kubeconfig the same way kubectl does, so the plugin talks to the same cluster and namespace
+func newSession(options *globalOptions) (*session, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = options.kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	namespace := options.namespace
+	if namespace == "" {
+		namespace, _, err = clientConfig.Namespace()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	kubeClient, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	kubeClientset, err := clientset.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &session{
+		config:    config,
+		client:    kubeClient,
+		clientset: kubeClientset,
+		namespace: namespace,
+	}, nil
+}
+
+// connection forwards a local port to every ready Redis pod of a cluster
+type connection struct {
+	kubeClient  client.Client
+	cluster     *v1beta1.RedisCluster
+	statefulset *appsv1.StatefulSet
+	pods        []*v1.Pod
+	notReady    []string
+	password    string
+	// localAddresses maps the addresses of the Redis nodes inside Kubernetes to their forwarded local addresses
+	localAddresses map[string]string
+	stopChannels   []chan struct{}
+}
+
+func (s *session) connect(ctx context.Context, clusterName string) (*connection, error) {
+	cluster := &v1beta1.RedisCluster{}
+	err := s.client.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: clusterName}, cluster)
+	if err != nil {
+		return nil, err
+	}
+	statefulset, err := kubernetes.FetchExistingStatefulset(ctx, s.client, cluster)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := kubernetes.FetchRedisPods(ctx, s.client, cluster)
+	if err != nil {
+		return nil, err
+	}
+	password, err := kubernetes.GetRedisPassword(ctx, s.client, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &connection{
+		kubeClient:     s.client,
+		cluster:        cluster,
+		statefulset:    statefulset,
+		password:       password,
+		localAddresses: map[string]string{},
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !utils.IsPodContainersReady(pod) {
+			conn.notReady = append(conn.notReady, pod.Name)
+			continue
+		}
+		localAddress, stop, err := s.forwardPort(pod)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.stopChannels = append(conn.stopChannels, stop)
+		conn.pods = append(conn.pods, pod)
+		// Nodes are addressed by their hostname by the Operator, but by their IP in the CLUSTER NODES output
+		conn.localAddresses[kubernetes.GetRedisPodHost(cluster, statefulset, pod)+":6379"] = localAddress
+		conn.localAddresses[pod.Status.PodIP+":6379"] = localAddress
+	}
+	return conn, nil
+}
+
+// forwardPort forwards a random local port to the Redis port of the pod, like kubectl port-forward does
+func (s *session) forwardPort(pod *v1.Pod) (string, chan struct{}, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(s.config)
+	if err != nil {
+		return "", nil, err
+	}
+	url := s.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stop := make(chan struct{})
+	ready := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{"0:6379"}, stop, ready, io.Discard, os.Stderr)
+	if err != nil {
+		return "", nil, err
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- forwarder.ForwardPorts()
+	}()
+	select {
+	case <-ready:
+	case err = <-errs:
+		return "", nil, fmt.Errorf("could not forward port to pod %s: %w", pod.Name, err)
+	}
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		close(stop)
+		return "", nil, err
+	}
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local), stop, nil
+}
+
+// Close stops all the port-forwards
+func (c *connection) Close() {
+	for _, stop := range c.stopChannels {
+		close(stop)
+	}
+	c.stopChannels = nil
+}
+
+// clientBuilder connects to the forwarded port of a Redis node, instead of its address inside Kubernetes.
+// Nodes keep the address inside Kubernetes, as that is the address the other nodes know them by.
+func (c *connection) clientBuilder(opt *redis.Options) *redis.Client {
+	forwarded := *opt
+	if localAddress, ok := c.localAddresses[opt.Addr]; ok {
+		forwarded.Addr = localAddress
+	}
+	forwarded.Password = c.password
+	return redis.NewClient(&forwarded)
+}
+
+func (c *connection) clusterNodes(ctx context.Context) (*redis_internal.ClusterNodes, error) {
+	if c.cluster.IsReplicationMode() {
+		return nil, fmt.Errorf("cluster %s runs in Replication mode, which has no Redis Cluster to operate on", c.cluster.Name)
+	}
+	clusterNodes := &redis_internal.ClusterNodes{}
+	for _, pod := range c.pods {
+		node, err := redis_internal.NewNode(ctx, &redis.Options{
+			Addr: kubernetes.GetRedisPodHost(c.cluster, c.statefulset, pod) + ":6379",
+		}, pod, c.clientBuilder)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to pod %s: %w", pod.Name, err)
+		}
+		clusterNodes.Nodes = append(clusterNodes.Nodes, node)
+	}
+	return clusterNodes, nil
+}
+
+func (c *connection) replicationNodes(ctx context.Context) (*redis_internal.ReplicationNodes, error) {
+	replicationNodes := &redis_internal.ReplicationNodes{}
+	for _, pod := range c.pods {
+		node, err := redis_internal.NewReplicationNode(ctx, &redis.Options{
+			Addr: kubernetes.GetRedisPodHost(c.cluster, c.statefulset, pod) + ":6379",
+		}, pod, c.clientBuilder)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to pod %s: %w", pod.Name, err)
+		}
+		replicationNodes.Nodes = append(replicationNodes.Nodes, node)
+	}
+	return replicationNodes, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-rediscluster is a kubectl plugin for inspecting and operating the Redis clusters managed by the Operator.
+// It connects to the Redis nodes through port-forwards, so redis-cli does not need to be installed anywhere.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `Inspect and operate Redis clusters managed by the Redis Cluster Operator.
+
+Usage:
+  kubectl rediscluster [flags] <command> [arguments]
+
+Commands:
+  status <cluster>                   Show the nodes of the cluster, with their roles and slots
+  check <cluster>                    Check whether all nodes agree on the state of the cluster
+  rebalance <cluster> [--dry-run]    Move slots until every master owns its share of the slots
+  failover <pod>                     Promote the replica in the pod to master
+  forget-failed <cluster>            Remove failed nodes from the cluster
+
+Flags:
+`
+
+func main() {
+	options := &globalOptions{}
+	flags := flag.NewFlagSet("kubectl-rediscluster", flag.ExitOnError)
+	flags.StringVar(&options.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file. Defaults to the kubeconfig kubectl uses.")
+	flags.StringVar(&options.namespace, "namespace", "", "Namespace of the cluster. Defaults to the namespace of the current context.")
+	flags.StringVar(&options.namespace, "n", "", "Shorthand for --namespace.")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	err := run(context.Background(), options, flags.Arg(0), flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, options *globalOptions, command string, args []string) error {
+	switch command {
+	case "status":
+		return runStatus(ctx, options, args)
+	case "check":
+		return runCheck(ctx, options, args)
+	case "rebalance":
+		return runRebalance(ctx, options, args)
+	case "failover":
+		return runFailover(ctx, options, args)
+	case "forget-failed":
+		return runForgetFailed(ctx, options, args)
+	}
+	return fmt.Errorf("unknown command %q. Run kubectl rediscluster --help for the list of commands", command)
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	appsv1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"time"
+)
+
+// reconcileAutoscaling measures the memory used by the masters, and changes the amount of masters in the spec
+// when the autoscaler wants a different amount, and the cooldown has passed.
+// Returns whether the amount of masters was changed.
+func (r *RedisClusterReconciler) reconcileAutoscaling(ctx context.Context, redisCluster *cachev1beta1.RedisCluster, clusterNodes *redis_internal.ClusterNodes) (bool, error) {
+	logger := log.FromContext(ctx)
+	autoscaling := redisCluster.Spec.Autoscaling
+	if autoscaling == nil || redisCluster.Spec.Masters == 0 {
+		if redisCluster.Status.Autoscaling == nil && meta.FindStatusCondition(redisCluster.Status.Conditions, cachev1beta1.ConditionAutoscalingAvailable) == nil {
+			return false, nil
+		}
+		return false, r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+			status.Autoscaling = nil
+			meta.RemoveStatusCondition(&status.Conditions, cachev1beta1.ConditionAutoscalingAvailable)
+		})
+	}
+
+	used, maxMemory, err := clusterNodes.GetMastersMemoryUsage(ctx)
+	if err != nil {
+		return false, err
+	}
+	if maxMemory == 0 {
+		// Without maxmemory there is nothing to compare the used memory against
+		logger.Info("Autoscaling is enabled, but maxmemory is not set on the masters. Skipping autoscaling")
+		if meta.IsStatusConditionFalse(redisCluster.Status.Conditions, cachev1beta1.ConditionAutoscalingAvailable) {
+			// We already told the user
+			return false, nil
+		}
+		err = r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    cachev1beta1.ConditionAutoscalingAvailable,
+				Status:  metav1.ConditionFalse,
+				Reason:  "MaxMemoryNotSet",
+				Message: "Autoscaling needs maxmemory to be set in the Redis config",
+			})
+		})
+		if err != nil {
+			return false, err
+		}
+		r.RecordEvent(redisCluster, v12.EventTypeWarning, "AutoscalingUnavailable", "Autoscaling needs maxmemory to be set in the Redis config")
+		return false, nil
+	}
+
+	masters := redisCluster.Spec.Masters
+	desired := autoscaling.DesiredMasters(masters, used, maxMemory)
+	// While the cluster is scaling, the amount of masters in the spec differs from the masters we measured
+	utilization := int32(used * 100 / (int64(len(clusterNodes.GetMasters())) * maxMemory))
+	var lastScaleTime *metav1.Time
+	if redisCluster.Status.Autoscaling != nil {
+		lastScaleTime = redisCluster.Status.Autoscaling.LastScaleTime
+	}
+	coolingDown := lastScaleTime != nil && time.Since(lastScaleTime.Time) < autoscaling.GetCooldown()
+	scale := desired != masters && !coolingDown
+
+	if scale {
+		// We only change the amount of masters. Scaling the statefulset and moving the slots is handled
+		// by the following reconciles, the same as when the amount of masters is changed by hand.
+		patch := client.MergeFrom(redisCluster.DeepCopy())
+		redisCluster.Spec.Masters = desired
+		err = r.Client.Patch(ctx, redisCluster, patch)
+		if err != nil {
+			return false, err
+		}
+		now := metav1.Now()
+		lastScaleTime = &now
+	}
+	err = r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+		status.Autoscaling = &cachev1beta1.AutoscalingStatus{
+			MemoryUtilization: utilization,
+			DesiredMasters:    desired,
+			LastMeasureTime:   metav1.Now(),
+			LastScaleTime:     lastScaleTime,
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    cachev1beta1.ConditionAutoscalingAvailable,
+			Status:  metav1.ConditionTrue,
+			Reason:  "MaxMemorySet",
+			Message: "The utilization of the masters is measured against their maxmemory",
+		})
+	})
+	if err != nil {
+		return scale, err
+	}
+
+	message := fmt.Sprintf("The masters use %d%% of maxmemory, against a target of %d%%", utilization, autoscaling.GetTargetMemoryUtilization())
+	switch {
+	case scale && desired > masters:
+		r.RecordEvent(redisCluster, v12.EventTypeNormal, "ScalingUp", fmt.Sprintf("Scaling up from %d to %d masters. %s", masters, desired, message))
+	case scale:
+		r.RecordEvent(redisCluster, v12.EventTypeNormal, "ScalingDown", fmt.Sprintf("Scaling down from %d to %d masters. %s", masters, desired, message))
+	case desired != masters:
+		logger.Info("Autoscaler wants to change the amount of masters, but is cooling down", "masters", masters, "desired", desired, "lastScaleTime", lastScaleTime)
+	}
+	return scale, nil
+}
+
+// reconcileScaleDown removes the pods beyond the nodes the cluster needs, once their slots are moved to the masters which are kept,
+// and the replicas which are kept no longer replicate the masters which are removed.
+func (r *RedisClusterReconciler) reconcileScaleDown(ctx context.Context, redisCluster *cachev1beta1.RedisCluster, statefulset *appsv1.StatefulSet, clusterNodes *redis_internal.ClusterNodes) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	if len(clusterNodes.Nodes) != int(*statefulset.Spec.Replicas) {
+		// We need every node to move the slots and replicas safely
+		logger.Info("Not all pods are ready to scale down. Reconciling again in 10 seconds")
+		return ctrl.Result{
+			RequeueAfter: 10 * time.Second,
+		}, nil
+	}
+	running, err := operationRunning(ctx, r.Client, redisCluster)
+	if err != nil {
+		return r.RequeueError(ctx, "could not check for running operations", err)
+	}
+	if running {
+		logger.Info("A RedisClusterOperation is running on the cluster. Waiting to scale down. Reconciling again in 10 seconds")
+		return ctrl.Result{
+			RequeueAfter: 10 * time.Second,
+		}, nil
+	}
+
+	ready, err := clusterNodes.PrepareScaleDown(ctx, redisCluster)
+	if err != nil {
+		return r.RequeueError(ctx, "Could not prepare the nodes for scaling down", err)
+	}
+	if !ready {
+		logger.Info("Moving slots and replicas off the nodes which are removed. Reconciling again in 5 seconds")
+		return ctrl.Result{
+			RequeueAfter: 5 * time.Second,
+		}, nil
+	}
+
+	logger.Info("Scaling down statefulset for Redis Cluster")
+	replicas := redisCluster.NodesNeeded()
+	removed := *statefulset.Spec.Replicas - replicas
+	statefulset.Spec.Replicas = &replicas
+	err = r.Client.Update(ctx, statefulset, client.FieldOwner(kubernetes.FieldManager))
+	if err != nil {
+		return r.RequeueError(ctx, "Could not update statefulset replicas", err)
+	}
+	// The removed nodes are forgotten by the cluster once they are detected as failing
+	r.RecordEvent(redisCluster, v12.EventTypeNormal, "ScaledDown", fmt.Sprintf("Removed %d nodes from the cluster", removed))
+	return ctrl.Result{
+		RequeueAfter: 5 * time.Second,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	redis_fake "github.com/containersolutions/redis-cluster-operator/internal/redis/fake"
+	"github.com/go-redis/redis/v8"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+// newAutoscalingTest returns a reconciler for the cluster, and the nodes of a formed fake cluster with the given amount of masters
+func newAutoscalingTest(t *testing.T, redisCluster *cachev1beta1.RedisCluster, masters int, maxMemory string) (*RedisClusterReconciler, *redis_internal.ClusterNodes, *record.FakeRecorder) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	recorder := record.NewFakeRecorder(10)
+	r := &RedisClusterReconciler{
+		Client:   fake.NewClientBuilder().WithObjects(redisCluster).Build(),
+		Scheme:   s,
+		Recorder: recorder,
+	}
+
+	nodes := redis_fake.NewCluster()
+	clusterNodes := &redis_internal.ClusterNodes{}
+	for i := 0; i < masters; i++ {
+		nodes.AddNode(fmt.Sprintf("10.0.0.%d", i+1))
+	}
+	nodes.Form(masters)
+	for i := 0; i < masters; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i+1)
+		node, err := redis_internal.NewNode(context.TODO(), &redis.Options{
+			Addr: ip + ":" + redis_fake.Port,
+		}, &v12.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("redis-cluster-%d", i),
+				Namespace: "default",
+			},
+			Status: v12.PodStatus{
+				PodIP: ip,
+			},
+		}, nodes.ClientBuilder())
+		if err != nil {
+			t.Fatalf("Could not connect to fake node %s %v", ip, err)
+		}
+		err = node.ConfigSet(context.TODO(), "maxmemory", maxMemory).Err()
+		if err != nil {
+			t.Fatalf("Could not set maxmemory on fake node %s %v", ip, err)
+		}
+		clusterNodes.Nodes = append(clusterNodes.Nodes, node)
+	}
+	return r, clusterNodes, recorder
+}
+
+func TestRedisClusterReconciler_ReconcileAutoscaling_WarnsOnceWithoutMaxMemory(t *testing.T) {
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+			Autoscaling: &cachev1beta1.Autoscaling{
+				MinMasters: 3,
+				MaxMasters: 6,
+			},
+		},
+	}
+	r, clusterNodes, recorder := newAutoscalingTest(t, redisCluster, 3, "0")
+
+	for i := 0; i < 3; i++ {
+		scaled, err := r.reconcileAutoscaling(context.TODO(), redisCluster, clusterNodes)
+		if err != nil || scaled {
+			t.Fatalf("Expected autoscaling to be skipped. Got %v %v", scaled, err)
+		}
+	}
+	if !meta.IsStatusConditionFalse(redisCluster.Status.Conditions, cachev1beta1.ConditionAutoscalingAvailable) {
+		t.Fatalf("Expected autoscaling to be unavailable. Got conditions %v", redisCluster.Status.Conditions)
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("Expected a single Warning for all reconciles. Got %d Events", len(recorder.Events))
+	}
+}
+
+func TestRedisClusterReconciler_ReconcileAutoscaling_MeasuresTheRunningMasters(t *testing.T) {
+	// The spec asks for more masters than are running, as the cluster is still scaling up
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 4,
+			Autoscaling: &cachev1beta1.Autoscaling{
+				MinMasters: 4,
+				MaxMasters: 4,
+			},
+		},
+	}
+	// Every empty fake node uses 1000000 bytes
+	r, clusterNodes, _ := newAutoscalingTest(t, redisCluster, 2, "4000000")
+
+	_, err := r.reconcileAutoscaling(context.TODO(), redisCluster, clusterNodes)
+	if err != nil {
+		t.Fatalf("Could not autoscale %v", err)
+	}
+	if redisCluster.Status.Autoscaling == nil || redisCluster.Status.Autoscaling.MemoryUtilization != 25 {
+		t.Fatalf("Expected the 2 running masters to use 25%% of their maxmemory. Got %v", redisCluster.Status.Autoscaling)
+	}
+	if !meta.IsStatusConditionTrue(redisCluster.Status.Conditions, cachev1beta1.ConditionAutoscalingAvailable) {
+		t.Fatalf("Expected autoscaling to be available. Got conditions %v", redisCluster.Status.Conditions)
+	}
+}
@@ -20,26 +20,49 @@ import (
 	"context"
 	"fmt"
 	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
 	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
 	"github.com/containersolutions/redis-cluster-operator/internal/utils"
 	"github.com/go-redis/redis/v8"
+	appsv1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
+	"strings"
+	"sync"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	cachev1alpha1 "github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 )
 
 // RedisClusterReconciler reconciles a RedisCluster object
 type RedisClusterReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// WatchNamespaces limits the namespaces the reconciler manages RedisClusters in. Empty means all namespaces.
+	WatchNamespaces []string
+	// NewRedisClient creates the clients of the Redis nodes. Defaults to redis.NewClient.
+	// Tests set it to the client builder of a fake cluster.
+	NewRedisClient func(opt *redis.Options) *redis.Client
+
+	// outcomes holds the ReconcileOutcome of the last reconcile of every RedisCluster, by its namespaced name
+	outcomes sync.Map
 }
 
 //+kubebuilder:rbac:groups=cache.container-solutions.com,resources=redisclusters,verbs=get;list;watch;create;update;patch;delete
@@ -47,7 +70,13 @@ type RedisClusterReconciler struct {
 //+kubebuilder:rbac:groups=cache.container-solutions.com,resources=redisclusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=configmaps;services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="apps",resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=pods/status,verbs=get;patch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=podmonitors;servicemonitors,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -59,11 +88,24 @@ type RedisClusterReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.0/pkg/reconcile
 func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.reconcile(ctx, req)
+	// The outcome is served by the topology API, so on-call tooling can see why a cluster is stuck
+	r.recordOutcome(req.NamespacedName, result, err)
+	return result, err
+}
+
+func (r *RedisClusterReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	logger.Info("Reconciling RedisCluster", "cluster", req.Name, "namespace", req.Namespace)
 
-	redisCluster := &cachev1alpha1.RedisCluster{}
+	if !r.InScope(req.Namespace) {
+		// The cache only watches namespaces in scope, but we don't want to touch anything outside of them by accident.
+		logger.Info("RedisCluster is not in a namespace watched by the operator. Ignoring.")
+		return ctrl.Result{}, nil
+	}
+
+	redisCluster := &cachev1beta1.RedisCluster{}
 	err := r.Client.Get(ctx, req.NamespacedName, redisCluster)
 
 	if err != nil {
@@ -72,7 +114,47 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			logger.Info("RedisCluster not found during reconcile. Probably deleted by user. Exiting early.")
 			return ctrl.Result{}, nil
 		}
+		logger.Error(err, "Could not fetch RedisCluster")
+		return ctrl.Result{}, err
+	}
+
+	if !redisCluster.DeletionTimestamp.IsZero() {
+		return r.reconcileDeletion(ctx, redisCluster)
+	}
+
+	//region Pause
+	if redisCluster.IsPaused() {
+		return r.reconcilePaused(ctx, redisCluster)
+	}
+	if meta.IsStatusConditionTrue(redisCluster.Status.Conditions, cachev1beta1.ConditionPaused) {
+		logger.Info("RedisCluster is no longer paused. Resuming reconciliation.")
+		err = r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    cachev1beta1.ConditionPaused,
+				Status:  metav1.ConditionFalse,
+				Reason:  "Resumed",
+				Message: "The Operator is managing the cluster",
+			})
+		})
+		if err != nil {
+			return r.RequeueError(ctx, "could not update paused condition", err)
+		}
+		r.RecordEvent(redisCluster, v12.EventTypeNormal, "Resumed", "The Operator is managing the cluster again")
 	}
+	//endregion
+
+	//region Ensure Finalizer
+	if !controllerutil.ContainsFinalizer(redisCluster, cachev1beta1.Finalizer) {
+		// The finalizer gives us the chance to execute the deletion policy before the cluster is removed
+		// The status might have been updated already during this reconcile, so we patch instead of updating the stale cluster
+		patch := client.MergeFrom(redisCluster.DeepCopy())
+		controllerutil.AddFinalizer(redisCluster, cachev1beta1.Finalizer)
+		err = r.Client.Patch(ctx, redisCluster, patch)
+		if err != nil {
+			return r.RequeueError(ctx, "could not add finalizer", err)
+		}
+	}
+	//endregion
 
 	//region Ensure ConfigMap
 	configMap, err := kubernetes.FetchExistingConfigMap(ctx, r.Client, redisCluster)
@@ -112,6 +194,10 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			logger.Error(err, "Cannot find configMap")
 			return err
 		}
+		if metav1.IsControlledBy(configMap, redisCluster) {
+			// The owner reference is already set, so there is nothing to update
+			return nil
+		}
 		err = ctrl.SetControllerReference(redisCluster, configMap, r.Scheme)
 		if err != nil {
 			logger.Error(err, "Could not set owner reference for configMap")
@@ -131,6 +217,24 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	}
 	//endregion
 
+	//region Ensure Headless Service
+	_, err = kubernetes.FetchExistingHeadlessService(ctx, r.Client, redisCluster)
+	if err != nil && !errors.IsNotFound(err) {
+		return r.RequeueError(ctx, "Could not check whether headless service exists due to error.", err)
+	}
+	if errors.IsNotFound(err) {
+		// The headless service gives every Redis pod a stable DNS name, so it needs to exist before the Statefulset.
+		_, err = kubernetes.CreateHeadlessService(ctx, r.Client, redisCluster)
+		if err != nil {
+			return r.RequeueError(ctx, "Failed to create headless Service for RedisCluster", err)
+		}
+		logger.Info("Created headless Service for RedisCluster. Reconciling in 5 seconds.")
+		return ctrl.Result{
+			RequeueAfter: 5 * time.Second,
+		}, nil
+	}
+	//endregion
+
 	//region Ensure Statefulset
 	statefulset, err := kubernetes.FetchExistingStatefulset(ctx, r.Client, redisCluster)
 	if err != nil && !errors.IsNotFound(err) {
@@ -173,12 +277,16 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			logger.Error(err, "Cannot find statefulset")
 			return err
 		}
+		if metav1.IsControlledBy(statefulset, redisCluster) {
+			// The owner reference is already set, so there is nothing to update
+			return nil
+		}
 		err = ctrl.SetControllerReference(redisCluster, statefulset, r.Scheme)
 		if err != nil {
 			logger.Error(err, "Could not set owner reference for statefulset")
 			return err
 		}
-		err = r.Client.Update(ctx, statefulset)
+		err = r.Client.Update(ctx, statefulset, client.FieldOwner(kubernetes.FieldManager))
 		if err != nil {
 			logger.Error(err, "Could not update statefulset with owner reference")
 		}
@@ -192,6 +300,18 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	}
 	//endregion
 
+	//region Correct Statefulset Drift
+	if kubernetes.StatefulsetNeedsUpdate(statefulset, redisCluster) {
+		// The spec of the RedisCluster changed since we created the Statefulset, or someone changed it by hand.
+		logger.Info("Statefulset does not match RedisCluster spec. Applying changes.")
+		statefulset, err = kubernetes.ApplyStatefulset(ctx, r.Client, redisCluster, statefulset)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not apply statefulset changes", err)
+		}
+		r.RecordEvent(redisCluster, v12.EventTypeNormal, "StatefulsetUpdated", "Updated Statefulset to match RedisCluster spec")
+	}
+	//endregion
+
 	//region Ensure Service
 	service, err := kubernetes.FetchExistingService(ctx, r.Client, redisCluster)
 	if err != nil && !errors.IsNotFound(err) {
@@ -233,6 +353,10 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			logger.Error(err, "Cannot find service")
 			return err
 		}
+		if metav1.IsControlledBy(service, redisCluster) {
+			// The owner reference is already set, so there is nothing to update
+			return nil
+		}
 		err = ctrl.SetControllerReference(redisCluster, service, r.Scheme)
 		if err != nil {
 			logger.Error(err, "Could not set owner reference for service")
@@ -252,6 +376,39 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	}
 	//endregion
 
+	//region Correct Service Drift
+	if kubernetes.ServiceNeedsUpdate(service, redisCluster) {
+		logger.Info("Service does not match RedisCluster spec. Applying changes.")
+		err = kubernetes.UpdateService(ctx, r.Client, redisCluster, service)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not update service", err)
+		}
+	}
+	//endregion
+
+	//region Ensure Role Services
+	for role, enabled := range map[string]bool{
+		kubernetes.RedisRoleMaster:  redisCluster.Spec.RoleServices != nil && redisCluster.Spec.RoleServices.Masters,
+		kubernetes.RedisRoleReplica: redisCluster.Spec.RoleServices != nil && redisCluster.Spec.RoleServices.Replicas,
+	} {
+		if enabled {
+			err = kubernetes.EnsureRoleService(ctx, r.Client, redisCluster, role)
+		} else {
+			err = kubernetes.DeleteRoleService(ctx, r.Client, redisCluster, role)
+		}
+		if err != nil {
+			return r.RequeueError(ctx, fmt.Sprintf("Could not reconcile the %s service", role), err)
+		}
+	}
+	//endregion
+
+	//region Ensure Monitoring
+	err = r.reconcileMonitors(ctx, redisCluster)
+	if err != nil {
+		return r.RequeueError(ctx, "Could not reconcile monitors", err)
+	}
+	//endregion
+
 	if *statefulset.Spec.Replicas < redisCluster.NodesNeeded() {
 		// The statefulset has less replicas than are needed for the cluster.
 		// This means the user is trying to scale up the cluster, and we need to scale up the statefulset
@@ -259,7 +416,7 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		logger.Info("Scaling up statefulset for Redis Cluster")
 		replicas := redisCluster.NodesNeeded()
 		statefulset.Spec.Replicas = &replicas
-		err = r.Client.Update(ctx, statefulset)
+		err = r.Client.Update(ctx, statefulset, client.FieldOwner(kubernetes.FieldManager))
 		if err != nil {
 			return r.RequeueError(ctx, "Could not update statefulset replicas", err)
 		}
@@ -277,15 +434,62 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return r.RequeueError(ctx, "Could not fetch pods for redis cluster", err)
 	}
 
+	if redisCluster.IsReplicationMode() {
+		// There are no slots to assign in Replication mode, so none of the Redis Cluster management below applies
+		return r.reconcileReplication(ctx, redisCluster, statefulset, pods)
+	}
+
+	// region Ensure External Access
+	externalServices := map[string]*v12.Service{}
+	if redisCluster.Spec.ExternalAccess != nil {
+		for _, pod := range pods.Items {
+			service, err := kubernetes.EnsureExternalService(ctx, r.Client, redisCluster, &pod)
+			if err != nil {
+				return r.RequeueError(ctx, "Could not ensure external service for pod", err)
+			}
+			externalServices[pod.Name] = service
+		}
+	}
+	// endregion
+
+	clientBuilder, err := redisClientBuilder(ctx, r.Client, redisCluster, r.NewRedisClient)
+	if err != nil {
+		return r.RequeueError(ctx, "Could not load the Redis password", err)
+	}
 	clusterNodes := redis_internal.ClusterNodes{}
-	for _, pod := range pods.Items {
-		if utils.IsPodReady(&pod) {
+	for i := range pods.Items {
+		// Every node keeps a pointer to its pod, so we can't point at the loop variable
+		pod := &pods.Items[i]
+		if utils.IsPodContainersReady(pod) {
 			node, err := redis_internal.NewNode(ctx, &redis.Options{
-				Addr: pod.Status.PodIP + ":6379",
-			}, &pod, redis.NewClient)
+				Addr: kubernetes.GetRedisPodHost(redisCluster, statefulset, pod) + ":6379",
+			}, pod, clientBuilder)
 			if err != nil {
 				return r.RequeueError(ctx, "Could not load Redis Client", err)
 			}
+			if redisCluster.Spec.ExternalAccess != nil {
+				externalAddress, err := kubernetes.GetExternalAddress(ctx, r.Client, externalServices[pod.Name], pod)
+				if err != nil {
+					return r.RequeueError(ctx, "Could not get external address for pod", err)
+				}
+				if externalAddress == nil {
+					// Announcing the internal address to external clients is no use, so we wait for the address to be assigned.
+					logger.Info("External address not assigned yet. Reconciling again in 10 seconds", "pod", pod.Name)
+					return ctrl.Result{
+						RequeueAfter: 10 * time.Second,
+					}, nil
+				}
+				err = node.AnnounceAddress(ctx, externalAddress.IP, externalAddress.Port, externalAddress.BusPort)
+				if err != nil {
+					return r.RequeueError(ctx, "Could not announce external address of node", err)
+				}
+			}
+			if redisCluster.SlotWeightsSelectNodes() {
+				node.HostLabels, err = kubernetes.FetchPodHostLabels(ctx, r.Client, pod)
+				if err != nil {
+					return r.RequeueError(ctx, "Could not fetch labels for the node of the pod", err)
+				}
+			}
 
 			// make sure that the node knows about itself
 			// This is necessary, as the nodes often startup without being able to retrieve their own IP address
@@ -297,6 +501,14 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	}
 
+	// region Scale Down
+	if *statefulset.Spec.Replicas > redisCluster.NodesNeeded() {
+		// The statefulset has more replicas than are needed for the cluster, as masters were removed.
+		// The nodes in the pods beyond the needed nodes need to be emptied before the statefulset can be scaled down.
+		return r.reconcileScaleDown(ctx, redisCluster, statefulset, &clusterNodes)
+	}
+	// endregion
+
 	allPodsReady := len(clusterNodes.Nodes) == int(redisCluster.NodesNeeded())
 	if !allPodsReady {
 		logger.Info("Not all pods are ready. Reconciling again in 10 seconds")
@@ -306,70 +518,215 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	}
 
 	if allPodsReady {
-		// region Ensure Cluster Meet
+		// region Apply Cluster Plan
+		// Invalid slot ranges are left out of the layout, so the rest of the cluster can still be managed
+		if _, err := planner.GetPinnedSlots(redisCluster); err != nil {
+			r.RecordEvent(redisCluster, v12.EventTypeWarning, "InvalidSlotRanges", err.Error())
+		}
+		// The plan meets the nodes which do not know each other, brings the cluster to the masters in the spec,
+		// assigns the missing slots, and forgets the failing nodes. It never moves slots, as the cluster may not be healthy yet.
+		actions := planner.Plan(clusterNodes.GetTopology(), redisCluster)
+		for _, action := range actions {
+			logger.Info("Applying cluster change", "action", action.String())
+		}
+		// We'll wait for 5 seconds after meeting nodes, to ensure the meet is propagated
+		err = clusterNodes.Execute(ctx, actions, 5*time.Second)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not apply the cluster plan", err)
+		}
+		// endregion
 
-		// todo we should check whether a cluster meet is necessary before just spraying cluster meets.
-		// This can also be augmented by doing cluster meet for all ready nodes, and ignoring any none ready ones.
-		// If the amount of ready pods is equal to the amount of nodes needed, we probably have some additional nodes we need to remove.
-		// We can forget these additional nodes, as they are probably nodes which pods got killed.
-		logger.Info("Meeting Redis nodes")
-		err = clusterNodes.ClusterMeet(ctx)
+		// region Label Pods
+		// Roles change when replicas are attached and after failovers, so we reload the nodes before labelling their pods
+		err = clusterNodes.ReloadNodes(ctx)
 		if err != nil {
-			return r.RequeueError(ctx, "Could not meet all nodes together", err)
+			return r.RequeueError(ctx, "Failed to reload node info for cluster", err)
+		}
+		for _, node := range clusterNodes.Nodes {
+			role := kubernetes.RedisRoleReplica
+			if node.IsMaster() {
+				role = kubernetes.RedisRoleMaster
+			}
+			err = kubernetes.SetPodNodeLabels(ctx, r.Client, node.PodDetails, role, node.NodeAttributes.ID)
+			if err != nil {
+				return r.RequeueError(ctx, "Could not label pod with the role of its node", err)
+			}
+		}
+		// endregion
+
+		// region Set Pod Readiness
+		for _, node := range clusterNodes.Nodes {
+			ready, message, err := node.GetReadiness(ctx, true)
+			if err != nil {
+				return r.RequeueError(ctx, "Could not check the readiness of node", err)
+			}
+			err = kubernetes.SetPodNodeReadiness(ctx, r.Client, node.PodDetails, ready, message)
+			if err != nil {
+				return r.RequeueError(ctx, "Could not set the readiness of pod", err)
+			}
 		}
-		// We'll wait for 10 seconds to ensure the meet is propagated
-		time.Sleep(time.Second * 5)
 		// endregion
 
-		logger.Info("Checking Cluster Master Replica Ratio")
-		// region Ensure Cluster Replication Ratio
-		err = clusterNodes.EnsureClusterReplicationRatio(ctx, redisCluster)
+		// region Check Cluster Consistency
+		logger.Info("Checking Redis Cluster consistency")
+		clusterCheck, err := clusterNodes.CheckCluster(ctx)
 		if err != nil {
-			return r.RequeueError(ctx, "Failed to ensure cluster ratio for cluster", err)
+			return r.RequeueError(ctx, "could not check cluster consistency", err)
+		}
+		err = r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+			status.ClusterCheck = getClusterCheckStatus(clusterCheck)
+		})
+		if err != nil {
+			return r.RequeueError(ctx, "could not update cluster check status", err)
+		}
+		if !clusterCheck.Consistent() || clusterCheck.HasIssue(redis_internal.OpenSlot) {
+			// Moving slots while the nodes disagree on who owns them could lose data,
+			// so we wait for the cluster to settle before balancing.
+			logger.Info("Redis Cluster is not consistent. Skipping slot balancing. Reconciling again in 10 seconds", "issues", len(clusterCheck.Issues))
+			return ctrl.Result{
+				RequeueAfter: 10 * time.Second,
+			}, nil
 		}
 		// endregion
 
-		err = clusterNodes.ReloadNodes(ctx)
+		running, err := operationRunning(ctx, r.Client, redisCluster)
 		if err != nil {
-			return r.RequeueError(ctx, "Failed to reload node info for cluster", err)
+			return r.RequeueError(ctx, "could not check for running operations", err)
+		}
+		if running {
+			// Scaling or moving slots while an operation moves slots or fails over could undo the operation, or race with it
+			logger.Info("A RedisClusterOperation is running on the cluster. Skipping autoscaling and slot balancing. Reconciling again in 10 seconds")
+			return ctrl.Result{
+				RequeueAfter: 10 * time.Second,
+			}, nil
 		}
 
-		// region Assign Slots
-		logger.Info("Assigning Missing Slots")
-		slotsAssignments := clusterNodes.CalculateSlotAssignment()
-		for node, slots := range slotsAssignments {
-			if len(slots) == 0 {
-				continue
-			}
-			var slotsInt []int
-			for _, slot := range slots {
-				slotsInt = append(slotsInt, int(slot))
+		// region Empty Surplus Masters
+		// Masters beyond the masters in the spec only become replicas once their slots have moved to the kept masters.
+		// This moves data, so like rebalancing it only happens once the cluster is consistent.
+		actions = planner.PlanEmptySurplusMasters(clusterNodes.GetTopology(), redisCluster)
+		if len(actions) > 0 {
+			for _, action := range actions {
+				logger.Info("Emptying surplus master", "action", action.String())
 			}
-			err = node.ClusterAddSlots(ctx, slotsInt...).Err()
+			err = clusterNodes.Execute(ctx, actions, 0)
 			if err != nil {
-				return r.RequeueError(ctx, "Could not assign node slots", err)
+				return r.RequeueError(ctx, "Could not empty surplus masters", err)
 			}
+			logger.Info("Emptied surplus masters. Reconciling again in 5 seconds")
+			return ctrl.Result{
+				RequeueAfter: 5 * time.Second,
+			}, nil
+		}
+		// endregion
+
+		// region Autoscale
+		scaled, err := r.reconcileAutoscaling(ctx, redisCluster, &clusterNodes)
+		if err != nil {
+			return r.RequeueError(ctx, "could not autoscale cluster", err)
+		}
+		if scaled {
+			logger.Info("Changed the amount of masters. Reconciling again in 5 seconds", "masters", redisCluster.Spec.Masters)
+			return ctrl.Result{
+				RequeueAfter: 5 * time.Second,
+			}, nil
 		}
 		// endregion
 
-		logger.Info("Forgetting Failed Nodes No Longer Valid")
-		failingNodes, err := clusterNodes.GetFailingNodes(ctx)
+		// region Balance Slots
+		logger.Info("Calculating Redis Cluster rebalance plan")
+		plan, err := clusterNodes.PlanRebalance(ctx, redisCluster)
 		if err != nil {
-			return r.RequeueError(ctx, "could not fetch failing nodes", err)
+			return r.RequeueError(ctx, "could not calculate rebalance plan", err)
+		}
+		rebalanceStatus := getRebalanceStatus(plan)
+		policy := redisCluster.Spec.Rebalance
+		switch {
+		case !policy.IsEnabled():
+			rebalanceStatus.State = cachev1beta1.RebalanceDisabled
+		case len(plan.Moves) == 0:
+			rebalanceStatus.State = cachev1beta1.RebalanceBalanced
+		case policy.DryRun:
+			rebalanceStatus.State = cachev1beta1.RebalanceDryRun
+		case policy.MaintenanceWindow != nil && !policy.MaintenanceWindow.Contains(time.Now()):
+			rebalanceStatus.State = cachev1beta1.RebalanceWaitingForWindow
+		default:
+			rebalanceStatus.State = cachev1beta1.RebalanceMoving
 		}
-		for _, node := range failingNodes {
-			err = clusterNodes.ForgetNode(ctx, node)
+		// The plan is calculated every reconcile, so we only publish it when it changes
+		planChanged := rebalanceStatusChanged(redisCluster.Status.Rebalance, rebalanceStatus)
+		if planChanged {
+			err = r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+				status.Rebalance = rebalanceStatus
+			})
 			if err != nil {
-				return r.RequeueError(ctx, fmt.Sprintf("could not forget node %s", node.NodeAttributes.ID), err)
+				return r.RequeueError(ctx, "could not update rebalance status", err)
 			}
 		}
 
-		logger.Info("Balancing Redis Cluster slots")
-		err = clusterNodes.BalanceSlots(ctx, redisCluster)
+		switch rebalanceStatus.State {
+		case cachev1beta1.RebalanceDryRun, cachev1beta1.RebalanceWaitingForWindow:
+			logger.Info("Not moving slots for Redis Cluster", "state", rebalanceStatus.State, "slots", plan.SlotCount())
+			if planChanged {
+				r.RecordEvent(redisCluster, v12.EventTypeNormal, "RebalancePlanned", fmt.Sprintf("Planned to move %d slots in %d moves (%s)", plan.SlotCount(), len(plan.Moves), rebalanceStatus.State))
+			}
+		case cachev1beta1.RebalanceMoving:
+			logger.Info("Balancing Redis Cluster slots", "slots", plan.SlotCount())
+			r.RecordEvent(redisCluster, v12.EventTypeNormal, "RebalanceStarted", fmt.Sprintf("Moving %d slots in %d moves", plan.SlotCount(), len(plan.Moves)))
+			err = clusterNodes.ApplyRebalancePlan(ctx, plan)
+			if err != nil {
+				r.RecordEvent(redisCluster, v12.EventTypeWarning, "RebalanceFailed", err.Error())
+				return r.RequeueError(ctx, "could not balance slots across nodes", err)
+			}
+			r.RecordEvent(redisCluster, v12.EventTypeNormal, "RebalanceCompleted", fmt.Sprintf("Moved %d slots", plan.SlotCount()))
+			logger.Info("Finished balancing Redis Cluster slots")
+		}
+		// endregion
+	}
+
+	return ctrl.Result{
+		RequeueAfter: 30 * time.Second,
+	}, nil
+}
+
+// reconcilePaused observes a paused cluster without changing anything, neither in Kubernetes nor in Redis.
+// This allows users to debug a cluster by hand, while still seeing what the Operator sees.
+func (r *RedisClusterReconciler) reconcilePaused(ctx context.Context, redisCluster *cachev1beta1.RedisCluster) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("RedisCluster is paused. Only observing the cluster.", "annotation", cachev1beta1.PausedAnnotation)
+
+	wasPaused := meta.IsStatusConditionTrue(redisCluster.Status.Conditions, cachev1beta1.ConditionPaused)
+	err := r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    cachev1beta1.ConditionPaused,
+			Status:  metav1.ConditionTrue,
+			Reason:  "PausedByAnnotation",
+			Message: fmt.Sprintf("The Operator does not change the cluster while the %s annotation is set to true", cachev1beta1.PausedAnnotation),
+		})
+	})
+	if err != nil {
+		return r.RequeueError(ctx, "could not update paused condition", err)
+	}
+	if !wasPaused {
+		r.RecordEvent(redisCluster, v12.EventTypeNormal, "Paused", "The Operator stopped making changes to the cluster")
+	}
+
+	clusterNodes, err := r.observeClusterNodes(ctx, redisCluster)
+	if err != nil {
+		return r.RequeueError(ctx, "Could not load Redis nodes", err)
+	}
+	defer clusterNodes.Close()
+	if len(clusterNodes.Nodes) > 0 && !redisCluster.IsReplicationMode() {
+		clusterCheck, err := clusterNodes.CheckCluster(ctx)
 		if err != nil {
-			return r.RequeueError(ctx, "could not balance slots across nodes", err)
+			return r.RequeueError(ctx, "could not check cluster consistency", err)
+		}
+		err = r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+			status.ClusterCheck = getClusterCheckStatus(clusterCheck)
+		})
+		if err != nil {
+			return r.RequeueError(ctx, "could not update cluster check status", err)
 		}
-		logger.Info("Finished balancing Redis Cluster slots")
 	}
 
 	return ctrl.Result{
@@ -377,6 +734,87 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	}, nil
 }
 
+// observeClusterNodes connects to the Redis nodes in all ready pods, without changing anything in the cluster.
+// Callers close the nodes once they are done with them.
+func (r *RedisClusterReconciler) observeClusterNodes(ctx context.Context, redisCluster *cachev1beta1.RedisCluster) (*redis_internal.ClusterNodes, error) {
+	clusterNodes := &redis_internal.ClusterNodes{}
+	statefulset, err := kubernetes.FetchExistingStatefulset(ctx, r.Client, redisCluster)
+	if errors.IsNotFound(err) {
+		// There are no Redis nodes to observe yet
+		return clusterNodes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pods, err := kubernetes.FetchRedisPods(ctx, r.Client, redisCluster)
+	if err != nil {
+		return nil, err
+	}
+	if redisCluster.IsReplicationMode() {
+		// Nodes without cluster support only know their role, which is all the callers need
+		replicationNodes, err := r.observeReplicationNodes(ctx, redisCluster, statefulset, pods)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range replicationNodes.Nodes {
+			clusterNodes.Nodes = append(clusterNodes.Nodes, node.Node)
+		}
+		return clusterNodes, nil
+	}
+	clientBuilder, err := redisClientBuilder(ctx, r.Client, redisCluster, r.NewRedisClient)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !utils.IsPodContainersReady(pod) {
+			continue
+		}
+		node, err := redis_internal.NewNode(ctx, &redis.Options{
+			Addr: kubernetes.GetRedisPodHost(redisCluster, statefulset, pod) + ":6379",
+		}, pod, clientBuilder)
+		if err != nil {
+			clusterNodes.Close()
+			return nil, err
+		}
+		clusterNodes.Nodes = append(clusterNodes.Nodes, node)
+	}
+	return clusterNodes, nil
+}
+
+// redisClientBuilder returns the builder for clients of the Redis nodes in the cluster.
+// Nodes build the clients of their friends with the same builder, so all of them authenticate with the password of the cluster.
+func redisClientBuilder(ctx context.Context, kubeClient client.Client, redisCluster *cachev1beta1.RedisCluster, newClient func(opt *redis.Options) *redis.Client) (func(opt *redis.Options) *redis.Client, error) {
+	password, err := kubernetes.GetRedisPassword(ctx, kubeClient, redisCluster)
+	if err != nil {
+		return nil, err
+	}
+	if newClient == nil {
+		newClient = redis.NewClient
+	}
+	return func(opt *redis.Options) *redis.Client {
+		opt.Password = password
+		return newClient(opt)
+	}, nil
+}
+
+// InScope returns whether the reconciler is allowed to manage RedisClusters in the namespace
+func (r *RedisClusterReconciler) InScope(namespace string) bool {
+	return namespaceInScope(r.WatchNamespaces, namespace)
+}
+
+func namespaceInScope(watchNamespaces []string, namespace string) bool {
+	if len(watchNamespaces) == 0 {
+		return true
+	}
+	for _, watchNamespace := range watchNamespaces {
+		if watchNamespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *RedisClusterReconciler) RequeueError(ctx context.Context, message string, err error) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Error(err, message)
@@ -385,9 +823,91 @@ func (r *RedisClusterReconciler) RequeueError(ctx context.Context, message strin
 	}, err
 }
 
+// UpdateStatus fetches the latest version of the RedisCluster, applies the mutation to its status, and updates it.
+// The RedisCluster we are reconciling is usually stale by the time we want to report status,
+// so we refetch it to avoid conflicts.
+func (r *RedisClusterReconciler) UpdateStatus(ctx context.Context, cluster *cachev1beta1.RedisCluster, mutate func(status *cachev1beta1.RedisClusterStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &cachev1beta1.RedisCluster{}
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(cluster), latest)
+		if err != nil {
+			return err
+		}
+		mutate(&latest.Status)
+		err = r.Client.Status().Update(ctx, latest)
+		if err != nil {
+			return err
+		}
+		cluster.Status = latest.Status
+		return nil
+	})
+}
+
+// RecordEvent records an Event for the RedisCluster, if the reconciler has been given a recorder
+func (r *RedisClusterReconciler) RecordEvent(cluster *cachev1beta1.RedisCluster, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(cluster, eventType, reason, message)
+}
+
+func getRebalanceStatus(plan *redis_internal.RebalancePlan) *cachev1beta1.RebalanceStatus {
+	status := &cachev1beta1.RebalanceStatus{
+		LastPlanTime: metav1.Now(),
+		SlotsToMove:  int32(plan.SlotCount()),
+	}
+	for _, slotMove := range plan.Moves {
+		status.Moves = append(status.Moves, cachev1beta1.RebalanceMove{
+			Source:      slotMove.Source.NodeAttributes.ID,
+			Destination: slotMove.Destination.NodeAttributes.ID,
+			Slots:       strings.Join(redis_internal.FormatSlotRanges(slotMove.Slots), ","),
+			SlotCount:   int32(len(slotMove.Slots)),
+		})
+	}
+	return status
+}
+
+// rebalanceStatusChanged returns whether the planned rebalance differs from the current one in its state or moves.
+// The time the plan was calculated is ignored, as it changes every reconcile.
+func rebalanceStatusChanged(current *cachev1beta1.RebalanceStatus, planned *cachev1beta1.RebalanceStatus) bool {
+	if current == nil {
+		return true
+	}
+	previous := current.DeepCopy()
+	previous.LastPlanTime = planned.LastPlanTime
+	return !equality.Semantic.DeepEqual(previous, planned)
+}
+
+func getClusterCheckStatus(result *redis_internal.ClusterCheckResult) *cachev1beta1.ClusterCheckStatus {
+	status := &cachev1beta1.ClusterCheckStatus{
+		Consistent:    result.Consistent(),
+		LastCheckTime: metav1.Now(),
+	}
+	for _, issue := range result.Issues {
+		status.Issues = append(status.Issues, cachev1beta1.ClusterCheckIssue{
+			Type:    string(issue.Type),
+			NodeID:  issue.NodeID,
+			Message: issue.Message,
+		})
+	}
+	return status
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *RedisClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&cachev1alpha1.RedisCluster{}).
+		// We report status on every reconcile, which should not trigger yet another reconcile.
+		// Annotations don't change the generation, but we need to react to the cluster being paused or resumed.
+		For(&cachev1beta1.RedisCluster{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		// The Statefulset status changes whenever a pod changes, which we already watch below.
+		Owns(&appsv1.StatefulSet{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&v12.ConfigMap{}).
+		Owns(&v12.Service{}).
+		// Watching the pods lets us react to Redis nodes failing straight away, instead of waiting for the next requeue.
+		Watches(
+			&source.Kind{Type: &v12.Pod{}},
+			handler.EnqueueRequestsFromMapFunc(mapPodToRedisCluster),
+			builder.WithPredicates(redisPodPredicate()),
+		).
 		Complete(r)
 }
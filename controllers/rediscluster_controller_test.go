@@ -18,23 +18,27 @@ package controllers
 
 import (
 	"context"
-	cachev1alpha1 "github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	kubernetes_fake "github.com/containersolutions/redis-cluster-operator/internal/kubernetes/fake"
 	v1 "k8s.io/api/apps/v1"
 	v12 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"testing"
+	"time"
 )
 
 func TestRedisClusterReconciler_Reconcile_ReturnsIfRedisClusterIsNotFound(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.ClientBuilder{}
 	// Create a ReconcileMemcached object with the scheme and fake client.
 	r := &RedisClusterReconciler{
@@ -56,10 +60,61 @@ func TestRedisClusterReconciler_Reconcile_ReturnsIfRedisClusterIsNotFound(t *tes
 	}
 }
 
+// unavailableRedisClusterClient fails to get RedisClusters, as if the API server was unavailable
+type unavailableRedisClusterClient struct {
+	ctrlclient.Client
+}
+
+func (c *unavailableRedisClusterClient) Get(ctx context.Context, key ctrlclient.ObjectKey, obj ctrlclient.Object) error {
+	if _, ok := obj.(*cachev1beta1.RedisCluster); ok {
+		return errors.NewServiceUnavailable("API server is unavailable")
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestRedisClusterReconciler_Reconcile_ReturnsErrorIfRedisClusterCannotBeFetched(t *testing.T) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithObjects(redisCluster).Build()
+	r := &RedisClusterReconciler{
+		Client: &unavailableRedisClusterClient{Client: fakeClient},
+		Scheme: s,
+	}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	_, err := r.Reconcile(context.TODO(), req)
+	if !errors.IsServiceUnavailable(err) {
+		t.Fatalf("Expected the error fetching the RedisCluster to be returned. Got %v", err)
+	}
+
+	statefulsets := &v1.StatefulSetList{}
+	err = fakeClient.List(context.TODO(), statefulsets)
+	if err != nil {
+		t.Fatalf("Failed to list statefulsets %v", err)
+	}
+	if len(statefulsets.Items) != 0 {
+		t.Fatalf("Expected nothing to be created for a RedisCluster which could not be fetched")
+	}
+}
+
 func TestRedisClusterReconciler_Reconcile_ReturnsErrorIfCannotGetStatefulset(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 	client := clientBuilder.Build()
 
@@ -86,15 +141,15 @@ func TestRedisClusterReconciler_Reconcile_ReturnsErrorIfCannotGetStatefulset(t *
 func TestRedisClusterReconciler_Reconcile_CreatesStatefulsetIfDoesntExist(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 
 	clientBuilder := fake.NewClientBuilder()
-	clientBuilder.WithObjects(&cachev1alpha1.RedisCluster{
+	clientBuilder.WithObjects(&cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 		},
@@ -136,16 +191,16 @@ func TestRedisClusterReconciler_Reconcile_CreatesStatefulsetIfDoesntExist(t *tes
 func TestRedisClusterReconciler_Reconcile_DoesNotFailIfStatefulsetExists(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 
 	replicas := int32(6)
 	clientBuilder := fake.NewClientBuilder()
-	clientBuilder.WithObjects(&cachev1alpha1.RedisCluster{
+	clientBuilder.WithObjects(&cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 		},
@@ -158,7 +213,8 @@ func TestRedisClusterReconciler_Reconcile_DoesNotFailIfStatefulsetExists(t *test
 			Replicas: &replicas,
 		},
 	})
-	client := clientBuilder.Build()
+	// The Statefulset does not match the cluster, so the reconciler will apply the changes
+	client := kubernetes_fake.NewApplyAsMergePatchClient(clientBuilder.Build())
 
 	// Create a ReconcileMemcached object with the scheme and fake client.
 	r := &RedisClusterReconciler{
@@ -195,15 +251,15 @@ func TestRedisClusterReconciler_Reconcile_DoesNotFailIfStatefulsetExists(t *test
 func TestRedisClusterReconciler_Reconcile_StatefulsetHasOwnerReferenceSetToRedisCluster(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 
-	redisCluster := &cachev1alpha1.RedisCluster{
+	redisCluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 			UID:       "5b85970f-d70e-4f32-a9f7-12b2cc81f125",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 		},
@@ -255,16 +311,78 @@ func TestRedisClusterReconciler_Reconcile_StatefulsetHasOwnerReferenceSetToRedis
 	}
 }
 
+func TestRedisClusterReconciler_Reconcile_OnlySetsOwnerReferencesOnce(t *testing.T) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+			UID:       "5b85970f-d70e-4f32-a9f7-12b2cc81f125",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(redisCluster).Build()
+	r := &RedisClusterReconciler{
+		Client: fakeClient,
+		Scheme: s,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	for i := 0; i < 8; i++ {
+		_, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	objects := map[string]ctrlclient.Object{
+		"redis-cluster":        &v1.StatefulSet{},
+		"redis-cluster-config": &v12.ConfigMap{},
+	}
+	resourceVersions := map[string]string{}
+	for name, object := range objects {
+		err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "default"}, object)
+		if err != nil {
+			t.Fatalf("Failed to fetch %s %v", name, err)
+		}
+		resourceVersions[name] = object.GetResourceVersion()
+	}
+
+	_, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	for name, object := range objects {
+		err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "default"}, object)
+		if err != nil {
+			t.Fatalf("Failed to fetch %s %v", name, err)
+		}
+		if object.GetResourceVersion() != resourceVersions[name] {
+			t.Fatalf("Expected %s not to be updated once its owner reference is set", name)
+		}
+	}
+}
+
 func TestRedisClusterReconciler_Reconcile_CreatesConfigMapForRedisCluster(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
-	redisCluster := &cachev1alpha1.RedisCluster{
+	_ = cachev1beta1.AddToScheme(s)
+	redisCluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 		},
@@ -318,13 +436,13 @@ func TestRedisClusterReconciler_Reconcile_CreatesConfigMapForRedisCluster(t *tes
 func TestRedisClusterReconciler_Reconcile_DoesNotFailIfConfigMapExists(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
-	redisCluster := &cachev1alpha1.RedisCluster{
+	_ = cachev1beta1.AddToScheme(s)
+	redisCluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 		},
@@ -386,15 +504,15 @@ func TestRedisClusterReconciler_Reconcile_DoesNotFailIfConfigMapExists(t *testin
 func TestRedisClusterReconciler_Reconcile_ConfigMapHasOwnerReferenceSetToRedisCluster(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 
-	redisCluster := &cachev1alpha1.RedisCluster{
+	redisCluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 			UID:       "5b85970f-d70e-4f32-a9f7-12b2cc81f125",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 		},
@@ -445,3 +563,164 @@ func TestRedisClusterReconciler_Reconcile_ConfigMapHasOwnerReferenceSetToRedisCl
 		t.Fatalf("Owner not correctly set")
 	}
 }
+
+func TestRedisClusterReconciler_Reconcile_DoesNotChangePausedRedisCluster(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+			Annotations: map[string]string{
+				cachev1beta1.PausedAnnotation: "true",
+			},
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}
+
+	clientBuilder := fake.NewClientBuilder()
+	clientBuilder.WithObjects(redisCluster)
+	client := clientBuilder.Build()
+
+	r := &RedisClusterReconciler{
+		Client: client,
+		Scheme: s,
+	}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+
+	for i := 0; i < 8; i++ {
+		_, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	err := client.Get(context.TODO(), types.NamespacedName{
+		Name:      "redis-cluster-config",
+		Namespace: "default",
+	}, &v12.ConfigMap{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected no ConfigMap to be created for a paused cluster. Got %v", err)
+	}
+	err = client.Get(context.TODO(), types.NamespacedName{
+		Name:      "redis-cluster",
+		Namespace: "default",
+	}, &v1.StatefulSet{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected no Statefulset to be created for a paused cluster. Got %v", err)
+	}
+
+	cluster := &cachev1beta1.RedisCluster{}
+	err = client.Get(context.TODO(), req.NamespacedName, cluster)
+	if err != nil {
+		t.Fatalf("Failed to fetch RedisCluster %v", err)
+	}
+	if !meta.IsStatusConditionTrue(cluster.Status.Conditions, cachev1beta1.ConditionPaused) {
+		t.Fatalf("Expected the Paused condition to be set. Got %v", cluster.Status.Conditions)
+	}
+
+	// Once the annotation is removed, the cluster should be managed again
+	cluster.Annotations = nil
+	err = client.Update(context.TODO(), cluster)
+	if err != nil {
+		t.Fatalf("Failed to resume RedisCluster %v", err)
+	}
+	_, err = r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	err = client.Get(context.TODO(), req.NamespacedName, cluster)
+	if err != nil {
+		t.Fatalf("Failed to fetch RedisCluster %v", err)
+	}
+	if meta.IsStatusConditionTrue(cluster.Status.Conditions, cachev1beta1.ConditionPaused) {
+		t.Fatalf("Expected the Paused condition to be cleared. Got %v", cluster.Status.Conditions)
+	}
+}
+
+func TestRedisClusterReconciler_Reconcile_IgnoresRedisClusterOutsideWatchedNamespaces(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "other-tenant",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}
+
+	clientBuilder := fake.NewClientBuilder()
+	clientBuilder.WithObjects(redisCluster)
+	client := clientBuilder.Build()
+
+	r := &RedisClusterReconciler{
+		Client:          client,
+		Scheme:          s,
+		WatchNamespaces: []string{"default", "team-a"},
+	}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "other-tenant",
+		},
+	}
+	_, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	err = client.Get(context.TODO(), types.NamespacedName{
+		Name:      "redis-cluster-config",
+		Namespace: "other-tenant",
+	}, &v12.ConfigMap{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected RedisCluster outside of the watched namespaces to be left alone. Got %v", err)
+	}
+	if !r.InScope("team-a") || r.InScope("other-tenant") {
+		t.Fatalf("Namespace scope not correctly determined")
+	}
+}
+
+func TestRebalanceStatusChangedIgnoresThePlanTime(t *testing.T) {
+	current := &cachev1beta1.RebalanceStatus{
+		State:        cachev1beta1.RebalanceDryRun,
+		LastPlanTime: metav1.NewTime(metav1.Now().Add(-time.Hour)),
+		SlotsToMove:  10,
+		Moves: []cachev1beta1.RebalanceMove{
+			{Source: "a", Destination: "b", Slots: "0-9", SlotCount: 10},
+		},
+	}
+	planned := current.DeepCopy()
+	planned.LastPlanTime = metav1.Now()
+	if rebalanceStatusChanged(current, planned) {
+		t.Fatalf("Expected the same plan calculated again not to be a change")
+	}
+
+	planned.State = cachev1beta1.RebalanceMoving
+	if !rebalanceStatusChanged(current, planned) {
+		t.Fatalf("Expected a change of state to be a change")
+	}
+	planned = current.DeepCopy()
+	planned.Moves[0].Destination = "c"
+	if !rebalanceStatusChanged(current, planned) {
+		t.Fatalf("Expected a change of moves to be a change")
+	}
+	if !rebalanceStatusChanged(nil, planned) {
+		t.Fatalf("Expected the first plan to be a change")
+	}
+}
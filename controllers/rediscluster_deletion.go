@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	deletingReasonSnapshotInProgress = "SnapshotInProgress"
+	deletingReasonSnapshotCompleted  = "SnapshotCompleted"
+	deletingReasonRetaining          = "RetainingData"
+	deletingReasonDeleting           = "DeletingResources"
+)
+
+// reconcileDeletion executes the deletion policy of the cluster, and then removes the finalizer so Kubernetes can delete it.
+// The resources owned by the cluster are garbage collected by Kubernetes once the cluster is gone.
+// Statefulsets never delete the persistent volume claims of their pods, so the data is kept unless the policy deletes it.
+func (r *RedisClusterReconciler) reconcileDeletion(ctx context.Context, redisCluster *cachev1beta1.RedisCluster) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(redisCluster, cachev1beta1.Finalizer) {
+		// We've already done our part, and are waiting for Kubernetes to remove the cluster
+		return ctrl.Result{}, nil
+	}
+	logger.Info("RedisCluster is being deleted", "deletionPolicy", redisCluster.Spec.DeletionPolicy)
+
+	switch redisCluster.Spec.DeletionPolicy {
+	case cachev1beta1.DeletionPolicyDelete:
+		err := r.setDeletingCondition(ctx, redisCluster, deletingReasonDeleting, "Deleting the persistent volume claims of the cluster")
+		if err != nil {
+			return r.RequeueError(ctx, "could not update deleting condition", err)
+		}
+		err = kubernetes.DeleteRedisPersistentVolumeClaims(ctx, r.Client, redisCluster)
+		if err != nil {
+			return r.RequeueError(ctx, "could not delete persistent volume claims", err)
+		}
+	case cachev1beta1.DeletionPolicySnapshotThenDelete:
+		done, err := r.snapshotBeforeDeletion(ctx, redisCluster)
+		if err != nil {
+			return r.RequeueError(ctx, "could not snapshot cluster before deletion", err)
+		}
+		if !done {
+			logger.Info("Waiting for snapshots to complete before deleting the cluster. Reconciling again in 5 seconds")
+			return ctrl.Result{
+				RequeueAfter: 5 * time.Second,
+			}, nil
+		}
+		// The persistent volume claims hold the snapshots, so we keep them
+	default:
+		// Retain, or no policy at all, for clusters created before the default was in place.
+		// Only an explicit Delete removes the data.
+		err := r.setDeletingCondition(ctx, redisCluster, deletingReasonRetaining, "Keeping the persistent volume claims of the cluster")
+		if err != nil {
+			return r.RequeueError(ctx, "could not update deleting condition", err)
+		}
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &cachev1beta1.RedisCluster{}
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(redisCluster), latest)
+		if err != nil {
+			return err
+		}
+		controllerutil.RemoveFinalizer(latest, cachev1beta1.Finalizer)
+		return r.Client.Update(ctx, latest)
+	})
+	if err != nil {
+		return r.RequeueError(ctx, "could not remove finalizer", err)
+	}
+	logger.Info("Executed deletion policy. RedisCluster can be removed.")
+	return ctrl.Result{}, nil
+}
+
+// snapshotBeforeDeletion saves a snapshot on every master, and returns whether all the snapshots are done.
+// Snapshots are saved in the background, so we start them on one reconcile, and check on them in the next ones.
+func (r *RedisClusterReconciler) snapshotBeforeDeletion(ctx context.Context, redisCluster *cachev1beta1.RedisCluster) (bool, error) {
+	deleting := meta.FindStatusCondition(redisCluster.Status.Conditions, cachev1beta1.ConditionDeleting)
+	if deleting != nil && deleting.Reason == deletingReasonSnapshotCompleted {
+		return true, nil
+	}
+
+	if redisCluster.Spec.Storage == nil {
+		// The snapshot would be saved in the pod, and lost with it
+		r.RecordEvent(redisCluster, v12.EventTypeWarning, "SnapshotSkipped", "The cluster has no storage to keep a snapshot in. Deleting the cluster without a snapshot")
+		return true, nil
+	}
+
+	clusterNodes, err := r.observeClusterNodes(ctx, redisCluster)
+	if err != nil {
+		return false, err
+	}
+	defer clusterNodes.Close()
+	if len(clusterNodes.GetMasters()) == 0 {
+		// There is no data we could save, and waiting won't change that while the cluster is being deleted
+		r.RecordEvent(redisCluster, v12.EventTypeWarning, "SnapshotSkipped", "No Redis masters are ready. Deleting the cluster without a snapshot")
+		return true, nil
+	}
+
+	if deleting == nil || deleting.Reason != deletingReasonSnapshotInProgress {
+		err = clusterNodes.SnapshotMasters(ctx)
+		if err != nil {
+			return false, err
+		}
+		r.RecordEvent(redisCluster, v12.EventTypeNormal, "SnapshotStarted", "Saving a snapshot on every master before deleting the cluster")
+		return false, r.setDeletingCondition(ctx, redisCluster, deletingReasonSnapshotInProgress, "Saving a snapshot on every master")
+	}
+
+	inProgress, err := clusterNodes.SnapshotsInProgress(ctx)
+	if err != nil || inProgress {
+		return false, err
+	}
+	r.RecordEvent(redisCluster, v12.EventTypeNormal, "SnapshotCompleted", "Saved a snapshot on every master")
+	return true, r.setDeletingCondition(ctx, redisCluster, deletingReasonSnapshotCompleted, "Saved a snapshot on every master")
+}
+
+func (r *RedisClusterReconciler) setDeletingCondition(ctx context.Context, redisCluster *cachev1beta1.RedisCluster, reason string, message string) error {
+	return r.UpdateStatus(ctx, redisCluster, func(status *cachev1beta1.RedisClusterStatus) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    cachev1beta1.ConditionDeleting,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		})
+	})
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+func TestRedisClusterReconciler_Reconcile_AddsFinalizer(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	client := fake.NewClientBuilder().WithObjects(&cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}).Build()
+	r := &RedisClusterReconciler{
+		Client: client,
+		Scheme: s,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+
+	_, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	cluster := &cachev1beta1.RedisCluster{}
+	err = client.Get(context.TODO(), req.NamespacedName, cluster)
+	if err != nil {
+		t.Fatalf("Failed to fetch RedisCluster %v", err)
+	}
+	if !controllerutil.ContainsFinalizer(cluster, cachev1beta1.Finalizer) {
+		t.Fatalf("Expected finalizer to be added to the RedisCluster")
+	}
+}
+
+func TestRedisClusterReconciler_Reconcile_ExecutesDeletionPolicy(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+
+	testMap := map[string]struct {
+		policy             cachev1beta1.DeletionPolicy
+		storage            *cachev1beta1.Storage
+		expectClaimDeleted bool
+	}{
+		"Delete": {
+			policy:             cachev1beta1.DeletionPolicyDelete,
+			expectClaimDeleted: true,
+		},
+		"Retain": {
+			policy: cachev1beta1.DeletionPolicyRetain,
+		},
+		"No policy": {
+			// Clusters created before the deletion policy existed keep their data
+		},
+		"SnapshotThenDelete": {
+			// Without ready masters there is nothing to snapshot, but the claims are kept
+			policy: cachev1beta1.DeletionPolicySnapshotThenDelete,
+			storage: &cachev1beta1.Storage{
+				Size: resource.MustParse("1Gi"),
+			},
+		},
+		"SnapshotThenDelete without storage": {
+			policy: cachev1beta1.DeletionPolicySnapshotThenDelete,
+		},
+	}
+	for name, test := range testMap {
+		t.Run(name, func(t *testing.T) {
+			redisCluster := &cachev1beta1.RedisCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "redis-cluster",
+					Namespace:  "default",
+					UID:        "5b85970f-d70e-4f32-a9f7-12b2cc81f125",
+					Finalizers: []string{cachev1beta1.Finalizer},
+				},
+				Spec: cachev1beta1.RedisClusterSpec{
+					Masters:        3,
+					DeletionPolicy: test.policy,
+					Storage:        test.storage,
+				},
+			}
+			client := fake.NewClientBuilder().WithObjects(redisCluster, &v1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "redis-cluster",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: cachev1beta1.GroupVersion.String(),
+							Kind:       "RedisCluster",
+							Name:       redisCluster.Name,
+							UID:        redisCluster.UID,
+						},
+					},
+				},
+			}, &v12.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "data-redis-cluster-0",
+					Namespace: "default",
+					Labels:    kubernetes.GetPodLabels(redisCluster),
+				},
+			}).Build()
+			// The fake client marks the cluster as deleted, as it still has a finalizer
+			err := client.Delete(context.TODO(), redisCluster)
+			if err != nil {
+				t.Fatalf("Failed to delete RedisCluster %v", err)
+			}
+
+			r := &RedisClusterReconciler{
+				Client: client,
+				Scheme: s,
+			}
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "redis-cluster",
+					Namespace: "default",
+				},
+			}
+			_, err = r.Reconcile(context.TODO(), req)
+			if err != nil {
+				t.Fatalf("reconcile: (%v)", err)
+			}
+
+			err = client.Get(context.TODO(), req.NamespacedName, &cachev1beta1.RedisCluster{})
+			if !errors.IsNotFound(err) {
+				t.Fatalf("Expected RedisCluster to be removed after executing the deletion policy. Got %v", err)
+			}
+			err = client.Get(context.TODO(), types.NamespacedName{Name: "data-redis-cluster-0", Namespace: "default"}, &v12.PersistentVolumeClaim{})
+			if errors.IsNotFound(err) != test.expectClaimDeleted {
+				t.Fatalf("Expected claim to be deleted: %v. Got %v", test.expectClaimDeleted, err)
+			}
+			// Kubernetes garbage collects the Statefulset of every policy, so the Redis nodes stop with the cluster
+			statefulset := &v1.StatefulSet{}
+			_ = client.Get(context.TODO(), req.NamespacedName, statefulset)
+			if len(statefulset.GetOwnerReferences()) != 1 {
+				t.Fatalf("Expected Statefulset to stay owned by the cluster. Got owner references %v", statefulset.GetOwnerReferences())
+			}
+		})
+	}
+}
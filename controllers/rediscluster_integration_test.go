@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	redis_fake "github.com/containersolutions/redis-cluster-operator/internal/redis/fake"
+	"github.com/go-redis/redis/v8"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"testing"
+)
+
+// integrationCluster runs the reconciler for a single RedisCluster against the API server of testEnv, and fake Redis nodes.
+// The API server runs none of the built-in controllers, so the harness takes the place of the statefulset controller
+// and the kubelet. It creates the missing pods of the statefulset, starts a fake Redis node for every pod,
+// and marks the pods as running on the IP of their node.
+type integrationCluster struct {
+	t          *testing.T
+	ctx        context.Context
+	client     client.Client
+	reconciler *RedisClusterReconciler
+	cluster    *cachev1beta1.RedisCluster
+	nodes      *redis_fake.Cluster
+	// podIPs holds the IP of the Redis node of every pod. Recreated pods get the same IP, so the fake node can be restarted.
+	podIPs map[string]string
+	// keepData holds the pods which keep the data of their Redis node when they are recreated, as if they had storage
+	keepData map[string]bool
+}
+
+func newIntegrationCluster(t *testing.T, spec cachev1beta1.RedisClusterSpec) *integrationCluster {
+	if testEnv == nil {
+		t.Skip("KUBEBUILDER_ASSETS is not set. Skipping integration test.")
+	}
+	ctx := context.TODO()
+	// Every test runs in its own namespace, as the API server is shared between the tests
+	namespace := &v12.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(t.Name(), "Test"), "_", "-")),
+		},
+	}
+	err := k8sClient.Create(ctx, namespace)
+	if err != nil {
+		t.Fatalf("Could not create namespace %v", err)
+	}
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rediscluster",
+			Namespace: namespace.Name,
+		},
+		Spec: spec,
+	}
+	err = k8sClient.Create(ctx, cluster)
+	if err != nil {
+		t.Fatalf("Could not create RedisCluster %v", err)
+	}
+	nodes := redis_fake.NewCluster()
+	return &integrationCluster{
+		t:      t,
+		ctx:    ctx,
+		client: k8sClient,
+		reconciler: &RedisClusterReconciler{
+			Client:         k8sClient,
+			Scheme:         k8sClient.Scheme(),
+			NewRedisClient: nodes.ClientBuilder(),
+		},
+		cluster:  cluster,
+		nodes:    nodes,
+		podIPs:   map[string]string{},
+		keepData: map[string]bool{},
+	}
+}
+
+// reconcileUntil reconciles the cluster, and runs its statefulset in between, until the condition holds.
+// Errors are expected while the cluster changes, so they only fail the test when the condition never holds.
+func (c *integrationCluster) reconcileUntil(description string, condition func() bool) {
+	c.t.Helper()
+	var err error
+	for i := 0; i < 30; i++ {
+		_, err = c.reconciler.Reconcile(c.ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(c.cluster),
+		})
+		c.runStatefulset()
+		if err == nil && condition() {
+			return
+		}
+	}
+	c.t.Fatalf("Expected %s. Last reconcile error: %v", description, err)
+}
+
+// runStatefulset creates the missing pods of the statefulset, as the statefulset controller would.
+// A recreated pod restarts the Redis node it had before, with or without its data.
+func (c *integrationCluster) runStatefulset() {
+	c.t.Helper()
+	statefulset := &v1.StatefulSet{}
+	err := c.client.Get(c.ctx, client.ObjectKeyFromObject(c.cluster), statefulset)
+	if errors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		c.t.Fatalf("Could not fetch statefulset %v", err)
+	}
+	for i := 0; i < int(*statefulset.Spec.Replicas); i++ {
+		name := fmt.Sprintf("%s-%d", statefulset.Name, i)
+		err = c.client.Get(c.ctx, types.NamespacedName{Namespace: statefulset.Namespace, Name: name}, &v12.Pod{})
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			c.t.Fatalf("Could not fetch pod %s %v", name, err)
+		}
+
+		ip, known := c.podIPs[name]
+		switch {
+		case !known:
+			ip = fmt.Sprintf("10.0.0.%d", len(c.podIPs)+1)
+			c.podIPs[name] = ip
+			c.nodes.AddNode(ip, kubernetes.GetPodHostname(c.cluster, name))
+		case c.keepData[name]:
+			c.nodes.Start(ip)
+		default:
+			c.nodes.Replace(ip)
+		}
+
+		pod := &v12.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   statefulset.Namespace,
+				Labels:      statefulset.Spec.Template.Labels,
+				Annotations: statefulset.Spec.Template.Annotations,
+			},
+			Spec: *statefulset.Spec.Template.Spec.DeepCopy(),
+		}
+		pod.Spec.Hostname = name
+		pod.Spec.Subdomain = statefulset.Spec.ServiceName
+		err = c.client.Create(c.ctx, pod)
+		if err != nil {
+			c.t.Fatalf("Could not create pod %s %v", name, err)
+		}
+		pod.Status = v12.PodStatus{
+			Phase: v12.PodRunning,
+			PodIP: ip,
+			PodIPs: []v12.PodIP{
+				{IP: ip},
+			},
+			Conditions: []v12.PodCondition{
+				{Type: v12.ContainersReady, Status: v12.ConditionTrue},
+			},
+		}
+		err = c.client.Status().Update(c.ctx, pod)
+		if err != nil {
+			c.t.Fatalf("Could not mark pod %s as running %v", name, err)
+		}
+	}
+}
+
+// losePod stops the Redis node of the pod and deletes the pod. The statefulset recreates the pod on the next reconcile.
+func (c *integrationCluster) losePod(name string, keepData bool) {
+	c.t.Helper()
+	c.nodes.Stop(c.podIPs[name])
+	c.keepData[name] = keepData
+	pod := &v12.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.cluster.Namespace,
+		},
+	}
+	err := c.client.Delete(c.ctx, pod, client.GracePeriodSeconds(0))
+	if err != nil {
+		c.t.Fatalf("Could not delete pod %s %v", name, err)
+	}
+}
+
+func (c *integrationCluster) updateSpec(mutate func(spec *cachev1beta1.RedisClusterSpec)) {
+	c.t.Helper()
+	cluster := &cachev1beta1.RedisCluster{}
+	err := c.client.Get(c.ctx, client.ObjectKeyFromObject(c.cluster), cluster)
+	if err != nil {
+		c.t.Fatalf("Could not fetch RedisCluster %v", err)
+	}
+	mutate(&cluster.Spec)
+	err = c.client.Update(c.ctx, cluster)
+	if err != nil {
+		c.t.Fatalf("Could not update RedisCluster %v", err)
+	}
+}
+
+func (c *integrationCluster) getPods() []v12.Pod {
+	c.t.Helper()
+	pods, err := kubernetes.FetchRedisPods(c.ctx, c.client, c.cluster)
+	if err != nil {
+		c.t.Fatalf("Could not fetch pods %v", err)
+	}
+	return pods.Items
+}
+
+// getSlotLayout returns the amount of slots every master owns, by the name of its pod.
+// Slots without an owner are counted under an empty name.
+func (c *integrationCluster) getSlotLayout() map[string]int {
+	podNames := map[string]string{}
+	for name, ip := range c.podIPs {
+		podNames[ip] = name
+	}
+	layout := map[string]int{}
+	for slot := 0; slot < 16384; slot++ {
+		layout[podNames[c.nodes.SlotOwner(slot)]]++
+	}
+	return layout
+}
+
+// isFormed returns whether the masters divide all slots evenly, and all pods are labelled with the role of their node
+func (c *integrationCluster) isFormed(masters int, nodes int) bool {
+	layout := c.getSlotLayout()
+	if len(layout) != masters || layout[""] != 0 {
+		return false
+	}
+	for _, slots := range layout {
+		if slots < 16384/masters || slots > 16384/masters+1 {
+			return false
+		}
+	}
+	pods := c.getPods()
+	if len(pods) != nodes {
+		return false
+	}
+	for _, pod := range pods {
+		_, isMaster := layout[pod.Name]
+		role := pod.Labels[kubernetes.RedisNodeRoleLabel]
+		if (isMaster && role != kubernetes.RedisRoleMaster) || (!isMaster && role != kubernetes.RedisRoleReplica) {
+			return false
+		}
+		if pod.Labels[kubernetes.RedisNodeIDLabel] != c.nodes.NodeID(c.podIPs[pod.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// getKnownNodeIDs returns the IDs of the nodes the Redis node of the pod knows about
+func (c *integrationCluster) getKnownNodeIDs(pod string) []string {
+	c.t.Helper()
+	redisClient := c.nodes.ClientBuilder()(&redis.Options{
+		Addr: c.podIPs[pod] + ":" + redis_fake.Port,
+	})
+	defer redisClient.Close()
+	clusterNodes, err := redisClient.ClusterNodes(c.ctx).Result()
+	if err != nil {
+		c.t.Fatalf("Could not fetch cluster nodes from %s %v", pod, err)
+	}
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(clusterNodes), "\n") {
+		ids = append(ids, strings.Fields(line)[0])
+	}
+	return ids
+}
+
+func TestRedisClusterIntegration_CreatesCluster(t *testing.T) {
+	c := newIntegrationCluster(t, cachev1beta1.RedisClusterSpec{
+		Masters:           3,
+		ReplicasPerMaster: 1,
+	})
+	c.reconcileUntil("the cluster to be formed", func() bool {
+		return c.isFormed(3, 6)
+	})
+
+	statefulset := &v1.StatefulSet{}
+	err := c.client.Get(c.ctx, client.ObjectKeyFromObject(c.cluster), statefulset)
+	if err != nil {
+		t.Fatalf("Could not fetch statefulset %v", err)
+	}
+	if *statefulset.Spec.Replicas != 6 {
+		t.Fatalf("Expected a pod for every master and replica. Got %d replicas", *statefulset.Spec.Replicas)
+	}
+	if statefulset.Spec.ServiceName != kubernetes.GetHeadlessServiceName(c.cluster) {
+		t.Fatalf("Expected the pods to get hostnames through the headless Service. Got service %s", statefulset.Spec.ServiceName)
+	}
+	if !metav1.IsControlledBy(statefulset, c.cluster) {
+		t.Fatalf("Expected the statefulset to be owned by the RedisCluster")
+	}
+
+	service, err := kubernetes.FetchExistingService(c.ctx, c.client, c.cluster)
+	if err != nil {
+		t.Fatalf("Could not fetch service %v", err)
+	}
+	if service.Spec.Ports[0].Port != 6379 {
+		t.Fatalf("Expected the service to expose Redis. Got ports %v", service.Spec.Ports)
+	}
+	_, err = kubernetes.FetchExistingHeadlessService(c.ctx, c.client, c.cluster)
+	if err != nil {
+		t.Fatalf("Could not fetch headless service %v", err)
+	}
+
+	configMap, err := kubernetes.FetchExistingConfigMap(c.ctx, c.client, c.cluster)
+	if err != nil {
+		t.Fatalf("Could not fetch configmap %v", err)
+	}
+	if !strings.Contains(configMap.Data["redis.conf"], "cluster-enabled yes\n") {
+		t.Fatalf("Expected the nodes to run with cluster support. Got redis.conf %s", configMap.Data["redis.conf"])
+	}
+	if !metav1.IsControlledBy(configMap, c.cluster) {
+		t.Fatalf("Expected the configmap to be owned by the RedisCluster")
+	}
+
+	for _, pod := range c.getPods() {
+		if len(c.getKnownNodeIDs(pod.Name)) != 6 {
+			t.Fatalf("Expected %s to know all nodes. Got %v", pod.Name, c.getKnownNodeIDs(pod.Name))
+		}
+	}
+}
+
+func TestRedisClusterIntegration_ScalesUp(t *testing.T) {
+	c := newIntegrationCluster(t, cachev1beta1.RedisClusterSpec{
+		Masters:           3,
+		ReplicasPerMaster: 1,
+	})
+	c.reconcileUntil("the cluster to be formed", func() bool {
+		return c.isFormed(3, 6)
+	})
+
+	c.updateSpec(func(spec *cachev1beta1.RedisClusterSpec) {
+		spec.Masters = 4
+	})
+	c.reconcileUntil("the slots to be divided over 4 masters", func() bool {
+		return c.isFormed(4, 8)
+	})
+
+	statefulset := &v1.StatefulSet{}
+	err := c.client.Get(c.ctx, client.ObjectKeyFromObject(c.cluster), statefulset)
+	if err != nil {
+		t.Fatalf("Could not fetch statefulset %v", err)
+	}
+	if *statefulset.Spec.Replicas != 8 {
+		t.Fatalf("Expected the statefulset to be scaled up to 8 pods. Got %d", *statefulset.Spec.Replicas)
+	}
+}
+
+func TestRedisClusterIntegration_RecoversFromPodLoss(t *testing.T) {
+	c := newIntegrationCluster(t, cachev1beta1.RedisClusterSpec{
+		Masters:           3,
+		ReplicasPerMaster: 1,
+	})
+	c.reconcileUntil("the cluster to be formed", func() bool {
+		return c.isFormed(3, 6)
+	})
+	layout := c.getSlotLayout()
+
+	// The master restarts with its data, after its replica took over
+	lost := ""
+	for _, pod := range c.getPods() {
+		if pod.Labels[kubernetes.RedisNodeRoleLabel] == kubernetes.RedisRoleMaster {
+			lost = pod.Name
+			break
+		}
+	}
+	c.losePod(lost, true)
+	c.reconcileUntil("the cluster to recover from the lost pod", func() bool {
+		return c.isFormed(3, 6)
+	})
+
+	newLayout := c.getSlotLayout()
+	if _, ok := newLayout[lost]; ok {
+		t.Fatalf("Expected the replica of %s to have taken over its slots. Got layout %v", lost, newLayout)
+	}
+	for pod, slots := range layout {
+		if pod != lost && newLayout[pod] != slots {
+			t.Fatalf("Expected the other masters to keep their slots. Got layout %v, was %v", newLayout, layout)
+		}
+	}
+}
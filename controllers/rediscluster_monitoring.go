@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reconcileMonitors creates the PodMonitor and ServiceMonitor requested by the cluster, and removes the ones no longer requested.
+// The Prometheus Operator is not a requirement of the Operator, so we only warn when its CRDs are not installed.
+func (r *RedisClusterReconciler) reconcileMonitors(ctx context.Context, redisCluster *cachev1beta1.RedisCluster) error {
+	var podMonitor, serviceMonitor *cachev1beta1.PrometheusMonitor
+	if redisCluster.Spec.Monitoring != nil {
+		podMonitor = redisCluster.Spec.Monitoring.PodMonitor
+		serviceMonitor = redisCluster.Spec.Monitoring.ServiceMonitor
+	}
+	err := r.reconcileMonitor(ctx, redisCluster, kubernetes.PodMonitorGVK, podMonitor)
+	if err != nil {
+		return err
+	}
+	return r.reconcileMonitor(ctx, redisCluster, kubernetes.ServiceMonitorGVK, serviceMonitor)
+}
+
+func (r *RedisClusterReconciler) reconcileMonitor(ctx context.Context, redisCluster *cachev1beta1.RedisCluster, gvk schema.GroupVersionKind, settings *cachev1beta1.PrometheusMonitor) error {
+	installed, err := kubernetes.MonitorCRDInstalled(r.Client, gvk)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		if settings != nil {
+			log.FromContext(ctx).Info("Prometheus Operator CRD is not installed. Not creating monitor.", "kind", gvk.Kind)
+			r.RecordEvent(redisCluster, v12.EventTypeWarning, "MonitoringUnavailable", fmt.Sprintf("Cannot create a %s, as the CRDs of the Prometheus Operator are not installed", gvk.Kind))
+		}
+		return nil
+	}
+	if settings == nil {
+		return kubernetes.DeleteMonitor(ctx, r.Client, redisCluster, gvk)
+	}
+	return kubernetes.EnsureMonitor(ctx, r.Client, redisCluster, gvk, settings)
+}
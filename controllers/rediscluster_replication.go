@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	"github.com/containersolutions/redis-cluster-operator/internal/utils"
+	"github.com/go-redis/redis/v8"
+	appsv1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reconcileReplication runs a single master with replicas, for clients which do not support Redis Cluster.
+// Without Redis Cluster nobody fails over for us, so the Operator takes that role: when the master fails,
+// the most up to date replica is promoted, and the master label, and with it the master Service, moves to its pod.
+func (r *RedisClusterReconciler) reconcileReplication(ctx context.Context, redisCluster *cachev1beta1.RedisCluster, statefulset *appsv1.StatefulSet, pods *v12.PodList) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	_, err := kubernetes.EnsureMasterService(ctx, r.Client, redisCluster)
+	if err != nil {
+		return r.RequeueError(ctx, "Could not ensure master service", err)
+	}
+
+	replicationNodes, err := r.observeReplicationNodes(ctx, redisCluster, statefulset, pods)
+	if err != nil {
+		return r.RequeueError(ctx, "Could not load Redis nodes", err)
+	}
+	if len(replicationNodes.Nodes) == 0 {
+		logger.Info("No pods are ready. Reconciling again in 10 seconds")
+		return ctrl.Result{
+			RequeueAfter: 10 * time.Second,
+		}, nil
+	}
+
+	currentMaster := ""
+	for _, pod := range pods.Items {
+		if pod.Labels[kubernetes.RedisNodeRoleLabel] == kubernetes.RedisRoleMaster {
+			currentMaster = pod.Name
+		}
+	}
+	master := replicationNodes.ElectMaster(currentMaster)
+	masterPod := master.PodDetails
+
+	//region Promote master
+	if !master.IsMaster() {
+		logger.Info("Promoting replica to master", "pod", masterPod.Name, "previousMaster", currentMaster)
+		err = master.PromoteToMaster(ctx)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not promote replica to master", err)
+		}
+		r.RecordEvent(redisCluster, v12.EventTypeWarning, "Failover", fmt.Sprintf("The master is not available. Promoted replica %s to master", masterPod.Name))
+	} else if masterPod.Name != currentMaster {
+		r.RecordEvent(redisCluster, v12.EventTypeNormal, "MasterElected", fmt.Sprintf("Elected %s as master", masterPod.Name))
+	}
+	//endregion
+
+	//region Label pods
+	// The previous master loses its label first, so the master Service never selects two masters
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Name == masterPod.Name {
+			continue
+		}
+		err = kubernetes.SetPodRole(ctx, r.Client, pod, kubernetes.RedisRoleReplica)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not label replica pod", err)
+		}
+	}
+	err = kubernetes.SetPodRole(ctx, r.Client, masterPod, kubernetes.RedisRoleMaster)
+	if err != nil {
+		return r.RequeueError(ctx, "Could not label master pod", err)
+	}
+	//endregion
+
+	//region Attach replicas
+	masterHost := kubernetes.GetRedisPodHost(redisCluster, statefulset, masterPod)
+	for _, node := range replicationNodes.Nodes {
+		if node == master || node.IsReplicaOf(masterHost) {
+			continue
+		}
+		logger.Info("Attaching replica to master", "pod", node.PodDetails.Name, "master", masterPod.Name)
+		err = node.ReplicateFrom(ctx, masterHost)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not attach replica to master", err)
+		}
+	}
+	//endregion
+
+	//region Set pod readiness
+	// Replicas which were just attached are not in sync yet, and are marked ready by a following reconcile
+	for _, node := range replicationNodes.Nodes {
+		ready, message, err := node.GetReadiness(ctx, false)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not check the readiness of node", err)
+		}
+		err = kubernetes.SetPodNodeReadiness(ctx, r.Client, node.PodDetails, ready, message)
+		if err != nil {
+			return r.RequeueError(ctx, "Could not set the readiness of pod", err)
+		}
+	}
+	//endregion
+
+	if len(replicationNodes.Nodes) != int(redisCluster.NodesNeeded()) {
+		logger.Info("Not all pods are ready. Reconciling again in 10 seconds")
+		return ctrl.Result{
+			RequeueAfter: 10 * time.Second,
+		}, nil
+	}
+	return ctrl.Result{
+		RequeueAfter: 30 * time.Second,
+	}, nil
+}
+
+// observeReplicationNodes connects to the Redis nodes in all ready pods of a cluster in Replication mode
+func (r *RedisClusterReconciler) observeReplicationNodes(ctx context.Context, redisCluster *cachev1beta1.RedisCluster, statefulset *appsv1.StatefulSet, pods *v12.PodList) (*redis_internal.ReplicationNodes, error) {
+	replicationNodes := &redis_internal.ReplicationNodes{}
+	clientBuilder, err := redisClientBuilder(ctx, r.Client, redisCluster, r.NewRedisClient)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !utils.IsPodContainersReady(pod) {
+			continue
+		}
+		node, err := redis_internal.NewReplicationNode(ctx, &redis.Options{
+			Addr: kubernetes.GetRedisPodHost(redisCluster, statefulset, pod) + ":6379",
+		}, pod, clientBuilder)
+		if err != nil {
+			replicationNodes.Close()
+			return nil, err
+		}
+		replicationNodes.Nodes = append(replicationNodes.Nodes, node)
+	}
+	return replicationNodes, nil
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	kubernetes_fake "github.com/containersolutions/redis-cluster-operator/internal/kubernetes/fake"
+	redis_fake "github.com/containersolutions/redis-cluster-operator/internal/redis/fake"
+	"github.com/go-redis/redis/v8"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+func TestRedisClusterReconciler_Reconcile_CreatesMasterServiceInReplicationMode(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+
+	clientBuilder := fake.NewClientBuilder()
+	clientBuilder.WithObjects(&cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Mode:              cachev1beta1.ModeReplication,
+			ReplicasPerMaster: 2,
+		},
+	})
+	client := kubernetes_fake.NewApplyAsMergePatchClient(clientBuilder.Build())
+	r := &RedisClusterReconciler{
+		Client: client,
+		Scheme: s,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+
+	// None of the pods are ready, so the reconciler stops before connecting to Redis
+	for i := 0; i < 8; i++ {
+		_, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	sts := &v1.StatefulSet{}
+	err := client.Get(context.TODO(), types.NamespacedName{
+		Name:      "redis-cluster",
+		Namespace: "default",
+	}, sts)
+	if err != nil {
+		t.Fatalf("Failed to fetch created Statefulset %v", err)
+	}
+	if *sts.Spec.Replicas != 3 {
+		t.Fatalf("Expected a master and 2 replicas. Got %d replicas", *sts.Spec.Replicas)
+	}
+
+	service := &v12.Service{}
+	err = client.Get(context.TODO(), types.NamespacedName{
+		Name:      "redis-cluster-master",
+		Namespace: "default",
+	}, service)
+	if err != nil {
+		t.Fatalf("Failed to fetch master Service %v", err)
+	}
+	if service.Spec.Selector[kubernetes.RedisNodeRoleLabel] != kubernetes.RedisRoleMaster {
+		t.Fatalf("Expected master Service to select the master pod. Got selector %v", service.Spec.Selector)
+	}
+}
+
+func TestRedisClusterReconciler_Reconcile_FailsOverReplicationOnFakeNodes(t *testing.T) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Mode:              cachev1beta1.ModeReplication,
+			ReplicasPerMaster: 2,
+		},
+	}
+	clientBuilder := fake.NewClientBuilder()
+	clientBuilder.WithObjects(redisCluster)
+	client := kubernetes_fake.NewApplyAsMergePatchClient(clientBuilder.Build())
+	nodes := redis_fake.NewReplication()
+	r := &RedisClusterReconciler{
+		Client:         client,
+		Scheme:         s,
+		NewRedisClient: nodes.ClientBuilder(),
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	reconcileOnce := func() {
+		_, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		reconcileOnce()
+	}
+
+	// The fake client does not run the statefulset controller, so we start the pods and their Redis nodes ourselves
+	for i := 0; i < 3; i++ {
+		pod := &v12.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("redis-cluster-%d", i),
+				Namespace: "default",
+				Labels:    kubernetes.GetPodLabels(redisCluster),
+			},
+			Status: v12.PodStatus{
+				PodIP: fmt.Sprintf("10.0.0.%d", i+1),
+				Conditions: []v12.PodCondition{
+					{Type: v12.ContainersReady, Status: v12.ConditionTrue},
+				},
+			},
+		}
+		err := client.Create(context.TODO(), pod)
+		if err != nil {
+			t.Fatalf("Failed to create pod %v", err)
+		}
+		nodes.AddNode(pod.Status.PodIP, kubernetes.GetPodHostname(redisCluster, pod.Name))
+	}
+	getMasterPod := func() *v12.Pod {
+		pods, err := kubernetes.FetchRedisPods(context.TODO(), client, redisCluster)
+		if err != nil {
+			t.Fatalf("Failed to fetch pods %v", err)
+		}
+		var master *v12.Pod
+		for i := range pods.Items {
+			if pods.Items[i].Labels[kubernetes.RedisNodeRoleLabel] == kubernetes.RedisRoleMaster {
+				if master != nil {
+					t.Fatalf("Expected a single master pod. Got %s and %s", master.Name, pods.Items[i].Name)
+				}
+				master = &pods.Items[i]
+			}
+		}
+		if master == nil {
+			t.Fatalf("Expected a master pod")
+		}
+		return master
+	}
+
+	reconcileOnce()
+	master := getMasterPod()
+	redisClient := nodes.ClientBuilder()(&redis.Options{
+		Addr: kubernetes.GetPodHostname(redisCluster, master.Name) + ":6379",
+	})
+	defer redisClient.Close()
+	err := redisClient.Set(context.TODO(), "key", "value", 0).Err()
+	if err != nil {
+		t.Fatalf("Could not write to master %v", err)
+	}
+
+	// The master goes away, and the Operator promotes one of the replicas which hold the key
+	nodes.Stop(master.Status.PodIP)
+	master.Status.Conditions = nil
+	err = client.Status().Update(context.TODO(), master)
+	if err != nil {
+		t.Fatalf("Failed to update pod status %v", err)
+	}
+	reconcileOnce()
+
+	newMaster := getMasterPod()
+	if newMaster.Name == master.Name {
+		t.Fatalf("Expected a replica to be promoted after %s stopped", master.Name)
+	}
+	// The other replica follows the new master
+	newMasterClient := nodes.ClientBuilder()(&redis.Options{
+		Addr: kubernetes.GetPodHostname(redisCluster, newMaster.Name) + ":6379",
+	})
+	defer newMasterClient.Close()
+	err = newMasterClient.Set(context.TODO(), "other-key", "other-value", 0).Err()
+	if err != nil {
+		t.Fatalf("Could not write to new master %v", err)
+	}
+	for _, pod := range []string{"redis-cluster-0", "redis-cluster-1", "redis-cluster-2"} {
+		if pod == master.Name {
+			continue
+		}
+		redisClient := nodes.ClientBuilder()(&redis.Options{
+			Addr: kubernetes.GetPodHostname(redisCluster, pod) + ":6379",
+		})
+		for key, expected := range map[string]string{"key": "value", "other-key": "other-value"} {
+			value, err := redisClient.Get(context.TODO(), key).Result()
+			if err != nil || value != expected {
+				t.Fatalf("Expected %s to hold %s, written before and after the failover. Got %q %v", pod, key, value, err)
+			}
+		}
+		_ = redisClient.Close()
+	}
+}
+
+func TestRedisClusterReconciler_Reconcile_KeepsDataWhenMasterRestartsEmptyOnFakeNodes(t *testing.T) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Mode:              cachev1beta1.ModeReplication,
+			ReplicasPerMaster: 2,
+		},
+	}
+	clientBuilder := fake.NewClientBuilder()
+	clientBuilder.WithObjects(redisCluster)
+	client := kubernetes_fake.NewApplyAsMergePatchClient(clientBuilder.Build())
+	nodes := redis_fake.NewReplication()
+	r := &RedisClusterReconciler{
+		Client:         client,
+		Scheme:         s,
+		NewRedisClient: nodes.ClientBuilder(),
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	reconcileOnce := func() {
+		_, err := r.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		reconcileOnce()
+	}
+
+	// The fake client does not run the statefulset controller, so we start the pods and their Redis nodes ourselves
+	for i := 0; i < 3; i++ {
+		pod := &v12.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("redis-cluster-%d", i),
+				Namespace: "default",
+				Labels:    kubernetes.GetPodLabels(redisCluster),
+			},
+			Status: v12.PodStatus{
+				PodIP: fmt.Sprintf("10.0.0.%d", i+1),
+				Conditions: []v12.PodCondition{
+					{Type: v12.ContainersReady, Status: v12.ConditionTrue},
+				},
+			},
+		}
+		err := client.Create(context.TODO(), pod)
+		if err != nil {
+			t.Fatalf("Failed to create pod %v", err)
+		}
+		nodes.AddNode(pod.Status.PodIP, kubernetes.GetPodHostname(redisCluster, pod.Name))
+	}
+	getMasterPod := func() *v12.Pod {
+		pods, err := kubernetes.FetchRedisPods(context.TODO(), client, redisCluster)
+		if err != nil {
+			t.Fatalf("Failed to fetch pods %v", err)
+		}
+		for i := range pods.Items {
+			if pods.Items[i].Labels[kubernetes.RedisNodeRoleLabel] == kubernetes.RedisRoleMaster {
+				return &pods.Items[i]
+			}
+		}
+		t.Fatalf("Expected a master pod")
+		return nil
+	}
+
+	reconcileOnce()
+	master := getMasterPod()
+	redisClient := nodes.ClientBuilder()(&redis.Options{
+		Addr: kubernetes.GetPodHostname(redisCluster, master.Name) + ":6379",
+	})
+	err := redisClient.Set(context.TODO(), "key", "value", 0).Err()
+	_ = redisClient.Close()
+	if err != nil {
+		t.Fatalf("Could not write to master %v", err)
+	}
+
+	// The master restarts without storage before the Operator notices, and comes back ready without its data
+	nodes.Replace(master.Status.PodIP)
+	reconcileOnce()
+	reconcileOnce()
+
+	newMaster := getMasterPod()
+	if newMaster.Name == master.Name {
+		t.Fatalf("Expected a replica holding the data to be promoted over the empty master %s", master.Name)
+	}
+	for _, pod := range []string{"redis-cluster-0", "redis-cluster-1", "redis-cluster-2"} {
+		redisClient := nodes.ClientBuilder()(&redis.Options{
+			Addr: kubernetes.GetPodHostname(redisCluster, pod) + ":6379",
+		})
+		value, err := redisClient.Get(context.TODO(), "key").Result()
+		_ = redisClient.Close()
+		if err != nil || value != "value" {
+			t.Fatalf("Expected %s to hold the key written before the master restarted. Got %q %v", pod, value, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"time"
+)
+
+// TopologyPath is the path the topology API is served on, next to the metrics of the manager
+const TopologyPath = "/topology"
+
+// topologyTimeout limits how long a request to the topology API waits for the Redis nodes to answer
+const topologyTimeout = 10 * time.Second
+
+// ReconcileOutcome describes how the last reconcile of a RedisCluster ended
+type ReconcileOutcome struct {
+	Time         metav1.Time `json:"time"`
+	Succeeded    bool        `json:"succeeded"`
+	Error        string      `json:"error,omitempty"`
+	RequeueAfter string      `json:"requeueAfter,omitempty"`
+}
+
+// ClusterTopology is the state of a RedisCluster as seen by its Redis nodes
+type ClusterTopology struct {
+	Namespace     string            `json:"namespace"`
+	Name          string            `json:"name"`
+	Mode          string            `json:"mode"`
+	Paused        bool              `json:"paused"`
+	Nodes         []NodeTopology    `json:"nodes"`
+	LastReconcile *ReconcileOutcome `json:"lastReconcile,omitempty"`
+	// Error is set when the Redis nodes could not be observed. The nodes are left out then.
+	Error string `json:"error,omitempty"`
+}
+
+// NodeTopology is the state of a single Redis node
+type NodeTopology struct {
+	ID                string           `json:"id"`
+	Pod               string           `json:"pod"`
+	Role              string           `json:"role"`
+	MasterID          string           `json:"masterId,omitempty"`
+	MasterHost        string           `json:"masterHost,omitempty"`
+	MasterLinkUp      bool             `json:"masterLinkUp,omitempty"`
+	Slots             []string         `json:"slots,omitempty"`
+	SlotCount         int              `json:"slotCount"`
+	ReplicationOffset int64            `json:"replicationOffset"`
+	MigratingSlots    map[int32]string `json:"migratingSlots,omitempty"`
+	ImportingSlots    map[int32]string `json:"importingSlots,omitempty"`
+	Error             string           `json:"error,omitempty"`
+}
+
+func (r *RedisClusterReconciler) recordOutcome(name types.NamespacedName, result ctrl.Result, err error) {
+	outcome := ReconcileOutcome{
+		Time:      metav1.Now(),
+		Succeeded: err == nil,
+	}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	if result.RequeueAfter > 0 {
+		outcome.RequeueAfter = result.RequeueAfter.String()
+	}
+	r.outcomes.Store(name, outcome)
+}
+
+// getOutcome returns the outcome of the last reconcile of the RedisCluster, or nil if this manager has not reconciled it
+func (r *RedisClusterReconciler) getOutcome(name types.NamespacedName) *ReconcileOutcome {
+	outcome, ok := r.outcomes.Load(name)
+	if !ok {
+		return nil
+	}
+	result := outcome.(ReconcileOutcome)
+	return &result
+}
+
+// TopologyHandler returns the read-only API which lists the nodes of the managed RedisClusters as JSON.
+// The namespace and name query parameters limit the list to the clusters in a namespace, or a single cluster.
+// The handler only reads from Kubernetes and Redis, so it is safe to serve to dashboards.
+func (r *RedisClusterReconciler) TopologyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx, cancel := context.WithTimeout(req.Context(), topologyTimeout)
+		defer cancel()
+		logger := log.FromContext(ctx).WithName("topology")
+
+		namespace := req.URL.Query().Get("namespace")
+		name := req.URL.Query().Get("name")
+		var clusters []cachev1beta1.RedisCluster
+		if name != "" {
+			if namespace == "" {
+				http.Error(w, "the name parameter needs the namespace parameter", http.StatusBadRequest)
+				return
+			}
+			cluster := cachev1beta1.RedisCluster{}
+			err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &cluster)
+			if client.IgnoreNotFound(err) != nil {
+				logger.Error(err, "Could not fetch RedisCluster")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err != nil || !r.InScope(namespace) {
+				http.Error(w, "RedisCluster not found", http.StatusNotFound)
+				return
+			}
+			clusters = append(clusters, cluster)
+		} else {
+			list := &cachev1beta1.RedisClusterList{}
+			err := r.Client.List(ctx, list, client.InNamespace(namespace))
+			if err != nil {
+				logger.Error(err, "Could not list RedisClusters")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, cluster := range list.Items {
+				if r.InScope(cluster.Namespace) {
+					clusters = append(clusters, cluster)
+				}
+			}
+		}
+
+		topologies := []ClusterTopology{}
+		for i := range clusters {
+			topologies = append(topologies, r.getClusterTopology(ctx, &clusters[i]))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(topologies)
+		if err != nil {
+			logger.Error(err, "Could not write topology")
+		}
+	})
+}
+
+// getClusterTopology observes the Redis nodes of the cluster. Errors are reported in the topology instead of failing the request,
+// so a single broken cluster does not hide the other clusters.
+func (r *RedisClusterReconciler) getClusterTopology(ctx context.Context, redisCluster *cachev1beta1.RedisCluster) ClusterTopology {
+	topology := ClusterTopology{
+		Namespace:     redisCluster.Namespace,
+		Name:          redisCluster.Name,
+		Mode:          string(cachev1beta1.ModeCluster),
+		Paused:        redisCluster.IsPaused(),
+		Nodes:         []NodeTopology{},
+		LastReconcile: r.getOutcome(client.ObjectKeyFromObject(redisCluster)),
+	}
+	if redisCluster.IsReplicationMode() {
+		topology.Mode = string(cachev1beta1.ModeReplication)
+	}
+	clusterNodes, err := r.observeClusterNodes(ctx, redisCluster)
+	if err != nil {
+		topology.Error = err.Error()
+		return topology
+	}
+	// Every request connects to the nodes again, so the connections must not outlive it
+	defer clusterNodes.Close()
+	for _, node := range clusterNodes.Nodes {
+		topology.Nodes = append(topology.Nodes, getNodeTopology(ctx, node))
+	}
+	return topology
+}
+
+func getNodeTopology(ctx context.Context, node *redis_internal.Node) NodeTopology {
+	slots := node.NodeAttributes.GetSlots()
+	topology := NodeTopology{
+		ID:             node.NodeAttributes.ID,
+		Pod:            node.PodDetails.Name,
+		Role:           kubernetes.RedisRoleReplica,
+		Slots:          redis_internal.FormatSlotRanges(slots),
+		SlotCount:      len(slots),
+		MigratingSlots: node.NodeAttributes.GetMigratingSlots(),
+		ImportingSlots: node.NodeAttributes.GetImportingSlots(),
+	}
+	if node.IsMaster() {
+		topology.Role = kubernetes.RedisRoleMaster
+	} else if node.NodeAttributes.GetMasterID() != "-" {
+		topology.MasterID = node.NodeAttributes.GetMasterID()
+	}
+	replication, err := node.GetReplicationInfo(ctx)
+	if err != nil {
+		topology.Error = err.Error()
+		return topology
+	}
+	topology.ReplicationOffset = replication.Offset
+	topology.MasterHost = replication.MasterHost
+	topology.MasterLinkUp = replication.MasterLinkUp
+	return topology
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	redis_fake "github.com/containersolutions/redis-cluster-operator/internal/redis/fake"
+	"github.com/go-redis/redis/v8"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestRedisClusterReconciler_TopologyHandlerListsClustersInScope(t *testing.T) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	clusters := []*cachev1beta1.RedisCluster{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "redis-cluster",
+				Namespace: "default",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "redis-replication",
+				Namespace: "default",
+			},
+			Spec: cachev1beta1.RedisClusterSpec{
+				Mode: cachev1beta1.ModeReplication,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "redis-cluster",
+				Namespace: "other",
+			},
+		},
+	}
+	clientBuilder := fake.NewClientBuilder().WithScheme(s)
+	for _, cluster := range clusters {
+		clientBuilder.WithObjects(cluster)
+	}
+	r := &RedisClusterReconciler{
+		Client:          clientBuilder.Build(),
+		Scheme:          s,
+		WatchNamespaces: []string{"default"},
+	}
+	r.recordOutcome(types.NamespacedName{Namespace: "default", Name: "redis-cluster"}, ctrl.Result{RequeueAfter: 10 * time.Second}, errors.New("could not load Redis nodes"))
+
+	recorder := httptest.NewRecorder()
+	r.TopologyHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, TopologyPath, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var topologies []ClusterTopology
+	err := json.Unmarshal(recorder.Body.Bytes(), &topologies)
+	if err != nil {
+		t.Fatalf("Could not decode topology %v", err)
+	}
+	if len(topologies) != 2 {
+		t.Fatalf("Expected only the 2 clusters in the watched namespace. Got %v", topologies)
+	}
+	for _, topology := range topologies {
+		switch topology.Name {
+		case "redis-cluster":
+			if topology.Mode != string(cachev1beta1.ModeCluster) {
+				t.Fatalf("Expected redis-cluster to run in Cluster mode. Got %s", topology.Mode)
+			}
+			if topology.LastReconcile == nil || topology.LastReconcile.Succeeded || topology.LastReconcile.Error != "could not load Redis nodes" {
+				t.Fatalf("Expected the failed reconcile of redis-cluster to be reported. Got %v", topology.LastReconcile)
+			}
+		case "redis-replication":
+			if topology.Mode != string(cachev1beta1.ModeReplication) {
+				t.Fatalf("Expected redis-replication to run in Replication mode. Got %s", topology.Mode)
+			}
+			if topology.LastReconcile != nil {
+				t.Fatalf("Expected no reconcile outcome for redis-replication. Got %v", topology.LastReconcile)
+			}
+		}
+		if topology.Error != "" || len(topology.Nodes) != 0 {
+			t.Fatalf("Expected no nodes for a cluster without statefulset. Got %v", topology)
+		}
+	}
+
+	// Clusters outside of the watched namespaces can't be fetched by name either
+	recorder = httptest.NewRecorder()
+	r.TopologyHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, TopologyPath+"?namespace=other&name=redis-cluster", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for a cluster outside of the watched namespaces. Got %d", recorder.Code)
+	}
+}
+
+func TestRedisClusterReconciler_TopologyHandlerClosesTheRedisClients(t *testing.T) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	redisCluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+		},
+	}
+	clientBuilder := fake.NewClientBuilder().WithScheme(s).WithObjects(redisCluster, &v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	})
+	nodes := redis_fake.NewCluster()
+	for i := 0; i < 3; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i+1)
+		nodes.AddNode(ip)
+		clientBuilder.WithObjects(&v12.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("redis-cluster-%d", i),
+				Namespace: "default",
+				Labels:    kubernetes.GetPodLabels(redisCluster),
+			},
+			Status: v12.PodStatus{
+				PodIP: ip,
+				Conditions: []v12.PodCondition{
+					{Type: v12.ContainersReady, Status: v12.ConditionTrue},
+				},
+			},
+		})
+	}
+	nodes.Form(3)
+	var redisClients []*redis.Client
+	r := &RedisClusterReconciler{
+		Client: clientBuilder.Build(),
+		Scheme: s,
+		NewRedisClient: func(opt *redis.Options) *redis.Client {
+			redisClient := nodes.ClientBuilder()(opt)
+			redisClients = append(redisClients, redisClient)
+			return redisClient
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	r.TopologyHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, TopologyPath, nil))
+	var topologies []ClusterTopology
+	err := json.Unmarshal(recorder.Body.Bytes(), &topologies)
+	if err != nil {
+		t.Fatalf("Could not decode topology %v", err)
+	}
+	if len(topologies) != 1 || len(topologies[0].Nodes) != 3 {
+		t.Fatalf("Expected the 3 nodes of the cluster. Got %v", topologies)
+	}
+	if len(redisClients) == 0 {
+		t.Fatalf("Expected the topology to connect to the Redis nodes")
+	}
+	for _, redisClient := range redisClients {
+		if redisClient.Ping(context.TODO()).Err() != redis.ErrClosed {
+			t.Fatalf("Expected every Redis client to be closed once the request is served")
+		}
+	}
+}
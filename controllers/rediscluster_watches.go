@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	"github.com/containersolutions/redis-cluster-operator/internal/utils"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// mapPodToRedisCluster finds the RedisCluster a Redis pod belongs to.
+// Pods are owned by the Statefulset and not by the RedisCluster, so we use the labels from GetPodLabels instead of owner references.
+func mapPodToRedisCluster(obj client.Object) []reconcile.Request {
+	podLabels := obj.GetLabels()
+	clusterName, ok := podLabels[kubernetes.RedisNodeNameStatefulsetLabel]
+	if !ok || podLabels[kubernetes.RedisNodeComponentLabel] != "redis" {
+		return nil
+	}
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Namespace: obj.GetNamespace(),
+				Name:      clusterName,
+			},
+		},
+	}
+}
+
+// redisPodPredicate filters out pod events which don't matter for the state of the Redis cluster.
+// Pods get updated often, for example when their status conditions are probed, but we only care about
+// pods appearing, disappearing, becoming (un)ready or getting a new IP.
+func redisPodPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPod, ok := e.ObjectOld.(*v12.Pod)
+			if !ok {
+				return false
+			}
+			newPod, ok := e.ObjectNew.(*v12.Pod)
+			if !ok {
+				return false
+			}
+			return podStateChanged(oldPod, newPod)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+func podStateChanged(oldPod *v12.Pod, newPod *v12.Pod) bool {
+	if utils.IsPodReady(oldPod) != utils.IsPodReady(newPod) {
+		return true
+	}
+	// The Operator connects to pods once their containers are ready, before their readiness gate is set
+	if utils.IsPodContainersReady(oldPod) != utils.IsPodContainersReady(newPod) {
+		return true
+	}
+	if oldPod.Status.PodIP != newPod.Status.PodIP || oldPod.Status.Phase != newPod.Status.Phase {
+		return true
+	}
+	// A pod being deleted stops serving before it is actually removed
+	return (oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil)
+}
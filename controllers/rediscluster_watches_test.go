@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"testing"
+)
+
+func TestMapPodToRedisCluster(t *testing.T) {
+	requests := mapPodToRedisCluster(&v12.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster-0",
+			Namespace: "default",
+			Labels: map[string]string{
+				kubernetes.RedisNodeNameStatefulsetLabel: "redis-cluster",
+				kubernetes.RedisNodeComponentLabel:       "redis",
+			},
+		},
+	})
+	if len(requests) != 1 || requests[0].Name != "redis-cluster" || requests[0].Namespace != "default" {
+		t.Fatalf("Expected pod to be mapped to its RedisCluster. Got %v", requests)
+	}
+
+	requests = mapPodToRedisCluster(&v12.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "some-other-pod",
+			Namespace: "default",
+		},
+	})
+	if len(requests) != 0 {
+		t.Fatalf("Expected pods without Redis labels to be ignored. Got %v", requests)
+	}
+}
+
+func TestRedisPodPredicate_Update(t *testing.T) {
+	readyPod := func(ready v12.ConditionStatus, ip string) *v12.Pod {
+		return &v12.Pod{
+			Status: v12.PodStatus{
+				Phase: v12.PodRunning,
+				PodIP: ip,
+				Conditions: []v12.PodCondition{
+					{
+						Type:   v12.PodReady,
+						Status: ready,
+					},
+				},
+			},
+		}
+	}
+	now := metav1.Now()
+	deletingPod := readyPod(v12.ConditionTrue, "10.0.0.1")
+	deletingPod.DeletionTimestamp = &now
+
+	testMap := map[string]struct {
+		oldPod   *v12.Pod
+		newPod   *v12.Pod
+		expected bool
+	}{
+		"Unchanged": {
+			oldPod:   readyPod(v12.ConditionTrue, "10.0.0.1"),
+			newPod:   readyPod(v12.ConditionTrue, "10.0.0.1"),
+			expected: false,
+		},
+		"BecameNotReady": {
+			oldPod:   readyPod(v12.ConditionTrue, "10.0.0.1"),
+			newPod:   readyPod(v12.ConditionFalse, "10.0.0.1"),
+			expected: true,
+		},
+		"IPChanged": {
+			oldPod:   readyPod(v12.ConditionTrue, "10.0.0.1"),
+			newPod:   readyPod(v12.ConditionTrue, "10.0.0.2"),
+			expected: true,
+		},
+		"Deleting": {
+			oldPod:   readyPod(v12.ConditionTrue, "10.0.0.1"),
+			newPod:   deletingPod,
+			expected: true,
+		},
+	}
+	for name, test := range testMap {
+		t.Run(name, func(t *testing.T) {
+			result := redisPodPredicate().Update(event.UpdateEvent{
+				ObjectOld: test.oldPod,
+				ObjectNew: test.newPod,
+			})
+			if result != test.expected {
+				t.Fatalf("Expected update to be processed: %v. Got %v", test.expected, result)
+			}
+		})
+	}
+}
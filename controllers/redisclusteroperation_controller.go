@@ -0,0 +1,379 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/kubernetes"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
+	redis_internal "github.com/containersolutions/redis-cluster-operator/internal/redis"
+	"github.com/containersolutions/redis-cluster-operator/internal/utils"
+	"github.com/go-redis/redis/v8"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// RedisClusterOperationReconciler executes RedisClusterOperations
+type RedisClusterOperationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// WatchNamespaces limits the namespaces the reconciler executes operations in. Empty means all namespaces.
+	WatchNamespaces []string
+	// FailoverTimeout is how long a failover may take before the operation fails. Defaults to 30 seconds.
+	FailoverTimeout time.Duration
+	// NewRedisClient creates the clients of the Redis nodes. Defaults to redis.NewClient.
+	NewRedisClient func(opt *redis.Options) *redis.Client
+}
+
+//+kubebuilder:rbac:groups=cache.container-solutions.com,resources=redisclusteroperations,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cache.container-solutions.com,resources=redisclusteroperations/status,verbs=get;update;patch
+
+// Reconcile executes an operation once. Operations wait in the Pending phase until the cluster is ready for them,
+// and are never executed again once they succeeded or failed, so they remain as a record of what happened to the cluster.
+func (r *RedisClusterOperationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if !namespaceInScope(r.WatchNamespaces, req.Namespace) {
+		logger.Info("RedisClusterOperation is not in a namespace watched by the operator. Ignoring.")
+		return ctrl.Result{}, nil
+	}
+
+	operation := &cachev1beta1.RedisClusterOperation{}
+	err := r.Client.Get(ctx, req.NamespacedName, operation)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return r.RequeueError(ctx, "could not fetch operation", err)
+	}
+	if operation.IsFinished() {
+		return ctrl.Result{}, nil
+	}
+	if operation.Status.Phase == cachev1beta1.OperationRunning {
+		// The Operator restarted while executing the operation. We don't know how far it got,
+		// and starting over could do more harm than good, so we leave it to the user to check the cluster.
+		return r.finish(ctx, operation, "", fmt.Errorf("the operation was interrupted by a restart of the Operator. Check the state of the cluster before creating a new operation"))
+	}
+	err = operation.ValidateParameters()
+	if err != nil {
+		return r.finish(ctx, operation, "", err)
+	}
+
+	logger.Info("Reconciling RedisClusterOperation", "operation", req.Name, "cluster", operation.Spec.Cluster, "type", operation.Spec.Type)
+
+	//region Wait For Cluster
+	cluster := &cachev1beta1.RedisCluster{}
+	err = r.Client.Get(ctx, types.NamespacedName{Namespace: operation.Namespace, Name: operation.Spec.Cluster}, cluster)
+	if errors.IsNotFound(err) {
+		return r.finish(ctx, operation, "", fmt.Errorf("RedisCluster %s does not exist", operation.Spec.Cluster))
+	}
+	if err != nil {
+		return r.RequeueError(ctx, "could not fetch cluster", err)
+	}
+	if cluster.IsReplicationMode() {
+		return r.finish(ctx, operation, "", fmt.Errorf("RedisCluster %s runs in Replication mode, which has no slots or cluster nodes to operate on. The Operator fails over by itself in Replication mode", cluster.Name))
+	}
+	if !cluster.DeletionTimestamp.IsZero() {
+		return r.finish(ctx, operation, "", fmt.Errorf("RedisCluster %s is being deleted", cluster.Name))
+	}
+	if cluster.IsPaused() {
+		// The Operator promises not to touch paused clusters, and operations go through the Operator
+		return r.pending(ctx, operation, "Waiting for the cluster to be resumed")
+	}
+
+	clusterNodes, allReady, err := r.loadClusterNodes(ctx, cluster)
+	if err != nil {
+		return r.RequeueError(ctx, "could not load Redis nodes", err)
+	}
+	if !allReady {
+		return r.pending(ctx, operation, "Waiting for all Redis nodes to be ready")
+	}
+	clusterCheck, err := clusterNodes.CheckCluster(ctx)
+	if err != nil {
+		return r.RequeueError(ctx, "could not check cluster consistency", err)
+	}
+	if !clusterCheck.Consistent() || clusterCheck.HasIssue(redis_internal.OpenSlot) {
+		// Same as for rebalancing, acting on a cluster the nodes disagree about could lose data
+		return r.pending(ctx, operation, "Waiting for the nodes to agree on the state of the cluster")
+	}
+	//endregion
+
+	//region Execute Operation
+	err = r.updateOperationStatus(ctx, operation, func(status *cachev1beta1.RedisClusterOperationStatus) {
+		now := metav1.Now()
+		status.Phase = cachev1beta1.OperationRunning
+		status.StartTime = &now
+		status.Message = fmt.Sprintf("Executing %s operation", operation.Spec.Type)
+	})
+	if err != nil {
+		return r.RequeueError(ctx, "could not update operation status", err)
+	}
+	r.RecordEvent(operation, v12.EventTypeNormal, "Started", fmt.Sprintf("Executing %s operation on RedisCluster %s", operation.Spec.Type, cluster.Name))
+
+	message, err := r.execute(ctx, operation, cluster, clusterNodes)
+	return r.finish(ctx, operation, message, err)
+	//endregion
+}
+
+// execute runs the operation against the Redis nodes, and returns a message describing the outcome
+func (r *RedisClusterOperationReconciler) execute(ctx context.Context, operation *cachev1beta1.RedisClusterOperation, cluster *cachev1beta1.RedisCluster, clusterNodes *redis_internal.ClusterNodes) (string, error) {
+	spec := operation.Spec
+	switch spec.Type {
+	case cachev1beta1.OperationFailover:
+		node := clusterNodes.GetNodeForPod(spec.Failover.Pod)
+		if node == nil {
+			return "", fmt.Errorf("pod %s does not run a node of the cluster", spec.Failover.Pod)
+		}
+		if node.IsMaster() {
+			return "", fmt.Errorf("pod %s already runs a master. Fail over to one of its replicas instead", spec.Failover.Pod)
+		}
+		err := node.ClusterFailover(ctx).Err()
+		if err != nil {
+			return "", err
+		}
+		// The replica only takes over once it has caught up with its master, so we wait to see whether it actually did
+		err = wait.PollImmediate(time.Second, r.failoverTimeout(), func() (bool, error) {
+			err := node.ReloadNodeInfo(ctx)
+			return err == nil && node.IsMaster(), nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("pod %s did not take over from its master within %s", spec.Failover.Pod, r.failoverTimeout())
+		}
+		return fmt.Sprintf("Pod %s is now a master", spec.Failover.Pod), nil
+
+	case cachev1beta1.OperationMoveSlots:
+		slots, err := planner.ParseSlotRanges(spec.MoveSlots.Slots)
+		if err != nil {
+			return "", err
+		}
+		destination := clusterNodes.GetNodeForPod(spec.MoveSlots.Destination)
+		if destination == nil {
+			return "", fmt.Errorf("pod %s does not run a node of the cluster", spec.MoveSlots.Destination)
+		}
+		plan, err := clusterNodes.PlanSlotMoves(slots, destination)
+		if err != nil {
+			return "", err
+		}
+		err = clusterNodes.ApplyRebalancePlan(ctx, plan)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Moved %d slots to pod %s", plan.SlotCount(), spec.MoveSlots.Destination), nil
+
+	case cachev1beta1.OperationForgetNode:
+		if node := clusterNodes.GetNodeByID(spec.ForgetNode.NodeID); node != nil {
+			return "", fmt.Errorf("node %s runs in pod %s. Only nodes which no longer run in any pod can be forgotten", spec.ForgetNode.NodeID, node.PodDetails.Name)
+		}
+		commandingNode, err := clusterNodes.GetCommandingNode(ctx)
+		if err != nil {
+			return "", err
+		}
+		friends, err := commandingNode.GetFriends(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, friend := range friends {
+			if friend.NodeAttributes.ID != spec.ForgetNode.NodeID {
+				continue
+			}
+			err = clusterNodes.ForgetNode(ctx, friend)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Forgot node %s", spec.ForgetNode.NodeID), nil
+		}
+		return "", fmt.Errorf("node %s is not part of the cluster", spec.ForgetNode.NodeID)
+
+	case cachev1beta1.OperationRebalance:
+		plan, err := clusterNodes.PlanRebalance(ctx, cluster)
+		if err != nil {
+			return "", err
+		}
+		if len(plan.Moves) == 0 {
+			return "The cluster is already balanced", nil
+		}
+		err = clusterNodes.ApplyRebalancePlan(ctx, plan)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Moved %d slots in %d moves", plan.SlotCount(), len(plan.Moves)), nil
+	}
+	return "", fmt.Errorf("unknown operation type %q", spec.Type)
+}
+
+// loadClusterNodes connects to the Redis nodes in all ready pods, and returns whether all the nodes the cluster needs are ready
+func (r *RedisClusterOperationReconciler) loadClusterNodes(ctx context.Context, cluster *cachev1beta1.RedisCluster) (*redis_internal.ClusterNodes, bool, error) {
+	clusterNodes := &redis_internal.ClusterNodes{}
+	statefulset, err := kubernetes.FetchExistingStatefulset(ctx, r.Client, cluster)
+	if errors.IsNotFound(err) {
+		return clusterNodes, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	pods, err := kubernetes.FetchRedisPods(ctx, r.Client, cluster)
+	if err != nil {
+		return nil, false, err
+	}
+	clientBuilder, err := redisClientBuilder(ctx, r.Client, cluster, r.NewRedisClient)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !utils.IsPodContainersReady(pod) {
+			continue
+		}
+		node, err := redis_internal.NewNode(ctx, &redis.Options{
+			Addr: kubernetes.GetRedisPodHost(cluster, statefulset, pod) + ":6379",
+		}, pod, clientBuilder)
+		if err != nil {
+			return nil, false, err
+		}
+		if cluster.SlotWeightsSelectNodes() {
+			node.HostLabels, err = kubernetes.FetchPodHostLabels(ctx, r.Client, pod)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		clusterNodes.Nodes = append(clusterNodes.Nodes, node)
+	}
+	return clusterNodes, len(clusterNodes.Nodes) == int(cluster.NodesNeeded()), nil
+}
+
+func (r *RedisClusterOperationReconciler) failoverTimeout() time.Duration {
+	if r.FailoverTimeout == 0 {
+		return 30 * time.Second
+	}
+	return r.FailoverTimeout
+}
+
+// pending keeps the operation pending, and tells the user what it is waiting for
+func (r *RedisClusterOperationReconciler) pending(ctx context.Context, operation *cachev1beta1.RedisClusterOperation, message string) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("RedisClusterOperation is waiting. Reconciling again in 10 seconds", "reason", message)
+	err := r.updateOperationStatus(ctx, operation, func(status *cachev1beta1.RedisClusterOperationStatus) {
+		status.Phase = cachev1beta1.OperationPending
+		status.Message = message
+	})
+	if err != nil {
+		return r.RequeueError(ctx, "could not update operation status", err)
+	}
+	return ctrl.Result{
+		RequeueAfter: 10 * time.Second,
+	}, nil
+}
+
+// finish records the outcome of the operation. Failed operations are not retried, so the error is not returned.
+func (r *RedisClusterOperationReconciler) finish(ctx context.Context, operation *cachev1beta1.RedisClusterOperation, message string, operationErr error) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	err := r.updateOperationStatus(ctx, operation, func(status *cachev1beta1.RedisClusterOperationStatus) {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		if operationErr != nil {
+			status.Phase = cachev1beta1.OperationFailed
+			status.Message = fmt.Sprintf("%s operation failed", operation.Spec.Type)
+			status.Error = operationErr.Error()
+			return
+		}
+		status.Phase = cachev1beta1.OperationSucceeded
+		status.Message = message
+	})
+	if err != nil {
+		return r.RequeueError(ctx, "could not update operation status", err)
+	}
+	if operationErr != nil {
+		logger.Error(operationErr, "RedisClusterOperation failed")
+		r.RecordEvent(operation, v12.EventTypeWarning, "Failed", operationErr.Error())
+		return ctrl.Result{}, nil
+	}
+	logger.Info("RedisClusterOperation succeeded", "message", message)
+	r.RecordEvent(operation, v12.EventTypeNormal, "Succeeded", message)
+	return ctrl.Result{}, nil
+}
+
+// updateOperationStatus fetches the latest version of the operation, applies the mutation to its status, and updates it
+func (r *RedisClusterOperationReconciler) updateOperationStatus(ctx context.Context, operation *cachev1beta1.RedisClusterOperation, mutate func(status *cachev1beta1.RedisClusterOperationStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &cachev1beta1.RedisClusterOperation{}
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(operation), latest)
+		if err != nil {
+			return err
+		}
+		mutate(&latest.Status)
+		err = r.Client.Status().Update(ctx, latest)
+		if err != nil {
+			return err
+		}
+		operation.Status = latest.Status
+		return nil
+	})
+}
+
+func (r *RedisClusterOperationReconciler) RequeueError(ctx context.Context, message string, err error) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Error(err, message)
+	return ctrl.Result{
+		RequeueAfter: 10 * time.Second,
+	}, err
+}
+
+// RecordEvent records an Event for the operation, if the reconciler has been given a recorder
+func (r *RedisClusterOperationReconciler) RecordEvent(operation *cachev1beta1.RedisClusterOperation, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(operation, eventType, reason, message)
+}
+
+// operationRunning returns whether an operation is executing on the cluster, so the cluster reconciler can stay out of its way
+func operationRunning(ctx context.Context, kubeClient client.Client, cluster *cachev1beta1.RedisCluster) (bool, error) {
+	operations := &cachev1beta1.RedisClusterOperationList{}
+	err := kubeClient.List(ctx, operations, client.InNamespace(cluster.Namespace))
+	if err != nil {
+		return false, err
+	}
+	for _, operation := range operations.Items {
+		if operation.Spec.Cluster == cluster.Name && operation.Status.Phase == cachev1beta1.OperationRunning {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RedisClusterOperationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// Our own status updates should not trigger another reconcile, we requeue while waiting anyway
+		For(&cachev1beta1.RedisClusterOperation{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
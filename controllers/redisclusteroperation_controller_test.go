@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"testing"
+)
+
+func reconcileOperation(t *testing.T, objects []client.Object, operation *cachev1beta1.RedisClusterOperation) *cachev1beta1.RedisClusterOperation {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	kubeClient := fake.NewClientBuilder().WithObjects(append(objects, operation)...).Build()
+	r := &RedisClusterOperationReconciler{
+		Client: kubeClient,
+		Scheme: s,
+	}
+	req := reconcile.Request{
+		NamespacedName: client.ObjectKeyFromObject(operation),
+	}
+	_, err := r.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	result := &cachev1beta1.RedisClusterOperation{}
+	err = kubeClient.Get(context.TODO(), req.NamespacedName, result)
+	if err != nil {
+		t.Fatalf("Failed to fetch RedisClusterOperation %v", err)
+	}
+	return result
+}
+
+func TestRedisClusterOperationReconciler_Reconcile_FailsForMissingCluster(t *testing.T) {
+	operation := reconcileOperation(t, nil, &cachev1beta1.RedisClusterOperation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rebalance",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterOperationSpec{
+			Cluster: "redis-cluster",
+			Type:    cachev1beta1.OperationRebalance,
+		},
+	})
+	if operation.Status.Phase != cachev1beta1.OperationFailed {
+		t.Fatalf("Expected operation to fail, got phase %s", operation.Status.Phase)
+	}
+	if !strings.Contains(operation.Status.Error, "does not exist") || operation.Status.CompletionTime == nil {
+		t.Fatalf("Expected the error and completion time to be recorded. Got %+v", operation.Status)
+	}
+}
+
+func TestRedisClusterOperationReconciler_Reconcile_FailsWithoutParameters(t *testing.T) {
+	operation := reconcileOperation(t, nil, &cachev1beta1.RedisClusterOperation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "failover",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterOperationSpec{
+			Cluster: "redis-cluster",
+			Type:    cachev1beta1.OperationFailover,
+		},
+	})
+	if operation.Status.Phase != cachev1beta1.OperationFailed || !strings.Contains(operation.Status.Error, "failover.pod") {
+		t.Fatalf("Expected operation to fail for the missing pod. Got %+v", operation.Status)
+	}
+}
+
+func TestRedisClusterOperationReconciler_Reconcile_WaitsForPausedCluster(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+			Annotations: map[string]string{
+				cachev1beta1.PausedAnnotation: "true",
+			},
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+		},
+	}
+	operation := reconcileOperation(t, []client.Object{cluster}, &cachev1beta1.RedisClusterOperation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rebalance",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterOperationSpec{
+			Cluster: "redis-cluster",
+			Type:    cachev1beta1.OperationRebalance,
+		},
+	})
+	if operation.Status.Phase != cachev1beta1.OperationPending || operation.Status.StartTime != nil {
+		t.Fatalf("Expected operation to wait for the cluster to be resumed. Got %+v", operation.Status)
+	}
+}
+
+func TestRedisClusterOperationReconciler_Reconcile_DoesNotRetryInterruptedOperation(t *testing.T) {
+	startTime := metav1.Now()
+	operation := reconcileOperation(t, nil, &cachev1beta1.RedisClusterOperation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "move-slots",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterOperationSpec{
+			Cluster: "redis-cluster",
+			Type:    cachev1beta1.OperationMoveSlots,
+			MoveSlots: &cachev1beta1.MoveSlotsOperation{
+				Slots:       "0-100",
+				Destination: "redis-cluster-1",
+			},
+		},
+		Status: cachev1beta1.RedisClusterOperationStatus{
+			Phase:     cachev1beta1.OperationRunning,
+			StartTime: &startTime,
+		},
+	})
+	if operation.Status.Phase != cachev1beta1.OperationFailed || !strings.Contains(operation.Status.Error, "interrupted") {
+		t.Fatalf("Expected interrupted operation to fail. Got %+v", operation.Status)
+	}
+}
+
+func TestOperationRunning(t *testing.T) {
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	newOperation := func(name, clusterName string, phase cachev1beta1.OperationPhase) *cachev1beta1.RedisClusterOperation {
+		return &cachev1beta1.RedisClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: cachev1beta1.RedisClusterOperationSpec{
+				Cluster: clusterName,
+				Type:    cachev1beta1.OperationRebalance,
+			},
+			Status: cachev1beta1.RedisClusterOperationStatus{
+				Phase: phase,
+			},
+		}
+	}
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		newOperation("finished", "redis-cluster", cachev1beta1.OperationSucceeded),
+		newOperation("other-cluster", "other-cluster", cachev1beta1.OperationRunning),
+	).Build()
+	running, err := operationRunning(context.TODO(), kubeClient, cluster)
+	if err != nil || running {
+		t.Fatalf("Expected no running operations for the cluster. Got %v, %v", running, err)
+	}
+
+	err = kubeClient.Create(context.TODO(), newOperation("running", "redis-cluster", cachev1beta1.OperationRunning))
+	if err != nil {
+		t.Fatalf("Failed to create operation %v", err)
+	}
+	running, err = operationRunning(context.TODO(), kubeClient, cluster)
+	if err != nil || !running {
+		t.Fatalf("Expected a running operation for the cluster. Got %v, %v", running, err)
+	}
+}
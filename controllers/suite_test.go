@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"fmt"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"testing"
+)
+
+// testEnv runs a real API server and etcd for the integration tests.
+// It is only started when KUBEBUILDER_ASSETS points at the envtest binaries, as `make test` does.
+var testEnv *envtest.Environment
+
+// requireEnvtestVariable makes the tests fail when the integration tests cannot run, instead of skipping them.
+// `make test` sets it, so CI never passes without running the integration tests.
+const requireEnvtestVariable = "REQUIRE_ENVTEST"
+
+// k8sClient talks to the API server of testEnv
+var k8sClient client.Client
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		if os.Getenv(requireEnvtestVariable) != "" {
+			fmt.Fprintf(os.Stderr, "KUBEBUILDER_ASSETS is not set, but %s is. The integration tests cannot run.\n", requireEnvtestVariable)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "WARNING: KUBEBUILDER_ASSETS is not set. Skipping the integration tests. Run `make test` to run them.")
+		os.Exit(m.Run())
+	}
+
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+	config, err := testEnv.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not start test environment: %v\n", err)
+		os.Exit(1)
+	}
+	err = cachev1beta1.AddToScheme(scheme.Scheme)
+	if err == nil {
+		k8sClient, err = client.New(config, client.Options{Scheme: scheme.Scheme})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create client for test environment: %v\n", err)
+		_ = testEnv.Stop()
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	err = testEnv.Stop()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not stop test environment: %v\n", err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,32 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetRedisPassword returns the password the Redis nodes of the cluster are protected with.
+// Clusters without auth have no password, so we return an empty string for them.
+func GetRedisPassword(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (string, error) {
+	if cluster.Spec.Auth == nil {
+		return "", nil
+	}
+	secretKey := cluster.Spec.Auth.PasswordSecret
+	secret := &v1.Secret{}
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Namespace: cluster.Namespace,
+		Name:      secretKey.Name,
+	}, secret)
+	if err != nil {
+		return "", err
+	}
+	password, ok := secret.Data[secretKey.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s does not contain the key %s", secretKey.Name, secretKey.Key)
+	}
+	return string(password), nil
+}
@@ -0,0 +1,53 @@
+package kubernetes
+
+import (
+	"context"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestGetRedisPassword(t *testing.T) {
+	client := fake.NewClientBuilder().WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-password",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"password": []byte("s3cr3t"),
+		},
+	}).Build()
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+
+	password, err := GetRedisPassword(context.TODO(), client, cluster)
+	if err != nil || password != "" {
+		t.Fatalf("Expected no password without auth. Got %s and error %v", password, err)
+	}
+
+	cluster.Spec.Auth = &cachev1beta1.Auth{
+		PasswordSecret: v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "redis-password"},
+			Key:                  "password",
+		},
+	}
+	password, err = GetRedisPassword(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected password to be loaded, but received an error %v", err)
+	}
+	if password != "s3cr3t" {
+		t.Fatalf("Expected password from the secret. Got %s", password)
+	}
+
+	cluster.Spec.Auth.PasswordSecret.Key = "missing"
+	_, err = GetRedisPassword(context.TODO(), client, cluster)
+	if err == nil {
+		t.Fatalf("Expected an error when the secret does not contain the key")
+	}
+}
@@ -3,15 +3,14 @@ package kubernetes
 import (
 	"context"
 	"fmt"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"strings"
 )
 
-func FetchExistingConfigMap(ctx context.Context, kubeClient client.Client, cluster *v1alpha1.RedisCluster) (*v1.ConfigMap, error) {
+func FetchExistingConfigMap(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.ConfigMap, error) {
 	configMap := &v1.ConfigMap{}
 	err := kubeClient.Get(ctx, types.NamespacedName{
 		Namespace: cluster.Namespace,
@@ -20,23 +19,17 @@ func FetchExistingConfigMap(ctx context.Context, kubeClient client.Client, clust
 	return configMap, err
 }
 
-func getConfigMapName(cluster *v1alpha1.RedisCluster) string {
+func getConfigMapName(cluster *v1beta1.RedisCluster) string {
 	return fmt.Sprintf("%s-config", cluster.Name)
 }
 
-func getDefaultRedisConfig() map[string]string {
-	return map[string]string{
-		"port":                 "6379",
-		"cluster-enabled":      "yes",
-		"cluster-config-file":  "nodes.conf",
-		"cluster-node-timeout": "5000",
-	}
+func getDefaultRedisConfig(cluster *v1beta1.RedisCluster) map[string]string {
+	return v1beta1.DefaultRedisConfig(cluster.Spec.Mode)
 }
 
-func getAppliedRedisConfig(cluster *v1alpha1.RedisCluster) map[string]string {
-	config := getDefaultRedisConfig()
-	redisConfig := getRedisConfigFromMultilineYaml(cluster.Spec.Config)
-	for setting, value := range redisConfig {
+func getAppliedRedisConfig(cluster *v1beta1.RedisCluster) map[string]string {
+	config := getDefaultRedisConfig(cluster)
+	for setting, value := range cluster.Spec.Redis.Config {
 		config[setting] = value
 	}
 	return config
@@ -50,21 +43,7 @@ func getRedisConfigAsMultilineYaml(config map[string]string) string {
 	return result
 }
 
-func getRedisConfigFromMultilineYaml(config string) map[string]string {
-	result := map[string]string{}
-	for _, settingLine := range strings.Split(config, "\n") {
-		if strings.TrimSpace(settingLine) == "" {
-			continue
-		}
-		settingParts := strings.Split(settingLine, " ")
-		setting := settingParts[0]
-		value := strings.Join(settingParts[1:], " ")
-		result[setting] = value
-	}
-	return result
-}
-
-func createConfigMapSpec(cluster *v1alpha1.RedisCluster) *v1.ConfigMap {
+func createConfigMapSpec(cluster *v1beta1.RedisCluster) *v1.ConfigMap {
 	redisConfig := getAppliedRedisConfig(cluster)
 	configMap := &v1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{},
@@ -79,7 +58,7 @@ func createConfigMapSpec(cluster *v1alpha1.RedisCluster) *v1.ConfigMap {
 	return configMap
 }
 
-func CreateConfigMap(ctx context.Context, kubeClient client.Client, cluster *v1alpha1.RedisCluster) (*v1.ConfigMap, error) {
+func CreateConfigMap(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.ConfigMap, error) {
 	configMap := createConfigMapSpec(cluster)
 	err := kubeClient.Create(ctx, configMap)
 	return configMap, err
@@ -2,7 +2,7 @@ package kubernetes
 
 import (
 	"context"
-	cachev1alpha1 "github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,7 +16,7 @@ import (
 
 //region getDefaultRedisConfig
 func TestGetDefaultRedisConfig(t *testing.T) {
-	defaultConfig := getDefaultRedisConfig()
+	defaultConfig := getDefaultRedisConfig(&cachev1beta1.RedisCluster{})
 
 	// Cluster needs to be enabled
 	if defaultConfig["cluster-enabled"] != "yes" {
@@ -28,13 +28,29 @@ func TestGetDefaultRedisConfig(t *testing.T) {
 	}
 }
 
+func TestGetDefaultRedisConfigInReplicationMode(t *testing.T) {
+	defaultConfig := getDefaultRedisConfig(&cachev1beta1.RedisCluster{
+		Spec: cachev1beta1.RedisClusterSpec{
+			Mode: cachev1beta1.ModeReplication,
+		},
+	})
+
+	// A single master with replicas cannot run with cluster mode enabled
+	if _, ok := defaultConfig["cluster-enabled"]; ok {
+		t.Fatalf("The default redis config enables cluster mode in replication mode")
+	}
+	if defaultConfig["port"] != "6379" {
+		t.Fatalf("The default redis config port is not 6379")
+	}
+}
+
 //endregion
 
 // region FetchExistingConfigMap
 func TestFindExistingConfigMapFetchesConfigMap(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 
 	clientBuilder.WithObjects(&v1.ConfigMap{
@@ -46,7 +62,7 @@ func TestFindExistingConfigMapFetchesConfigMap(t *testing.T) {
 
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -65,7 +81,7 @@ func TestFindExistingConfigMapFetchesConfigMap(t *testing.T) {
 func TestFindExistingConfigMapFetchesCorrectConfigMap(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 
 	clientBuilder.WithObjects(&v1.ConfigMap{
@@ -87,7 +103,7 @@ func TestFindExistingConfigMapFetchesCorrectConfigMap(t *testing.T) {
 
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -106,11 +122,11 @@ func TestFindExistingConfigMapFetchesCorrectConfigMap(t *testing.T) {
 func TestFindExistingConfigMapReturnsNotFoundErrorIfNotExists(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -155,19 +171,20 @@ cluster-enabled true
 
 //region getAppliedRedisConfig
 func TestGetAppliedRedisConfigProcessesAdditionalConfigPassedIntoRedisClusterWithDefault(t *testing.T) {
-	redisConfig := `
-maxmemory 128mb
-maxmemory-samples 5
-`
-	redisCluster := &cachev1alpha1.RedisCluster{
+	redisCluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
-			Config:            redisConfig,
+			Redis: cachev1beta1.RedisSettings{
+				Config: map[string]string{
+					"maxmemory":         "128mb",
+					"maxmemory-samples": "5",
+				},
+			},
 		},
 	}
 	gotAppliedConfig := getAppliedRedisConfig(redisCluster)
@@ -189,30 +206,9 @@ Got: %v
 
 //endregion
 
-//region getRedisConfigFromMultilineYaml
-func TestGetRedisConfigFromMultilineYaml(t *testing.T) {
-	redisConfigString := `maxmemory 128mb
-maxmemory-samples 5
-port 7000
-`
-	gotRedisConfig := getRedisConfigFromMultilineYaml(redisConfigString)
-	expectedRedisConfig := map[string]string{
-		"maxmemory":         "128mb",
-		"maxmemory-samples": "5",
-		"port":              "7000",
-	}
-	if !reflect.DeepEqual(gotRedisConfig, expectedRedisConfig) {
-		t.Fatalf(`Expected String config to be processed correctly. 
-Expected %v
-Got %v`, expectedRedisConfig, gotRedisConfig)
-	}
-}
-
-//endregion
-
 //region createConfigMapSpec
 func TestCreateConfigMapSpecShouldHaveRedisConfKey(t *testing.T) {
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -231,18 +227,18 @@ func TestCreateConfigMapSpecShouldHaveRedisConfKey(t *testing.T) {
 
 //region CreateConfigMap
 func TestCreateConfigMap(t *testing.T) {
-	redisCluster := cachev1alpha1.RedisCluster{
+	redisCluster := cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 		},
 	}
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 	clientBuilder.WithObjects(&redisCluster)
 	client := clientBuilder.Build()
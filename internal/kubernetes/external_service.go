@@ -0,0 +1,151 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// statefulsetPodNameLabel is set by the Statefulset controller on every pod, and allows us to select a single pod
+	statefulsetPodNameLabel = "statefulset.kubernetes.io/pod-name"
+)
+
+// ExternalAddress is the address on which a Redis node can be reached from outside of Kubernetes
+type ExternalAddress struct {
+	IP      string
+	Port    int32
+	BusPort int32
+}
+
+func GetExternalServiceName(pod *v1.Pod) string {
+	return fmt.Sprintf("%s-external", pod.Name)
+}
+
+func FetchExistingExternalService(ctx context.Context, kubeClient client.Client, pod *v1.Pod) (*v1.Service, error) {
+	service := &v1.Service{}
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Namespace: pod.Namespace,
+		Name:      GetExternalServiceName(pod),
+	}, service)
+	return service, err
+}
+
+func createExternalServiceSpec(cluster *v1beta1.RedisCluster, pod *v1.Pod) *v1.Service {
+	labels := GetPodLabels(cluster)
+	labels[statefulsetPodNameLabel] = pod.Name
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        GetExternalServiceName(pod),
+			Namespace:   pod.Namespace,
+			Labels:      GetStatefulSetLabels(cluster),
+			Annotations: cluster.Spec.ExternalAccess.Annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Name:       "redis",
+					Port:       6379,
+					TargetPort: intstr.FromInt(6379),
+				},
+				{
+					// Other nodes connect through the announced address as well, so the gossip port needs to be exposed
+					Name:       "redis-gossip",
+					Port:       16379,
+					TargetPort: intstr.FromInt(16379),
+				},
+			},
+			Selector: labels,
+			Type:     cluster.Spec.ExternalAccess.Type,
+			// Every Redis node keeps its own data, so traffic should never be sent to another node
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+		},
+	}
+	return service
+}
+
+// EnsureExternalService creates the Service exposing a single Redis pod outside of Kubernetes, if it does not exist yet
+func EnsureExternalService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster, pod *v1.Pod) (*v1.Service, error) {
+	service, err := FetchExistingExternalService(ctx, kubeClient, pod)
+	if err == nil || !errors.IsNotFound(err) {
+		return service, err
+	}
+	service = createExternalServiceSpec(cluster, pod)
+	err = controllerutil.SetControllerReference(cluster, service, kubeClient.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	err = kubeClient.Create(ctx, service)
+	return service, err
+}
+
+// GetExternalAddress returns the address clients outside of Kubernetes can use to reach the pod through the Service.
+// It returns nil if the address is not known yet, for example while the load balancer is being provisioned.
+// Redis can only announce IP addresses, so load balancers which only provide a hostname cannot be used.
+func GetExternalAddress(ctx context.Context, kubeClient client.Client, service *v1.Service, pod *v1.Pod) (*ExternalAddress, error) {
+	switch service.Spec.Type {
+	case v1.ServiceTypeLoadBalancer:
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				return &ExternalAddress{
+					IP:      ingress.IP,
+					Port:    getServicePort(service, "redis").Port,
+					BusPort: getServicePort(service, "redis-gossip").Port,
+				}, nil
+			}
+		}
+		return nil, nil
+	case v1.ServiceTypeNodePort:
+		if pod.Spec.NodeName == "" {
+			return nil, nil
+		}
+		node := &v1.Node{}
+		err := kubeClient.Get(ctx, types.NamespacedName{
+			Name: pod.Spec.NodeName,
+		}, node)
+		if err != nil {
+			return nil, err
+		}
+		ip := getNodeAddress(node, v1.NodeExternalIP)
+		if ip == "" {
+			// Nodes without an external IP are usually reachable through their internal IP, for example on bare metal
+			ip = getNodeAddress(node, v1.NodeInternalIP)
+		}
+		redisPort := getServicePort(service, "redis").NodePort
+		busPort := getServicePort(service, "redis-gossip").NodePort
+		if ip == "" || redisPort == 0 || busPort == 0 {
+			return nil, nil
+		}
+		return &ExternalAddress{
+			IP:      ip,
+			Port:    redisPort,
+			BusPort: busPort,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported external service type %s", service.Spec.Type)
+}
+
+func getServicePort(service *v1.Service, name string) v1.ServicePort {
+	for _, port := range service.Spec.Ports {
+		if port.Name == name {
+			return port
+		}
+	}
+	return v1.ServicePort{}
+}
+
+func getNodeAddress(node *v1.Node, addressType v1.NodeAddressType) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == addressType {
+			return address.Address
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package kubernetes
+
+import (
+	"context"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestEnsureExternalService_CreatesServiceForPod(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	client := fake.NewClientBuilder().Build()
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			ExternalAccess: &cachev1beta1.ExternalAccess{
+				Type: v1.ServiceTypeNodePort,
+			},
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster-1",
+			Namespace: "default",
+		},
+	}
+
+	_, err := EnsureExternalService(context.TODO(), client, cluster, pod)
+	if err != nil {
+		t.Fatalf("Expected external Service to be created, but received an error %v", err)
+	}
+	// Ensuring the Service again should not fail, as it already exists
+	service, err := EnsureExternalService(context.TODO(), client, cluster, pod)
+	if err != nil {
+		t.Fatalf("Expected existing external Service to be returned, but received an error %v", err)
+	}
+	if service.Name != "redis-cluster-1-external" || service.Spec.Type != v1.ServiceTypeNodePort {
+		t.Fatalf("Incorrect external Service created %s of type %s", service.Name, service.Spec.Type)
+	}
+	if service.Spec.Selector[statefulsetPodNameLabel] != "redis-cluster-1" {
+		t.Fatalf("Expected external Service to select a single pod. Got selector %v", service.Spec.Selector)
+	}
+}
+
+func TestGetExternalAddress(t *testing.T) {
+	client := fake.NewClientBuilder().WithObjects(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+		},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+				{Type: v1.NodeExternalIP, Address: "203.0.113.10"},
+			},
+		},
+	}).Build()
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			NodeName: "node-1",
+		},
+	}
+	ports := []v1.ServicePort{
+		{Name: "redis", Port: 6379, NodePort: 30001},
+		{Name: "redis-gossip", Port: 16379, NodePort: 30002},
+	}
+
+	address, err := GetExternalAddress(context.TODO(), client, &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeNodePort,
+			Ports: ports,
+		},
+	}, pod)
+	if err != nil || address == nil {
+		t.Fatalf("Expected node port address, but received %v %v", address, err)
+	}
+	if address.IP != "203.0.113.10" || address.Port != 30001 || address.BusPort != 30002 {
+		t.Fatalf("Incorrect node port address %v", address)
+	}
+
+	loadBalancer := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: ports,
+		},
+	}
+	address, err = GetExternalAddress(context.TODO(), client, loadBalancer, pod)
+	if err != nil || address != nil {
+		t.Fatalf("Expected no address while the load balancer is pending, but received %v %v", address, err)
+	}
+	loadBalancer.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "198.51.100.7"}}
+	address, err = GetExternalAddress(context.TODO(), client, loadBalancer, pod)
+	if err != nil || address == nil {
+		t.Fatalf("Expected load balancer address, but received %v %v", address, err)
+	}
+	if address.IP != "198.51.100.7" || address.Port != 6379 || address.BusPort != 16379 {
+		t.Fatalf("Incorrect load balancer address %v", address)
+	}
+}
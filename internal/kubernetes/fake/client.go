@@ -0,0 +1,67 @@
+// Package fake adds server-side apply to the fake client of controller-runtime, which does not support it.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
+)
+
+// ApplyAsMergePatchClient turns server-side apply patches into JSON merge patches.
+// Like the API server, it removes the fields a field manager applied before, but leaves out of its next apply.
+// Fields written in any other way are kept, as if they were owned by another field manager.
+type ApplyAsMergePatchClient struct {
+	client.Client
+
+	mutex sync.Mutex
+	// applied holds the last object applied by every field manager, by the field manager and the object
+	applied map[string][]byte
+}
+
+// NewApplyAsMergePatchClient wraps the client, which is usually a fake client
+func NewApplyAsMergePatchClient(kubeClient client.Client) *ApplyAsMergePatchClient {
+	return &ApplyAsMergePatchClient{
+		Client:  kubeClient,
+		applied: map[string][]byte{},
+	}
+}
+
+func (c *ApplyAsMergePatchClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	modified, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	current := obj.DeepCopyObject().(client.Object)
+	err = c.Client.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if err != nil {
+		return err
+	}
+	currentData, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	key := fmt.Sprintf("%s/%T/%s", options.FieldManager, obj, client.ObjectKeyFromObject(obj))
+	c.mutex.Lock()
+	original, ok := c.applied[key]
+	c.applied[key] = modified
+	c.mutex.Unlock()
+	if !ok {
+		original = []byte("{}")
+	}
+
+	data, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, currentData)
+	if err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data))
+}
@@ -0,0 +1,171 @@
+package kubernetes
+
+import (
+	"context"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	ExporterContainerName = "redis-exporter"
+	MetricsPortName       = "metrics"
+	MetricsPort           = 9121
+)
+
+// The Prometheus Operator is optional, so we don't depend on its Go types, and manage the monitors as unstructured objects
+var (
+	PodMonitorGVK = schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    "PodMonitor",
+	}
+	ServiceMonitorGVK = schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    "ServiceMonitor",
+	}
+)
+
+func getExporterImage(cluster *v1beta1.RedisCluster) string {
+	if cluster.Spec.Monitoring.Image != "" {
+		return cluster.Spec.Monitoring.Image
+	}
+	return v1beta1.DefaultExporterImage
+}
+
+// getExporterContainers returns the exporter sidecar if monitoring is enabled.
+// The exporter connects to the Redis node in the same pod, with the same password as everyone else.
+func getExporterContainers(cluster *v1beta1.RedisCluster) []v1.Container {
+	if cluster.Spec.Monitoring == nil {
+		return nil
+	}
+	env := []v1.EnvVar{
+		{
+			Name:  "REDIS_ADDR",
+			Value: "redis://localhost:6379",
+		},
+	}
+	if cluster.Spec.Auth != nil {
+		env = append(env, v1.EnvVar{
+			Name: "REDIS_PASSWORD",
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: cluster.Spec.Auth.PasswordSecret.DeepCopy(),
+			},
+		})
+	}
+	return []v1.Container{
+		{
+			Name:      ExporterContainerName,
+			Image:     getExporterImage(cluster),
+			Env:       env,
+			Resources: *cluster.Spec.Monitoring.Resources.DeepCopy(),
+			Ports: []v1.ContainerPort{
+				{
+					Name:          MetricsPortName,
+					ContainerPort: MetricsPort,
+				},
+			},
+		},
+	}
+}
+
+// MonitorCRDInstalled returns whether the API server serves the given kind of the Prometheus Operator
+func MonitorCRDInstalled(kubeClient client.Client, gvk schema.GroupVersionKind) (bool, error) {
+	_, err := kubeClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func createMonitorSpec(cluster *v1beta1.RedisCluster, gvk schema.GroupVersionKind, settings *v1beta1.PrometheusMonitor) *unstructured.Unstructured {
+	endpoint := map[string]interface{}{
+		"port": MetricsPortName,
+	}
+	if settings.Interval != "" {
+		endpoint["interval"] = settings.Interval
+	}
+	// Unstructured objects only hold JSON values, so the labels can't be kept as a map of strings
+	matchLabels := map[string]interface{}{}
+	endpointsField := "podMetricsEndpoints"
+	selectedLabels := GetPodLabels(cluster)
+	if gvk == ServiceMonitorGVK {
+		endpointsField = "endpoints"
+		selectedLabels = GetStatefulSetLabels(cluster)
+	}
+	for key, value := range selectedLabels {
+		matchLabels[key] = value
+	}
+
+	labels := GetStatefulSetLabels(cluster)
+	for key, value := range settings.Labels {
+		labels[key] = value
+	}
+	monitor := &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(gvk)
+	monitor.SetName(cluster.Name)
+	monitor.SetNamespace(cluster.Namespace)
+	monitor.SetLabels(labels)
+	monitor.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": matchLabels,
+		},
+		endpointsField: []interface{}{endpoint},
+	}
+	return monitor
+}
+
+// EnsureMonitor creates the PodMonitor or ServiceMonitor of the cluster, or updates it when it does not match the cluster
+func EnsureMonitor(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster, gvk schema.GroupVersionKind, settings *v1beta1.PrometheusMonitor) error {
+	desired := createMonitorSpec(cluster, gvk, settings)
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	err := kubeClient.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+	if errors.IsNotFound(err) {
+		err = controllerutil.SetControllerReference(cluster, desired, kubeClient.Scheme())
+		if err != nil {
+			return err
+		}
+		return kubeClient.Create(ctx, desired)
+	}
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepDerivative(desired.Object["spec"], existing.Object["spec"]) &&
+		equality.Semantic.DeepDerivative(desired.GetLabels(), existing.GetLabels()) {
+		return nil
+	}
+	existing.Object["spec"] = desired.Object["spec"]
+	existing.SetLabels(desired.GetLabels())
+	return kubeClient.Update(ctx, existing)
+}
+
+// DeleteMonitor deletes the PodMonitor or ServiceMonitor of the cluster, if the Operator created one.
+// Monitors with the same name which were created by hand are left alone.
+func DeleteMonitor(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster, gvk schema.GroupVersionKind) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Name,
+	}, existing)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(existing, cluster) {
+		return nil
+	}
+	return client.IgnoreNotFound(kubeClient.Delete(ctx, existing))
+}
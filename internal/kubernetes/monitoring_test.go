@@ -0,0 +1,144 @@
+package kubernetes
+
+import (
+	"context"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+// monitoringClient returns a fake client which serves the CRDs of the Prometheus Operator
+func monitoringClient(t *testing.T) client.Client {
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatalf("Could not add client-go types to scheme: %v", err)
+	}
+	if err := cachev1beta1.AddToScheme(s); err != nil {
+		t.Fatalf("Could not add v1beta1 to scheme: %v", err)
+	}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{PodMonitorGVK.GroupVersion()})
+	for _, gvk := range []schema.GroupVersionKind{PodMonitorGVK, ServiceMonitorGVK} {
+		s.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	return fake.NewClientBuilder().WithScheme(s).WithRESTMapper(mapper).Build()
+}
+
+func TestCreateStatefulsetSpec_AddsExporterWithPassword(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:    3,
+			Monitoring: &cachev1beta1.Monitoring{},
+			Auth: &cachev1beta1.Auth{
+				PasswordSecret: v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "redis-password"},
+					Key:                  "password",
+				},
+			},
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+	containers := statefulset.Spec.Template.Spec.Containers
+	if len(containers) != 2 || containers[1].Name != ExporterContainerName {
+		t.Fatalf("Expected exporter container next to Redis. Got %v", containers)
+	}
+	exporter := containers[1]
+	if exporter.Image != cachev1beta1.DefaultExporterImage {
+		t.Fatalf("Expected default exporter image. Got %s", exporter.Image)
+	}
+	if exporter.Ports[0].Name != MetricsPortName || exporter.Ports[0].ContainerPort != MetricsPort {
+		t.Fatalf("Expected exporter to expose the metrics port. Got %v", exporter.Ports)
+	}
+	foundPassword := false
+	for _, env := range exporter.Env {
+		if env.Name == "REDIS_PASSWORD" && env.ValueFrom.SecretKeyRef.Name == "redis-password" {
+			foundPassword = true
+		}
+	}
+	if !foundPassword {
+		t.Fatalf("Expected exporter to authenticate with the password of the cluster. Got %v", exporter.Env)
+	}
+
+	service := createServiceSpec(cluster)
+	if len(service.Spec.Ports) != 2 || service.Spec.Ports[1].Name != MetricsPortName {
+		t.Fatalf("Expected metrics port on the Service. Got %v", service.Spec.Ports)
+	}
+}
+
+func TestMonitorCRDInstalled(t *testing.T) {
+	installed, err := MonitorCRDInstalled(fake.NewClientBuilder().Build(), PodMonitorGVK)
+	if err != nil || installed {
+		t.Fatalf("Expected PodMonitor not to be installed. Got %v %v", installed, err)
+	}
+	installed, err = MonitorCRDInstalled(monitoringClient(t), PodMonitorGVK)
+	if err != nil || !installed {
+		t.Fatalf("Expected PodMonitor to be installed. Got %v %v", installed, err)
+	}
+}
+
+func TestEnsureMonitorCreatesAndUpdatesPodMonitor(t *testing.T) {
+	kubeClient := monitoringClient(t)
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+			UID:       "1234",
+		},
+	}
+	settings := &cachev1beta1.PrometheusMonitor{
+		Labels: map[string]string{
+			"release": "prometheus",
+		},
+	}
+
+	err := EnsureMonitor(context.TODO(), kubeClient, cluster, PodMonitorGVK, settings)
+	if err != nil {
+		t.Fatalf("Expected PodMonitor to be created. Got %v", err)
+	}
+	settings.Interval = "15s"
+	err = EnsureMonitor(context.TODO(), kubeClient, cluster, PodMonitorGVK, settings)
+	if err != nil {
+		t.Fatalf("Expected PodMonitor to be updated. Got %v", err)
+	}
+
+	monitor := &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(PodMonitorGVK)
+	err = kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "redis-cluster"}, monitor)
+	if err != nil {
+		t.Fatalf("Expected PodMonitor to be found. Got %v", err)
+	}
+	if monitor.GetLabels()["release"] != "prometheus" {
+		t.Fatalf("Expected PodMonitor to have the labels of the cluster. Got %v", monitor.GetLabels())
+	}
+	matchLabels, _, _ := unstructured.NestedStringMap(monitor.Object, "spec", "selector", "matchLabels")
+	if matchLabels[RedisNodeNameStatefulsetLabel] != "redis-cluster" || matchLabels[RedisNodeComponentLabel] != "redis" {
+		t.Fatalf("Expected PodMonitor to select the Redis pods. Got %v", matchLabels)
+	}
+	endpoints, _, _ := unstructured.NestedSlice(monitor.Object, "spec", "podMetricsEndpoints")
+	if len(endpoints) != 1 || endpoints[0].(map[string]interface{})["interval"] != "15s" {
+		t.Fatalf("Expected PodMonitor to scrape every 15s. Got %v", endpoints)
+	}
+
+	err = DeleteMonitor(context.TODO(), kubeClient, cluster, PodMonitorGVK)
+	if err != nil {
+		t.Fatalf("Expected PodMonitor to be deleted. Got %v", err)
+	}
+	err = kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "redis-cluster"}, monitor)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected PodMonitor to be gone. Got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package kubernetes
+
+import (
+	"context"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v12 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func FetchRedisPersistentVolumeClaims(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v12.PersistentVolumeClaimList, error) {
+	claims := &v12.PersistentVolumeClaimList{}
+	// Claims created from the volume claim templates of a Statefulset get the labels of its selector
+	err := kubeClient.List(
+		ctx,
+		claims,
+		client.MatchingLabelsSelector{Selector: GetPodLabels(cluster).AsSelector()},
+		client.InNamespace(cluster.Namespace),
+	)
+	return claims, err
+}
+
+// DeleteRedisPersistentVolumeClaims deletes the claims holding the data of the Redis nodes.
+// Statefulsets never delete the claims of their pods, so we need to clean them up ourselves.
+func DeleteRedisPersistentVolumeClaims(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) error {
+	claims, err := FetchRedisPersistentVolumeClaims(ctx, kubeClient, cluster)
+	if err != nil {
+		return err
+	}
+	for i := range claims.Items {
+		err = client.IgnoreNotFound(kubeClient.Delete(ctx, &claims.Items[i]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
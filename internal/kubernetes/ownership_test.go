@@ -0,0 +1,41 @@
+package kubernetes
+
+import (
+	"context"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestDeleteRedisPersistentVolumeClaims_OnlyDeletesClaimsOfCluster(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	client := fake.NewClientBuilder().WithObjects(&v12.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "data-redis-cluster-0",
+			Namespace: "default",
+			Labels:    GetPodLabels(cluster),
+		},
+	}, &v12.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "some-other-claim",
+			Namespace: "default",
+		},
+	}).Build()
+
+	err := DeleteRedisPersistentVolumeClaims(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Received error while deleting claims %v", err)
+	}
+	claims := &v12.PersistentVolumeClaimList{}
+	_ = client.List(context.TODO(), claims)
+	if len(claims.Items) != 1 || claims.Items[0].Name != "some-other-claim" {
+		t.Fatalf("Expected only the claims of the cluster to be deleted. Got %v", claims.Items)
+	}
+}
@@ -2,12 +2,15 @@ package kubernetes
 
 import (
 	"context"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/utils"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func FetchRedisPods(ctx context.Context, kubeClient client.Client, cluster *v1alpha1.RedisCluster) (*v1.PodList, error) {
+func FetchRedisPods(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.PodList, error) {
 	pods := &v1.PodList{}
 	err := kubeClient.List(
 		ctx,
@@ -17,3 +20,85 @@ func FetchRedisPods(ctx context.Context, kubeClient client.Client, cluster *v1al
 	)
 	return pods, err
 }
+
+// FetchPodHostLabels returns the labels of the Kubernetes node the pod is scheduled on
+func FetchPodHostLabels(ctx context.Context, kubeClient client.Client, pod *v1.Pod) (map[string]string, error) {
+	node := &v1.Node{}
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Name: pod.Spec.NodeName,
+	}, node)
+	if err != nil {
+		return nil, err
+	}
+	return node.Labels, nil
+}
+
+// SetPodRole labels the pod with the role its Redis node has, if the label does not match yet
+func SetPodRole(ctx context.Context, kubeClient client.Client, pod *v1.Pod, role string) error {
+	return setPodLabels(ctx, kubeClient, pod, map[string]string{
+		RedisNodeRoleLabel: role,
+	})
+}
+
+// SetPodNodeLabels labels the pod with the role and ID its Redis node has, if the labels do not match yet.
+// The role changes on failovers, and the ID when a node loses its nodes.conf, so we check them on every reconcile.
+func SetPodNodeLabels(ctx context.Context, kubeClient client.Client, pod *v1.Pod, role string, nodeID string) error {
+	return setPodLabels(ctx, kubeClient, pod, map[string]string{
+		RedisNodeRoleLabel: role,
+		RedisNodeIDLabel:   nodeID,
+	})
+}
+
+func setPodLabels(ctx context.Context, kubeClient client.Client, pod *v1.Pod, labels map[string]string) error {
+	changed := false
+	for key, value := range labels {
+		if pod.Labels[key] != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	for key, value := range labels {
+		pod.Labels[key] = value
+	}
+	return kubeClient.Patch(ctx, pod, patch)
+}
+
+// SetPodNodeReadiness sets the readiness gate condition of the pod, if the condition does not match yet.
+// The pod only turns Ready once both its containers and this condition are ready.
+func SetPodNodeReadiness(ctx context.Context, kubeClient client.Client, pod *v1.Pod, ready bool, message string) error {
+	status := v1.ConditionFalse
+	reason := "NodeNotReady"
+	if ready {
+		status = v1.ConditionTrue
+		reason = "NodeReady"
+	}
+	index, _ := utils.GetPodCondition(&pod.Status, RedisNodeReadyCondition)
+	if index >= 0 && pod.Status.Conditions[index].Status == status && pod.Status.Conditions[index].Message == message {
+		return nil
+	}
+
+	patch := client.StrategicMergeFrom(pod.DeepCopy())
+	condition := v1.PodCondition{
+		Type:               RedisNodeReadyCondition,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+	if index < 0 {
+		pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	} else {
+		if pod.Status.Conditions[index].Status == status {
+			// Only the message changed, so the condition did not transition
+			condition.LastTransitionTime = pod.Status.Conditions[index].LastTransitionTime
+		}
+		pod.Status.Conditions[index] = condition
+	}
+	return kubeClient.Status().Patch(ctx, pod, patch)
+}
@@ -2,10 +2,11 @@ package kubernetes
 
 import (
 	"context"
-	cachev1alpha1 "github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
@@ -13,14 +14,14 @@ import (
 
 // region FetchRedisPods
 func TestFetchRedisPodsFetchesAllRedisPods(t *testing.T) {
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
 	}
 	s := scheme.Scheme
-	s.AddKnownTypes(cachev1alpha1.GroupVersion)
+	s.AddKnownTypes(cachev1beta1.GroupVersion)
 	clientBuilder := fake.NewClientBuilder()
 	clientBuilder.WithObjects(cluster)
 	clientBuilder.WithObjects(&v1.Pod{
@@ -53,14 +54,14 @@ func TestFetchRedisPodsFetchesAllRedisPods(t *testing.T) {
 }
 
 func TestFetchRedisPodsFetchesAllRedisPodsExcludingOtherPods(t *testing.T) {
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
 	}
 	s := scheme.Scheme
-	s.AddKnownTypes(cachev1alpha1.GroupVersion)
+	s.AddKnownTypes(cachev1beta1.GroupVersion)
 	clientBuilder := fake.NewClientBuilder()
 	clientBuilder.WithObjects(cluster)
 	clientBuilder.WithObjects(&v1.Pod{
@@ -99,3 +100,145 @@ func TestFetchRedisPodsFetchesAllRedisPodsExcludingOtherPods(t *testing.T) {
 }
 
 // endregion
+
+// region FetchPodHostLabels
+func TestFetchPodHostLabelsReturnsLabelsOfScheduledNode(t *testing.T) {
+	clientBuilder := fake.NewClientBuilder()
+	clientBuilder.WithObjects(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-large",
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.2xlarge",
+			},
+		},
+	}, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-small",
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.large",
+			},
+		},
+	})
+	client := clientBuilder.Build()
+	hostLabels, err := FetchPodHostLabels(context.TODO(), client, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster-0",
+			Namespace: "default",
+		},
+		Spec: v1.PodSpec{
+			NodeName: "node-large",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Received error while trying to fetch host labels %v", err)
+	}
+	if hostLabels["node.kubernetes.io/instance-type"] != "m5.2xlarge" {
+		t.Fatalf("Received labels for the wrong node. Got %v", hostLabels)
+	}
+}
+
+// endregion
+
+// region SetPodRole
+func TestSetPodRoleLabelsPod(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster-0",
+			Namespace: "default",
+		},
+	}
+	client := fake.NewClientBuilder().WithObjects(pod).Build()
+
+	err := SetPodRole(context.TODO(), client, pod, RedisRoleMaster)
+	if err != nil {
+		t.Fatalf("Received error while trying to label pod %v", err)
+	}
+	fetchedPod := &v1.Pod{}
+	err = client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "redis-cluster-0"}, fetchedPod)
+	if err != nil {
+		t.Fatalf("Received error while trying to fetch pod %v", err)
+	}
+	if fetchedPod.Labels[RedisNodeRoleLabel] != RedisRoleMaster {
+		t.Fatalf("Expected pod to be labelled as master. Got labels %v", fetchedPod.Labels)
+	}
+}
+
+// endregion
+
+// region SetPodNodeLabels
+func TestSetPodNodeLabelsUpdatesLabelsAfterFailover(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster-0",
+			Namespace: "default",
+			Labels: map[string]string{
+				"app":              "redis",
+				RedisNodeRoleLabel: RedisRoleMaster,
+				RedisNodeIDLabel:   "9fd8800b31d569538917c0aaeaa5588e2f9c6edf",
+			},
+		},
+	}
+	client := fake.NewClientBuilder().WithObjects(pod).Build()
+
+	err := SetPodNodeLabels(context.TODO(), client, pod, RedisRoleReplica, "9fd8800b31d569538917c0aaeaa5588e2f9c6edf")
+	if err != nil {
+		t.Fatalf("Received error while trying to label pod %v", err)
+	}
+	fetchedPod := &v1.Pod{}
+	err = client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "redis-cluster-0"}, fetchedPod)
+	if err != nil {
+		t.Fatalf("Received error while trying to fetch pod %v", err)
+	}
+	expected := labels.Set{
+		"app":              "redis",
+		RedisNodeRoleLabel: RedisRoleReplica,
+		RedisNodeIDLabel:   "9fd8800b31d569538917c0aaeaa5588e2f9c6edf",
+	}
+	if !labels.Equals(fetchedPod.Labels, expected) {
+		t.Fatalf("Expected pod labels %v. Got %v", expected, fetchedPod.Labels)
+	}
+}
+
+// endregion
+
+// region SetPodNodeReadiness
+func TestSetPodNodeReadinessKeepsOtherConditions(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster-0",
+			Namespace: "default",
+		},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{
+				{
+					Type:   v1.ContainersReady,
+					Status: v1.ConditionTrue,
+				},
+			},
+		},
+	}
+	client := fake.NewClientBuilder().WithObjects(pod).Build()
+
+	err := SetPodNodeReadiness(context.TODO(), client, pod, false, "The master owns no slots")
+	if err != nil {
+		t.Fatalf("Received error while trying to set pod readiness %v", err)
+	}
+	err = SetPodNodeReadiness(context.TODO(), client, pod, true, "")
+	if err != nil {
+		t.Fatalf("Received error while trying to set pod readiness %v", err)
+	}
+	fetchedPod := &v1.Pod{}
+	err = client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "redis-cluster-0"}, fetchedPod)
+	if err != nil {
+		t.Fatalf("Received error while trying to fetch pod %v", err)
+	}
+	conditions := map[v1.PodConditionType]v1.ConditionStatus{}
+	for _, condition := range fetchedPod.Status.Conditions {
+		conditions[condition.Type] = condition.Status
+	}
+	if len(conditions) != 2 || conditions[v1.ContainersReady] != v1.ConditionTrue || conditions[RedisNodeReadyCondition] != v1.ConditionTrue {
+		t.Fatalf("Expected the node to be ready, next to the existing conditions. Got %v", fetchedPod.Status.Conditions)
+	}
+}
+
+// endregion
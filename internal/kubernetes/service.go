@@ -2,15 +2,19 @@ package kubernetes
 
 import (
 	"context"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func FetchExistingService(ctx context.Context, kubeClient client.Client, cluster *v1alpha1.RedisCluster) (*v1.Service, error) {
+func FetchExistingService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.Service, error) {
 	service := &v1.Service{}
 	err := kubeClient.Get(ctx, types.NamespacedName{
 		Namespace: cluster.Namespace,
@@ -19,12 +23,100 @@ func FetchExistingService(ctx context.Context, kubeClient client.Client, cluster
 	return service, err
 }
 
-func createServiceSpec(cluster *v1alpha1.RedisCluster) *v1.Service {
+func createServiceSpec(cluster *v1beta1.RedisCluster) *v1.Service {
+	ports := []v1.ServicePort{
+		{
+			Name:       "redis",
+			Port:       6379,
+			TargetPort: intstr.FromInt(6379),
+		},
+	}
+	if cluster.Spec.Monitoring != nil {
+		ports = append(ports, v1.ServicePort{
+			Name:       MetricsPortName,
+			Port:       MetricsPort,
+			TargetPort: intstr.FromInt(MetricsPort),
+		})
+	}
 	service := &v1.Service{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cluster.Name,
 			Namespace: cluster.Namespace,
+			// The labels allow a ServiceMonitor to select the Service
+			Labels: GetStatefulSetLabels(cluster),
+		},
+		Spec: v1.ServiceSpec{
+			Ports:    ports,
+			Selector: GetPodLabels(cluster),
+			Type:     "ClusterIP",
+		},
+	}
+	return service
+}
+
+func CreateService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.Service, error) {
+	service := createServiceSpec(cluster)
+	err := kubeClient.Create(ctx, service)
+	return service, err
+}
+
+// ServiceNeedsUpdate returns whether the labels or ports of the Service differ from the Service we would create for the cluster.
+// The API server fills in the protocol and node ports, so only the fields we set are compared.
+func ServiceNeedsUpdate(existing *v1.Service, cluster *v1beta1.RedisCluster) bool {
+	desired := createServiceSpec(cluster)
+	if !equality.Semantic.DeepDerivative(desired.Labels, existing.Labels) {
+		return true
+	}
+	if len(desired.Spec.Ports) != len(existing.Spec.Ports) {
+		return true
+	}
+	for i, port := range desired.Spec.Ports {
+		existingPort := existing.Spec.Ports[i]
+		if port.Name != existingPort.Name || port.Port != existingPort.Port || port.TargetPort != existingPort.TargetPort {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateService updates the labels and ports of the existing Service to match the cluster spec
+func UpdateService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster, existing *v1.Service) error {
+	desired := createServiceSpec(cluster)
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		existing.Labels[key] = value
+	}
+	existing.Spec.Ports = desired.Spec.Ports
+	return kubeClient.Update(ctx, existing)
+}
+
+// GetHeadlessServiceName returns the name of the headless Service which gives every Redis pod a stable DNS name
+func GetHeadlessServiceName(cluster *v1beta1.RedisCluster) string {
+	return fmt.Sprintf("%s-headless", cluster.Name)
+}
+
+// GetPodHostname returns the stable DNS name of a Redis pod, through the headless Service
+func GetPodHostname(cluster *v1beta1.RedisCluster, podName string) string {
+	return fmt.Sprintf("%s.%s.%s.svc", podName, GetHeadlessServiceName(cluster), cluster.Namespace)
+}
+
+func FetchExistingHeadlessService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.Service, error) {
+	service := &v1.Service{}
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Namespace: cluster.Namespace,
+		Name:      GetHeadlessServiceName(cluster),
+	}, service)
+	return service, err
+}
+
+func createHeadlessServiceSpec(cluster *v1beta1.RedisCluster) *v1.Service {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetHeadlessServiceName(cluster),
+			Namespace: cluster.Namespace,
 		},
 		Spec: v1.ServiceSpec{
 			Ports: []v1.ServicePort{
@@ -33,16 +125,149 @@ func createServiceSpec(cluster *v1alpha1.RedisCluster) *v1.Service {
 					Port:       6379,
 					TargetPort: intstr.FromInt(6379),
 				},
+				{
+					Name:       "redis-gossip",
+					Port:       16379,
+					TargetPort: intstr.FromInt(16379),
+				},
 			},
-			Selector: GetPodLabels(cluster),
+			Selector:  GetPodLabels(cluster),
+			ClusterIP: v1.ClusterIPNone,
+			// Redis nodes need to find each other before they can become ready,
+			// so the DNS records need to exist before the pods are ready.
+			PublishNotReadyAddresses: true,
+		},
+	}
+	return service
+}
+
+func CreateHeadlessService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.Service, error) {
+	service := createHeadlessServiceSpec(cluster)
+	err := controllerutil.SetControllerReference(cluster, service, kubeClient.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	err = kubeClient.Create(ctx, service)
+	return service, err
+}
+
+// GetMasterServiceName returns the name of the Service pointing at the current master of a cluster in Replication mode
+func GetMasterServiceName(cluster *v1beta1.RedisCluster) string {
+	return fmt.Sprintf("%s-master", cluster.Name)
+}
+
+func FetchExistingMasterService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.Service, error) {
+	service := &v1.Service{}
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Namespace: cluster.Namespace,
+		Name:      GetMasterServiceName(cluster),
+	}, service)
+	return service, err
+}
+
+func createMasterServiceSpec(cluster *v1beta1.RedisCluster) *v1.Service {
+	// The Operator moves the master label to the promoted replica on failover, which moves the Service along with it
+	selector := GetPodLabels(cluster)
+	selector[RedisNodeRoleLabel] = RedisRoleMaster
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetMasterServiceName(cluster),
+			Namespace: cluster.Namespace,
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Name:       "redis",
+					Port:       6379,
+					TargetPort: intstr.FromInt(6379),
+				},
+			},
+			Selector: selector,
 			Type:     "ClusterIP",
 		},
 	}
 	return service
 }
 
-func CreateService(ctx context.Context, kubeClient client.Client, cluster *v1alpha1.RedisCluster) (*v1.Service, error) {
-	service := createServiceSpec(cluster)
-	err := kubeClient.Create(ctx, service)
+// EnsureMasterService creates the Service pointing at the current master, if it does not exist yet
+func EnsureMasterService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.Service, error) {
+	service, err := FetchExistingMasterService(ctx, kubeClient, cluster)
+	if err == nil || !errors.IsNotFound(err) {
+		return service, err
+	}
+	service = createMasterServiceSpec(cluster)
+	err = controllerutil.SetControllerReference(cluster, service, kubeClient.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	err = kubeClient.Create(ctx, service)
 	return service, err
 }
+
+// GetRoleServiceName returns the name of the Service selecting the pods with the given role, for example redis-cluster-replicas
+func GetRoleServiceName(cluster *v1beta1.RedisCluster, role string) string {
+	return fmt.Sprintf("%s-%ss", cluster.Name, role)
+}
+
+func createRoleServiceSpec(cluster *v1beta1.RedisCluster, role string) *v1.Service {
+	// The Operator updates the role label of the pods after failovers, which moves the pods between the Services
+	selector := GetPodLabels(cluster)
+	selector[RedisNodeRoleLabel] = role
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetRoleServiceName(cluster, role),
+			Namespace: cluster.Namespace,
+			Labels:    GetStatefulSetLabels(cluster),
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Name:       "redis",
+					Port:       6379,
+					TargetPort: intstr.FromInt(6379),
+				},
+			},
+			Selector: selector,
+			Type:     "ClusterIP",
+		},
+	}
+	return service
+}
+
+// EnsureRoleService creates the Service selecting the pods with the given role, if it does not exist yet
+func EnsureRoleService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster, role string) error {
+	service := &v1.Service{}
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Namespace: cluster.Namespace,
+		Name:      GetRoleServiceName(cluster, role),
+	}, service)
+	if err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+	service = createRoleServiceSpec(cluster, role)
+	err = controllerutil.SetControllerReference(cluster, service, kubeClient.Scheme())
+	if err != nil {
+		return err
+	}
+	return kubeClient.Create(ctx, service)
+}
+
+// DeleteRoleService deletes the Service selecting the pods with the given role, if the Operator created one.
+// Services with the same name which were created by hand are left alone.
+func DeleteRoleService(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster, role string) error {
+	service := &v1.Service{}
+	err := kubeClient.Get(ctx, types.NamespacedName{
+		Namespace: cluster.Namespace,
+		Name:      GetRoleServiceName(cluster, role),
+	}, service)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(service, cluster) {
+		return nil
+	}
+	return client.IgnoreNotFound(kubeClient.Delete(ctx, service))
+}
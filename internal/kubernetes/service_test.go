@@ -2,11 +2,12 @@ package kubernetes
 
 import (
 	"context"
-	cachev1alpha1 "github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
 	"reflect"
@@ -18,7 +19,7 @@ import (
 func TestFindExistingService(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 
 	clientBuilder.WithObjects(&v1.Service{
@@ -30,7 +31,7 @@ func TestFindExistingService(t *testing.T) {
 
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -49,7 +50,7 @@ func TestFindExistingService(t *testing.T) {
 func TestFindExistingServiceFetchesCorrectService(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 
 	clientBuilder.WithObjects(&v1.Service{
@@ -71,7 +72,7 @@ func TestFindExistingServiceFetchesCorrectService(t *testing.T) {
 
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -90,11 +91,11 @@ func TestFindExistingServiceFetchesCorrectService(t *testing.T) {
 func TestFindExistingServiceReturnsNotFoundErrorIfNotExists(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	_ = cachev1alpha1.AddToScheme(s)
+	_ = cachev1beta1.AddToScheme(s)
 	clientBuilder := fake.NewClientBuilder()
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -112,7 +113,7 @@ func TestFindExistingServiceReturnsNotFoundErrorIfNotExists(t *testing.T) {
 // endregion
 
 func TestCreateServiceSpec(t *testing.T) {
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "redis-cluster",
 			Namespace: "default",
@@ -125,4 +126,128 @@ func TestCreateServiceSpec(t *testing.T) {
 	if !reflect.DeepEqual(labels.Set(service.Spec.Selector), GetPodLabels(cluster)) {
 		t.Fatalf("Service selector does not match pods labels")
 	}
-}
\ No newline at end of file
+}
+
+func TestServiceNeedsUpdateWhenMonitoringIsEnabled(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	service := createServiceSpec(cluster)
+	// The API server fills in the protocol, which should not count as a change
+	service.Spec.Ports[0].Protocol = v1.ProtocolTCP
+	if ServiceNeedsUpdate(service, cluster) {
+		t.Fatalf("Expected Service created for the cluster not to need an update")
+	}
+	cluster.Spec.Monitoring = &cachev1beta1.Monitoring{}
+	if !ServiceNeedsUpdate(service, cluster) {
+		t.Fatalf("Expected Service to need the metrics port")
+	}
+}
+
+func TestCreateHeadlessService(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	client := fake.NewClientBuilder().Build()
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+
+	_, err := CreateHeadlessService(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected headless Service to be created, but received an error %v", err)
+	}
+	service, err := FetchExistingHeadlessService(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected headless Service to be found, but received an error %v", err)
+	}
+	if service.Name != "redis-cluster-headless" || service.Spec.ClusterIP != v1.ClusterIPNone {
+		t.Fatalf("Expected headless Service redis-cluster-headless. Got %s with cluster IP %s", service.Name, service.Spec.ClusterIP)
+	}
+	if !service.Spec.PublishNotReadyAddresses {
+		t.Fatalf("Expected headless Service to publish addresses of pods which are not ready")
+	}
+	if len(service.GetOwnerReferences()) == 0 || service.GetOwnerReferences()[0].Name != cluster.Name {
+		t.Fatalf("Owner reference is not set on headless Service")
+	}
+}
+
+func TestEnsureMasterServiceSelectsMaster(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	client := fake.NewClientBuilder().Build()
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Mode: cachev1beta1.ModeReplication,
+		},
+	}
+
+	_, err := EnsureMasterService(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected master Service to be created, but received an error %v", err)
+	}
+	// Ensuring again leaves the existing Service in place
+	_, err = EnsureMasterService(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected existing master Service to be returned, but received an error %v", err)
+	}
+	service, err := FetchExistingMasterService(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected master Service to be found, but received an error %v", err)
+	}
+	if service.Name != "redis-cluster-master" {
+		t.Fatalf("Expected master Service redis-cluster-master. Got %s", service.Name)
+	}
+	if service.Spec.Selector[RedisNodeRoleLabel] != RedisRoleMaster || service.Spec.Selector[RedisNodeNameStatefulsetLabel] != "redis-cluster" {
+		t.Fatalf("Expected master Service to select the master of the cluster. Got selector %v", service.Spec.Selector)
+	}
+	if len(service.GetOwnerReferences()) == 0 || service.GetOwnerReferences()[0].Name != cluster.Name {
+		t.Fatalf("Owner reference is not set on master Service")
+	}
+}
+
+func TestRoleServicesAreCreatedAndDeleted(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	client := fake.NewClientBuilder().Build()
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+
+	err := EnsureRoleService(context.TODO(), client, cluster, RedisRoleReplica)
+	if err != nil {
+		t.Fatalf("Expected replicas Service to be created, but received an error %v", err)
+	}
+	service := &v1.Service{}
+	err = client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "redis-cluster-replicas"}, service)
+	if err != nil {
+		t.Fatalf("Expected replicas Service to be found, but received an error %v", err)
+	}
+	if service.Spec.Selector[RedisNodeRoleLabel] != RedisRoleReplica || service.Spec.Selector[RedisNodeNameStatefulsetLabel] != "redis-cluster" {
+		t.Fatalf("Expected replicas Service to select the replicas of the cluster. Got selector %v", service.Spec.Selector)
+	}
+
+	err = DeleteRoleService(context.TODO(), client, cluster, RedisRoleReplica)
+	if err != nil {
+		t.Fatalf("Expected replicas Service to be deleted, but received an error %v", err)
+	}
+	err = client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "redis-cluster-replicas"}, service)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("Expected replicas Service to be deleted. Got %v", err)
+	}
+}
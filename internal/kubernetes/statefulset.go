@@ -1,36 +1,83 @@
 package kubernetes
 
 import (
+	"bytes"
 	"context"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	"github.com/containersolutions/redis-cluster-operator/internal/utils"
 	v1 "k8s.io/api/apps/v1"
 	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 )
 
 const (
+	// FieldManager is the field manager the Operator uses when applying objects with server-side apply
+	FieldManager = "redis-cluster-operator"
+
 	RedisNodeNameStatefulsetLabel = "cache.container-solutions.com/cluster-name"
 	RedisNodeComponentLabel       = "cache.container-solutions.com/cluster-component"
+
+	// RedisNodeRoleLabel is set by the Operator on the pods of a cluster to the current role of their Redis node,
+	// so Services can select only the masters or only the replicas
+	RedisNodeRoleLabel = "cache.container-solutions.com/role"
+	RedisRoleMaster    = "master"
+	RedisRoleReplica   = "replica"
+
+	// RedisNodeIDLabel is set by the Operator on the pods of a cluster in Cluster mode, to the ID of their Redis node
+	RedisNodeIDLabel = "cache.container-solutions.com/node-id"
+
+	// RedisNodeReadyCondition is the readiness gate of the pods of a cluster.
+	// The Operator sets it once the Redis node can serve clients, according to the state of the cluster.
+	RedisNodeReadyCondition v12.PodConditionType = "cache.container-solutions.com/node-ready"
+
+	// StatefulsetSpecChecksumAnnotation is set on the Statefulset to the checksum of the spec the Operator applied,
+	// so fields removed from the RedisCluster are seen as drift as well
+	StatefulsetSpecChecksumAnnotation = "cache.container-solutions.com/spec-checksum"
+
+	// legacyFieldManager is the field manager of the fields the Operator wrote before it set a field manager.
+	// The API server names the field manager after the binary when the client does not set one.
+	legacyFieldManager = "manager"
 )
 
-func GetStatefulSetLabels(cluster *v1alpha1.RedisCluster) labels.Set {
+// getReadinessGates returns the readiness gates from the pod spec of the cluster, together with the gate set by the Operator
+func getReadinessGates(cluster *v1beta1.RedisCluster) []v12.PodReadinessGate {
+	gates := []v12.PodReadinessGate{
+		{
+			ConditionType: RedisNodeReadyCondition,
+		},
+	}
+	for _, gate := range cluster.Spec.PodSpec.ReadinessGates {
+		if gate.ConditionType != RedisNodeReadyCondition {
+			gates = append(gates, gate)
+		}
+	}
+	return gates
+}
+
+func GetStatefulSetLabels(cluster *v1beta1.RedisCluster) labels.Set {
 	return labels.Set{
 		RedisNodeNameStatefulsetLabel: cluster.Name,
 	}
 }
 
-func GetPodLabels(cluster *v1alpha1.RedisCluster) labels.Set {
+func GetPodLabels(cluster *v1beta1.RedisCluster) labels.Set {
 	return labels.Set{
 		RedisNodeNameStatefulsetLabel: cluster.Name,
 		RedisNodeComponentLabel:       "redis",
 	}
 }
 
-func FetchExistingStatefulset(ctx context.Context, kubeClient client.Client, cluster *v1alpha1.RedisCluster) (*v1.StatefulSet, error) {
+func FetchExistingStatefulset(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.StatefulSet, error) {
 	statefulset := &v1.StatefulSet{}
 	err := kubeClient.Get(ctx, types.NamespacedName{
 		Namespace: cluster.Namespace,
@@ -39,7 +86,124 @@ func FetchExistingStatefulset(ctx context.Context, kubeClient client.Client, clu
 	return statefulset, err
 }
 
-func createStatefulsetSpec(cluster *v1alpha1.RedisCluster) *v1.StatefulSet {
+// UsesHostnames returns whether the pods of the Statefulset have stable DNS names through the headless Service.
+// Statefulsets created before the headless Service existed use the ClusterIP Service, which cannot be changed,
+// so the Redis nodes in these Statefulsets are addressed by IP.
+func UsesHostnames(cluster *v1beta1.RedisCluster, statefulset *v1.StatefulSet) bool {
+	return statefulset.Spec.ServiceName == GetHeadlessServiceName(cluster)
+}
+
+// GetRedisPodHost returns the host the Operator should use to connect to the Redis node in the pod
+func GetRedisPodHost(cluster *v1beta1.RedisCluster, statefulset *v1.StatefulSet, pod *v12.Pod) string {
+	if UsesHostnames(cluster, statefulset) {
+		return GetPodHostname(cluster, pod.Name)
+	}
+	return pod.Status.PodIP
+}
+
+func createStatefulsetSpec(cluster *v1beta1.RedisCluster) *v1.StatefulSet {
+	return createStatefulsetSpecForService(cluster, GetHeadlessServiceName(cluster))
+}
+
+// getRedisArgs returns the arguments for the Redis server.
+// When the pods have stable DNS names, every node announces its own hostname to the rest of the cluster,
+// and asks clients to connect through the hostname instead of the IP, which changes whenever the pod restarts.
+// With external access, clients are redirected to the IP of the external Service instead.
+func getRedisArgs(cluster *v1beta1.RedisCluster, serviceName string) []string {
+	args := []string{
+		"/usr/local/etc/redis/redis.conf",
+	}
+	if cluster.Spec.Auth != nil {
+		// Replicas authenticate against their master with the same password clients use
+		args = append(args, "--requirepass", "$(REDISCLI_AUTH)", "--masterauth", "$(REDISCLI_AUTH)")
+	}
+	if cluster.IsReplicationMode() || serviceName != GetHeadlessServiceName(cluster) {
+		return args
+	}
+	args = append(args, "--cluster-announce-hostname", fmt.Sprintf("$(POD_NAME).%s.%s.svc", serviceName, cluster.Namespace))
+	if cluster.Spec.ExternalAccess == nil {
+		// External clients cannot resolve the hostnames, so they need to be redirected to the announced IP instead
+		args = append(args, "--cluster-preferred-endpoint-type", "hostname")
+	}
+	return args
+}
+
+func getRedisImage(cluster *v1beta1.RedisCluster) string {
+	if cluster.Spec.Redis.Image != "" {
+		return cluster.Spec.Redis.Image
+	}
+	return v1beta1.DefaultRedisImage
+}
+
+// getRedisEnv returns the environment of the Redis container.
+// redis-cli picks up the password from REDISCLI_AUTH, so the probes and anyone using kubectl exec are authenticated as well.
+func getRedisEnv(cluster *v1beta1.RedisCluster) []v12.EnvVar {
+	env := []v12.EnvVar{
+		{
+			Name: "POD_NAME",
+			ValueFrom: &v12.EnvVarSource{
+				FieldRef: &v12.ObjectFieldSelector{
+					APIVersion: "v1",
+					FieldPath:  "metadata.name",
+				},
+			},
+		},
+	}
+	if cluster.Spec.Auth != nil {
+		env = append(env, v12.EnvVar{
+			Name: "REDISCLI_AUTH",
+			ValueFrom: &v12.EnvVarSource{
+				SecretKeyRef: cluster.Spec.Auth.PasswordSecret.DeepCopy(),
+			},
+		})
+	}
+	return env
+}
+
+func getRedisVolumeMounts(cluster *v1beta1.RedisCluster) []v12.VolumeMount {
+	mounts := []v12.VolumeMount{
+		{
+			Name:      "redis-cluster-config",
+			MountPath: "/usr/local/etc/redis",
+		},
+	}
+	if cluster.Spec.Storage != nil {
+		// /data is the working directory of the Redis image, where it keeps the snapshots and nodes.conf
+		mounts = append(mounts, v12.VolumeMount{
+			Name:      "data",
+			MountPath: "/data",
+		})
+	}
+	return mounts
+}
+
+// getVolumeClaimTemplates returns the claim for the data volume of each node.
+// The claims are named data-<cluster>-<ordinal>, and are labeled with the pod labels by the Statefulset controller.
+func getVolumeClaimTemplates(cluster *v1beta1.RedisCluster) []v12.PersistentVolumeClaim {
+	if cluster.Spec.Storage == nil {
+		return nil
+	}
+	return []v12.PersistentVolumeClaim{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "data",
+			},
+			Spec: v12.PersistentVolumeClaimSpec{
+				AccessModes: []v12.PersistentVolumeAccessMode{
+					v12.ReadWriteOnce,
+				},
+				Resources: v12.ResourceRequirements{
+					Requests: v12.ResourceList{
+						v12.ResourceStorage: cluster.Spec.Storage.Size,
+					},
+				},
+				StorageClassName: cluster.Spec.Storage.StorageClassName,
+			},
+		},
+	}
+}
+
+func createStatefulsetSpecForService(cluster *v1beta1.RedisCluster, serviceName string) *v1.StatefulSet {
 	replicasNeeded := cluster.NodesNeeded()
 	statefulset := &v1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -81,16 +245,16 @@ func createStatefulsetSpec(cluster *v1alpha1.RedisCluster) *v1.StatefulSet {
 						cluster.Spec.PodSpec.InitContainers,
 					),
 					Containers: utils.MergeContainers(
-						[]v12.Container{
+						append([]v12.Container{
 							{
 								Name:  "redis",
-								Image: "redis:7.0.0",
+								Image: getRedisImage(cluster),
 								Command: []string{
 									"redis-server",
 								},
-								Args: []string{
-									"/usr/local/etc/redis/redis.conf",
-								},
+								Args:      getRedisArgs(cluster, serviceName),
+								Env:       getRedisEnv(cluster),
+								Resources: *cluster.Spec.Resources.DeepCopy(),
 								Ports: []v12.ContainerPort{
 									{
 										Name:          "redis",
@@ -113,6 +277,8 @@ func createStatefulsetSpec(cluster *v1alpha1.RedisCluster) *v1.StatefulSet {
 									InitialDelaySeconds: 10,
 									TimeoutSeconds:      5,
 									PeriodSeconds:       3,
+									SuccessThreshold:    1,
+									FailureThreshold:    3,
 								},
 								ReadinessProbe: &v12.Probe{
 									ProbeHandler: v12.ProbeHandler{
@@ -126,15 +292,12 @@ func createStatefulsetSpec(cluster *v1alpha1.RedisCluster) *v1.StatefulSet {
 									InitialDelaySeconds: 10,
 									TimeoutSeconds:      5,
 									PeriodSeconds:       3,
+									SuccessThreshold:    1,
+									FailureThreshold:    3,
 								},
-								VolumeMounts: []v12.VolumeMount{
-									{
-										Name:      "redis-cluster-config",
-										MountPath: "/usr/local/etc/redis",
-									},
-								},
+								VolumeMounts: getRedisVolumeMounts(cluster),
 							},
-						},
+						}, getExporterContainers(cluster)...),
 						cluster.Spec.PodSpec.Containers,
 					),
 					EphemeralContainers:           cluster.Spec.PodSpec.EphemeralContainers,
@@ -159,7 +322,7 @@ func createStatefulsetSpec(cluster *v1alpha1.RedisCluster) *v1.StatefulSet {
 					PriorityClassName:             cluster.Spec.PodSpec.PriorityClassName,
 					Priority:                      cluster.Spec.PodSpec.Priority,
 					DNSConfig:                     cluster.Spec.PodSpec.DNSConfig,
-					ReadinessGates:                cluster.Spec.PodSpec.ReadinessGates,
+					ReadinessGates:                getReadinessGates(cluster),
 					RuntimeClassName:              cluster.Spec.PodSpec.RuntimeClassName,
 					EnableServiceLinks:            cluster.Spec.PodSpec.EnableServiceLinks,
 					PreemptionPolicy:              cluster.Spec.PodSpec.PreemptionPolicy,
@@ -169,15 +332,140 @@ func createStatefulsetSpec(cluster *v1alpha1.RedisCluster) *v1.StatefulSet {
 					OS:                            cluster.Spec.PodSpec.OS,
 				},
 			},
-			ServiceName:     cluster.Name,
-			MinReadySeconds: 10,
+			VolumeClaimTemplates: getVolumeClaimTemplates(cluster),
+			ServiceName:          serviceName,
+			MinReadySeconds:      10,
 		},
 	}
+	statefulset.Annotations = map[string]string{
+		StatefulsetSpecChecksumAnnotation: getStatefulsetSpecChecksum(statefulset),
+	}
 	return statefulset
 }
 
-func CreateStatefulset(ctx context.Context, kubeClient client.Client, cluster *v1alpha1.RedisCluster) (*v1.StatefulSet, error) {
+// getStatefulsetSpecChecksum returns the checksum of the labels and spec of the Statefulset.
+// Replicas and claim templates are left out, as applying the spec keeps the existing ones.
+func getStatefulsetSpecChecksum(statefulset *v1.StatefulSet) string {
+	spec := statefulset.Spec.DeepCopy()
+	spec.Replicas = nil
+	spec.VolumeClaimTemplates = nil
+	data, _ := json.Marshal(struct {
+		Labels map[string]string   `json:"labels"`
+		Spec   *v1.StatefulSetSpec `json:"spec"`
+	}{statefulset.Labels, spec})
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// CreateStatefulset creates the Statefulset for the cluster, with the field manager the Operator applies changes with
+func CreateStatefulset(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster) (*v1.StatefulSet, error) {
 	statefulset := createStatefulsetSpec(cluster)
-	err := kubeClient.Create(ctx, statefulset)
+	err := kubeClient.Create(ctx, statefulset, client.FieldOwner(FieldManager))
 	return statefulset, err
 }
+
+// StatefulsetNeedsUpdate compares the live Statefulset against the one we would create for the cluster.
+// Fields removed from the cluster spec are found through the checksum of the spec we last applied.
+// Changes made by hand are found by comparing the fields we set. The API server defaults a lot of fields we don't set,
+// so we use a semantic derivative comparison, which ignores any fields left empty in the desired Statefulset.
+// Replicas are not compared, as scaling is handled separately from the rest of the spec.
+func StatefulsetNeedsUpdate(existing *v1.StatefulSet, cluster *v1beta1.RedisCluster) bool {
+	desired := createStatefulsetSpecForService(cluster, existing.Spec.ServiceName)
+	if existing.Annotations[StatefulsetSpecChecksumAnnotation] != desired.Annotations[StatefulsetSpecChecksumAnnotation] {
+		return true
+	}
+	if !equality.Semantic.DeepDerivative(desired.Labels, existing.Labels) {
+		return true
+	}
+	if desired.Spec.MinReadySeconds != existing.Spec.MinReadySeconds {
+		return true
+	}
+	return !equality.Semantic.DeepDerivative(desired.Spec.Template, existing.Spec.Template)
+}
+
+// ApplyStatefulset updates the existing Statefulset to match the cluster spec using server-side apply.
+// We keep the replicas of the existing Statefulset, so applying the spec never scales the cluster by accident,
+// and the service name, as it cannot be changed once the Statefulset is created.
+func ApplyStatefulset(ctx context.Context, kubeClient client.Client, cluster *v1beta1.RedisCluster, existing *v1.StatefulSet) (*v1.StatefulSet, error) {
+	statefulset := createStatefulsetSpecForService(cluster, existing.Spec.ServiceName)
+	// Server-side apply needs the type information, which is usually inferred by the client
+	statefulset.TypeMeta = metav1.TypeMeta{
+		APIVersion: v1.SchemeGroupVersion.String(),
+		Kind:       "StatefulSet",
+	}
+	statefulset.Spec.Replicas = existing.Spec.Replicas
+	if len(statefulset.Spec.VolumeClaimTemplates) != len(existing.Spec.VolumeClaimTemplates) {
+		return nil, errors.New("the storage of a cluster cannot be added or removed once the cluster is created")
+	}
+	// The claim templates cannot be changed either, so we keep the existing ones
+	statefulset.Spec.VolumeClaimTemplates = existing.Spec.VolumeClaimTemplates
+	err := controllerutil.SetControllerReference(cluster, statefulset, kubeClient.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	err = migrateFieldManagers(ctx, kubeClient, existing)
+	if err != nil {
+		return nil, err
+	}
+	err = kubeClient.Patch(ctx, statefulset, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+	return statefulset, err
+}
+
+// migrateFieldManagers hands the fields the Operator wrote with create and update requests over to its apply field manager.
+// Server-side apply only removes fields which are owned by the field manager applying the object,
+// so without this, fields removed from the cluster spec would stay on Statefulsets created by create requests.
+func migrateFieldManagers(ctx context.Context, kubeClient client.Client, existing *v1.StatefulSet) error {
+	original := existing.DeepCopy()
+	migrated, err := upgradeManagedFields(existing)
+	if err != nil || !migrated {
+		return err
+	}
+	return kubeClient.Patch(ctx, existing, client.MergeFrom(original))
+}
+
+// upgradeManagedFields merges the fields owned by update requests of the Operator into the fields owned by its applies.
+// Returns whether any fields were moved.
+func upgradeManagedFields(object metav1.Object) (bool, error) {
+	applied := fieldpath.NewSet()
+	var apiVersion string
+	var managedFields []metav1.ManagedFieldsEntry
+	migrated := false
+	for _, entry := range object.GetManagedFields() {
+		isApply := entry.Manager == FieldManager && entry.Operation == metav1.ManagedFieldsOperationApply
+		isUpdate := (entry.Manager == FieldManager || entry.Manager == legacyFieldManager) &&
+			entry.Operation == metav1.ManagedFieldsOperationUpdate && entry.Subresource == ""
+		if !isApply && !isUpdate {
+			managedFields = append(managedFields, entry)
+			continue
+		}
+		if entry.FieldsV1 != nil {
+			fields := fieldpath.NewSet()
+			err := fields.FromJSON(bytes.NewReader(entry.FieldsV1.Raw))
+			if err != nil {
+				return false, err
+			}
+			applied = applied.Union(fields)
+		}
+		if isApply || apiVersion == "" {
+			apiVersion = entry.APIVersion
+		}
+		migrated = migrated || isUpdate
+	}
+	if !migrated {
+		return false, nil
+	}
+	raw, err := applied.ToJSON()
+	if err != nil {
+		return false, err
+	}
+	now := metav1.Now()
+	managedFields = append(managedFields, metav1.ManagedFieldsEntry{
+		Manager:    FieldManager,
+		Operation:  metav1.ManagedFieldsOperationApply,
+		APIVersion: apiVersion,
+		Time:       &now,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: raw},
+	})
+	object.SetManagedFields(managedFields)
+	return true, nil
+}
@@ -2,26 +2,29 @@ package kubernetes
 
 import (
 	"context"
-	cachev1alpha1 "github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	kubernetes_fake "github.com/containersolutions/redis-cluster-operator/internal/kubernetes/fake"
 	v1 "k8s.io/api/apps/v1"
 	v13 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sort"
+	"strings"
 	"testing"
 )
 
 func TestFetchExistingStatefulSetReturnsErrorIfNotFound(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	s.AddKnownTypes(cachev1alpha1.GroupVersion)
+	s.AddKnownTypes(cachev1beta1.GroupVersion)
 	clientBuilder := fake.NewClientBuilder()
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -40,7 +43,7 @@ func TestFetchExistingStatefulSetReturnsErrorIfNotFound(t *testing.T) {
 func TestFetchExistingStatefulSetReturnsStatefulsetIfFound(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	s.AddKnownTypes(cachev1alpha1.GroupVersion)
+	s.AddKnownTypes(cachev1beta1.GroupVersion)
 	clientBuilder := fake.NewClientBuilder()
 
 	clientBuilder.WithObjects(&v1.StatefulSet{
@@ -52,7 +55,7 @@ func TestFetchExistingStatefulSetReturnsStatefulsetIfFound(t *testing.T) {
 
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -71,7 +74,7 @@ func TestFetchExistingStatefulSetReturnsStatefulsetIfFound(t *testing.T) {
 func TestFetchExistingStatefulSetReturnsCorrectStatefulsetIfMany(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	s.AddKnownTypes(cachev1alpha1.GroupVersion)
+	s.AddKnownTypes(cachev1beta1.GroupVersion)
 	clientBuilder := fake.NewClientBuilder()
 
 	clientBuilder.WithObjects(&v1.StatefulSet{
@@ -93,7 +96,7 @@ func TestFetchExistingStatefulSetReturnsCorrectStatefulsetIfMany(t *testing.T) {
 
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -112,11 +115,11 @@ func TestFetchExistingStatefulSetReturnsCorrectStatefulsetIfMany(t *testing.T) {
 func TestCreateStatefulset_CanCreateStatefulset(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	s.AddKnownTypes(cachev1alpha1.GroupVersion)
+	s.AddKnownTypes(cachev1beta1.GroupVersion)
 	clientBuilder := fake.NewClientBuilder()
 	client := clientBuilder.Build()
 
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -145,7 +148,7 @@ func TestCreateStatefulset_CanCreateStatefulset(t *testing.T) {
 func TestCreateStatefulset_ThrowsErrorIfStatefulsetAlreadyExists(t *testing.T) {
 	// Register operator types with the runtime scheme.
 	s := scheme.Scheme
-	s.AddKnownTypes(cachev1alpha1.GroupVersion)
+	s.AddKnownTypes(cachev1beta1.GroupVersion)
 	clientBuilder := fake.NewClientBuilder()
 
 	clientBuilder.WithObjects(&v1.StatefulSet{
@@ -156,7 +159,7 @@ func TestCreateStatefulset_ThrowsErrorIfStatefulsetAlreadyExists(t *testing.T) {
 	})
 
 	client := clientBuilder.Build()
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -171,7 +174,7 @@ func TestCreateStatefulset_ThrowsErrorIfStatefulsetAlreadyExists(t *testing.T) {
 
 func TestCreateStatefulset_MountsConfigMapAsVolumeCorrectly(t *testing.T) {
 	// Register operator types with the runtime scheme.
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -222,7 +225,7 @@ func TestCreateStatefulset_MountsConfigMapAsVolumeCorrectly(t *testing.T) {
 
 func TestCreateStatefulset_SetsLivenessAndReadinessProbes(t *testing.T) {
 	// Register operator types with the runtime scheme.
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
@@ -239,14 +242,38 @@ func TestCreateStatefulset_SetsLivenessAndReadinessProbes(t *testing.T) {
 	}
 }
 
+func TestCreateStatefulsetSpec_AddsNodeReadinessGate(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			PodSpec: v13.PodSpec{
+				ReadinessGates: []v13.PodReadinessGate{
+					{
+						ConditionType: "example.com/load-balancer-ready",
+					},
+				},
+			},
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+
+	gates := statefulset.Spec.Template.Spec.ReadinessGates
+	if len(gates) != 2 || gates[0].ConditionType != RedisNodeReadyCondition || gates[1].ConditionType != "example.com/load-balancer-ready" {
+		t.Fatalf("Expected the node readiness gate to be added to the readiness gates of the pod spec. Got %v", gates)
+	}
+}
+
 func TestCreateStatefulsetSpec_CanAddAdditionalContainers(t *testing.T) {
 	// Register operator types with the runtime scheme.
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 			PodSpec: v13.PodSpec{
@@ -277,12 +304,12 @@ func TestCreateStatefulsetSpec_CanAddAdditionalContainers(t *testing.T) {
 
 func TestCreateStatefulsetSpec_CanOverrideRedisConfigurations(t *testing.T) {
 	// Register operator types with the runtime scheme.
-	cluster := &cachev1alpha1.RedisCluster{
+	cluster := &cachev1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: cachev1alpha1.RedisClusterSpec{
+		Spec: cachev1beta1.RedisClusterSpec{
 			Masters:           3,
 			ReplicasPerMaster: 1,
 			PodSpec: v13.PodSpec{
@@ -316,3 +343,435 @@ func TestCreateStatefulsetSpec_CanOverrideRedisConfigurations(t *testing.T) {
 		t.Fatalf("Additional port was not added")
 	}
 }
+
+func TestStatefulsetNeedsUpdate(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+	// Fields defaulted by the API server should not be seen as drift
+	statefulset.Spec.Template.Spec.SchedulerName = "default-scheduler"
+	statefulset.Spec.Template.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+	statefulset.Spec.Template.Spec.Containers[0].ImagePullPolicy = v13.PullIfNotPresent
+	if StatefulsetNeedsUpdate(statefulset, cluster) {
+		t.Fatalf("Expected Statefulset with server defaults to match the cluster")
+	}
+
+	cluster.Spec.PodSpec.Tolerations = []v13.Toleration{
+		{
+			Key:      "dedicated",
+			Operator: v13.TolerationOpEqual,
+			Value:    "redis",
+			Effect:   v13.TaintEffectNoSchedule,
+		},
+	}
+	if !StatefulsetNeedsUpdate(statefulset, cluster) {
+		t.Fatalf("Expected Statefulset to need an update after tolerations were added to the cluster")
+	}
+}
+
+func TestStatefulsetNeedsUpdate_WhenTolerationIsRemoved(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}
+	cluster.Spec.PodSpec.Tolerations = []v13.Toleration{
+		{
+			Key:      "dedicated",
+			Operator: v13.TolerationOpEqual,
+			Value:    "redis",
+			Effect:   v13.TaintEffectNoSchedule,
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+
+	// An empty field in the desired Statefulset is ignored by the semantic comparison, so only the checksum sees the removal
+	cluster.Spec.PodSpec.Tolerations = nil
+	if !StatefulsetNeedsUpdate(statefulset, cluster) {
+		t.Fatalf("Expected Statefulset to need an update after the toleration was removed from the cluster")
+	}
+}
+
+func TestApplyStatefulset_RemovesToleration(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}
+	client := kubernetes_fake.NewApplyAsMergePatchClient(fake.NewClientBuilder().Build())
+	existing, err := CreateStatefulset(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be created sucessfully, but received an error %v", err)
+	}
+
+	cluster.Spec.PodSpec.Tolerations = []v13.Toleration{
+		{
+			Key:      "dedicated",
+			Operator: v13.TolerationOpEqual,
+			Value:    "redis",
+			Effect:   v13.TaintEffectNoSchedule,
+		},
+	}
+	_, err = ApplyStatefulset(context.TODO(), client, cluster, existing)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be applied sucessfully, but received an error %v", err)
+	}
+	existing, err = FetchExistingStatefulset(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be found, but received an error %v", err)
+	}
+	if len(existing.Spec.Template.Spec.Tolerations) != 1 {
+		t.Fatalf("Expected the toleration to be added. Got %v", existing.Spec.Template.Spec.Tolerations)
+	}
+
+	cluster.Spec.PodSpec.Tolerations = nil
+	if !StatefulsetNeedsUpdate(existing, cluster) {
+		t.Fatalf("Expected Statefulset to need an update after the toleration was removed from the cluster")
+	}
+	_, err = ApplyStatefulset(context.TODO(), client, cluster, existing)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be applied sucessfully, but received an error %v", err)
+	}
+	statefulset, err := FetchExistingStatefulset(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be found, but received an error %v", err)
+	}
+	if len(statefulset.Spec.Template.Spec.Tolerations) != 0 {
+		t.Fatalf("Expected the toleration to be removed. Got %v", statefulset.Spec.Template.Spec.Tolerations)
+	}
+	if StatefulsetNeedsUpdate(statefulset, cluster) {
+		t.Fatalf("Expected Statefulset to match the cluster after applying")
+	}
+}
+
+func TestUpgradeManagedFields_MovesFieldsOfTheOperatorToItsApply(t *testing.T) {
+	statefulset := &v1.StatefulSet{}
+	statefulset.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			// Statefulsets created before the Operator set a field manager
+			Manager:    "manager",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:tolerations":{}}}}}`)},
+		},
+		{
+			Manager:    FieldManager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:minReadySeconds":{}}}`)},
+		},
+		{
+			Manager:     "kube-controller-manager",
+			Operation:   metav1.ManagedFieldsOperationUpdate,
+			APIVersion:  "apps/v1",
+			Subresource: "status",
+			FieldsType:  "FieldsV1",
+			FieldsV1:    &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:replicas":{}}}`)},
+		},
+		{
+			Manager:    "kubectl-edit",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:team":{}}}}`)},
+		},
+	})
+
+	migrated, err := upgradeManagedFields(statefulset)
+	if err != nil || !migrated {
+		t.Fatalf("Expected the fields of the Operator to be migrated. Got %v %v", migrated, err)
+	}
+	managers := map[string]metav1.ManagedFieldsEntry{}
+	for _, entry := range statefulset.GetManagedFields() {
+		managers[entry.Manager+"/"+string(entry.Operation)] = entry
+	}
+	if len(managers) != 3 || managers["kube-controller-manager/Update"].Manager == "" || managers["kubectl-edit/Update"].Manager == "" {
+		t.Fatalf("Expected the fields of other field managers to be kept. Got %v", statefulset.GetManagedFields())
+	}
+	applied := string(managers[FieldManager+"/Apply"].FieldsV1.Raw)
+	if !strings.Contains(applied, `"f:tolerations"`) || !strings.Contains(applied, `"f:minReadySeconds"`) {
+		t.Fatalf("Expected the apply of the Operator to own the fields of both entries. Got %s", applied)
+	}
+
+	migrated, err = upgradeManagedFields(statefulset)
+	if err != nil || migrated {
+		t.Fatalf("Expected nothing left to migrate. Got %v %v", migrated, err)
+	}
+}
+
+func TestApplyStatefulset_UpdatesSpecAndKeepsReplicas(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters:           3,
+			ReplicasPerMaster: 1,
+		},
+	}
+	client := kubernetes_fake.NewApplyAsMergePatchClient(fake.NewClientBuilder().Build())
+	_, err := CreateStatefulset(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be created sucessfully, but received an error %v", err)
+	}
+
+	cluster.Spec.PodSpec.Containers = []v13.Container{
+		{
+			Name:  "redis",
+			Image: "redis:7.0.5",
+		},
+	}
+	existing, err := FetchExistingStatefulset(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be found, but received an error %v", err)
+	}
+	replicas := int32(4)
+	existing.Spec.Replicas = &replicas
+	_, err = ApplyStatefulset(context.TODO(), client, cluster, existing)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be applied sucessfully, but received an error %v", err)
+	}
+
+	statefulset, err := FetchExistingStatefulset(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be found, but received an error %v", err)
+	}
+	if statefulset.Spec.Template.Spec.Containers[0].Image != "redis:7.0.5" {
+		t.Fatalf("Expected Redis image to be updated. Got %s", statefulset.Spec.Template.Spec.Containers[0].Image)
+	}
+	if *statefulset.Spec.Replicas != 4 {
+		t.Fatalf("Expected replicas to be kept. Got %d", *statefulset.Spec.Replicas)
+	}
+	if StatefulsetNeedsUpdate(statefulset, cluster) {
+		t.Fatalf("Expected Statefulset to match the cluster after applying")
+	}
+}
+
+func TestCreateStatefulsetSpec_AnnouncesHostnameThroughHeadlessService(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+	if statefulset.Spec.ServiceName != "redis-cluster-headless" {
+		t.Fatalf("Expected Statefulset to use the headless Service. Got %s", statefulset.Spec.ServiceName)
+	}
+	args := strings.Join(statefulset.Spec.Template.Spec.Containers[0].Args, " ")
+	if !strings.Contains(args, "--cluster-announce-hostname $(POD_NAME).redis-cluster-headless.default.svc") ||
+		!strings.Contains(args, "--cluster-preferred-endpoint-type hostname") {
+		t.Fatalf("Expected Redis to announce its hostname. Got args %s", args)
+	}
+
+	pod := &v13.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "redis-cluster-0",
+		},
+		Status: v13.PodStatus{
+			PodIP: "10.0.0.1",
+		},
+	}
+	if GetRedisPodHost(cluster, statefulset, pod) != "redis-cluster-0.redis-cluster-headless.default.svc" {
+		t.Fatalf("Expected pod to be addressed by hostname. Got %s", GetRedisPodHost(cluster, statefulset, pod))
+	}
+
+	// Statefulsets created before the headless Service keep using IPs
+	legacyStatefulset := createStatefulsetSpecForService(cluster, cluster.Name)
+	if strings.Contains(strings.Join(legacyStatefulset.Spec.Template.Spec.Containers[0].Args, " "), "--cluster-announce-hostname") {
+		t.Fatalf("Expected Redis not to announce a hostname which cannot be resolved")
+	}
+	if GetRedisPodHost(cluster, legacyStatefulset, pod) != "10.0.0.1" {
+		t.Fatalf("Expected pod to be addressed by IP. Got %s", GetRedisPodHost(cluster, legacyStatefulset, pod))
+	}
+	if StatefulsetNeedsUpdate(legacyStatefulset, cluster) {
+		t.Fatalf("Expected Statefulset using the ClusterIP Service not to be changed")
+	}
+}
+
+func TestCreateStatefulsetSpec_DoesNotAnnounceClusterInReplicationMode(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Mode:              cachev1beta1.ModeReplication,
+			ReplicasPerMaster: 2,
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+	if *statefulset.Spec.Replicas != 3 {
+		t.Fatalf("Expected a master and 2 replicas. Got %d replicas", *statefulset.Spec.Replicas)
+	}
+	args := strings.Join(statefulset.Spec.Template.Spec.Containers[0].Args, " ")
+	if strings.Contains(args, "--cluster-") {
+		t.Fatalf("Expected Redis not to be given cluster settings. Got args %s", args)
+	}
+}
+
+func TestCreateStatefulsetSpec_UsesRedisSettingsAndResources(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+			Redis: cachev1beta1.RedisSettings{
+				Image: "redis:7.0.5",
+			},
+			Resources: v13.ResourceRequirements{
+				Requests: v13.ResourceList{
+					v13.ResourceMemory: resource.MustParse("512Mi"),
+				},
+			},
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+	redisContainer := statefulset.Spec.Template.Spec.Containers[0]
+	if redisContainer.Image != "redis:7.0.5" {
+		t.Fatalf("Expected the Redis image from the spec. Got %s", redisContainer.Image)
+	}
+	if !redisContainer.Resources.Requests.Memory().Equal(resource.MustParse("512Mi")) {
+		t.Fatalf("Expected the resources from the spec. Got %v", redisContainer.Resources)
+	}
+
+	// Overrides in the pod spec still win
+	cluster.Spec.PodSpec.Containers = []v13.Container{
+		{
+			Name:  "redis",
+			Image: "custom-redis-image:1.0.0",
+		},
+	}
+	statefulset = createStatefulsetSpec(cluster)
+	if statefulset.Spec.Template.Spec.Containers[0].Image != "custom-redis-image:1.0.0" {
+		t.Fatalf("Expected the Redis image from the pod spec. Got %s", statefulset.Spec.Template.Spec.Containers[0].Image)
+	}
+}
+
+func TestCreateStatefulsetSpec_ClaimsStorage(t *testing.T) {
+	storageClass := "fast"
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+			Storage: &cachev1beta1.Storage{
+				Size:             resource.MustParse("10Gi"),
+				StorageClassName: &storageClass,
+			},
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+	if len(statefulset.Spec.VolumeClaimTemplates) != 1 {
+		t.Fatalf("Expected a single volume claim template. Got %d", len(statefulset.Spec.VolumeClaimTemplates))
+	}
+	claim := statefulset.Spec.VolumeClaimTemplates[0]
+	if claim.Name != "data" || *claim.Spec.StorageClassName != "fast" || !claim.Spec.Resources.Requests.Storage().Equal(resource.MustParse("10Gi")) {
+		t.Fatalf("Volume claim template does not match the storage of the cluster. Got %v", claim)
+	}
+	mounted := false
+	for _, mount := range statefulset.Spec.Template.Spec.Containers[0].VolumeMounts {
+		if mount.Name == "data" && mount.MountPath == "/data" {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Fatalf("Expected the data volume to be mounted in the Redis container")
+	}
+
+	if len(createStatefulsetSpec(&cachev1beta1.RedisCluster{}).Spec.VolumeClaimTemplates) != 0 {
+		t.Fatalf("Expected no volume claim templates without storage")
+	}
+}
+
+func TestApplyStatefulset_CannotAddStorage(t *testing.T) {
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	_ = cachev1beta1.AddToScheme(s)
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+		},
+	}
+	client := kubernetes_fake.NewApplyAsMergePatchClient(fake.NewClientBuilder().Build())
+	existing, err := CreateStatefulset(context.TODO(), client, cluster)
+	if err != nil {
+		t.Fatalf("Expected Statefulset to be created sucessfully, but received an error %v", err)
+	}
+
+	cluster.Spec.Storage = &cachev1beta1.Storage{
+		Size: resource.MustParse("10Gi"),
+	}
+	_, err = ApplyStatefulset(context.TODO(), client, cluster, existing)
+	if err == nil {
+		t.Fatalf("Expected an error when adding storage to an existing cluster")
+	}
+}
+
+func TestCreateStatefulsetSpec_ProtectsRedisWithPassword(t *testing.T) {
+	cluster := &cachev1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "redis-cluster",
+			Namespace: "default",
+		},
+		Spec: cachev1beta1.RedisClusterSpec{
+			Masters: 3,
+			Auth: &cachev1beta1.Auth{
+				PasswordSecret: v13.SecretKeySelector{
+					LocalObjectReference: v13.LocalObjectReference{Name: "redis-password"},
+					Key:                  "password",
+				},
+			},
+		},
+	}
+	statefulset := createStatefulsetSpec(cluster)
+	redisContainer := statefulset.Spec.Template.Spec.Containers[0]
+	args := strings.Join(redisContainer.Args, " ")
+	if !strings.Contains(args, "--requirepass $(REDISCLI_AUTH) --masterauth $(REDISCLI_AUTH)") {
+		t.Fatalf("Expected Redis to require the password. Got args %s", args)
+	}
+	var passwordEnv *v13.EnvVar
+	for i, env := range redisContainer.Env {
+		if env.Name == "REDISCLI_AUTH" {
+			passwordEnv = &redisContainer.Env[i]
+		}
+	}
+	if passwordEnv == nil || passwordEnv.ValueFrom.SecretKeyRef.Name != "redis-password" || passwordEnv.ValueFrom.SecretKeyRef.Key != "password" {
+		t.Fatalf("Expected the password to be loaded from the secret. Got env %v", redisContainer.Env)
+	}
+}
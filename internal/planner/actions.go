@@ -0,0 +1,187 @@
+package planner
+
+import (
+	"fmt"
+)
+
+// Action is a single change to the cluster. Actions refer to nodes by their ID.
+// Every action knows how it changes a topology, so plans can be checked without applying them to any nodes.
+type Action interface {
+	fmt.Stringer
+	apply(t *Topology)
+}
+
+// Meet lets the node meet the peer, after which the gossip protocol introduces them to the rest of the cluster
+type Meet struct {
+	Node string
+	Peer string
+}
+
+func (a Meet) String() string {
+	return fmt.Sprintf("meet node %s with node %s", a.Node, a.Peer)
+}
+
+func (a Meet) apply(t *Topology) {
+	node, peer := t.node(a.Node), t.node(a.Peer)
+	if node == nil || peer == nil {
+		return
+	}
+	node.Peers[peer.ID] = peer.Address
+	peer.Peers[node.ID] = node.Address
+}
+
+// Replicate turns the node into a replica of the master. The node must not own any slots.
+type Replicate struct {
+	Node   string
+	Master string
+}
+
+func (a Replicate) String() string {
+	return fmt.Sprintf("make node %s replicate node %s", a.Node, a.Master)
+}
+
+func (a Replicate) apply(t *Topology) {
+	node := t.node(a.Node)
+	if node == nil {
+		return
+	}
+	node.Master = false
+	node.MasterID = a.Master
+}
+
+// Reset turns the replica into an empty master, through a soft reset.
+// The reset also makes the node forget the rest of the cluster, so it needs to meet the other nodes again.
+type Reset struct {
+	Node string
+}
+
+func (a Reset) String() string {
+	return fmt.Sprintf("reset node %s to an empty master", a.Node)
+}
+
+func (a Reset) apply(t *Topology) {
+	node := t.node(a.Node)
+	if node == nil {
+		return
+	}
+	node.Master = true
+	node.MasterID = ""
+	node.Slots = nil
+	node.Peers = map[string]string{}
+}
+
+// AddSlots assigns slots which are not owned by any master to the node
+type AddSlots struct {
+	Node  string
+	Slots []int32
+}
+
+func (a AddSlots) String() string {
+	return fmt.Sprintf("assign %d slots to node %s", len(a.Slots), a.Node)
+}
+
+func (a AddSlots) apply(t *Topology) {
+	node := t.node(a.Node)
+	if node == nil {
+		return
+	}
+	node.Slots = append(node.Slots, a.Slots...)
+	sortSlots(node.Slots)
+}
+
+// MoveSlots migrates the slots, and the keys in them, from the source to the destination master
+type MoveSlots struct {
+	Source      string
+	Destination string
+	Slots       []int32
+}
+
+func (a MoveSlots) String() string {
+	return fmt.Sprintf("move %d slots from node %s to node %s", len(a.Slots), a.Source, a.Destination)
+}
+
+func (a MoveSlots) apply(t *Topology) {
+	source, destination := t.node(a.Source), t.node(a.Destination)
+	if source == nil || destination == nil {
+		return
+	}
+	moved := map[int32]bool{}
+	for _, slot := range a.Slots {
+		moved[slot] = true
+	}
+	var kept []int32
+	for _, slot := range source.Slots {
+		if moved[slot] {
+			destination.Slots = append(destination.Slots, slot)
+			continue
+		}
+		kept = append(kept, slot)
+	}
+	source.Slots = kept
+	sortSlots(destination.Slots)
+}
+
+// Forget removes the node from the cluster. Every reachable node needs to forget it.
+type Forget struct {
+	Node string
+}
+
+func (a Forget) String() string {
+	return fmt.Sprintf("forget node %s", a.Node)
+}
+
+func (a Forget) apply(t *Topology) {
+	for i := range t.Nodes {
+		delete(t.Nodes[i].Peers, a.Node)
+	}
+	var failing []string
+	for _, id := range t.Failing {
+		if id != a.Node {
+			failing = append(failing, id)
+		}
+	}
+	t.Failing = failing
+}
+
+// Failover lets the replica take over from its master, which becomes its replica.
+// The data stays in place, so a failover is cheaper than moving the slots.
+type Failover struct {
+	Node string
+}
+
+func (a Failover) String() string {
+	return fmt.Sprintf("fail over to node %s", a.Node)
+}
+
+func (a Failover) apply(t *Topology) {
+	node := t.node(a.Node)
+	if node == nil || node.Master {
+		return
+	}
+	oldMasterID := node.MasterID
+	node.Master = true
+	node.MasterID = ""
+	for i := range t.Nodes {
+		other := &t.Nodes[i]
+		if other.ID == oldMasterID {
+			node.Slots = other.Slots
+			other.Slots = nil
+			other.Master = false
+			other.MasterID = node.ID
+			continue
+		}
+		if !other.Master && other.MasterID == oldMasterID {
+			other.MasterID = node.ID
+		}
+	}
+}
+
+// Apply returns the topology the cluster is expected to have once the actions are applied.
+// The given topology is left unchanged.
+func Apply(t Topology, actions []Action) Topology {
+	result := t.clone()
+	for _, action := range actions {
+		action.apply(&result)
+	}
+	return result
+}
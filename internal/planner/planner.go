@@ -0,0 +1,308 @@
+package planner
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"sort"
+)
+
+// Plan returns the actions which bring the cluster to the amount of masters in the spec, with every slot assigned.
+// The actions are in the order they need to be applied. Nodes which do not know each other meet first,
+// then masters are added or removed, the missing slots are assigned, and finally the failing nodes are forgotten.
+// Plan never moves slots. Emptying surplus masters, rebalancing and scaling down are planned separately,
+// as they move data and only start once the cluster is healthy.
+func Plan(t Topology, cluster *v1beta1.RedisCluster) []Action {
+	actions := PlanMeet(t)
+	actions = append(actions, PlanReplicationRatio(t, cluster)...)
+	actions = append(actions, PlanSlotAssignment(Apply(t, actions), cluster)...)
+	actions = append(actions, PlanForget(t)...)
+	return actions
+}
+
+// PlanMeet plans a meeting for every pair of nodes which do not both know each other on their current address.
+// A node only accepts a node it does not know through a meeting, so one side knowing the other is not enough.
+// Meeting from one side is, as the handshake introduces the nodes to each other.
+func PlanMeet(t Topology) []Action {
+	var actions []Action
+	for i, node := range t.Nodes {
+		for _, peer := range t.Nodes[i+1:] {
+			if node.Peers[peer.ID] == peer.Address && peer.Peers[node.ID] == node.Address {
+				continue
+			}
+			actions = append(actions, Meet{Node: node.ID, Peer: peer.ID})
+		}
+	}
+	return actions
+}
+
+// PlanReplicationRatio plans the actions which make the cluster run the amount of masters in the spec.
+// Surplus masters without slots replicate the kept master with the fewest replicas, together with their own replicas.
+// Surplus masters which still own slots are left to PlanEmptySurplusMasters, as emptying them moves data.
+// When there are too few masters, replicas are reset to empty masters, which get their slots assigned afterwards.
+func PlanReplicationRatio(t Topology, cluster *v1beta1.RedisCluster) []Action {
+	var actions []Action
+	masters := t.Masters()
+	wanted := int(cluster.Spec.Masters)
+
+	if len(masters) > wanted && wanted > 0 {
+		keptMasters, removedMasters := splitSurplusMasters(t, cluster)
+
+		// The replicas of the removed masters move along, as replicas can not replicate other replicas
+		replicaCounts := map[string]int{}
+		replicasOf := map[string][]Node{}
+		for _, replica := range t.Replicas() {
+			replicaCounts[replica.MasterID]++
+			replicasOf[replica.MasterID] = append(replicasOf[replica.MasterID], replica)
+		}
+		for _, removedMaster := range removedMasters {
+			if len(removedMaster.Slots) > 0 {
+				continue
+			}
+			for _, node := range append([]Node{removedMaster}, replicasOf[removedMaster.ID]...) {
+				master := keptMasters[0]
+				for _, keptMaster := range keptMasters[1:] {
+					if replicaCounts[keptMaster.ID] < replicaCounts[master.ID] {
+						master = keptMaster
+					}
+				}
+				actions = append(actions, Replicate{Node: node.ID, Master: master.ID})
+				replicaCounts[master.ID]++
+			}
+		}
+	}
+
+	if len(masters) < wanted {
+		replicas := t.Replicas()
+		needed := wanted - len(masters)
+		if needed > len(replicas) {
+			needed = len(replicas)
+		}
+		for _, replica := range replicas[:needed] {
+			actions = append(actions, Reset{Node: replica.ID})
+		}
+	}
+	return actions
+}
+
+// PlanEmptySurplusMasters plans moving the slots of the masters beyond the masters in the spec to the kept masters.
+// Once they are empty, PlanReplicationRatio turns them into replicas.
+func PlanEmptySurplusMasters(t Topology, cluster *v1beta1.RedisCluster) []Action {
+	var actions []Action
+	keptMasters, removedMasters := splitSurplusMasters(t, cluster)
+	for _, move := range planEmptying(removedMasters, keptMasters) {
+		actions = append(actions, move)
+	}
+	return actions
+}
+
+// splitSurplusMasters returns the masters the cluster keeps, and the masters beyond the masters in the spec.
+// The masters with the most slots are kept, so the fewest slots need to move.
+func splitSurplusMasters(t Topology, cluster *v1beta1.RedisCluster) ([]Node, []Node) {
+	masters := t.Masters()
+	wanted := int(cluster.Spec.Masters)
+	if len(masters) <= wanted || wanted <= 0 {
+		return masters, nil
+	}
+	sorted := make([]Node, len(masters))
+	copy(sorted, masters)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Slots) > len(sorted[j].Slots)
+	})
+	return sorted[:wanted], sorted[wanted:]
+}
+
+// PlanSlotAssignment plans assigning every slot which is not owned by any master.
+// Pinned slots go straight to their master. The remaining slots are spread across the masters by their weights,
+// topping up the masters which own fewer slots than their share, in the order of the topology.
+func PlanSlotAssignment(t Topology, cluster *v1beta1.RedisCluster) []Action {
+	masters := t.Masters()
+	assignment := map[string][]int32{}
+
+	totalWeight := 0
+	for _, node := range masters {
+		totalWeight += int(slotWeight(node, cluster))
+	}
+
+	pins := getSlotPins(t, cluster)
+	var slotsStillToAssign []int32
+	for _, slot := range t.MissingSlots() {
+		if master, ok := pins.masters[slot]; ok {
+			assignment[master] = append(assignment[master], slot)
+			continue
+		}
+		slotsStillToAssign = append(slotsStillToAssign, slot)
+	}
+	unpinnedSlots := TotalSlots - len(pins.masters)
+	for _, node := range masters {
+		if totalWeight == 0 {
+			break
+		}
+		// We add one for the remainder, as 16834 does not go even into an uneven amount of nodes.
+		// By adding one to each node, we don't need a check after to see whether there are unassigned slots left,
+		// as assignable slots will be less than the sum of slotsNeededPerNode for all nodes.
+		slotsNeededPerNode := unpinnedSlots*int(slotWeight(node, cluster))/totalWeight + 1
+		ownedSlots := 0
+		for _, slot := range node.Slots {
+			if _, ok := pins.masters[slot]; !ok {
+				ownedSlots++
+			}
+		}
+		if ownedSlots >= slotsNeededPerNode {
+			continue
+		}
+		slotsNeededForNode := slotsNeededPerNode - ownedSlots
+		if slotsNeededForNode > len(slotsStillToAssign) {
+			slotsNeededForNode = len(slotsStillToAssign)
+		}
+		assignment[node.ID] = append(assignment[node.ID], slotsStillToAssign[:slotsNeededForNode]...)
+		slotsStillToAssign = slotsStillToAssign[slotsNeededForNode:]
+	}
+
+	var actions []Action
+	for _, node := range masters {
+		slots := assignment[node.ID]
+		if len(slots) == 0 {
+			continue
+		}
+		sortSlots(slots)
+		actions = append(actions, AddSlots{Node: node.ID, Slots: slots})
+	}
+	return actions
+}
+
+// PlanForget plans forgetting every node the cluster marks as failing
+func PlanForget(t Topology) []Action {
+	var actions []Action
+	for _, id := range t.Failing {
+		actions = append(actions, Forget{Node: id})
+	}
+	return actions
+}
+
+// EvenSlotCount returns the amount of slots the master in the pod with the given ordinal needs,
+// when slots are spread evenly across all masters. The remainder goes to the masters with the lowest ordinals.
+func EvenSlotCount(ordinal int32, masters int32) int32 {
+	if masters <= 0 {
+		return 0
+	}
+	slots := TotalSlots / masters
+	if ordinal < TotalSlots%masters {
+		slots++
+	}
+	return slots
+}
+
+// SlotQuotas returns the amount of slots each master should own, by the ID of the master.
+// Pinned slots count towards the quota of the master they are pinned to, or the master holding them,
+// and the remaining slots are divided according to the weight of each master.
+// Slots left over after dividing are given to the masters which were rounded down the most,
+// and then to the masters with the lowest ordinals.
+func SlotQuotas(t Topology, cluster *v1beta1.RedisCluster) map[string]int32 {
+	quotas := map[string]int32{}
+	masters := t.Masters()
+	if len(cluster.Spec.SlotWeights) == 0 && len(cluster.Spec.SlotRanges) == 0 {
+		assigned := int32(0)
+		for _, node := range masters {
+			quotas[node.ID] = EvenSlotCount(node.Ordinal, cluster.Spec.Masters)
+			assigned += quotas[node.ID]
+		}
+		// After failovers the masters can run in any pod, so the pods with the lowest ordinals might not run a master.
+		// The slots left over then go to the masters owning the most slots, so a failover does not make slots move.
+		byOwned := make([]Node, len(masters))
+		copy(byOwned, masters)
+		sort.SliceStable(byOwned, func(i, j int) bool {
+			if len(byOwned[i].Slots) != len(byOwned[j].Slots) {
+				return len(byOwned[i].Slots) > len(byOwned[j].Slots)
+			}
+			return byOwned[i].Ordinal < byOwned[j].Ordinal
+		})
+		for _, node := range byOwned {
+			if assigned >= TotalSlots || len(masters) != int(cluster.Spec.Masters) {
+				break
+			}
+			if quotas[node.ID] == TotalSlots/cluster.Spec.Masters {
+				quotas[node.ID]++
+				assigned++
+			}
+		}
+		return quotas
+	}
+
+	totalWeight := 0
+	for _, node := range masters {
+		totalWeight += int(slotWeight(node, cluster))
+	}
+	if totalWeight == 0 {
+		return quotas
+	}
+
+	pins := getSlotPins(t, cluster)
+	fixedSlots := map[string]int{}
+	unpinnedSlots := TotalSlots
+	for _, node := range masters {
+		fixedSlots[node.ID] = pins.fixedSlotCount(node)
+		unpinnedSlots -= fixedSlots[node.ID]
+	}
+
+	assigned := 0
+	remainders := map[string]int{}
+	for _, node := range masters {
+		share := unpinnedSlots * int(slotWeight(node, cluster))
+		quotas[node.ID] = int32(share / totalWeight)
+		remainders[node.ID] = share % totalWeight
+		assigned += share / totalWeight
+	}
+
+	byRemainder := make([]Node, len(masters))
+	copy(byRemainder, masters)
+	sort.SliceStable(byRemainder, func(i, j int) bool {
+		if remainders[byRemainder[i].ID] != remainders[byRemainder[j].ID] {
+			return remainders[byRemainder[i].ID] > remainders[byRemainder[j].ID]
+		}
+		return byRemainder[i].Ordinal < byRemainder[j].Ordinal
+	})
+	for i := 0; assigned < unpinnedSlots; i++ {
+		quotas[byRemainder[i%len(byRemainder)].ID]++
+		assigned++
+	}
+	for _, node := range masters {
+		quotas[node.ID] += int32(fixedSlots[node.ID])
+	}
+	return quotas
+}
+
+// planEmptying plans moving all slots of the sources to the destinations.
+// Every slot goes to the destination owning the fewest slots, so the slots end up spread evenly.
+func planEmptying(sources []Node, destinations []Node) []MoveSlots {
+	var moves []MoveSlots
+	if len(destinations) == 0 {
+		return moves
+	}
+	owned := map[string]int{}
+	for _, node := range destinations {
+		owned[node.ID] = len(node.Slots)
+	}
+	for _, source := range sources {
+		slots := map[string][]int32{}
+		for _, slot := range source.Slots {
+			destination := destinations[0]
+			for _, node := range destinations[1:] {
+				if owned[node.ID] < owned[destination.ID] {
+					destination = node
+				}
+			}
+			owned[destination.ID]++
+			slots[destination.ID] = append(slots[destination.ID], slot)
+		}
+		for _, destination := range destinations {
+			if len(slots[destination.ID]) == 0 {
+				continue
+			}
+			moves = append(moves, MoveSlots{
+				Source:      source.ID,
+				Destination: destination.ID,
+				Slots:       slots[destination.ID],
+			})
+		}
+	}
+	return moves
+}
@@ -0,0 +1,351 @@
+package planner
+
+import (
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// propertyRuns is the amount of random topologies every property is checked against.
+// Every run uses its own seed, so a failing run can be reproduced from the seed in the failure.
+const propertyRuns = 100
+
+func newTestNode(ordinal int, slots ...int32) Node {
+	return Node{
+		ID:      fmt.Sprintf("node-%d", ordinal),
+		Address: fmt.Sprintf("10.0.0.%d:6379", ordinal),
+		Pod:     fmt.Sprintf("rediscluster-%d", ordinal),
+		Ordinal: int32(ordinal),
+		Master:  true,
+		Slots:   slots,
+		Peers:   map[string]string{},
+	}
+}
+
+func slotRange(start int32, end int32) []int32 {
+	var slots []int32
+	for slot := start; slot <= end; slot++ {
+		slots = append(slots, slot)
+	}
+	return slots
+}
+
+// randomTopology returns a cluster spec, and a topology with a pod for every node the spec needs,
+// in any state a cluster can be in. Roles, slots and known peers are all random.
+func randomTopology(r *rand.Rand) (Topology, *v1beta1.RedisCluster) {
+	cluster := &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters:           int32(1 + r.Intn(6)),
+			ReplicasPerMaster: int32(r.Intn(3)),
+		},
+	}
+	if r.Intn(3) == 0 {
+		ordinal := int32(r.Intn(int(cluster.Spec.Masters)))
+		cluster.Spec.SlotWeights = []v1beta1.SlotWeight{
+			{Ordinal: &ordinal, Weight: int32(1 + r.Intn(4))},
+		}
+	}
+	if r.Intn(3) == 0 {
+		start := r.Intn(TotalSlots - 1000)
+		cluster.Spec.SlotRanges = []v1beta1.SlotRange{
+			{Ordinal: int32(r.Intn(int(cluster.NodesNeeded()))), Slots: fmt.Sprintf("%d-%d", start, start+r.Intn(1000))},
+		}
+	}
+
+	topology := Topology{}
+	for i := 0; i < int(cluster.NodesNeeded()); i++ {
+		node := newTestNode(i)
+		node.Master = r.Intn(2) == 0
+		topology.Nodes = append(topology.Nodes, node)
+	}
+	masters := topology.Masters()
+	for i := range topology.Nodes {
+		node := &topology.Nodes[i]
+		if !node.Master {
+			node.MasterID = "gone"
+			if len(masters) > 0 {
+				node.MasterID = masters[r.Intn(len(masters))].ID
+			}
+		}
+		for _, peer := range topology.Nodes {
+			switch {
+			case peer.ID == node.ID || r.Intn(3) == 0:
+			case r.Intn(4) == 0:
+				// The peer restarted on another address since the node last saw it
+				node.Peers[peer.ID] = "10.0.1.1:6379"
+			default:
+				node.Peers[peer.ID] = peer.Address
+			}
+		}
+	}
+	// Slots are spread across the masters in ranges, with gaps of missing slots
+	for start := int32(0); start < TotalSlots && len(masters) > 0; {
+		end := start + int32(r.Intn(3000))
+		if end >= TotalSlots {
+			end = TotalSlots - 1
+		}
+		if r.Intn(4) != 0 {
+			owner := topology.node(masters[r.Intn(len(masters))].ID)
+			owner.Slots = append(owner.Slots, slotRange(start, end)...)
+		}
+		start = end + 1
+	}
+	for i := 0; i < r.Intn(3); i++ {
+		topology.Failing = append(topology.Failing, fmt.Sprintf("failed-%d", i))
+	}
+	return topology, cluster
+}
+
+// checkAction returns an error if the action can not be applied to the topology
+func checkAction(t Topology, action Action) error {
+	switch action := action.(type) {
+	case AddSlots:
+		owners := slotOwners(t)
+		for _, slot := range action.Slots {
+			if owners[slot] != "" {
+				return fmt.Errorf("%s: slot %d is already owned by %s", action, slot, owners[slot])
+			}
+		}
+	case MoveSlots:
+		destination, _ := t.Node(action.Destination)
+		if !destination.Master {
+			return fmt.Errorf("%s: the destination is not a master", action)
+		}
+		owners := slotOwners(t)
+		for _, slot := range action.Slots {
+			if owners[slot] != action.Source {
+				return fmt.Errorf("%s: slot %d is owned by %q", action, slot, owners[slot])
+			}
+		}
+	case Replicate:
+		node, _ := t.Node(action.Node)
+		if len(node.Slots) > 0 {
+			return fmt.Errorf("%s: the node still owns %d slots", action, len(node.Slots))
+		}
+		master, _ := t.Node(action.Master)
+		if !master.Master {
+			return fmt.Errorf("%s: the master is a replica", action)
+		}
+	case Reset:
+		node, _ := t.Node(action.Node)
+		if node.Master {
+			return fmt.Errorf("%s: the node is not a replica", action)
+		}
+	case Failover:
+		node, _ := t.Node(action.Node)
+		if node.Master {
+			return fmt.Errorf("%s: the node is not a replica", action)
+		}
+	}
+	return nil
+}
+
+// applyChecked applies the actions one by one, failing the test if any of them can not be applied
+func applyChecked(t *testing.T, seed int64, topology Topology, actions []Action) Topology {
+	result := topology.clone()
+	for _, action := range actions {
+		err := checkAction(result, action)
+		if err != nil {
+			t.Fatalf("Seed %d: invalid action in plan %v", seed, err)
+		}
+		action.apply(&result)
+	}
+	return result
+}
+
+// checkSlotCoverage fails the test unless every slot is owned by exactly one master
+func checkSlotCoverage(t *testing.T, seed int64, topology Topology) {
+	owned := make([]int, TotalSlots)
+	for _, node := range topology.Nodes {
+		if !node.Master && len(node.Slots) > 0 {
+			t.Fatalf("Seed %d: replica %s owns %d slots", seed, node.ID, len(node.Slots))
+		}
+		for _, slot := range node.Slots {
+			owned[slot]++
+		}
+	}
+	for slot, owners := range owned {
+		if owners != 1 {
+			t.Fatalf("Seed %d: slot %d is owned by %d masters", seed, slot, owners)
+		}
+	}
+}
+
+func TestPlanMeetSkipsNodesWhichKnowEachOther(t *testing.T) {
+	topology := Topology{
+		Nodes: []Node{newTestNode(0), newTestNode(1), newTestNode(2)},
+	}
+	// Nodes 1 and 2 know each other. Node 0 knows node 2 but not the other way around,
+	// and node 1 knows node 0, but node 0 only knows node 1 on an address it no longer runs on.
+	topology.Nodes[1].Peers["node-2"] = "10.0.0.2:6379"
+	topology.Nodes[2].Peers["node-1"] = "10.0.0.1:6379"
+	topology.Nodes[0].Peers["node-2"] = "10.0.0.2:6379"
+	topology.Nodes[1].Peers["node-0"] = "10.0.0.0:6379"
+	topology.Nodes[0].Peers["node-1"] = "10.0.9.9:6379"
+
+	got := PlanMeet(topology)
+	expected := []Action{
+		Meet{Node: "node-0", Peer: "node-1"},
+		Meet{Node: "node-0", Peer: "node-2"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected meetings %v, got %v", expected, got)
+	}
+}
+
+func TestPlanReplicationRatioEmptiesSurplusMastersBeforeReplicating(t *testing.T) {
+	topology := Topology{
+		Nodes: []Node{
+			newTestNode(0, slotRange(0, 8191)...),
+			newTestNode(1, slotRange(8192, 16379)...),
+			newTestNode(2, slotRange(16380, 16383)...),
+		},
+	}
+	cluster := &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters:           2,
+			ReplicasPerMaster: 0,
+		},
+	}
+	if got := PlanReplicationRatio(topology, cluster); len(got) != 0 {
+		t.Fatalf("Expected the surplus master to keep its slots until it is emptied. Got %v", got)
+	}
+
+	// The master with the fewest slots is removed, and its slots go to the master with the fewest slots
+	got := PlanEmptySurplusMasters(topology, cluster)
+	expected := []Action{
+		MoveSlots{Source: "node-2", Destination: "node-1", Slots: slotRange(16380, 16383)},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected actions %v, got %v", expected, got)
+	}
+
+	got = PlanReplicationRatio(Apply(topology, got), cluster)
+	expected = []Action{
+		Replicate{Node: "node-2", Master: "node-0"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected actions %v, got %v", expected, got)
+	}
+}
+
+func TestPlanReplicationRatioResetsReplicasWhenMastersAreMissing(t *testing.T) {
+	topology := Topology{
+		Nodes: []Node{newTestNode(0, slotRange(0, 16383)...), newTestNode(1), newTestNode(2), newTestNode(3)},
+	}
+	for i := 1; i <= 3; i++ {
+		topology.Nodes[i].Master = false
+		topology.Nodes[i].MasterID = "node-0"
+	}
+	got := PlanReplicationRatio(topology, &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters:           2,
+			ReplicasPerMaster: 1,
+		},
+	})
+	expected := []Action{Reset{Node: "node-1"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected actions %v, got %v", expected, got)
+	}
+}
+
+func TestPlanForgetsFailingNodesLast(t *testing.T) {
+	topology := Topology{
+		Nodes:   []Node{newTestNode(0, slotRange(0, 16383)...)},
+		Failing: []string{"failed"},
+	}
+	got := Plan(topology, &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 1,
+		},
+	})
+	expected := []Action{Forget{Node: "failed"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected actions %v, got %v", expected, got)
+	}
+}
+
+func TestPlanNeverMovesSlots(t *testing.T) {
+	// Moving slots migrates data, which may only start once the cluster is healthy
+	for seed := int64(0); seed < propertyRuns; seed++ {
+		topology, cluster := randomTopology(rand.New(rand.NewSource(seed)))
+		for _, action := range Plan(topology, cluster) {
+			if _, ok := action.(MoveSlots); ok {
+				t.Fatalf("Seed %d: expected the plan not to move slots. Got %v", seed, action)
+			}
+		}
+	}
+}
+
+func TestPlan_Properties(t *testing.T) {
+	for seed := int64(0); seed < propertyRuns; seed++ {
+		topology, cluster := randomTopology(rand.New(rand.NewSource(seed)))
+		original := topology.clone()
+
+		actions := Plan(topology, cluster)
+		if !reflect.DeepEqual(topology, original) {
+			t.Fatalf("Seed %d: planning changed the topology", seed)
+		}
+		if again := Plan(topology, cluster); !reflect.DeepEqual(actions, again) {
+			t.Fatalf("Seed %d: planning the same topology twice gave different plans.\n%v\n%v", seed, actions, again)
+		}
+
+		planned := applyChecked(t, seed, topology, actions)
+		checkSlotCoverage(t, seed, planned)
+		if len(planned.Failing) > 0 {
+			t.Fatalf("Seed %d: expected the failing nodes to be forgotten. Got %v", seed, planned.Failing)
+		}
+		// Pinned slots which were missing go straight to their master. Owned slots are moved by the rebalance instead.
+		missing := map[int32]bool{}
+		for _, slot := range topology.MissingSlots() {
+			missing[slot] = true
+		}
+		owners := slotOwners(planned)
+		pinned, _ := GetPinnedSlots(cluster)
+		for _, node := range planned.Masters() {
+			for slot, ordinal := range pinned {
+				if node.Ordinal == ordinal && missing[slot] && owners[slot] != node.ID {
+					t.Fatalf("Seed %d: expected missing pinned slot %d to be assigned to %s", seed, slot, node.ID)
+				}
+			}
+		}
+
+		// Nodes which were reset forget the cluster, so it takes another reconcile for them to meet the others.
+		// Surplus masters are emptied once the cluster is healthy, and only replicate in the next reconcile.
+		// After that, the cluster needs no more changes.
+		for round := 0; len(actions) > 0; round++ {
+			if round == 3 {
+				t.Fatalf("Seed %d: the plans do not converge. Still planning %v", seed, actions)
+			}
+			actions = PlanEmptySurplusMasters(planned, cluster)
+			planned = applyChecked(t, seed, planned, actions)
+			plan := Plan(planned, cluster)
+			planned = applyChecked(t, seed, planned, plan)
+			actions = append(actions, plan...)
+		}
+		if len(planned.Masters()) != int(cluster.Spec.Masters) {
+			t.Fatalf("Seed %d: expected %d masters, got %d", seed, cluster.Spec.Masters, len(planned.Masters()))
+		}
+		checkSlotCoverage(t, seed, planned)
+		for _, node := range planned.Nodes {
+			for _, peer := range planned.Nodes {
+				if peer.ID != node.ID && node.Peers[peer.ID] != peer.Address {
+					t.Fatalf("Seed %d: expected %s to know %s once the plans converged", seed, node.ID, peer.ID)
+				}
+			}
+		}
+	}
+}
+
+// slotOwners returns the ID of the node owning every slot
+func slotOwners(t Topology) []string {
+	owners := make([]string, TotalSlots)
+	for _, node := range t.Nodes {
+		for _, slot := range node.Slots {
+			owners[slot] = node.ID
+		}
+	}
+	return owners
+}
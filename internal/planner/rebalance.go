@@ -0,0 +1,247 @@
+package planner
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"math"
+	"sort"
+)
+
+// Rebalance contains the slot moves needed to balance the cluster
+type Rebalance struct {
+	Moves []MoveSlots
+	// MaxDeviationPercentage is the largest difference between what a master owns and what it should own,
+	// as a percentage of what it should own.
+	MaxDeviationPercentage float64
+}
+
+// Actions returns the moves of the rebalance as actions
+func (r Rebalance) Actions() []Action {
+	var actions []Action
+	for _, move := range r.Moves {
+		actions = append(actions, move)
+	}
+	return actions
+}
+
+// limit cuts down the rebalance to the maximum amount of slots per reconcile in the rebalance policy, if any
+func (r *Rebalance) limit(cluster *v1beta1.RedisCluster) {
+	remaining := int(cluster.Spec.Rebalance.MaxSlotsPerReconcile)
+	if remaining <= 0 {
+		return
+	}
+	var limited []MoveSlots
+	for _, move := range r.Moves {
+		if remaining == 0 {
+			break
+		}
+		if len(move.Slots) > remaining {
+			move.Slots = move.Slots[:remaining]
+		}
+		remaining -= len(move.Slots)
+		limited = append(limited, move)
+	}
+	r.Moves = limited
+}
+
+// PlanRebalance plans the slot moves needed for every master to own the amount of slots in its quota.
+// Pinned slots owned by other masters are always moved to the master they are pinned to, and pinned slots are never moved away.
+// Other than that the rebalance is empty if no master deviates from its quota by more than the threshold in the rebalance policy.
+// The rebalance is limited to the maximum amount of slots per reconcile, moving pinned slots first.
+func PlanRebalance(t Topology, cluster *v1beta1.RedisCluster) Rebalance {
+	// Only the slots which are not pinned are balanced, against what is left of the quota once the pinned slots are in place
+	quotas := SlotQuotas(t, cluster)
+	pins := getSlotPins(t, cluster)
+	masters := t.Masters()
+	freeSlots := map[string][]int32{}
+	freeQuotas := map[string]int{}
+	for _, node := range masters {
+		freeSlots[node.ID] = pins.freeSlots(node)
+		freeQuotas[node.ID] = int(quotas[node.ID]) - pins.fixedSlotCount(node)
+	}
+	result := Rebalance{
+		Moves: planPinnedMoves(t, pins),
+	}
+	for _, node := range masters {
+		if quotas[node.ID] == 0 {
+			continue
+		}
+		deviation := math.Abs(float64(len(freeSlots[node.ID])-freeQuotas[node.ID])) / float64(quotas[node.ID]) * 100
+		result.MaxDeviationPercentage = math.Max(result.MaxDeviationPercentage, deviation)
+	}
+	if result.MaxDeviationPercentage <= float64(cluster.Spec.Rebalance.ThresholdPercentage) {
+		// The cluster is balanced enough. Moving slots has a cost, so we only do it when needed.
+		result.limit(cluster)
+		return result
+	}
+
+	// We sort the masters by how many slots they have above their quota.
+	// This allows us to loop through and steal slots from masters with too many slots,
+	// and then when we get to the ones with too few slots, we have a list of "stealable" slots to take from.
+	// The masters are sorted on a copy, so the topology keeps its order.
+	sorted := make([]Node, len(masters))
+	copy(sorted, masters)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(freeSlots[sorted[i].ID])-freeQuotas[sorted[i].ID] > len(freeSlots[sorted[j].ID])-freeQuotas[sorted[j].ID]
+	})
+	var stealFrom []string
+	stealable := map[string][]int32{}
+	for _, node := range sorted {
+		slots := freeSlots[node.ID]
+		if len(slots) > freeQuotas[node.ID] {
+			// This master has too many slots, so other masters can steal some of them
+			stealFrom = append(stealFrom, node.ID)
+			stealable[node.ID] = slots[:len(slots)-freeQuotas[node.ID]]
+			continue
+		}
+		// This master has too few slots, so it takes slots from the stealable set
+		slotsNeeded := freeQuotas[node.ID] - len(slots)
+		for _, source := range stealFrom {
+			if slotsNeeded == 0 {
+				break
+			}
+			stealSlots := stealable[source]
+			if len(stealSlots) == 0 {
+				// All the slots of this master have already been given away
+				continue
+			}
+			if len(stealSlots) > slotsNeeded {
+				stealSlots = stealSlots[:slotsNeeded]
+			}
+			stealable[source] = stealable[source][len(stealSlots):]
+			slotsNeeded -= len(stealSlots)
+			result.Moves = append(result.Moves, MoveSlots{
+				Source:      source,
+				Destination: node.ID,
+				Slots:       stealSlots,
+			})
+		}
+	}
+	result.limit(cluster)
+	return result
+}
+
+// PlanLoadRebalance plans the slot moves needed for the load of every master to match its share of the total load,
+// where the share of a master is proportional to its slot weight. Loads maps every slot to its load.
+// Pinned slots are handled like in PlanRebalance. They are moved first, and count towards the load of their master.
+// Other than that the rebalance is empty if no master deviates from its share by more than the threshold in the rebalance policy.
+func PlanLoadRebalance(t Topology, cluster *v1beta1.RedisCluster, loads map[int32]float64) Rebalance {
+	masters := t.Masters()
+	pins := getSlotPins(t, cluster)
+	result := Rebalance{
+		Moves: planPinnedMoves(t, pins),
+	}
+
+	// The load of every master once the pinned slots are in place
+	masterLoads := map[string]float64{}
+	freeSlots := map[string][]int32{}
+	totalLoad := 0.0
+	for slot, master := range pins.masters {
+		masterLoads[master] += loads[slot]
+		totalLoad += loads[slot]
+	}
+	totalWeight := 0
+	for _, node := range masters {
+		totalWeight += int(slotWeight(node, cluster))
+		for _, slot := range node.Slots {
+			if pins.held[slot] {
+				masterLoads[node.ID] += loads[slot]
+				totalLoad += loads[slot]
+			}
+		}
+		freeSlots[node.ID] = pins.freeSlots(node)
+		for _, slot := range freeSlots[node.ID] {
+			masterLoads[node.ID] += loads[slot]
+			totalLoad += loads[slot]
+		}
+	}
+	if totalLoad == 0 || totalWeight == 0 {
+		// An empty cluster is balanced, whichever master owns the slots
+		result.limit(cluster)
+		return result
+	}
+
+	targets := map[string]float64{}
+	for _, node := range masters {
+		targets[node.ID] = totalLoad * float64(slotWeight(node, cluster)) / float64(totalWeight)
+		if targets[node.ID] == 0 {
+			continue
+		}
+		deviation := math.Abs(masterLoads[node.ID]-targets[node.ID]) / targets[node.ID] * 100
+		result.MaxDeviationPercentage = math.Max(result.MaxDeviationPercentage, deviation)
+	}
+	if result.MaxDeviationPercentage <= float64(cluster.Spec.Rebalance.ThresholdPercentage) {
+		result.limit(cluster)
+		return result
+	}
+
+	// We move the heaviest slots first, so we need as few moves as possible
+	for _, node := range masters {
+		slots := freeSlots[node.ID]
+		sort.SliceStable(slots, func(i, j int) bool {
+			return loads[slots[i]] > loads[slots[j]]
+		})
+	}
+
+	// Every step moves the heaviest slot which fits in the gap between a master above its share,
+	// and the master furthest below its share. Masters never overshoot their share,
+	// so slots never move back and forth, and every step gets the cluster closer to balanced.
+	var pairs [][2]string
+	moved := map[[2]string][]int32{}
+	for {
+		var destination *Node
+		for i := range masters {
+			node := &masters[i]
+			if destination == nil || targets[node.ID]-masterLoads[node.ID] > targets[destination.ID]-masterLoads[destination.ID] {
+				destination = node
+			}
+		}
+		if destination == nil || targets[destination.ID]-masterLoads[destination.ID] <= 0 {
+			break
+		}
+		sources := make([]Node, len(masters))
+		copy(sources, masters)
+		sort.SliceStable(sources, func(i, j int) bool {
+			return masterLoads[sources[i].ID]-targets[sources[i].ID] > masterLoads[sources[j].ID]-targets[sources[j].ID]
+		})
+		found := false
+		for _, source := range sources {
+			surplus := masterLoads[source.ID] - targets[source.ID]
+			if surplus <= 0 {
+				break
+			}
+			gap := math.Min(surplus, targets[destination.ID]-masterLoads[destination.ID])
+			slots := freeSlots[source.ID]
+			i := sort.Search(len(slots), func(i int) bool {
+				return loads[slots[i]] <= gap
+			})
+			if i == len(slots) || loads[slots[i]] == 0 {
+				continue
+			}
+			slot := slots[i]
+			freeSlots[source.ID] = append(slots[:i], slots[i+1:]...)
+			masterLoads[source.ID] -= loads[slot]
+			masterLoads[destination.ID] += loads[slot]
+			pair := [2]string{source.ID, destination.ID}
+			if _, ok := moved[pair]; !ok {
+				pairs = append(pairs, pair)
+			}
+			moved[pair] = append(moved[pair], slot)
+			found = true
+			break
+		}
+		if !found {
+			break
+		}
+	}
+	for _, pair := range pairs {
+		slots := moved[pair]
+		sortSlots(slots)
+		result.Moves = append(result.Moves, MoveSlots{
+			Source:      pair[0],
+			Destination: pair[1],
+			Slots:       slots,
+		})
+	}
+	result.limit(cluster)
+	return result
+}
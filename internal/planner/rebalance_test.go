@@ -0,0 +1,104 @@
+package planner
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// formedTopology returns a random topology once the planner brought it to the layout in its spec
+func formedTopology(seed int64) (Topology, *v1beta1.RedisCluster) {
+	topology, cluster := randomTopology(rand.New(rand.NewSource(seed)))
+	for round := 0; round < 3; round++ {
+		topology = Apply(topology, PlanEmptySurplusMasters(topology, cluster))
+		topology = Apply(topology, Plan(topology, cluster))
+	}
+	return topology, cluster
+}
+
+func TestPlanRebalanceKeepsTheOrderOfTheTopology(t *testing.T) {
+	topology := Topology{
+		Nodes: []Node{newTestNode(0), newTestNode(1, slotRange(0, 16383)...), newTestNode(2)},
+	}
+	original := topology.clone()
+	rebalance := PlanRebalance(topology, &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+		},
+	})
+	if !reflect.DeepEqual(topology, original) {
+		t.Fatalf("Expected planning the rebalance to leave the topology unchanged")
+	}
+	// The master missing the most slots comes last, so node-2 gets its slots before node-0
+	if len(rebalance.Moves) != 2 || rebalance.Moves[0].Destination != "node-2" || rebalance.Moves[1].Destination != "node-0" {
+		t.Fatalf("Expected slots to move to node-2 and node-0 in order. Got %v", rebalance.Moves)
+	}
+}
+
+func TestPlanRebalance_Properties(t *testing.T) {
+	for seed := int64(0); seed < propertyRuns; seed++ {
+		topology, cluster := formedTopology(seed)
+		original := topology.clone()
+
+		rebalance := PlanRebalance(topology, cluster)
+		if !reflect.DeepEqual(topology, original) {
+			t.Fatalf("Seed %d: planning the rebalance changed the topology", seed)
+		}
+		if again := PlanRebalance(topology, cluster); !reflect.DeepEqual(rebalance, again) {
+			t.Fatalf("Seed %d: planning the same rebalance twice gave different plans", seed)
+		}
+
+		balanced := applyChecked(t, seed, topology, rebalance.Actions())
+		checkSlotCoverage(t, seed, balanced)
+		quotas := SlotQuotas(balanced, cluster)
+		for _, node := range balanced.Masters() {
+			if len(node.Slots) != int(quotas[node.ID]) {
+				t.Fatalf("Seed %d: expected %s to own %d slots, got %d", seed, node.ID, quotas[node.ID], len(node.Slots))
+			}
+		}
+		owners := slotOwners(balanced)
+		pinned, _ := GetPinnedSlots(cluster)
+		for _, node := range balanced.Masters() {
+			for slot, ordinal := range pinned {
+				if node.Ordinal == ordinal && owners[slot] != node.ID {
+					t.Fatalf("Seed %d: expected pinned slot %d to be moved to %s", seed, slot, node.ID)
+				}
+			}
+		}
+		if again := PlanRebalance(balanced, cluster); len(again.Moves) != 0 {
+			t.Fatalf("Seed %d: expected a balanced cluster to need no moves. Got %v", seed, again.Moves)
+		}
+	}
+}
+
+func TestPlanLoadRebalance_Properties(t *testing.T) {
+	for seed := int64(0); seed < propertyRuns; seed++ {
+		topology, cluster := formedTopology(seed)
+		r := rand.New(rand.NewSource(seed))
+		loads := map[int32]float64{}
+		// Most slots carry no load, like in a cluster with few hot keys
+		for slot := int32(0); slot < TotalSlots; slot++ {
+			if r.Intn(64) == 0 {
+				loads[slot] = float64(r.Intn(100))
+			}
+		}
+
+		rebalance := PlanLoadRebalance(topology, cluster, loads)
+		if again := PlanLoadRebalance(topology, cluster, loads); !reflect.DeepEqual(rebalance, again) {
+			t.Fatalf("Seed %d: planning the same rebalance twice gave different plans", seed)
+		}
+		balanced := applyChecked(t, seed, topology, rebalance.Actions())
+		checkSlotCoverage(t, seed, balanced)
+		if len(cluster.Spec.SlotRanges) > 0 {
+			// Pinned slots can keep the load from ever evening out
+			continue
+		}
+		after := PlanLoadRebalance(balanced, cluster, loads)
+		if after.MaxDeviationPercentage > rebalance.MaxDeviationPercentage+math.SmallestNonzeroFloat64 {
+			t.Fatalf("Seed %d: expected the rebalance to even out the load. Deviation went from %f to %f",
+				seed, rebalance.MaxDeviationPercentage, after.MaxDeviationPercentage)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package planner
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+)
+
+// removedByScaleDown returns whether the pod of the node is removed when the statefulset is scaled down to the nodes the cluster needs
+func removedByScaleDown(cluster *v1beta1.RedisCluster, node Node) bool {
+	return node.Ordinal >= cluster.NodesNeeded()
+}
+
+// PlanScaleDown plans the next step towards removing the nodes in the pods beyond the nodes the cluster needs.
+// Replicas of removed masters take over from their master while the kept pods run too few masters,
+// the slots of the remaining removed masters are moved to the kept masters,
+// and the kept replicas of removed masters start replicating the kept master with the fewest replicas.
+// Every step needs the previous one to be in place, so only the first step which is needed is planned.
+// Returns no actions once the pods can be removed without losing slots, or leaving replicas without a master.
+func PlanScaleDown(t Topology, cluster *v1beta1.RedisCluster) []Action {
+	removedMasterIDs := map[string]bool{}
+	var keptMasters []Node
+	var removedMasters []Node
+	for _, node := range t.Masters() {
+		if removedByScaleDown(cluster, node) {
+			removedMasterIDs[node.ID] = true
+			removedMasters = append(removedMasters, node)
+			continue
+		}
+		keptMasters = append(keptMasters, node)
+	}
+	replicaCounts := map[string]int{}
+	var orphans []Node
+	for _, node := range t.Replicas() {
+		if removedByScaleDown(cluster, node) {
+			continue
+		}
+		if removedMasterIDs[node.MasterID] {
+			orphans = append(orphans, node)
+			continue
+		}
+		replicaCounts[node.MasterID]++
+	}
+
+	// A failover keeps the data in place, so we prefer it over moving slots
+	if len(keptMasters) < int(cluster.Spec.Masters) && len(orphans) > 0 {
+		return []Action{Failover{Node: orphans[0].ID}}
+	}
+
+	// Weights and pinned slots are left to the rebalance after scaling down, as the removed masters need to be emptied regardless
+	var actions []Action
+	for _, move := range planEmptying(removedMasters, keptMasters) {
+		actions = append(actions, move)
+	}
+	if len(actions) > 0 {
+		return actions
+	}
+
+	if len(keptMasters) == 0 {
+		return actions
+	}
+	for _, orphan := range orphans {
+		master := keptMasters[0]
+		for _, node := range keptMasters[1:] {
+			if replicaCounts[node.ID] < replicaCounts[master.ID] {
+				master = node
+			}
+		}
+		actions = append(actions, Replicate{Node: orphan.ID, Master: master.ID})
+		replicaCounts[master.ID]++
+	}
+	return actions
+}
@@ -0,0 +1,106 @@
+package planner
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"math/rand"
+	"testing"
+)
+
+func TestPlanScaleDownEmptiesRemovedMasters(t *testing.T) {
+	topology := Topology{
+		Nodes: []Node{
+			newTestNode(0, slotRange(0, 4095)...),
+			newTestNode(1, slotRange(4096, 8191)...),
+			newTestNode(2, slotRange(8192, 12287)...),
+			newTestNode(3, slotRange(12288, 16383)...),
+		},
+	}
+	// The cluster is scaled down from 4 to 3 masters, which removes the pod with ordinal 3
+	actions := PlanScaleDown(topology, &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+		},
+	})
+	moved := 0
+	for _, action := range actions {
+		moved += len(action.(MoveSlots).Slots)
+	}
+	if moved != 4096 {
+		t.Fatalf("Expected all 4096 slots of the removed master to move. Got %d", moved)
+	}
+	planned := Apply(topology, actions)
+	if len(planned.Nodes[3].Slots) != 0 {
+		t.Fatalf("Expected the removed master to own no slots. Got %d", len(planned.Nodes[3].Slots))
+	}
+	for _, node := range planned.Nodes[:3] {
+		if len(node.Slots) < 5461 || len(node.Slots) > 5462 {
+			t.Fatalf("Expected the slots to be spread across the kept masters. %s owns %d", node.ID, len(node.Slots))
+		}
+	}
+}
+
+func TestPlanScaleDownFailsOverBeforeMovingSlots(t *testing.T) {
+	topology := Topology{
+		Nodes: []Node{
+			newTestNode(0, slotRange(0, 8191)...),
+			newTestNode(1),
+			newTestNode(2, slotRange(8192, 16383)...),
+			newTestNode(3),
+		},
+	}
+	// The master of the second shard runs in a removed pod, but its replica runs in a kept pod
+	topology.Nodes[1].Master = false
+	topology.Nodes[1].MasterID = "node-2"
+	topology.Nodes[3].Master = false
+	topology.Nodes[3].MasterID = "node-0"
+	actions := PlanScaleDown(topology, &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters:           2,
+			ReplicasPerMaster: 0,
+		},
+	})
+	if len(actions) != 1 || actions[0] != (Failover{Node: "node-1"}) {
+		t.Fatalf("Expected node-1 to take over from its master. Got %v", actions)
+	}
+}
+
+func TestPlanScaleDown_Properties(t *testing.T) {
+	for seed := int64(0); seed < propertyRuns; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		topology, cluster := randomTopology(r)
+		// Bring the cluster to the layout in the spec first, and then remove some of its masters
+		for round := 0; round < 3; round++ {
+			topology = Apply(topology, Plan(topology, cluster))
+		}
+		if cluster.Spec.Masters == 1 {
+			continue
+		}
+		cluster.Spec.Masters -= int32(1 + r.Intn(int(cluster.Spec.Masters)-1))
+
+		for round := 0; ; round++ {
+			if round == 10 {
+				t.Fatalf("Seed %d: scaling down did not finish", seed)
+			}
+			actions := PlanScaleDown(topology, cluster)
+			if len(actions) == 0 {
+				break
+			}
+			topology = applyChecked(t, seed, topology, actions)
+			checkSlotCoverage(t, seed, topology)
+		}
+
+		for _, node := range topology.Nodes {
+			if !removedByScaleDown(cluster, node) {
+				continue
+			}
+			if len(node.Slots) > 0 {
+				t.Fatalf("Seed %d: removed node %s still owns %d slots", seed, node.ID, len(node.Slots))
+			}
+			for _, other := range topology.Nodes {
+				if !removedByScaleDown(cluster, other) && other.MasterID == node.ID {
+					t.Fatalf("Seed %d: kept node %s still replicates removed node %s", seed, other.ID, node.ID)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package planner
+
+import (
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"strconv"
+	"strings"
+)
+
+// ParseSlotRanges parses slot ranges given by users, like 0-100,200.
+// It rejects anything which is not a valid slot.
+func ParseSlotRanges(ranges string) ([]int32, error) {
+	var result []int32
+	for _, slotRange := range strings.Split(ranges, ",") {
+		slotParts := strings.Split(strings.TrimSpace(slotRange), "-")
+		if len(slotParts) > 2 {
+			return nil, fmt.Errorf("invalid slot range %q", slotRange)
+		}
+		var bounds []int
+		for _, slotPart := range slotParts {
+			slot, err := strconv.Atoi(slotPart)
+			if err != nil || slot < 0 || slot >= TotalSlots {
+				return nil, fmt.Errorf("invalid slot %q. Slots go from 0 to %d", slotPart, TotalSlots-1)
+			}
+			bounds = append(bounds, slot)
+		}
+		start, end := bounds[0], bounds[len(bounds)-1]
+		if start > end {
+			return nil, fmt.Errorf("invalid slot range %q. The range starts after it ends", slotRange)
+		}
+		for slot := start; slot <= end; slot++ {
+			result = append(result, int32(slot))
+		}
+	}
+	return result, nil
+}
+
+// GetPinnedSlots returns the ordinal of the pod every pinned slot of the cluster is pinned to.
+// The first entry pinning a slot is used. Entries which can not be parsed are left out, and returned as an error,
+// so the valid entries still apply.
+func GetPinnedSlots(cluster *v1beta1.RedisCluster) (map[int32]int32, error) {
+	pinned := map[int32]int32{}
+	var invalid []string
+	for _, slotRange := range cluster.Spec.SlotRanges {
+		slots, err := ParseSlotRanges(slotRange.Slots)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("ordinal %d: %v", slotRange.Ordinal, err))
+			continue
+		}
+		for _, slot := range slots {
+			if _, ok := pinned[slot]; !ok {
+				pinned[slot] = slotRange.Ordinal
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return pinned, fmt.Errorf("invalid slot ranges, which are ignored: %s", strings.Join(invalid, "; "))
+	}
+	return pinned, nil
+}
+
+// slotPins are the pinned slots of a cluster, matched to the masters in the topology
+type slotPins struct {
+	// masters maps the slots pinned to pods running a master to the ID of that master
+	masters map[int32]string
+	// held are the slots pinned to pods which do not run a master, for example after a failover.
+	// They stay with the master which owns them, until their pod runs a master again.
+	held map[int32]bool
+}
+
+func getSlotPins(t Topology, cluster *v1beta1.RedisCluster) slotPins {
+	pins := slotPins{
+		masters: map[int32]string{},
+		held:    map[int32]bool{},
+	}
+	if len(cluster.Spec.SlotRanges) == 0 {
+		return pins
+	}
+	pinnedSlots, _ := GetPinnedSlots(cluster)
+	mastersByOrdinal := map[int32]string{}
+	for _, node := range t.Masters() {
+		mastersByOrdinal[node.Ordinal] = node.ID
+	}
+	for slot, ordinal := range pinnedSlots {
+		if master, ok := mastersByOrdinal[ordinal]; ok {
+			pins.masters[slot] = master
+			continue
+		}
+		pins.held[slot] = true
+	}
+	return pins
+}
+
+func (p slotPins) isPinned(slot int32) bool {
+	_, ok := p.masters[slot]
+	return ok || p.held[slot]
+}
+
+// fixedSlotCount returns the amount of slots the node keeps regardless of balancing.
+// These are the slots pinned to the node, and the held slots it owns.
+func (p slotPins) fixedSlotCount(node Node) int {
+	count := 0
+	for _, master := range p.masters {
+		if master == node.ID {
+			count++
+		}
+	}
+	for _, slot := range node.Slots {
+		if p.held[slot] {
+			count++
+		}
+	}
+	return count
+}
+
+// freeSlots returns the slots the node owns which are not pinned, sorted, so they can be balanced across the masters
+func (p slotPins) freeSlots(node Node) []int32 {
+	var result []int32
+	for _, slot := range node.Slots {
+		if !p.isPinned(slot) {
+			result = append(result, slot)
+		}
+	}
+	sortSlots(result)
+	return result
+}
+
+// planPinnedMoves plans moving the pinned slots which are owned by other masters to the master they are pinned to
+func planPinnedMoves(t Topology, pins slotPins) []MoveSlots {
+	var moves []MoveSlots
+	for _, destination := range t.Masters() {
+		for _, source := range t.Masters() {
+			if source.ID == destination.ID {
+				continue
+			}
+			var misplaced []int32
+			for _, slot := range source.Slots {
+				if pins.masters[slot] == destination.ID {
+					misplaced = append(misplaced, slot)
+				}
+			}
+			if len(misplaced) > 0 {
+				moves = append(moves, MoveSlots{
+					Source:      source.ID,
+					Destination: destination.ID,
+					Slots:       misplaced,
+				})
+			}
+		}
+	}
+	return moves
+}
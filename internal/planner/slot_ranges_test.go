@@ -0,0 +1,46 @@
+package planner
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"reflect"
+	"testing"
+)
+
+func TestParseSlotRanges(t *testing.T) {
+	got, err := ParseSlotRanges("0-3, 7,16383")
+	if err != nil {
+		t.Fatalf("Could not parse slot ranges: %v", err)
+	}
+	expected := []int32{0, 1, 2, 3, 7, 16383}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected slots %v, got %v", expected, got)
+	}
+
+	for _, invalid := range []string{"", "a", "16384", "-1", "5-3", "1-2-3", "0,"} {
+		_, err = ParseSlotRanges(invalid)
+		if err == nil {
+			t.Fatalf("Expected an error for slot ranges %q", invalid)
+		}
+	}
+}
+
+func TestGetPinnedSlots(t *testing.T) {
+	pinned, err := GetPinnedSlots(&v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			SlotRanges: []v1beta1.SlotRange{
+				{Ordinal: 2, Slots: "0-9,20"},
+				{Ordinal: 1, Slots: "5-14"},
+				{Ordinal: 0, Slots: "16384"},
+			},
+		},
+	})
+	if err == nil {
+		t.Fatalf("Expected an error for the slot outside of the cluster")
+	}
+	if len(pinned) != 16 {
+		t.Fatalf("Expected 16 pinned slots, got %d", len(pinned))
+	}
+	if pinned[5] != 2 || pinned[14] != 1 || pinned[20] != 2 {
+		t.Fatalf("Expected the first entry pinning a slot to be used. Got %v", pinned)
+	}
+}
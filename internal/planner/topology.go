@@ -0,0 +1,135 @@
+// Package planner decides which changes bring a Redis cluster to the layout in its spec.
+// It works on a snapshot of the cluster and never talks to Redis itself,
+// so the same snapshot always gives the same plan, and plans can be tested without any nodes.
+// The redis package takes the snapshots, and applies the plans to the nodes.
+package planner
+
+import (
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"sort"
+)
+
+const (
+	TotalSlots = 16384
+)
+
+// Topology is a snapshot of the reachable nodes of a cluster.
+// The planner never changes a topology, it plans on copies instead.
+type Topology struct {
+	Nodes []Node
+	// Failing are the IDs of the nodes the cluster marks as failing
+	Failing []string
+}
+
+// Node is a single Redis node in a topology
+type Node struct {
+	ID string
+	// Address is the ip:port the other nodes meet the node on
+	Address string
+	// Pod is the name of the pod running the node
+	Pod string
+	// Ordinal is the ordinal of the pod in the statefulset, or -1 if the pod is not known
+	Ordinal int32
+	Master  bool
+	// MasterID is the ID of the master the node replicates. It is empty for masters.
+	MasterID string
+	// Slots are the slots the node owns, sorted
+	Slots []int32
+	// HostLabels are the labels of the Kubernetes node the pod is running on
+	HostLabels map[string]string
+	// Peers maps the IDs of the other nodes this node knows, to the address it knows them on
+	Peers map[string]string
+}
+
+// Masters returns the masters, in the order of the topology
+func (t Topology) Masters() []Node {
+	var masters []Node
+	for _, node := range t.Nodes {
+		if node.Master {
+			masters = append(masters, node)
+		}
+	}
+	return masters
+}
+
+// Replicas returns the replicas, in the order of the topology
+func (t Topology) Replicas() []Node {
+	var replicas []Node
+	for _, node := range t.Nodes {
+		if !node.Master {
+			replicas = append(replicas, node)
+		}
+	}
+	return replicas
+}
+
+// Node returns the node with the given ID
+func (t Topology) Node(id string) (Node, bool) {
+	for _, node := range t.Nodes {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
+
+// MissingSlots returns the slots which are not owned by any of the nodes, sorted
+func (t Topology) MissingSlots() []int32 {
+	owned := make([]bool, TotalSlots)
+	for _, node := range t.Nodes {
+		for _, slot := range node.Slots {
+			owned[slot] = true
+		}
+	}
+	var result []int32
+	for slot := int32(0); slot < TotalSlots; slot++ {
+		if !owned[slot] {
+			result = append(result, slot)
+		}
+	}
+	return result
+}
+
+// clone returns a deep copy of the topology, which can be changed without changing the original
+func (t Topology) clone() Topology {
+	result := Topology{
+		Nodes:   make([]Node, len(t.Nodes)),
+		Failing: append([]string(nil), t.Failing...),
+	}
+	for i, node := range t.Nodes {
+		node.Slots = append([]int32(nil), node.Slots...)
+		peers := map[string]string{}
+		for id, address := range node.Peers {
+			peers[id] = address
+		}
+		node.Peers = peers
+		// Host labels are never changed by the planner, so they can be shared
+		result.Nodes[i] = node
+	}
+	return result
+}
+
+// node returns a pointer to the node with the given ID, so a cloned topology can be changed in place
+func (t *Topology) node(id string) *Node {
+	for i := range t.Nodes {
+		if t.Nodes[i].ID == id {
+			return &t.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// slotWeight returns the relative share of slots the node should own if it is a master
+func slotWeight(node Node, cluster *v1beta1.RedisCluster) int32 {
+	if len(cluster.Spec.SlotWeights) == 0 {
+		// Without weights all masters are equal, and we don't need to know anything about the pod
+		return 1
+	}
+	return cluster.GetSlotWeight(node.Ordinal, node.HostLabels)
+}
+
+func sortSlots(slots []int32) {
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i] < slots[j]
+	})
+}
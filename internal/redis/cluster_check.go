@@ -0,0 +1,214 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ClusterCheckIssueType string
+
+const (
+	// SlotOwnershipMismatch means a node sees a different owner for one or more slots than the rest of the cluster
+	SlotOwnershipMismatch ClusterCheckIssueType = "SlotOwnershipMismatch"
+	// ConfigEpochMismatch means a node sees a different config epoch for a master than the rest of the cluster
+	ConfigEpochMismatch ClusterCheckIssueType = "ConfigEpochMismatch"
+	// PeerMismatch means a node knows about a different set of nodes than the rest of the cluster
+	PeerMismatch ClusterCheckIssueType = "PeerMismatch"
+	// SlotsNotCovered means not all 16384 slots are assigned to a master
+	SlotsNotCovered ClusterCheckIssueType = "SlotsNotCovered"
+	// OpenSlot means a node has slots in the migrating or importing state
+	OpenSlot ClusterCheckIssueType = "OpenSlot"
+)
+
+type ClusterCheckIssue struct {
+	Type    ClusterCheckIssueType
+	NodeID  string
+	Message string
+}
+
+// ClusterCheckResult contains all the issues found while checking the cluster.
+// An empty list of issues means the cluster is healthy.
+type ClusterCheckResult struct {
+	Issues []ClusterCheckIssue
+}
+
+func (r *ClusterCheckResult) HasIssue(issueType ClusterCheckIssueType) bool {
+	for _, issue := range r.Issues {
+		if issue.Type == issueType {
+			return true
+		}
+	}
+	return false
+}
+
+// Consistent returns whether all the nodes share the same view of the cluster.
+// When nodes disagree, any decision we make based on the view of a single node might be wrong.
+func (r *ClusterCheckResult) Consistent() bool {
+	return !r.HasIssue(SlotOwnershipMismatch) && !r.HasIssue(ConfigEpochMismatch) && !r.HasIssue(PeerMismatch)
+}
+
+func (r *ClusterCheckResult) Healthy() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *ClusterCheckResult) addIssue(issueType ClusterCheckIssueType, nodeID string, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, ClusterCheckIssue{
+		Type:    issueType,
+		NodeID:  nodeID,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckCluster verifies the cluster the same way `redis-cli --cluster check` does.
+// Every node is queried for its view of the cluster, and the views are compared against the view of the first node.
+// We check that all nodes agree on slot ownership, config epochs and the nodes in the cluster,
+// that all slots are covered, and that no slots are left open.
+func (c *ClusterNodes) CheckCluster(ctx context.Context) (*ClusterCheckResult, error) {
+	result := &ClusterCheckResult{}
+	if len(c.Nodes) == 0 {
+		return result, nil
+	}
+
+	views := map[string][]NodeAttributes{}
+	for _, node := range c.Nodes {
+		view, err := node.GetClusterView(ctx)
+		if err != nil {
+			return nil, err
+		}
+		views[node.NodeAttributes.ID] = view
+	}
+
+	referenceNode := c.Nodes[0].NodeAttributes.ID
+	referencePeers := getViewPeers(views[referenceNode])
+	referenceOwners := getViewSlotOwners(views[referenceNode])
+	referenceEpochs := getViewConfigEpochs(views[referenceNode])
+
+	for _, node := range c.Nodes {
+		nodeID := node.NodeAttributes.ID
+		view := views[nodeID]
+
+		// region Open Slots
+		for _, attributes := range view {
+			if !attributes.HasFlag("myself") {
+				continue
+			}
+			if len(attributes.GetMigratingSlots()) > 0 {
+				result.addIssue(OpenSlot, nodeID, "node %s has slots in migrating state: %s", nodeID, strings.Join(FormatSlotRanges(getMapSlots(attributes.GetMigratingSlots())), ","))
+			}
+			if len(attributes.GetImportingSlots()) > 0 {
+				result.addIssue(OpenSlot, nodeID, "node %s has slots in importing state: %s", nodeID, strings.Join(FormatSlotRanges(getMapSlots(attributes.GetImportingSlots())), ","))
+			}
+		}
+		// endregion
+
+		if nodeID == referenceNode {
+			continue
+		}
+
+		// region Peers
+		peers := getViewPeers(view)
+		var missing, extra []string
+		for peer := range referencePeers {
+			if _, ok := peers[peer]; !ok {
+				missing = append(missing, peer)
+			}
+		}
+		for peer := range peers {
+			if _, ok := referencePeers[peer]; !ok {
+				extra = append(extra, peer)
+			}
+		}
+		if len(missing) > 0 || len(extra) > 0 {
+			sort.Strings(missing)
+			sort.Strings(extra)
+			result.addIssue(PeerMismatch, nodeID, "node %s does not agree with node %s on the nodes in the cluster. Missing: [%s] Extra: [%s]", nodeID, referenceNode, strings.Join(missing, ","), strings.Join(extra, ","))
+		}
+		// endregion
+
+		// region Slot Ownership
+		owners := getViewSlotOwners(view)
+		var mismatchedSlots []int32
+		for slot := int32(0); slot < TotalRedisSlots; slot++ {
+			if owners[slot] != referenceOwners[slot] {
+				mismatchedSlots = append(mismatchedSlots, slot)
+			}
+		}
+		if len(mismatchedSlots) > 0 {
+			result.addIssue(SlotOwnershipMismatch, nodeID, "node %s does not agree with node %s on the owner of %d slots: %s", nodeID, referenceNode, len(mismatchedSlots), strings.Join(FormatSlotRanges(mismatchedSlots), ","))
+		}
+		// endregion
+
+		// region Config Epochs
+		epochs := getViewConfigEpochs(view)
+		var mismatchedEpochs []string
+		for master, epoch := range epochs {
+			referenceEpoch, ok := referenceEpochs[master]
+			if ok && referenceEpoch != epoch {
+				mismatchedEpochs = append(mismatchedEpochs, fmt.Sprintf("%s:%d!=%d", master, epoch, referenceEpoch))
+			}
+		}
+		if len(mismatchedEpochs) > 0 {
+			sort.Strings(mismatchedEpochs)
+			result.addIssue(ConfigEpochMismatch, nodeID, "node %s does not agree with node %s on config epochs: %s", nodeID, referenceNode, strings.Join(mismatchedEpochs, ","))
+		}
+		// endregion
+	}
+
+	// region Slot Coverage
+	var uncoveredSlots []int32
+	for slot := int32(0); slot < TotalRedisSlots; slot++ {
+		if referenceOwners[slot] == "" {
+			uncoveredSlots = append(uncoveredSlots, slot)
+		}
+	}
+	if len(uncoveredSlots) > 0 {
+		result.addIssue(SlotsNotCovered, "", "%d slots are not covered by any master: %s", len(uncoveredSlots), strings.Join(FormatSlotRanges(uncoveredSlots), ","))
+	}
+	// endregion
+
+	return result, nil
+}
+
+// getViewPeers returns the set of node IDs in a view.
+// Nodes still in the handshake phase are not part of the cluster yet, and get a random ID, so they are ignored.
+func getViewPeers(view []NodeAttributes) map[string]interface{} {
+	peers := map[string]interface{}{}
+	for _, attributes := range view {
+		if attributes.HasFlag("handshake") {
+			continue
+		}
+		peers[attributes.ID] = true
+	}
+	return peers
+}
+
+func getViewSlotOwners(view []NodeAttributes) map[int32]string {
+	owners := map[int32]string{}
+	for _, attributes := range view {
+		for _, slot := range attributes.GetSlots() {
+			owners[slot] = attributes.ID
+		}
+	}
+	return owners
+}
+
+func getViewConfigEpochs(view []NodeAttributes) map[string]int64 {
+	epochs := map[string]int64{}
+	for _, attributes := range view {
+		if !attributes.HasFlag("master") {
+			continue
+		}
+		epochs[attributes.ID] = attributes.GetConfigEpoch()
+	}
+	return epochs
+}
+
+func getMapSlots(slotMap map[int32]string) []int32 {
+	var slots []int32
+	for slot := range slotMap {
+		slots = append(slots, slot)
+	}
+	return slots
+}
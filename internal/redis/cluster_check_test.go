@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+// newCheckedNode creates a node which returns the given cluster nodes output,
+// once when the node is created, and once when the cluster is checked.
+func newCheckedNode(t *testing.T, addr string, clusterNodes string) (*Node, redismock.ClientMock) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectClusterNodes().SetVal(clusterNodes)
+	mock.ExpectClusterNodes().SetVal(clusterNodes)
+	node, err := NewNode(context.TODO(), &redis.Options{
+		Addr: addr,
+	}, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rediscluster",
+			Namespace: "default",
+		},
+	}, func(opt *redis.Options) *redis.Client {
+		return client
+	})
+	if err != nil {
+		t.Fatalf("received error while trying to create node %v", err)
+	}
+	return node, mock
+}
+
+func TestClusterNodes_CheckClusterReturnsNoIssuesForHealthyCluster(t *testing.T) {
+	node1, mock1 := newCheckedNode(t, "10.20.30.40:6379", `5dbeafc760e4ec355f007b2ce10c690a56306dc8 10.20.30.40:6379@16379 myself,master - 0 1653479781000 1 connected 0-8191
+4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad 10.20.30.41:6379@16379 master - 0 1653479781745 2 connected 8192-16383
+`)
+	node2, mock2 := newCheckedNode(t, "10.20.30.41:6379", `5dbeafc760e4ec355f007b2ce10c690a56306dc8 10.20.30.40:6379@16379 master - 0 1653479781000 1 connected 0-8191
+4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad 10.20.30.41:6379@16379 myself,master - 0 1653479781745 2 connected 8192-16383
+`)
+	clusterNodes := ClusterNodes{
+		Nodes: []*Node{node1, node2},
+	}
+
+	result, err := clusterNodes.CheckCluster(context.TODO())
+	if err != nil {
+		t.Fatalf("Received error while checking cluster %v", err)
+	}
+	if !result.Healthy() || !result.Consistent() {
+		t.Fatalf("Expected cluster to be healthy, but got issues %v", result.Issues)
+	}
+	if mock1.ExpectationsWereMet() != nil || mock2.ExpectationsWereMet() != nil {
+		t.Fatalf("Expected every node to be queried for its view of the cluster")
+	}
+}
+
+func TestClusterNodes_CheckClusterDetectsDisagreeingViews(t *testing.T) {
+	node1, _ := newCheckedNode(t, "10.20.30.40:6379", `5dbeafc760e4ec355f007b2ce10c690a56306dc8 10.20.30.40:6379@16379 myself,master - 0 1653479781000 1 connected 0-8191
+4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad 10.20.30.41:6379@16379 master - 0 1653479781745 2 connected 8192-16383
+0465e428668773fc3bbeb02150bbd4324e409fe0 10.20.30.42:6379@16379 slave 5dbeafc760e4ec355f007b2ce10c690a56306dc8 0 1653479781544 1 connected
+`)
+	// The second node still thinks it owns slot 0, has an older epoch for the first master, and has not met the replica yet.
+	node2, _ := newCheckedNode(t, "10.20.30.41:6379", `5dbeafc760e4ec355f007b2ce10c690a56306dc8 10.20.30.40:6379@16379 master - 0 1653479781000 0 connected 1-8191
+4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad 10.20.30.41:6379@16379 myself,master - 0 1653479781745 2 connected 0 8192-16383
+`)
+	clusterNodes := ClusterNodes{
+		Nodes: []*Node{node1, node2},
+	}
+
+	result, err := clusterNodes.CheckCluster(context.TODO())
+	if err != nil {
+		t.Fatalf("Received error while checking cluster %v", err)
+	}
+	if result.Consistent() {
+		t.Fatalf("Expected cluster views to be inconsistent")
+	}
+	for _, issueType := range []ClusterCheckIssueType{SlotOwnershipMismatch, ConfigEpochMismatch, PeerMismatch} {
+		if !result.HasIssue(issueType) {
+			t.Fatalf("Expected issue %s to be reported. Got %v", issueType, result.Issues)
+		}
+	}
+	if result.HasIssue(SlotsNotCovered) {
+		t.Fatalf("Did not expect slots to be reported as uncovered. Got %v", result.Issues)
+	}
+}
+
+func TestClusterNodes_CheckClusterDetectsUncoveredAndOpenSlots(t *testing.T) {
+	node1, _ := newCheckedNode(t, "10.20.30.40:6379", `5dbeafc760e4ec355f007b2ce10c690a56306dc8 10.20.30.40:6379@16379 myself,master - 0 1653479781000 1 connected 0-8000 [8001->-4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad]
+4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad 10.20.30.41:6379@16379 master - 0 1653479781745 2 connected 8192-16383
+`)
+	clusterNodes := ClusterNodes{
+		Nodes: []*Node{node1},
+	}
+
+	result, err := clusterNodes.CheckCluster(context.TODO())
+	if err != nil {
+		t.Fatalf("Received error while checking cluster %v", err)
+	}
+	if !result.Consistent() {
+		t.Fatalf("A single node should always be consistent with itself. Got %v", result.Issues)
+	}
+	if !result.HasIssue(OpenSlot) || !result.HasIssue(SlotsNotCovered) {
+		t.Fatalf("Expected open and uncovered slots to be reported. Got %v", result.Issues)
+	}
+	for _, issue := range result.Issues {
+		if issue.Type == SlotsNotCovered && issue.Message != "191 slots are not covered by any master: 8001-8191" {
+			t.Fatalf("Incorrect uncovered slots reported. Got %s", issue.Message)
+		}
+	}
+}
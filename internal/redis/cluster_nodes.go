@@ -3,8 +3,9 @@ package redis
 import (
 	"context"
 	"errors"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
-	"math"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
 	"sort"
 )
 
@@ -12,6 +13,13 @@ type ClusterNodes struct {
 	Nodes []*Node
 }
 
+// Close closes the clients of all the nodes. Every client holds a pool of connections, which is only released when it is closed.
+func (c *ClusterNodes) Close() {
+	for _, node := range c.Nodes {
+		_ = node.Close()
+	}
+}
+
 func (c *ClusterNodes) ReloadNodes(ctx context.Context) error {
 	for _, node := range c.Nodes {
 		err := node.ReloadNodeInfo(ctx)
@@ -33,8 +41,13 @@ func (c *ClusterNodes) GetCommandingNode(ctx context.Context) (*Node, error) {
 }
 
 func (c *ClusterNodes) ForgetNode(ctx context.Context, forgetNode *Node) error {
+	return c.forgetNodeID(ctx, forgetNode.NodeAttributes.ID)
+}
+
+// forgetNodeID makes every reachable node forget the node with the given ID
+func (c *ClusterNodes) forgetNodeID(ctx context.Context, id string) error {
 	for _, node := range c.Nodes {
-		err := node.ClusterForget(ctx, forgetNode.NodeAttributes.ID).Err()
+		err := node.ClusterForget(ctx, id).Err()
 		if err != nil {
 			return err
 		}
@@ -68,18 +81,6 @@ func (c *ClusterNodes) GetFailingNodes(ctx context.Context) ([]*Node, error) {
 	return result, nil
 }
 
-func (c *ClusterNodes) ClusterMeet(ctx context.Context) error {
-	for _, node := range c.Nodes {
-		for _, joinNode := range c.Nodes {
-			err := node.MeetNode(ctx, joinNode)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
 func (c *ClusterNodes) GetAssignedSlots() []int32 {
 	var result []int32
 	for _, node := range c.Nodes {
@@ -109,34 +110,6 @@ func (c *ClusterNodes) GetMissingSlots() []int32 {
 	return result
 }
 
-func (c *ClusterNodes) CalculateSlotAssignment() map[*Node][]int32 {
-	slotAssignment := map[*Node][]int32{}
-
-	// We add one for the remainder, as 16834 does not go even into an uneven amount of nodes.
-	// By adding one to each node, we don't need a check after to see whether there are unassigned slots left,
-	// as assignable slots will be less than slotsNeededPerNode * len(nodes).
-	slotsNeededPerNode := int(TotalRedisSlots/len(c.GetMasters())) + 1
-	slotsStillToAssign := c.GetMissingSlots()
-	for _, node := range c.GetMasters() {
-		if len(node.NodeAttributes.slots) < slotsNeededPerNode {
-			// This node needs some slots to fill it's quota of slots.
-			// We can cut some slots from the allocatable slots, if there are enough
-			slotsNeededForNode := slotsNeededPerNode - len(node.NodeAttributes.slots)
-			var slotsTake []int32
-			if len(slotsStillToAssign) <= slotsNeededForNode {
-				slotsTake = slotsStillToAssign
-				slotsStillToAssign = []int32{}
-			}
-			if len(slotsStillToAssign) > slotsNeededForNode {
-				slotsTake = slotsStillToAssign[:slotsNeededForNode]
-				slotsStillToAssign = slotsStillToAssign[slotsNeededForNode:]
-			}
-			slotAssignment[node] = slotsTake
-		}
-	}
-	return slotAssignment
-}
-
 func (c *ClusterNodes) GetMasters() []*Node {
 	var masters []*Node
 	for _, node := range c.Nodes {
@@ -157,58 +130,74 @@ func (c *ClusterNodes) GetReplicas() []*Node {
 	return replicas
 }
 
-func (c *ClusterNodes) EnsureClusterReplicationRatio(ctx context.Context, cluster *v1alpha1.RedisCluster) error {
-	masters := c.GetMasters()
-
-	if len(masters) == int(cluster.Spec.Masters) {
-		// There are the appropriate amount of masters and replicas
-		return nil
+// GetNodeForPod returns the node running in the pod with the given name, or nil if the node is not reachable
+func (c *ClusterNodes) GetNodeForPod(podName string) *Node {
+	for _, node := range c.Nodes {
+		if node.PodDetails != nil && node.PodDetails.Name == podName {
+			return node
+		}
 	}
+	return nil
+}
 
-	// If we have too many masters, we can failover the extra masters to replicate the original masters
-	if len(masters) > int(cluster.Spec.Masters) {
-		// todo select keepable masters as masters with most slots attached
-		var keepMasters []*Node
-		var removeMasters []*Node
-
-		// We want to sort the masters by the amount of slots they have.
-		// That way when we select removable masters, they are most likely to be empty from slots
-		sort.Slice(masters, func(i, j int) bool {
-			return len(masters[i].NodeAttributes.slots) > len(masters[j].NodeAttributes.slots)
-		})
-
-		// We have too many masters and need to fail over some
-		keepMasters = masters[:cluster.Spec.Masters]
-		removeMasters = masters[cluster.Spec.Masters:]
-
-		// todo we need to replicate the masters which have the least amount of replicas, rather than just the matching index
-		for k, removeMaster := range removeMasters {
-			selectedMaster := keepMasters[int(math.Mod(float64(k), float64(cluster.Spec.Masters)))]
-
-			// todo we need to make sure that there are no slots in the master before replicating it.
-			err := removeMaster.ClusterReplicate(ctx, selectedMaster.NodeAttributes.ID).Err()
-			if err != nil {
-				return err
-			}
+// GetNodeByID returns the reachable node with the given ID, or nil if none of the nodes has the ID
+func (c *ClusterNodes) GetNodeByID(id string) *Node {
+	for _, node := range c.Nodes {
+		if node.NodeAttributes.ID == id {
+			return node
 		}
-		return nil
 	}
+	return nil
+}
 
-	if len(masters) < int(cluster.Spec.Masters) {
-		mastersNeeded := int(cluster.Spec.Masters) - len(masters)
-		replicas := c.GetReplicas()
-		replicaNeedsReset := replicas[:mastersNeeded]
-		for _, replica := range replicaNeedsReset {
-			err := replica.ClusterResetSoft(ctx).Err()
-			if err != nil {
-				return err
+// GetSlotOwner returns the master which owns the slot, or nil if the slot is not assigned to any of the masters
+func (c *ClusterNodes) GetSlotOwner(slot int32) *Node {
+	for _, node := range c.GetMasters() {
+		for _, ownedSlot := range node.NodeAttributes.GetSlots() {
+			if ownedSlot == slot {
+				return node
 			}
 		}
 	}
-
 	return nil
 }
 
+// PlanSlotMoves plans moving the slots to the destination, grouped by the master which currently owns them.
+// Slots the destination already owns are left out, so the plan can be applied again after it was interrupted.
+func (c *ClusterNodes) PlanSlotMoves(slots []int32, destination *Node) (*RebalancePlan, error) {
+	if !destination.IsMaster() {
+		return nil, fmt.Errorf("slots can only be moved to masters, but node %s is a replica", destination.NodeAttributes.ID)
+	}
+	owners := map[int32]*Node{}
+	for _, node := range c.GetMasters() {
+		for _, slot := range node.NodeAttributes.GetSlots() {
+			owners[slot] = node
+		}
+	}
+	plan := &RebalancePlan{}
+	moves := map[*Node]int{}
+	for _, slot := range slots {
+		source := owners[slot]
+		if source == nil {
+			return nil, fmt.Errorf("slot %d is not assigned to any of the masters", slot)
+		}
+		if source == destination {
+			continue
+		}
+		index, ok := moves[source]
+		if !ok {
+			index = len(plan.Moves)
+			moves[source] = index
+			plan.Moves = append(plan.Moves, SlotMove{
+				Source:      source,
+				Destination: destination,
+			})
+		}
+		plan.Moves[index].Slots = append(plan.Moves[index].Slots, slot)
+	}
+	return plan, nil
+}
+
 func (c *ClusterNodes) MoveSlot(ctx context.Context, source, destination *Node, slot int) error {
 	err := destination.Client.Do(ctx, "cluster", "setslot", slot, "importing", source.NodeAttributes.ID).Err()
 	if err != nil {
@@ -231,7 +220,7 @@ func (c *ClusterNodes) MoveSlot(ctx context.Context, source, destination *Node,
 		// migrate 10.244.1.132 6379 "" 0 5000 KEYS A:163262 A:166510 A:172223 A:177551 A:18733 A:21915 A:247961 A:30954 A:383958 A:392919
 		migrateCmd := []interface{}{
 			"migrate",
-			destination.NodeAttributes.GetHost(),
+			destination.GetIP(),
 			destination.NodeAttributes.GetPort(),
 			"",
 			"0",
@@ -257,14 +246,16 @@ func (c *ClusterNodes) MoveSlot(ctx context.Context, source, destination *Node,
 	if err != nil {
 		return err
 	}
-	for _, node := range c.Nodes {
+	// The other masters learn about the new owner straight away, instead of through the gossip protocol.
+	// Replicas only accept SETSLOT from their master, so they are left to the gossip protocol.
+	for _, node := range c.GetMasters() {
 		if node.NodeAttributes.ID == destination.NodeAttributes.ID {
 			continue
 		}
 		if node.NodeAttributes.ID == source.NodeAttributes.ID {
 			continue
 		}
-		err = source.Client.Do(ctx, "cluster", "setslot", slot, "NODE", destination.NodeAttributes.ID).Err()
+		err = node.Client.Do(ctx, "cluster", "setslot", slot, "NODE", destination.NodeAttributes.ID).Err()
 		if err != nil {
 			return err
 		}
@@ -272,9 +263,9 @@ func (c *ClusterNodes) MoveSlot(ctx context.Context, source, destination *Node,
 	return nil
 }
 
-func (c *ClusterNodes) BalanceSlots(ctx context.Context, cluster *v1alpha1.RedisCluster) error {
-	slotMoves := c.CalculateRebalance(ctx, cluster)
-	for _, slotMove := range slotMoves {
+// ApplyRebalancePlan moves all the slots in the plan
+func (c *ClusterNodes) ApplyRebalancePlan(ctx context.Context, plan *RebalancePlan) error {
+	for _, slotMove := range plan.Moves {
 		for _, slot := range slotMove.Slots {
 			err := c.MoveSlot(ctx, slotMove.Source, slotMove.Destination, int(slot))
 			if err != nil {
@@ -285,61 +276,48 @@ func (c *ClusterNodes) BalanceSlots(ctx context.Context, cluster *v1alpha1.Redis
 	return nil
 }
 
-type slotMoveMap struct {
+// SlotMove is a set of slots which need to move from the source to the destination node
+type SlotMove struct {
 	Source      *Node
 	Destination *Node
 	Slots       []int32
 }
 
-func (c *ClusterNodes) CalculateRebalance(ctx context.Context, cluster *v1alpha1.RedisCluster) []slotMoveMap {
-	// First we sort the nodes by slot count.
-	// This allows us to loop through and steal slots from nodes with too many slots,
-	// and then when we get to the ones with too few slots, we have a list of "stealable" slots to take from.
-	masters := c.Nodes
-	sort.Slice(masters, func(i, j int) bool {
-		return len(masters[i].NodeAttributes.GetSlots()) > len(masters[j].NodeAttributes.GetSlots())
-	})
-	var result []slotMoveMap
-	stealMap := map[*Node][]int32{}
-	for _, node := range c.GetMasters() {
-		slots := node.NodeAttributes.GetSlots()
-		sort.Slice(slots, func(i, j int) bool {
-			return slots[i] < slots[j]
-		})
-		if len(slots) > int(node.NeedsSlotCount(cluster)) {
-			// This node has too many slots
-			// We need to steal some slots from it
-			stealMap[node] = slots[:len(slots)-int(node.NeedsSlotCount(cluster))]
+// RebalancePlan contains all the slot moves needed to balance the cluster
+type RebalancePlan struct {
+	Moves []SlotMove
+	// MaxDeviationPercentage is the largest difference between the slots a master owns and the slots it should own,
+	// as a percentage of the slots it should own.
+	MaxDeviationPercentage float64
+}
+
+func (p *RebalancePlan) SlotCount() int {
+	count := 0
+	for _, slotMove := range p.Moves {
+		count += len(slotMove.Slots)
+	}
+	return count
+}
+
+// Limit cuts down the plan to contain at most maxSlots slots
+func (p *RebalancePlan) Limit(maxSlots int) {
+	var limited []SlotMove
+	remaining := maxSlots
+	for _, slotMove := range p.Moves {
+		if remaining == 0 {
+			break
 		}
-		if len(slots) <= int(node.NeedsSlotCount(cluster)) {
-			// This node has too few slots
-			// We need to take slots from the stealable set
-			slotsNeeded := int(node.NeedsSlotCount(cluster)) - len(slots)
-			for stealNode, stealSlots := range stealMap {
-				if slotsNeeded == 0 {
-					break
-				}
-				if len(stealSlots) > slotsNeeded {
-					slotsStolen := stealSlots[:slotsNeeded]
-					stealMap[stealNode] = stealSlots[slotsNeeded:]
-					result = append(result, slotMoveMap{
-						Source:      stealNode,
-						Destination: node,
-						Slots:       slotsStolen,
-					})
-					break
-				}
-				if len(stealSlots) <= slotsNeeded {
-					slotsNeeded -= len(stealSlots)
-					stealMap[stealNode] = []int32{}
-					result = append(result, slotMoveMap{
-						Source:      stealNode,
-						Destination: node,
-						Slots:       stealSlots,
-					})
-				}
-			}
+		if len(slotMove.Slots) > remaining {
+			slotMove.Slots = slotMove.Slots[:remaining]
 		}
+		remaining -= len(slotMove.Slots)
+		limited = append(limited, slotMove)
 	}
-	return result
+	p.Moves = limited
+}
+
+// CalculateRebalance plans the slot moves needed for every master to own the amount of slots in its quota.
+// See planner.PlanRebalance for how the moves are chosen.
+func (c *ClusterNodes) CalculateRebalance(ctx context.Context, cluster *v1beta1.RedisCluster) *RebalancePlan {
+	return c.getRebalancePlan(planner.PlanRebalance(c.GetTopology(), cluster))
 }
@@ -3,7 +3,8 @@ package redis
 import (
 	"context"
 	"fmt"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
 	"github.com/go-redis/redis/v8"
 	"github.com/go-redis/redismock/v8"
 	v1 "k8s.io/api/core/v1"
@@ -14,13 +15,12 @@ import (
 )
 
 // region ClusterMeet
-func TestClusterMeetMeetsAllNodes(t *testing.T) {
+func TestClusterNodes_ExecuteMeetsNodesWhichDoNotKnowEachOther(t *testing.T) {
 	node1Client, node1Mock := redismock.NewClientMock()
 	node1Mock.ExpectClusterNodes().SetVal(`9fd8800b31d569538917c0aaeaa5588e2f9c6edf 10.20.30.40:6379@16379 myself,master - 0 1652373716000 0 connected
-8a99a71a38d099de6862284f5aab9329d796c34f 10.20.30.41:6379@16379 master - 0 1652373718026 1 connected
 `)
-	node1Mock.ExpectClusterMeet("10.20.30.40", "6379").SetVal("OK")
 	node1Mock.ExpectClusterMeet("10.20.30.41", "6379").SetVal("OK")
+	node1Mock.ExpectClusterMeet("10.20.30.42", "6379").SetVal("OK")
 
 	node1, err := NewNode(context.TODO(), &redis.Options{
 		Addr: "10.20.30.40:6379",
@@ -36,12 +36,11 @@ func TestClusterMeetMeetsAllNodes(t *testing.T) {
 		t.Fatalf("received error while trying to create node %v", err)
 	}
 
+	// Nodes 2 and 3 know each other already, so they do not need to meet
 	node2Client, node2Mock := redismock.NewClientMock()
-	node2Mock.ExpectClusterNodes().SetVal(`9fd8800b31d569538917c0aaeaa5588e2f9c6edf 10.20.30.40:6379@16379 master - 0 1652373716000 0 connected
-8a99a71a38d099de6862284f5aab9329d796c34f 10.20.30.41:6379@16379 myself,master - 0 1652373718026 1 connected
+	node2Mock.ExpectClusterNodes().SetVal(`8a99a71a38d099de6862284f5aab9329d796c34f 10.20.30.41:6379@16379 myself,master - 0 1652373718026 1 connected
+3c2ee4b3b9e1c9fa7e7e1b1e1d5b8d4a0d1a6e2f 10.20.30.42:6379@16379 master - 0 1652373718026 2 connected
 `)
-	node2Mock.ExpectClusterMeet("10.20.30.40", "6379").SetVal("OK")
-	node2Mock.ExpectClusterMeet("10.20.30.41", "6379").SetVal("OK")
 	node2, err := NewNode(context.TODO(), &redis.Options{
 		Addr: "10.20.30.41:6379",
 	}, &v1.Pod{
@@ -56,21 +55,44 @@ func TestClusterMeetMeetsAllNodes(t *testing.T) {
 		t.Fatalf("received error while trying to create node %v", err)
 	}
 
+	node3Client, node3Mock := redismock.NewClientMock()
+	node3Mock.ExpectClusterNodes().SetVal(`3c2ee4b3b9e1c9fa7e7e1b1e1d5b8d4a0d1a6e2f 10.20.30.42:6379@16379 myself,master - 0 1652373718026 2 connected
+8a99a71a38d099de6862284f5aab9329d796c34f 10.20.30.41:6379@16379 master - 0 1652373718026 1 connected
+`)
+	node3, err := NewNode(context.TODO(), &redis.Options{
+		Addr: "10.20.30.42:6379",
+	}, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rediscluster",
+			Namespace: "default",
+		},
+	}, func(opt *redis.Options) *redis.Client {
+		return node3Client
+	})
+	if err != nil {
+		t.Fatalf("received error while trying to create node %v", err)
+	}
+
 	clusterNodes := ClusterNodes{
 		Nodes: []*Node{
 			node1,
 			node2,
+			node3,
 		},
 	}
-	err = clusterNodes.ClusterMeet(context.TODO())
+	actions := planner.PlanMeet(clusterNodes.GetTopology())
+	if len(actions) != 2 {
+		t.Fatalf("Expected node 1 to meet the other two nodes. Got %v", actions)
+	}
+	err = clusterNodes.Execute(context.TODO(), actions, 0)
 	if err != nil {
 		t.Fatalf("Receives error when trying to cluster meet %v", err)
 	}
 	if node1Mock.ExpectationsWereMet() != nil {
 		t.Fatalf("Node 1 did not receive all the cluster meet commands it was expected.")
 	}
-	if node2Mock.ExpectationsWereMet() != nil {
-		t.Fatalf("Node 2 did not receive all the cluster meet commands it was expected.")
+	if node2Mock.ExpectationsWereMet() != nil || node3Mock.ExpectationsWereMet() != nil {
+		t.Fatalf("Nodes 2 and 3 know each other, so they should not have met.")
 	}
 }
 
@@ -134,7 +156,7 @@ func TestGetMissingSlots(t *testing.T) {
 
 // endregion
 
-func TestCalculateSlotAssignmentWorksForMastersOnly(t *testing.T) {
+func TestPlanSlotAssignmentWorksForMastersOnly(t *testing.T) {
 	var nodes []*Node
 	mocks := map[string]*redismock.ClientMock{}
 	for i := 0; i <= 3; i++ {
@@ -190,13 +212,14 @@ func TestCalculateSlotAssignmentWorksForMastersOnly(t *testing.T) {
 	clusterNodes := ClusterNodes{
 		Nodes: nodes,
 	}
-	got := clusterNodes.CalculateSlotAssignment()
+	got := planner.PlanSlotAssignment(clusterNodes.GetTopology(), &v1beta1.RedisCluster{})
 
 	var gotNodeIds []string
 	var gotSlots [][]int32
-	for node, slots := range got {
-		gotNodeIds = append(gotNodeIds, node.NodeAttributes.ID)
-		gotSlots = append(gotSlots, slots)
+	for _, action := range got {
+		addSlots := action.(planner.AddSlots)
+		gotNodeIds = append(gotNodeIds, addSlots.Node)
+		gotSlots = append(gotSlots, addSlots.Slots)
 	}
 	if !reflect.DeepEqual(gotNodeIds, []string{"5dbeafc760e4ec355f007b2ce10c690a56306dc8", "4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad"}) &&
 		!reflect.DeepEqual(gotNodeIds, []string{"4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad", "5dbeafc760e4ec355f007b2ce10c690a56306dc8"}) {
@@ -291,7 +314,7 @@ func TestClusterNodes_GetReplicas(t *testing.T) {
 	}
 }
 
-func TestClusterNodes_EnsureClusterReplicationRatioIfTooManyMasters(t *testing.T) {
+func TestClusterNodes_ExecuteReplicationRatioIfTooManyMasters(t *testing.T) {
 	// We are testing here that a cluster is replicated in the way we specified.
 	node1, err := NewNode(context.TODO(), &redis.Options{
 		Addr: "10.20.30.40:6379",
@@ -337,16 +360,16 @@ func TestClusterNodes_EnsureClusterReplicationRatioIfTooManyMasters(t *testing.T
 			node2,
 		},
 	}
-	err = clusterNodes.EnsureClusterReplicationRatio(context.TODO(), &v1alpha1.RedisCluster{
+	err = clusterNodes.Execute(context.TODO(), planner.PlanReplicationRatio(clusterNodes.GetTopology(), &v1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: v1alpha1.RedisClusterSpec{
+		Spec: v1beta1.RedisClusterSpec{
 			Masters:           1,
 			ReplicasPerMaster: 1,
 		},
-	})
+	}), 0)
 
 	if err != nil {
 		t.Fatalf("Did not expect error %v", err)
@@ -357,7 +380,7 @@ func TestClusterNodes_EnsureClusterReplicationRatioIfTooManyMasters(t *testing.T
 	}
 }
 
-func TestClusterNodes_EnsureClusterReplicationRatioIfTooFewMasters(t *testing.T) {
+func TestClusterNodes_ExecuteReplicationRatioIfTooFewMasters(t *testing.T) {
 	var nodes []*Node
 	mocks := map[string]*redismock.ClientMock{}
 	for i := 0; i <= 3; i++ {
@@ -413,16 +436,16 @@ func TestClusterNodes_EnsureClusterReplicationRatioIfTooFewMasters(t *testing.T)
 	clusterNodes := ClusterNodes{
 		Nodes: nodes,
 	}
-	err := clusterNodes.EnsureClusterReplicationRatio(context.TODO(), &v1alpha1.RedisCluster{
+	err := clusterNodes.Execute(context.TODO(), planner.PlanReplicationRatio(clusterNodes.GetTopology(), &v1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: v1alpha1.RedisClusterSpec{
+		Spec: v1beta1.RedisClusterSpec{
 			Masters:           2,
 			ReplicasPerMaster: 1,
 		},
-	})
+	}), 0)
 
 	if err != nil {
 		t.Fatalf("Did not expect error %v", err)
@@ -621,16 +644,16 @@ func TestClusterNodes_CalculateRebalanceWhenOneNodeNeedsToMoveSlots(t *testing.T
 	clusterNodes := ClusterNodes{
 		Nodes: nodes,
 	}
-	slotMoves := clusterNodes.CalculateRebalance(context.TODO(), &v1alpha1.RedisCluster{
+	slotMoves := clusterNodes.CalculateRebalance(context.TODO(), &v1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: v1alpha1.RedisClusterSpec{
+		Spec: v1beta1.RedisClusterSpec{
 			Masters: 3,
 		},
 	})
-	for _, slotMoveMap := range slotMoves {
+	for _, slotMoveMap := range slotMoves.Moves {
 		if slotMoveMap.Source.NodeAttributes.ID == "4e70ffa7e012ecec890b25f52fbc3d2e8edd89ad" {
 			if slotMoveMap.Destination.NodeAttributes.ID != "0465e428668773fc3bbeb02150bbd4324e409fe0" {
 				t.Fatalf("Slots are being moved to the wrong destination")
@@ -719,16 +742,16 @@ func TestClusterNodes_CalculateRebalanceWhenTwoNodesNeedToMoveSlots(t *testing.T
 	clusterNodes := ClusterNodes{
 		Nodes: nodes,
 	}
-	slotMoves := clusterNodes.CalculateRebalance(context.TODO(), &v1alpha1.RedisCluster{
+	slotMoves := clusterNodes.CalculateRebalance(context.TODO(), &v1beta1.RedisCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "redis-cluster",
 			Namespace: "default",
 		},
-		Spec: v1alpha1.RedisClusterSpec{
+		Spec: v1beta1.RedisClusterSpec{
 			Masters: 3,
 		},
 	})
-	for _, slotMoveMap := range slotMoves {
+	for _, slotMoveMap := range slotMoves.Moves {
 		if slotMoveMap.Source.NodeAttributes.ID == "5dbeafc760e4ec355f007b2ce10c690a56306dc8" {
 			if slotMoveMap.Destination.NodeAttributes.ID != "0465e428668773fc3bbeb02150bbd4324e409fe0" {
 				t.Fatalf("Slots are being moved to the wrong destination")
@@ -754,3 +777,237 @@ func TestClusterNodes_CalculateRebalanceWhenTwoNodesNeedToMoveSlots(t *testing.T
 		}
 	}
 }
+
+func TestClusterNodes_SlotQuotasHonoursSlotWeights(t *testing.T) {
+	var nodes []*Node
+	for i := 0; i <= 2; i++ {
+		nodes = append(nodes, &Node{
+			NodeAttributes: NodeAttributes{
+				ID:    fmt.Sprintf("node-%d", i),
+				flags: []string{"master"},
+			},
+			PodDetails: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rediscluster-" + strconv.FormatInt(int64(i), 10),
+					Namespace: "default",
+				},
+			},
+		})
+	}
+	// The third master runs on a bigger node
+	nodes[2].HostLabels = map[string]string{
+		"node.kubernetes.io/instance-type": "m5.2xlarge",
+	}
+	clusterNodes := ClusterNodes{
+		Nodes: nodes,
+	}
+	quotas := planner.SlotQuotas(clusterNodes.GetTopology(), &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			SlotWeights: []v1beta1.SlotWeight{
+				{
+					NodeSelector: map[string]string{
+						"node.kubernetes.io/instance-type": "m5.2xlarge",
+					},
+					Weight: 2,
+				},
+			},
+		},
+	})
+	// 16384 / 4 = 4096 slots per unit of weight
+	expected := []int32{4096, 4096, 8192}
+	for i, node := range nodes {
+		if quotas[node.NodeAttributes.ID] != expected[i] {
+			t.Fatalf("Incorrect slot quota for node %d. Expected %d, got %d", i, expected[i], quotas[node.NodeAttributes.ID])
+		}
+	}
+}
+
+func TestClusterNodes_SlotQuotasSpreadsRemainderByWeight(t *testing.T) {
+	var nodes []*Node
+	for i := 0; i <= 2; i++ {
+		nodes = append(nodes, &Node{
+			NodeAttributes: NodeAttributes{
+				ID:    fmt.Sprintf("node-%d", i),
+				flags: []string{"master"},
+			},
+			PodDetails: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rediscluster-" + strconv.FormatInt(int64(i), 10),
+					Namespace: "default",
+				},
+			},
+		})
+	}
+	clusterNodes := ClusterNodes{
+		Nodes: nodes,
+	}
+	ordinal := int32(1)
+	quotas := planner.SlotQuotas(clusterNodes.GetTopology(), &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			SlotWeights: []v1beta1.SlotWeight{
+				{
+					Ordinal: &ordinal,
+					Weight:  3,
+				},
+			},
+		},
+	})
+	// 16384 / 5 = 3276.8 slots per unit of weight.
+	// Node 1 gets 9830.4, and the others 3276.8, so the 2 remaining slots go to the nodes rounded down the most.
+	expected := []int32{3277, 9830, 3277}
+	total := int32(0)
+	for i, node := range nodes {
+		total += quotas[node.NodeAttributes.ID]
+		if quotas[node.NodeAttributes.ID] != expected[i] {
+			t.Fatalf("Incorrect slot quota for node %d. Expected %d, got %d", i, expected[i], quotas[node.NodeAttributes.ID])
+		}
+	}
+	if total != TotalRedisSlots {
+		t.Fatalf("Slot quotas do not add up to all slots. Got %d", total)
+	}
+}
+
+func TestClusterNodes_CalculateRebalanceHonoursSlotWeights(t *testing.T) {
+	var nodes []*Node
+	slotRanges := [][]string{{"0-5461"}, {"5462-10922"}, {"10923-16383"}}
+	for i := 0; i <= 2; i++ {
+		nodes = append(nodes, &Node{
+			NodeAttributes: NodeAttributes{
+				ID:    fmt.Sprintf("node-%d", i),
+				flags: []string{"master"},
+				slots: ProcessSlotStrings(slotRanges[i]),
+			},
+			PodDetails: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rediscluster-" + strconv.FormatInt(int64(i), 10),
+					Namespace: "default",
+				},
+			},
+		})
+	}
+	clusterNodes := ClusterNodes{
+		Nodes: nodes,
+	}
+	ordinal := int32(0)
+	slotMoves := clusterNodes.CalculateRebalance(context.TODO(), &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			SlotWeights: []v1beta1.SlotWeight{
+				{
+					Ordinal: &ordinal,
+					Weight:  2,
+				},
+			},
+		},
+	})
+	// Node 0 should end up with 8192 slots, and the other nodes with 4096 each.
+	received := map[string]int{}
+	for _, slotMove := range slotMoves.Moves {
+		received[slotMove.Destination.NodeAttributes.ID] += len(slotMove.Slots)
+		received[slotMove.Source.NodeAttributes.ID] -= len(slotMove.Slots)
+	}
+	if received["node-0"] != 8192-5462 || received["node-1"] != 4096-5461 || received["node-2"] != 4096-5461 {
+		t.Fatalf("Slots not moved according to weights. Got %v", received)
+	}
+}
+
+func TestClusterNodes_CalculateRebalanceRespectsThresholdAndLimit(t *testing.T) {
+	newNodes := func() ClusterNodes {
+		var nodes []*Node
+		slotRanges := [][]string{{"0-5500"}, {"5501-10922"}, {"10923-16383"}}
+		for i := 0; i <= 2; i++ {
+			nodes = append(nodes, &Node{
+				NodeAttributes: NodeAttributes{
+					ID:    fmt.Sprintf("node-%d", i),
+					flags: []string{"master"},
+					slots: ProcessSlotStrings(slotRanges[i]),
+				},
+				PodDetails: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "rediscluster-" + strconv.FormatInt(int64(i), 10),
+						Namespace: "default",
+					},
+				},
+			})
+		}
+		return ClusterNodes{
+			Nodes: nodes,
+		}
+	}
+
+	// Node 0 has 39 slots too many, which is less than 1% of its quota
+	clusterNodes := newNodes()
+	plan := clusterNodes.CalculateRebalance(context.TODO(), &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			Rebalance: v1beta1.RebalancePolicy{
+				ThresholdPercentage: 1,
+			},
+		},
+	})
+	if len(plan.Moves) != 0 {
+		t.Fatalf("Expected no moves below the threshold. Got %d slots to move", plan.SlotCount())
+	}
+
+	clusterNodes = newNodes()
+	plan = clusterNodes.CalculateRebalance(context.TODO(), &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			Rebalance: v1beta1.RebalancePolicy{
+				MaxSlotsPerReconcile: 10,
+			},
+		},
+	})
+	if plan.SlotCount() != 10 {
+		t.Fatalf("Expected the plan to be limited to 10 slots. Got %d", plan.SlotCount())
+	}
+	if plan.MaxDeviationPercentage == 0 {
+		t.Fatalf("Expected the deviation of the cluster to be reported")
+	}
+}
+
+func TestClusterNodes_PlanSlotMovesGroupsSlotsBySource(t *testing.T) {
+	var nodes []*Node
+	slotRanges := [][]string{{"0-5461"}, {"5462-10922"}, {"10923-16383"}, {}}
+	flags := []string{"master", "master", "master", "slave"}
+	for i := 0; i <= 3; i++ {
+		nodes = append(nodes, &Node{
+			NodeAttributes: NodeAttributes{
+				ID:    fmt.Sprintf("node-%d", i),
+				flags: []string{flags[i]},
+				slots: ProcessSlotStrings(slotRanges[i]),
+			},
+			PodDetails: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rediscluster-" + strconv.FormatInt(int64(i), 10),
+					Namespace: "default",
+				},
+			},
+		})
+	}
+	clusterNodes := ClusterNodes{
+		Nodes: nodes,
+	}
+
+	plan, err := clusterNodes.PlanSlotMoves([]int32{0, 1, 10923, 5462, 2}, clusterNodes.GetNodeForPod("rediscluster-1"))
+	if err != nil {
+		t.Fatalf("Could not plan slot moves: %v", err)
+	}
+	// Slot 5462 is already owned by the destination, so it is left out
+	if len(plan.Moves) != 2 || plan.SlotCount() != 4 {
+		t.Fatalf("Expected 4 slots in 2 moves, got %d slots in %d moves", plan.SlotCount(), len(plan.Moves))
+	}
+	if plan.Moves[0].Source != nodes[0] || !reflect.DeepEqual(plan.Moves[0].Slots, []int32{0, 1, 2}) {
+		t.Fatalf("Expected slots 0-2 to move from node-0, got %v from %s", plan.Moves[0].Slots, plan.Moves[0].Source.NodeAttributes.ID)
+	}
+	if plan.Moves[1].Source != nodes[2] || !reflect.DeepEqual(plan.Moves[1].Slots, []int32{10923}) {
+		t.Fatalf("Expected slot 10923 to move from node-2, got %v from %s", plan.Moves[1].Slots, plan.Moves[1].Source.NodeAttributes.ID)
+	}
+
+	_, err = clusterNodes.PlanSlotMoves([]int32{0}, clusterNodes.GetNodeForPod("rediscluster-3"))
+	if err == nil {
+		t.Fatalf("Expected an error when moving slots to a replica")
+	}
+}
@@ -0,0 +1,424 @@
+// Package fake simulates Redis nodes in memory, so the Operator can be tested against clusters which change over time.
+//
+// Clients connect to the fake nodes through the client builder of a Cluster, which is the same builder NewNode takes.
+// Every node keeps its own data, replication state and open slots, like a real Redis node.
+// Gossip is simplified, as it happens straight away. Slot owners and config epochs are shared by the whole cluster,
+// and nodes which meet learn about all the nodes the other node knows about at once.
+// Failovers happen straight away as well when a master with replicas is stopped.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Port is the port every fake node listens on
+const Port = "6379"
+
+// Cluster is a set of fake Redis nodes, which can reach each other.
+// Despite the name, the nodes can run without cluster support as well, to simulate Replication mode.
+type Cluster struct {
+	mu             sync.Mutex
+	clusterEnabled bool
+	password       string
+	nodes          []*node
+	// owners is the master owning every slot. Gossip is instant, so all nodes agree on it.
+	owners       [totalSlots]*node
+	currentEpoch int64
+	lastID       int
+}
+
+type node struct {
+	index     int
+	id        string
+	ip        string
+	hostnames []string
+	stopped   bool
+	// replaced nodes were removed together with their pod. Other nodes still remember them until they are forgotten.
+	replaced bool
+	loading  bool
+	peers    map[*node]bool
+	// A replica replicates its master. In Replication mode the master is nil while the host of the master is unknown.
+	replica    bool
+	master     *node
+	masterHost string
+	migrating  map[int]*node
+	importing  map[int]*node
+	epoch      int64
+	data       map[string]string
+	replID     string
+	offset     int64
+	lastSave   int64
+	config     map[string]string
+	conns      map[net.Conn]bool
+}
+
+// NewCluster returns an empty set of nodes with cluster support, as they run in Cluster mode
+func NewCluster() *Cluster {
+	return &Cluster{
+		clusterEnabled: true,
+	}
+}
+
+// NewReplication returns an empty set of nodes without cluster support, as they run in Replication mode
+func NewReplication() *Cluster {
+	return &Cluster{}
+}
+
+// SetPassword makes all nodes require clients to authenticate with the password
+func (c *Cluster) SetPassword(password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.password = password
+}
+
+// AddNode starts a new, empty node on the IP. Clients can connect to the node through the IP and the hostnames.
+// Returns the address of the node.
+func (c *Cluster) AddNode(ip string, hostnames ...string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addNode(ip, hostnames)
+	return ip + ":" + Port
+}
+
+func (c *Cluster) addNode(ip string, hostnames []string) *node {
+	n := &node{
+		index:     len(c.nodes),
+		id:        c.newID(),
+		ip:        ip,
+		hostnames: hostnames,
+		peers:     map[*node]bool{},
+		migrating: map[int]*node{},
+		importing: map[int]*node{},
+		data:      map[string]string{},
+		replID:    c.newID(),
+		config: map[string]string{
+			"maxmemory": "0",
+		},
+		conns: map[net.Conn]bool{},
+	}
+	c.nodes = append(c.nodes, n)
+	return n
+}
+
+// newID returns a new node or replication ID. IDs are sequential, so tests are deterministic.
+func (c *Cluster) newID() string {
+	c.lastID++
+	return fmt.Sprintf("%040x", c.lastID)
+}
+
+// ClientBuilder returns a client builder which connects clients to the fake nodes, instead of over the network.
+// Connecting to an address without a running node fails, the same as a connection being refused.
+func (c *Cluster) ClientBuilder() func(opt *redis.Options) *redis.Client {
+	return func(opt *redis.Options) *redis.Client {
+		opt.Dialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			n := c.lookup(addr)
+			if n == nil || n.stopped {
+				return nil, fmt.Errorf("dial %s %s: connection refused", network, addr)
+			}
+			client, server := net.Pipe()
+			n.conns[server] = true
+			go c.serve(server, n)
+			return client, nil
+		}
+		return redis.NewClient(opt)
+	}
+}
+
+// lookup returns the live node listening on the address, or nil if there is none
+func (c *Cluster) lookup(addr string) *node {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port != Port {
+		return nil
+	}
+	for _, n := range c.nodes {
+		if n.replaced {
+			continue
+		}
+		if n.ip == host {
+			return n
+		}
+		for _, hostname := range n.hostnames {
+			if hostname == host {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) mustLookup(ip string) *node {
+	n := c.lookup(ip + ":" + Port)
+	if n == nil {
+		panic(fmt.Sprintf("fake: no node on %s", ip))
+	}
+	return n
+}
+
+// Stop stops the node on the IP, closing the connections of its clients.
+// The other nodes mark it as failing. If it was a master with replicas, its first replica takes over straight away.
+func (c *Cluster) Stop(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stop(c.mustLookup(ip))
+}
+
+func (c *Cluster) stop(n *node) {
+	n.stopped = true
+	for conn := range n.conns {
+		_ = conn.Close()
+	}
+	n.conns = map[net.Conn]bool{}
+	if !c.clusterEnabled || n.replica {
+		return
+	}
+	for _, candidate := range c.nodes {
+		if candidate.replica && candidate.master == n && !candidate.stopped {
+			c.failover(candidate)
+			return
+		}
+	}
+}
+
+// Start starts the stopped node on the IP again, with its data and cluster config.
+// Replicas resync with their master.
+func (c *Cluster) Start(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, n := range c.nodes {
+		if n.ip == ip && n.stopped && !n.replaced {
+			n.stopped = false
+			if n.replica && n.master != nil {
+				c.sync(n)
+			}
+			return
+		}
+	}
+	panic(fmt.Sprintf("fake: no stopped node on %s", ip))
+}
+
+// Replace simulates the pod of the node on the IP being recreated without its data or cluster config.
+// The new node gets a new ID, and knows no other nodes. The other nodes keep the old node as failing until they forget it.
+// Returns the ID of the new node.
+func (c *Cluster) Replace(ip string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.mustLookup(ip)
+	if !old.stopped {
+		c.stop(old)
+	}
+	old.replaced = true
+	return c.addNode(old.ip, old.hostnames).id
+}
+
+// Form creates a cluster out of all nodes, as the Operator would. The first nodes become masters, and divide the slots evenly.
+// The other nodes replicate the masters in turn.
+func (c *Cluster) Form(masters int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var live []*node
+	for _, n := range c.nodes {
+		if !n.replaced {
+			live = append(live, n)
+		}
+	}
+	for _, n := range live[1:] {
+		c.meet(live[0], n)
+	}
+	for i, n := range live[:masters] {
+		for slot := i * totalSlots / masters; slot < (i+1)*totalSlots/masters; slot++ {
+			c.owners[slot] = n
+		}
+	}
+	for i, n := range live[masters:] {
+		c.replicate(n, live[i%masters])
+	}
+}
+
+// NodeID returns the ID of the node on the IP
+func (c *Cluster) NodeID(ip string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mustLookup(ip).id
+}
+
+// SlotOwner returns the IP of the master owning the slot, or an empty string if no master owns it
+func (c *Cluster) SlotOwner(slot int) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.owners[slot] == nil {
+		return ""
+	}
+	return c.owners[slot].ip
+}
+
+// Keys returns the keys stored on the node on the IP, sorted
+func (c *Cluster) Keys(ip string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return sortedKeys(c.mustLookup(ip).data, func(string) bool {
+		return true
+	})
+}
+
+// SetLoading simulates the node on the IP loading its data set, during which it only answers INFO
+func (c *Cluster) SetLoading(ip string, loading bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mustLookup(ip).loading = loading
+}
+
+//region Cluster state
+
+// meet makes the nodes known to both nodes known to each other, as gossip would
+func (c *Cluster) meet(a, b *node) {
+	group := map[*node]bool{a: true, b: true}
+	for peer := range a.peers {
+		group[peer] = true
+	}
+	for peer := range b.peers {
+		group[peer] = true
+	}
+	for n := range group {
+		for peer := range group {
+			if n != peer && !peer.replaced {
+				n.peers[peer] = true
+			}
+		}
+	}
+}
+
+// known returns the node and its peers, in the order they were created
+func (c *Cluster) known(n *node) []*node {
+	result := []*node{n}
+	for peer := range n.peers {
+		result = append(result, peer)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].index < result[j].index
+	})
+	return result
+}
+
+// findKnown returns the node with the ID, if the node knows about it
+func (c *Cluster) findKnown(n *node, id string) *node {
+	for _, known := range c.known(n) {
+		if known.id == id {
+			return known
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) ownedSlots(n *node) []int {
+	var slots []int
+	for slot, owner := range c.owners {
+		if owner == n {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// clusterOK returns whether all slots are served by masters the node knows about
+func (c *Cluster) clusterOK(n *node) bool {
+	known := map[*node]bool{}
+	for _, k := range c.known(n) {
+		known[k] = true
+	}
+	for _, owner := range c.owners {
+		if owner == nil || !known[owner] || owner.stopped {
+			return false
+		}
+	}
+	return true
+}
+
+// replicate makes the node a replica of the master, and loads the data of the master
+func (c *Cluster) replicate(n *node, master *node) {
+	n.replica = true
+	n.master = master
+	n.masterHost = master.ip
+	n.migrating = map[int]*node{}
+	n.importing = map[int]*node{}
+	c.sync(n)
+}
+
+// sync replaces the data of the replica with the data of its master, like a full resync
+func (c *Cluster) sync(n *node) {
+	if n.master == nil || n.master.stopped {
+		return
+	}
+	n.data = map[string]string{}
+	for key, value := range n.master.data {
+		n.data[key] = value
+	}
+	n.replID = n.master.replID
+	n.offset = n.master.offset
+}
+
+// failover promotes the replica to master. It takes over the slots of its master,
+// and the master and its other replicas start replicating the promoted replica.
+func (c *Cluster) failover(n *node) {
+	old := n.master
+	n.replica = false
+	n.master = nil
+	n.masterHost = ""
+	c.currentEpoch++
+	n.epoch = c.currentEpoch
+	if old == nil {
+		return
+	}
+	for slot, owner := range c.owners {
+		if owner == old {
+			c.owners[slot] = n
+		}
+	}
+	for _, other := range c.nodes {
+		if other != n && (other == old || (other.replica && other.master == old)) {
+			c.replicate(other, n)
+		}
+	}
+}
+
+// write changes the data of the master, and replicates the change to its replicas
+func (c *Cluster) write(n *node, change func(data map[string]string)) {
+	change(n.data)
+	n.offset++
+	for _, other := range c.nodes {
+		if other.replica && other.master == n && !other.stopped {
+			c.write(other, change)
+			other.offset = n.offset
+		}
+	}
+}
+
+//endregion
+
+func sortedKeys(data map[string]string, include func(key string) bool) []string {
+	keys := []string{}
+	for key := range data {
+		if include(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (n *node) address() string {
+	return n.ip + ":" + Port
+}
+
+func (n *node) role() string {
+	if n.replica {
+		return "slave"
+	}
+	return "master"
+}
@@ -0,0 +1,182 @@
+package fake
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"strings"
+	"testing"
+)
+
+func TestKeySlotMatchesRedis(t *testing.T) {
+	// The slots Redis returns for CLUSTER KEYSLOT
+	expected := map[string]int{
+		"foo":          12182,
+		"bar":          5061,
+		"{user1000}.a": 3443,
+		"{user1000}.b": 3443,
+		"foo{}{bar}":   8363,
+		"123456789":    12739,
+		"user1000":     3443,
+	}
+	for key, slot := range expected {
+		if KeySlot(key) != slot {
+			t.Fatalf("Expected key %s to hash to slot %d. Got %d", key, slot, KeySlot(key))
+		}
+	}
+}
+
+func TestCluster_RedirectsKeysToTheirMaster(t *testing.T) {
+	ctx := context.TODO()
+	cluster := NewCluster()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		cluster.AddNode(ip)
+	}
+	cluster.Form(2)
+	client := cluster.ClientBuilder()(&redis.Options{Addr: "10.0.0.1:6379"})
+	defer client.Close()
+
+	info, err := client.ClusterInfo(ctx).Result()
+	if err != nil {
+		t.Fatalf("Could not get cluster info %v", err)
+	}
+	if !strings.Contains(info, "cluster_state:ok") || !strings.Contains(info, "cluster_known_nodes:4") {
+		t.Fatalf("Expected a healthy cluster of 4 nodes. Got %s", info)
+	}
+	// foo hashes to slot 12182, which belongs to the second master
+	err = client.Set(ctx, "foo", "bar", 0).Err()
+	if err == nil || err.Error() != "MOVED 12182 10.0.0.2:6379" {
+		t.Fatalf("Expected to be redirected to the second master. Got %v", err)
+	}
+	master := cluster.ClientBuilder()(&redis.Options{Addr: "10.0.0.2:6379"})
+	defer master.Close()
+	err = master.Set(ctx, "foo", "bar", 0).Err()
+	if err != nil {
+		t.Fatalf("Could not set key on its master %v", err)
+	}
+	// The second master is replicated by the last node
+	if keys := cluster.Keys("10.0.0.4"); len(keys) != 1 || keys[0] != "foo" {
+		t.Fatalf("Expected the key to be replicated. Got %v", keys)
+	}
+}
+
+func TestCluster_FailsOverWhenMasterStops(t *testing.T) {
+	ctx := context.TODO()
+	cluster := NewCluster()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"} {
+		cluster.AddNode(ip)
+	}
+	cluster.Form(3)
+	client := cluster.ClientBuilder()(&redis.Options{Addr: "10.0.0.2:6379"})
+	defer client.Close()
+
+	cluster.Stop("10.0.0.1")
+	if cluster.SlotOwner(0) != "10.0.0.4" {
+		t.Fatalf("Expected the replica to take over the slots of the stopped master. Got %s", cluster.SlotOwner(0))
+	}
+	nodes, err := client.ClusterNodes(ctx).Result()
+	if err != nil {
+		t.Fatalf("Could not get cluster nodes %v", err)
+	}
+	if !strings.Contains(nodes, cluster.NodeID("10.0.0.4")+" 10.0.0.4:6379@16379 master - 0 0 1 connected 0-5460") {
+		t.Fatalf("Expected the replica to be promoted. Got %s", nodes)
+	}
+	if !strings.Contains(nodes, "slave,fail") {
+		t.Fatalf("Expected the stopped master to be marked as failing. Got %s", nodes)
+	}
+
+	cluster.Start("10.0.0.1")
+	info, err := cluster.ClientBuilder()(&redis.Options{Addr: "10.0.0.1:6379"}).Info(ctx, "replication").Result()
+	if err != nil {
+		t.Fatalf("Could not get replication info %v", err)
+	}
+	if !strings.Contains(info, "role:slave") || !strings.Contains(info, "master_host:10.0.0.4") {
+		t.Fatalf("Expected the old master to rejoin as a replica of the promoted replica. Got %s", info)
+	}
+}
+
+func TestCluster_MigratesSlot(t *testing.T) {
+	ctx := context.TODO()
+	cluster := NewCluster()
+	cluster.AddNode("10.0.0.1")
+	cluster.AddNode("10.0.0.2")
+	cluster.Form(2)
+	source := cluster.ClientBuilder()(&redis.Options{Addr: "10.0.0.1:6379"})
+	destination := cluster.ClientBuilder()(&redis.Options{Addr: "10.0.0.2:6379"})
+	defer source.Close()
+	defer destination.Close()
+	sourceID := cluster.NodeID("10.0.0.1")
+	destinationID := cluster.NodeID("10.0.0.2")
+	// bar hashes to slot 5061, which belongs to the first master
+	if err := source.Set(ctx, "bar", "baz", 0).Err(); err != nil {
+		t.Fatalf("Could not set key %v", err)
+	}
+
+	steps := [][]interface{}{
+		{"cluster", "setslot", 5061, "importing", sourceID},
+		{"cluster", "setslot", 5061, "migrating", destinationID},
+	}
+	for i, step := range steps {
+		client := destination
+		if i == 1 {
+			client = source
+		}
+		if err := client.Do(ctx, step...).Err(); err != nil {
+			t.Fatalf("Could not run %v %v", step, err)
+		}
+	}
+	nodes, _ := source.ClusterNodes(ctx).Result()
+	if !strings.Contains(nodes, "[5061->-"+destinationID+"]") {
+		t.Fatalf("Expected the slot to be migrating. Got %s", nodes)
+	}
+	if err := source.Do(ctx, "migrate", "10.0.0.2", "6379", "", "0", "5000", "KEYS", "bar").Err(); err != nil {
+		t.Fatalf("Could not migrate key %v", err)
+	}
+	err := source.Get(ctx, "bar").Err()
+	if err == nil || err.Error() != "ASK 5061 10.0.0.2:6379" {
+		t.Fatalf("Expected the client to be asked to try the destination. Got %v", err)
+	}
+	for _, client := range []*redis.Client{destination, source} {
+		if err := client.Do(ctx, "cluster", "setslot", 5061, "node", destinationID).Err(); err != nil {
+			t.Fatalf("Could not assign slot %v", err)
+		}
+	}
+	value, err := destination.Get(ctx, "bar").Result()
+	if err != nil || value != "baz" {
+		t.Fatalf("Expected the key to be served by the destination. Got %s %v", value, err)
+	}
+	if cluster.SlotOwner(5061) != "10.0.0.2" {
+		t.Fatalf("Expected the destination to own the slot. Got %s", cluster.SlotOwner(5061))
+	}
+}
+
+func TestReplication_ReplicatesFromHost(t *testing.T) {
+	ctx := context.TODO()
+	cluster := NewReplication()
+	cluster.AddNode("10.0.0.1", "redis-0.redis-headless")
+	cluster.AddNode("10.0.0.2", "redis-1.redis-headless")
+	master := cluster.ClientBuilder()(&redis.Options{Addr: "redis-0.redis-headless:6379"})
+	replica := cluster.ClientBuilder()(&redis.Options{Addr: "redis-1.redis-headless:6379"})
+	defer master.Close()
+	defer replica.Close()
+
+	if err := master.Set(ctx, "foo", "bar", 0).Err(); err != nil {
+		t.Fatalf("Could not set key %v", err)
+	}
+	if err := replica.SlaveOf(ctx, "redis-0.redis-headless", "6379").Err(); err != nil {
+		t.Fatalf("Could not replicate master %v", err)
+	}
+	if err := replica.Set(ctx, "foo", "baz", 0).Err(); err == nil || !strings.HasPrefix(err.Error(), "READONLY") {
+		t.Fatalf("Expected replica to refuse writes. Got %v", err)
+	}
+	value, err := replica.Get(ctx, "foo").Result()
+	if err != nil || value != "bar" {
+		t.Fatalf("Expected the replica to load the data of the master. Got %s %v", value, err)
+	}
+	info, _ := replica.Info(ctx, "replication").Result()
+	if !strings.Contains(info, "master_host:redis-0.redis-headless") || !strings.Contains(info, "master_link_status:up") {
+		t.Fatalf("Expected replica to be linked to the master. Got %s", info)
+	}
+	if err := replica.ClusterNodes(ctx).Err(); err == nil {
+		t.Fatalf("Expected cluster commands to fail without cluster support")
+	}
+}
@@ -0,0 +1,727 @@
+package fake
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// execute runs a single command against the node. The whole cluster is locked while the command runs,
+// so commands run one at a time, in the order they arrive.
+func (c *Cluster) execute(n *node, s *session, args []string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(args) == 0 {
+		return errorReply("ERR empty command")
+	}
+	if n.stopped {
+		return errorReply("ERR node is stopped")
+	}
+	command := strings.ToUpper(args[0])
+	args = args[1:]
+
+	if command == "AUTH" {
+		if len(args) == 0 || args[len(args)-1] != c.password {
+			return errorReply("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		s.authenticated = true
+		return ok()
+	}
+	if c.password != "" && !s.authenticated {
+		return errorReply("NOAUTH Authentication required.")
+	}
+	if n.loading && command != "INFO" {
+		return errorReply("LOADING Redis is loading the dataset in memory")
+	}
+	asking := s.asking
+	s.asking = false
+
+	switch command {
+	case "PING":
+		return simpleString("PONG")
+	case "ECHO":
+		if len(args) != 1 {
+			return wrongArguments(command)
+		}
+		return bulkString(args[0])
+	case "SELECT":
+		return ok()
+	case "ASKING":
+		s.asking = true
+		return ok()
+	case "INFO":
+		return bulkString(c.info(n, args))
+	case "CONFIG":
+		return c.configCommand(n, args)
+	case "BGSAVE":
+		n.lastSave++
+		return simpleString("Background saving started")
+	case "SAVE":
+		n.lastSave++
+		return ok()
+	case "LASTSAVE":
+		return integer(n.lastSave)
+	case "DBSIZE":
+		return integer(int64(len(n.data)))
+	case "GET", "SET", "DEL", "EXISTS":
+		return c.keyCommand(n, command, args, asking)
+	case "MEMORY":
+		if len(args) < 2 || strings.ToUpper(args[0]) != "USAGE" {
+			return errorReply("ERR unknown subcommand of MEMORY")
+		}
+		value, exists := n.data[args[1]]
+		if !exists {
+			return nilReply()
+		}
+		return integer(int64(len(args[1]) + len(value) + 50))
+	case "SLAVEOF", "REPLICAOF":
+		return c.replicaOf(n, args)
+	case "MIGRATE":
+		return c.migrate(n, args)
+	case "CLUSTER":
+		if !c.clusterEnabled {
+			return errorReply("ERR This instance has cluster support disabled")
+		}
+		return c.clusterCommand(n, args)
+	}
+	return errorReply("ERR unknown command '%s'", strings.ToLower(command))
+}
+
+func wrongArguments(command string) []byte {
+	return errorReply("ERR wrong number of arguments for '%s' command", strings.ToLower(command))
+}
+
+//region Keys
+
+// keyCommand runs the commands on keys. In Cluster mode clients are redirected to the master owning the slot,
+// or asked to try the master importing the slot while the key has already been migrated.
+func (c *Cluster) keyCommand(n *node, command string, args []string, asking bool) []byte {
+	if len(args) == 0 {
+		return wrongArguments(command)
+	}
+	write := command == "SET" || command == "DEL"
+	if c.clusterEnabled {
+		slot := KeySlot(args[0])
+		for _, key := range args[1:] {
+			if command != "SET" && KeySlot(key) != slot {
+				return errorReply("CROSSSLOT Keys in request don't hash to the same slot")
+			}
+		}
+		if !c.clusterOK(n) {
+			return errorReply("CLUSTERDOWN The cluster is down")
+		}
+		owner := c.owners[slot]
+		switch {
+		case owner == n && n.migrating[slot] != nil && !keysExist(n, args[:1]):
+			return errorReply("ASK %d %s", slot, n.migrating[slot].address())
+		case owner != n && !(asking && n.importing[slot] != nil):
+			return errorReply("MOVED %d %s", slot, owner.address())
+		}
+	}
+	if write && n.replica {
+		return errorReply("READONLY You can't write against a read only replica.")
+	}
+
+	switch command {
+	case "GET":
+		value, exists := n.data[args[0]]
+		if !exists {
+			return nilReply()
+		}
+		return bulkString(value)
+	case "SET":
+		if len(args) < 2 {
+			return wrongArguments(command)
+		}
+		c.write(n, func(data map[string]string) {
+			data[args[0]] = args[1]
+		})
+		return ok()
+	case "DEL":
+		deleted := 0
+		for _, key := range args {
+			if _, exists := n.data[key]; exists {
+				deleted++
+			}
+		}
+		c.write(n, func(data map[string]string) {
+			for _, key := range args {
+				delete(data, key)
+			}
+		})
+		return integer(int64(deleted))
+	default:
+		existing := 0
+		for _, key := range args {
+			if _, exists := n.data[key]; exists {
+				existing++
+			}
+		}
+		return integer(int64(existing))
+	}
+}
+
+func keysExist(n *node, keys []string) bool {
+	for _, key := range keys {
+		if _, exists := n.data[key]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// migrate moves keys to the node on the host, as MIGRATE host port key|"" db timeout [COPY] [REPLACE] [AUTH password] [KEYS key...]
+func (c *Cluster) migrate(n *node, args []string) []byte {
+	if len(args) < 5 {
+		return wrongArguments("MIGRATE")
+	}
+	destination := c.lookup(net.JoinHostPort(args[0], args[1]))
+	if destination == nil || destination.stopped {
+		return errorReply("IOERR error or timeout connecting to the client")
+	}
+	keys := []string{args[2]}
+	replace, keep := false, false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COPY":
+			keep = true
+		case "REPLACE":
+			replace = true
+		case "AUTH":
+			i++
+		case "AUTH2":
+			i += 2
+		case "KEYS":
+			if args[2] != "" {
+				return errorReply("ERR When using MIGRATE KEYS option, the key argument must be set to the empty string")
+			}
+			keys = args[i+1:]
+			i = len(args)
+		}
+	}
+	var existing []string
+	for _, key := range keys {
+		if _, exists := n.data[key]; !exists {
+			continue
+		}
+		if _, exists := destination.data[key]; exists && !replace {
+			return errorReply("ERR Target instance replied with error: BUSYKEY Target key name already exists.")
+		}
+		existing = append(existing, key)
+	}
+	if len(existing) == 0 {
+		return simpleString("NOKEY")
+	}
+	values := map[string]string{}
+	for _, key := range existing {
+		values[key] = n.data[key]
+	}
+	c.write(destination, func(data map[string]string) {
+		for key, value := range values {
+			data[key] = value
+		}
+	})
+	if !keep {
+		c.write(n, func(data map[string]string) {
+			for key := range values {
+				delete(data, key)
+			}
+		})
+	}
+	return ok()
+}
+
+//endregion
+
+//region Server
+
+func (c *Cluster) info(n *node, args []string) string {
+	sections := map[string]bool{}
+	for _, arg := range args {
+		sections[strings.ToLower(arg)] = true
+	}
+	all := len(sections) == 0 || sections["all"] || sections["default"] || sections["everything"]
+	var result strings.Builder
+	section := func(name string, fields ...string) {
+		if !all && !sections[strings.ToLower(name)] {
+			return
+		}
+		if result.Len() > 0 {
+			result.WriteString("\r\n")
+		}
+		result.WriteString("# " + name + "\r\n")
+		for _, field := range fields {
+			result.WriteString(field + "\r\n")
+		}
+	}
+
+	mode := "standalone"
+	if c.clusterEnabled {
+		mode = "cluster"
+	}
+	section("Server", "redis_version:7.0.0", "redis_mode:"+mode, "tcp_port:"+Port)
+
+	used := 1000000
+	for key, value := range n.data {
+		used += len(key) + len(value) + 50
+	}
+	section("Memory", fmt.Sprintf("used_memory:%d", used), "maxmemory:"+n.config["maxmemory"])
+
+	loading := "0"
+	if n.loading {
+		loading = "1"
+	}
+	section("Persistence", "loading:"+loading, "rdb_bgsave_in_progress:0", "rdb_last_bgsave_status:ok", fmt.Sprintf("rdb_last_save_time:%d", n.lastSave))
+
+	replication := []string{"role:" + n.role()}
+	if n.replica {
+		linkStatus := "down"
+		if n.master != nil && !n.master.stopped {
+			linkStatus = "up"
+		}
+		replication = append(replication,
+			"master_host:"+n.masterHost,
+			"master_port:"+Port,
+			"master_link_status:"+linkStatus,
+			fmt.Sprintf("slave_repl_offset:%d", n.offset),
+		)
+	} else {
+		replicas := 0
+		for _, other := range c.nodes {
+			if other.replica && other.master == n && !other.stopped {
+				replication = append(replication, fmt.Sprintf("slave%d:ip=%s,port=%s,state=online,offset=%d,lag=0", replicas, other.ip, Port, other.offset))
+				replicas++
+			}
+		}
+		replication = append(replication, fmt.Sprintf("connected_slaves:%d", replicas))
+	}
+	replication = append(replication, "master_replid:"+n.replID, fmt.Sprintf("master_repl_offset:%d", n.offset))
+	section("Replication", replication...)
+
+	clusterEnabled := "0"
+	if c.clusterEnabled {
+		clusterEnabled = "1"
+	}
+	section("Cluster", "cluster_enabled:"+clusterEnabled)
+
+	var keyspace []string
+	if len(n.data) > 0 {
+		keyspace = append(keyspace, fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0", len(n.data)))
+	}
+	section("Keyspace", keyspace...)
+	return result.String()
+}
+
+func (c *Cluster) configCommand(n *node, args []string) []byte {
+	if len(args) == 0 {
+		return wrongArguments("CONFIG")
+	}
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return wrongArguments("CONFIG|SET")
+		}
+		for i := 1; i < len(args); i += 2 {
+			n.config[strings.ToLower(args[i])] = args[i+1]
+		}
+		return ok()
+	case "GET":
+		if len(args) < 2 {
+			return wrongArguments("CONFIG|GET")
+		}
+		var result []string
+		parameters := make([]string, 0, len(n.config))
+		for parameter := range n.config {
+			parameters = append(parameters, parameter)
+		}
+		sort.Strings(parameters)
+		for _, parameter := range parameters {
+			for _, pattern := range args[1:] {
+				if matched, _ := path.Match(strings.ToLower(pattern), parameter); matched {
+					result = append(result, parameter, n.config[parameter])
+					break
+				}
+			}
+		}
+		return stringArray(result)
+	}
+	return errorReply("ERR unknown subcommand '%s'", args[0])
+}
+
+// replicaOf handles SLAVEOF for nodes without cluster support
+func (c *Cluster) replicaOf(n *node, args []string) []byte {
+	if c.clusterEnabled {
+		return errorReply("ERR REPLICAOF not allowed in cluster mode.")
+	}
+	if len(args) != 2 {
+		return wrongArguments("REPLICAOF")
+	}
+	if strings.EqualFold(args[0], "NO") && strings.EqualFold(args[1], "ONE") {
+		if n.replica {
+			// The replica continues the history of its master, under a new ID
+			n.replica = false
+			n.master = nil
+			n.masterHost = ""
+			n.replID = c.newID()
+		}
+		return ok()
+	}
+	master := c.lookup(net.JoinHostPort(args[0], args[1]))
+	if master == n {
+		return errorReply("ERR Can't replicate myself")
+	}
+	n.replica = true
+	n.masterHost = args[0]
+	n.master = master
+	c.sync(n)
+	return ok()
+}
+
+//endregion
+
+//region Cluster
+
+func (c *Cluster) clusterCommand(n *node, args []string) []byte {
+	if len(args) == 0 {
+		return wrongArguments("CLUSTER")
+	}
+	subcommand := strings.ToUpper(args[0])
+	args = args[1:]
+	switch subcommand {
+	case "MYID":
+		return bulkString(n.id)
+	case "NODES":
+		return bulkString(c.clusterNodes(n))
+	case "INFO":
+		return bulkString(c.clusterInfo(n))
+	case "SAVECONFIG":
+		return ok()
+	case "MEET":
+		if len(args) < 2 {
+			return wrongArguments("CLUSTER|MEET")
+		}
+		// Meeting an unknown address succeeds, as the handshake happens in the background
+		if other := c.lookup(net.JoinHostPort(args[0], args[1])); other != nil && !other.stopped && other != n {
+			c.meet(n, other)
+		}
+		return ok()
+	case "ADDSLOTS", "DELSLOTS":
+		return c.changeSlots(n, subcommand, args)
+	case "SETSLOT":
+		return c.setSlot(n, args)
+	case "REPLICATE":
+		if len(args) != 1 {
+			return wrongArguments("CLUSTER|REPLICATE")
+		}
+		master := c.findKnown(n, args[0])
+		switch {
+		case master == nil:
+			return errorReply("ERR Unknown node %s", args[0])
+		case master == n:
+			return errorReply("ERR Can't replicate myself")
+		case master.replica:
+			return errorReply("ERR I can only replicate a master, not a replica.")
+		case !n.replica && (len(c.ownedSlots(n)) > 0 || len(n.data) > 0):
+			return errorReply("ERR To set a master the node must be empty and without assigned slots.")
+		}
+		c.replicate(n, master)
+		return ok()
+	case "FORGET":
+		if len(args) != 1 {
+			return wrongArguments("CLUSTER|FORGET")
+		}
+		forget := c.findKnown(n, args[0])
+		switch {
+		case forget == nil:
+			return errorReply("ERR Unknown node %s", args[0])
+		case forget == n:
+			return errorReply("ERR I tried hard but I can't forget myself...")
+		case n.replica && n.master == forget:
+			return errorReply("ERR Can't forget my master!")
+		}
+		delete(n.peers, forget)
+		return ok()
+	case "FAILOVER":
+		if !n.replica {
+			return errorReply("ERR You should send CLUSTER FAILOVER to a replica")
+		}
+		force := len(args) > 0 && (strings.EqualFold(args[0], "FORCE") || strings.EqualFold(args[0], "TAKEOVER"))
+		if !force && (n.master == nil || n.master.stopped) {
+			return errorReply("ERR Master is down or failed, please use CLUSTER FAILOVER FORCE")
+		}
+		c.failover(n)
+		return ok()
+	case "RESET":
+		return c.reset(n, args)
+	case "COUNTKEYSINSLOT":
+		if len(args) != 1 {
+			return wrongArguments("CLUSTER|COUNTKEYSINSLOT")
+		}
+		slot, err := parseSlot(args[0])
+		if err != nil {
+			return errorReply("ERR %v", err)
+		}
+		return integer(int64(len(keysInSlot(n, slot))))
+	case "GETKEYSINSLOT":
+		if len(args) != 2 {
+			return wrongArguments("CLUSTER|GETKEYSINSLOT")
+		}
+		slot, err := parseSlot(args[0])
+		if err != nil {
+			return errorReply("ERR %v", err)
+		}
+		count, err := strconv.Atoi(args[1])
+		if err != nil || count < 0 {
+			return errorReply("ERR Invalid number of keys")
+		}
+		keys := keysInSlot(n, slot)
+		if len(keys) > count {
+			keys = keys[:count]
+		}
+		return stringArray(keys)
+	case "KEYSLOT":
+		if len(args) != 1 {
+			return wrongArguments("CLUSTER|KEYSLOT")
+		}
+		return integer(int64(KeySlot(args[0])))
+	}
+	return errorReply("ERR unknown subcommand '%s'", strings.ToLower(subcommand))
+}
+
+func parseSlot(value string) (int, error) {
+	slot, err := strconv.Atoi(value)
+	if err != nil || slot < 0 || slot >= totalSlots {
+		return 0, fmt.Errorf("Invalid or out of range slot")
+	}
+	return slot, nil
+}
+
+func keysInSlot(n *node, slot int) []string {
+	return sortedKeys(n.data, func(key string) bool {
+		return KeySlot(key) == slot
+	})
+}
+
+// clusterNodes returns the view of the node on the cluster, in the format of CLUSTER NODES
+func (c *Cluster) clusterNodes(n *node) string {
+	var result strings.Builder
+	for _, known := range c.known(n) {
+		flags := []string{}
+		if known == n {
+			flags = append(flags, "myself")
+		}
+		flags = append(flags, known.role())
+		if known.stopped {
+			flags = append(flags, "fail")
+		}
+		master := "-"
+		if known.replica && known.master != nil {
+			master = known.master.id
+		}
+		linkState := "connected"
+		if known.stopped {
+			linkState = "disconnected"
+		}
+		address := known.address() + "@1" + Port
+		if hostname := known.config["cluster-announce-hostname"]; hostname != "" {
+			address += "," + hostname
+		}
+		fields := []string{known.id, address, strings.Join(flags, ","), master, "0", "0", strconv.FormatInt(known.epoch, 10), linkState}
+		if !known.replica {
+			fields = append(fields, formatSlots(c.ownedSlots(known))...)
+		}
+		if known == n {
+			fields = append(fields, formatOpenSlots(n.migrating, "->-")...)
+			fields = append(fields, formatOpenSlots(n.importing, "-<-")...)
+		}
+		result.WriteString(strings.Join(fields, " ") + "\n")
+	}
+	return result.String()
+}
+
+func (c *Cluster) clusterInfo(n *node) string {
+	known := map[*node]bool{}
+	for _, k := range c.known(n) {
+		known[k] = true
+	}
+	assigned, failed := 0, 0
+	sizes := map[*node]bool{}
+	for _, owner := range c.owners {
+		if owner == nil || !known[owner] {
+			continue
+		}
+		assigned++
+		sizes[owner] = true
+		if owner.stopped {
+			failed++
+		}
+	}
+	state := "fail"
+	if c.clusterOK(n) {
+		state = "ok"
+	}
+	fields := []string{
+		"cluster_state:" + state,
+		fmt.Sprintf("cluster_slots_assigned:%d", assigned),
+		fmt.Sprintf("cluster_slots_ok:%d", assigned-failed),
+		"cluster_slots_pfail:0",
+		fmt.Sprintf("cluster_slots_fail:%d", failed),
+		fmt.Sprintf("cluster_known_nodes:%d", len(known)),
+		fmt.Sprintf("cluster_size:%d", len(sizes)),
+		fmt.Sprintf("cluster_current_epoch:%d", c.currentEpoch),
+		fmt.Sprintf("cluster_my_epoch:%d", n.epoch),
+	}
+	return strings.Join(fields, "\r\n") + "\r\n"
+}
+
+func formatSlots(slots []int) []string {
+	var result []string
+	for i := 0; i < len(slots); {
+		start := slots[i]
+		end := start
+		for i++; i < len(slots) && slots[i] == end+1; i++ {
+			end = slots[i]
+		}
+		if start == end {
+			result = append(result, strconv.Itoa(start))
+		} else {
+			result = append(result, fmt.Sprintf("%d-%d", start, end))
+		}
+	}
+	return result
+}
+
+func formatOpenSlots(openSlots map[int]*node, separator string) []string {
+	slots := make([]int, 0, len(openSlots))
+	for slot := range openSlots {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	var result []string
+	for _, slot := range slots {
+		result = append(result, fmt.Sprintf("[%d%s%s]", slot, separator, openSlots[slot].id))
+	}
+	return result
+}
+
+func (c *Cluster) changeSlots(n *node, subcommand string, args []string) []byte {
+	if len(args) == 0 {
+		return wrongArguments("CLUSTER|" + subcommand)
+	}
+	if n.replica {
+		return errorReply("ERR Only masters can own slots")
+	}
+	var slots []int
+	for _, arg := range args {
+		slot, err := parseSlot(arg)
+		if err != nil {
+			return errorReply("ERR %v", err)
+		}
+		switch {
+		case subcommand == "ADDSLOTS" && c.owners[slot] != nil:
+			return errorReply("ERR Slot %d is already busy", slot)
+		case subcommand == "DELSLOTS" && c.owners[slot] == nil:
+			return errorReply("ERR Slot %d is already unassigned", slot)
+		}
+		slots = append(slots, slot)
+	}
+	for _, slot := range slots {
+		if subcommand == "ADDSLOTS" {
+			c.owners[slot] = n
+		} else {
+			c.owners[slot] = nil
+		}
+	}
+	return ok()
+}
+
+// setSlot handles CLUSTER SETSLOT slot IMPORTING|MIGRATING|NODE node-id, and CLUSTER SETSLOT slot STABLE
+func (c *Cluster) setSlot(n *node, args []string) []byte {
+	if len(args) < 2 {
+		return wrongArguments("CLUSTER|SETSLOT")
+	}
+	if n.replica {
+		return errorReply("ERR Please use SETSLOT only with masters.")
+	}
+	slot, err := parseSlot(args[0])
+	if err != nil {
+		return errorReply("ERR %v", err)
+	}
+	action := strings.ToUpper(args[1])
+	if action == "STABLE" {
+		delete(n.migrating, slot)
+		delete(n.importing, slot)
+		return ok()
+	}
+	if len(args) != 3 {
+		return wrongArguments("CLUSTER|SETSLOT")
+	}
+	target := c.findKnown(n, args[2])
+	if target == nil {
+		return errorReply("ERR I don't know about node %s", args[2])
+	}
+	switch action {
+	case "MIGRATING":
+		if c.owners[slot] != n {
+			return errorReply("ERR I'm not the owner of hash slot %d", slot)
+		}
+		n.migrating[slot] = target
+	case "IMPORTING":
+		if c.owners[slot] == n {
+			return errorReply("ERR I'm already the owner of hash slot %d", slot)
+		}
+		n.importing[slot] = target
+	case "NODE":
+		if target.replica {
+			return errorReply("ERR Can't assign hashslot %d to a replica node.", slot)
+		}
+		if c.owners[slot] == n && target != n && len(keysInSlot(n, slot)) > 0 {
+			return errorReply("ERR Can't assign hashslot %d to a different node while I still hold keys for this hash slot.", slot)
+		}
+		delete(n.migrating, slot)
+		if target == n && n.importing[slot] != nil {
+			// Closing the import claims the slot without agreement from the other masters, so the node bumps its epoch
+			delete(n.importing, slot)
+			c.currentEpoch++
+			n.epoch = c.currentEpoch
+		}
+		c.owners[slot] = target
+	default:
+		return errorReply("ERR Invalid CLUSTER SETSLOT action or number of arguments")
+	}
+	return ok()
+}
+
+// reset handles CLUSTER RESET [SOFT|HARD]. The node forgets the other nodes, and releases its slots.
+// Replicas become empty masters.
+func (c *Cluster) reset(n *node, args []string) []byte {
+	hard := len(args) > 0 && strings.EqualFold(args[0], "HARD")
+	if !n.replica && len(n.data) > 0 {
+		return errorReply("ERR CLUSTER RESET can't be called with master nodes containing keys")
+	}
+	if n.replica {
+		n.replica = false
+		n.master = nil
+		n.masterHost = ""
+		n.data = map[string]string{}
+	}
+	for _, slot := range c.ownedSlots(n) {
+		c.owners[slot] = nil
+	}
+	n.peers = map[*node]bool{}
+	n.migrating = map[int]*node{}
+	n.importing = map[int]*node{}
+	if hard {
+		for _, other := range c.nodes {
+			delete(other.peers, n)
+		}
+		n.id = c.newID()
+		n.epoch = 0
+	}
+	return ok()
+}
+
+//endregion
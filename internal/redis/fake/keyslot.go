@@ -0,0 +1,32 @@
+package fake
+
+import "strings"
+
+const totalSlots = 16384
+
+// KeySlot returns the hash slot of the key, the same way Redis Cluster calculates it.
+// Only the part between the first { and the following } is hashed, if that part is not empty.
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % totalSlots)
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum Redis Cluster uses for key slots
+func crc16(key string) uint16 {
+	crc := uint16(0)
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,183 @@
+package fake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// session is the state of a single client connection
+type session struct {
+	authenticated bool
+	// asking is set by ASKING, and allows the next command to access a slot which is being imported
+	asking bool
+}
+
+// serve runs the commands sent over the connection against the node, until the connection is closed
+func (c *Cluster) serve(conn net.Conn, n *node) {
+	writer := newReplyWriter(conn)
+	go writer.run()
+	defer writer.close()
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(n.conns, conn)
+	}()
+	reader := bufio.NewReader(conn)
+	s := &session{}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+		writer.write(c.execute(n, s, args))
+	}
+}
+
+// readCommand reads a single command in the RESP format, an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		// Inline commands are only used by hand, for example through telnet
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid array length %q", line)
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		line, err = readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk string length %q", line)
+		}
+		data := make([]byte, length+2)
+		_, err = io.ReadFull(reader, data)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:length]))
+	}
+	return args, nil
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// replyWriter writes replies to the connection in the background.
+// Clients write a whole pipeline before reading any reply, and connections made with net.Pipe are not buffered,
+// so writing the replies straight away would block the server until the client is done writing.
+type replyWriter struct {
+	conn   net.Conn
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  [][]byte
+	closed bool
+}
+
+func newReplyWriter(conn net.Conn) *replyWriter {
+	w := &replyWriter{conn: conn}
+	w.cond = sync.NewCond(&w.mu)
+	return w
+}
+
+func (w *replyWriter) write(reply []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.queue = append(w.queue, reply)
+	w.cond.Signal()
+}
+
+func (w *replyWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	w.cond.Signal()
+}
+
+func (w *replyWriter) run() {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		batch := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, reply := range batch {
+			_, err := w.conn.Write(reply)
+			if err != nil {
+				_ = w.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+//region Replies
+
+func simpleString(value string) []byte {
+	return []byte("+" + value + "\r\n")
+}
+
+func ok() []byte {
+	return simpleString("OK")
+}
+
+func errorReply(format string, args ...interface{}) []byte {
+	return []byte("-" + fmt.Sprintf(format, args...) + "\r\n")
+}
+
+func integer(value int64) []byte {
+	return []byte(":" + strconv.FormatInt(value, 10) + "\r\n")
+}
+
+func bulkString(value string) []byte {
+	return []byte("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+}
+
+func nilReply() []byte {
+	return []byte("$-1\r\n")
+}
+
+func array(items [][]byte) []byte {
+	reply := []byte("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		reply = append(reply, item...)
+	}
+	return reply
+}
+
+func stringArray(values []string) []byte {
+	items := make([][]byte, 0, len(values))
+	for _, value := range values {
+		items = append(items, bulkString(value))
+	}
+	return array(items)
+}
+
+//endregion
@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
+	"github.com/containersolutions/redis-cluster-operator/internal/redis/fake"
+	"github.com/go-redis/redis/v8"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+// newFakeClusterNodes connects to the fake nodes on the IPs, as the Operator connects to the nodes in the pods of a cluster.
+// The pod of every node is named after the position of its IP.
+func newFakeClusterNodes(t *testing.T, cluster *fake.Cluster, ips ...string) *ClusterNodes {
+	clusterNodes := &ClusterNodes{}
+	for i, ip := range ips {
+		node, err := NewNode(context.TODO(), &redis.Options{
+			Addr: ip + ":" + fake.Port,
+		}, &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("rediscluster-%d", i),
+				Namespace: "default",
+			},
+			Status: v1.PodStatus{
+				PodIP: ip,
+			},
+		}, cluster.ClientBuilder())
+		if err != nil {
+			t.Fatalf("Could not connect to fake node %s %v", ip, err)
+		}
+		clusterNodes.Nodes = append(clusterNodes.Nodes, node)
+	}
+	return clusterNodes
+}
+
+func TestClusterNodes_RebalanceMovesKeysToNewMaster(t *testing.T) {
+	ctx := context.TODO()
+	cluster := fake.NewCluster()
+	cluster.AddNode("10.0.0.1")
+	cluster.AddNode("10.0.0.2")
+	cluster.Form(2)
+	cluster.AddNode("10.0.0.3")
+	clusterNodes := newFakeClusterNodes(t, cluster, "10.0.0.1", "10.0.0.2", "10.0.0.3")
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner := cluster.SlotOwner(fake.KeySlot(key))
+		err := clusterNodes.Nodes[0].clientBuilder(&redis.Options{Addr: owner + ":" + fake.Port}).Set(ctx, key, "value", 0).Err()
+		if err != nil {
+			t.Fatalf("Could not set key %s %v", key, err)
+		}
+	}
+
+	err := clusterNodes.Execute(ctx, planner.PlanMeet(clusterNodes.GetTopology()), 0)
+	if err != nil {
+		t.Fatalf("Could not meet nodes %v", err)
+	}
+	err = clusterNodes.ReloadNodes(ctx)
+	if err != nil {
+		t.Fatalf("Could not reload nodes %v", err)
+	}
+	plan := clusterNodes.CalculateRebalance(ctx, &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+		},
+	})
+	err = clusterNodes.ApplyRebalancePlan(ctx, plan)
+	if err != nil {
+		t.Fatalf("Could not apply rebalance plan %v", err)
+	}
+
+	err = clusterNodes.ReloadNodes(ctx)
+	if err != nil {
+		t.Fatalf("Could not reload nodes %v", err)
+	}
+	check, err := clusterNodes.CheckCluster(ctx)
+	if err != nil {
+		t.Fatalf("Could not check cluster %v", err)
+	}
+	if !check.Healthy() {
+		t.Fatalf("Expected the cluster to be healthy after rebalancing. Got %v", check.Issues)
+	}
+	newMaster := clusterNodes.Nodes[2]
+	if len(newMaster.NodeAttributes.GetSlots()) < TotalRedisSlots/3 {
+		t.Fatalf("Expected the new master to own a third of the slots. Got %d", len(newMaster.NodeAttributes.GetSlots()))
+	}
+	// Every key lives on the master which owns its slot
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		for _, key := range cluster.Keys(ip) {
+			if owner := cluster.SlotOwner(fake.KeySlot(key)); owner != ip {
+				t.Fatalf("Expected key %s to be moved to %s. Found it on %s", key, owner, ip)
+			}
+		}
+	}
+	if len(cluster.Keys("10.0.0.3")) == 0 {
+		t.Fatalf("Expected keys to be moved to the new master")
+	}
+}
+
+func TestClusterNodes_ForgetsNodeReplacedAfterFailover(t *testing.T) {
+	ctx := context.TODO()
+	cluster := fake.NewCluster()
+	ips := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}
+	for _, ip := range ips {
+		cluster.AddNode(ip)
+	}
+	cluster.Form(3)
+	oldID := cluster.NodeID("10.0.0.1")
+
+	// The pod of the first master is recreated without its data, so its replica takes over
+	cluster.Replace("10.0.0.1")
+	clusterNodes := newFakeClusterNodes(t, cluster, ips[1:]...)
+	failing, err := clusterNodes.GetFailingNodes(ctx)
+	if err != nil {
+		t.Fatalf("Could not get failing nodes %v", err)
+	}
+	if len(failing) != 1 || failing[0].NodeAttributes.ID != oldID {
+		t.Fatalf("Expected the replaced master to be failing. Got %v", failing)
+	}
+	err = clusterNodes.ForgetNode(ctx, failing[0])
+	if err != nil {
+		t.Fatalf("Could not forget node %v", err)
+	}
+
+	check, err := clusterNodes.CheckCluster(ctx)
+	if err != nil {
+		t.Fatalf("Could not check cluster %v", err)
+	}
+	if !check.Healthy() {
+		t.Fatalf("Expected the cluster to be healthy once the failing node is forgotten. Got %v", check.Issues)
+	}
+	if cluster.SlotOwner(0) != "10.0.0.4" {
+		t.Fatalf("Expected the replica of the replaced master to own its slots. Got %s", cluster.SlotOwner(0))
+	}
+}
@@ -0,0 +1,160 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetTopology returns a snapshot of the nodes for the planner.
+// It is taken from what was loaded with the nodes, so it does not send any commands.
+// The failing nodes are taken from the view of the first master, like GetFailingNodes does.
+func (c *ClusterNodes) GetTopology() planner.Topology {
+	topology := planner.Topology{}
+	failingSeen := false
+	for _, node := range c.Nodes {
+		topologyNode := planner.Node{
+			ID:         node.NodeAttributes.ID,
+			Address:    node.GetIP() + ":" + node.NodeAttributes.port,
+			Ordinal:    -1,
+			Master:     node.IsMaster(),
+			Slots:      append([]int32(nil), node.NodeAttributes.slots...),
+			HostLabels: node.HostLabels,
+			Peers:      map[string]string{},
+		}
+		if node.PodDetails != nil {
+			topologyNode.Pod = node.PodDetails.Name
+			podParts := strings.Split(node.PodDetails.Name, "-")
+			if ordinal, err := strconv.Atoi(podParts[len(podParts)-1]); err == nil {
+				topologyNode.Ordinal = int32(ordinal)
+			}
+		}
+		if !topologyNode.Master {
+			topologyNode.MasterID = node.NodeAttributes.masterID
+		}
+		for _, peer := range node.view {
+			if peer.HasFlag("myself") {
+				continue
+			}
+			topologyNode.Peers[peer.ID] = peer.host + ":" + peer.port
+			if topologyNode.Master && !failingSeen && peer.HasFlag("fail") {
+				topology.Failing = append(topology.Failing, peer.ID)
+			}
+		}
+		if topologyNode.Master {
+			failingSeen = true
+		}
+		topology.Nodes = append(topology.Nodes, topologyNode)
+	}
+	return topology
+}
+
+// Execute applies the actions to the nodes, in order, and stops at the first action which fails.
+// Nodes only accept commands about nodes they know, so after meeting nodes it waits for the meetings
+// to propagate through the gossip protocol, before applying the next kind of action.
+func (c *ClusterNodes) Execute(ctx context.Context, actions []planner.Action, meetPropagationDelay time.Duration) error {
+	for i, action := range actions {
+		err := c.execute(ctx, action)
+		if err != nil {
+			return fmt.Errorf("could not %s: %w", action, err)
+		}
+		if _, ok := action.(planner.Meet); !ok {
+			continue
+		}
+		if i+1 < len(actions) {
+			if _, ok := actions[i+1].(planner.Meet); ok {
+				continue
+			}
+		}
+		time.Sleep(meetPropagationDelay)
+	}
+	return nil
+}
+
+func (c *ClusterNodes) execute(ctx context.Context, action planner.Action) error {
+	switch action := action.(type) {
+	case planner.Meet:
+		node, err := c.getReachableNode(action.Node)
+		if err != nil {
+			return err
+		}
+		peer, err := c.getReachableNode(action.Peer)
+		if err != nil {
+			return err
+		}
+		return node.MeetNode(ctx, peer)
+	case planner.Replicate:
+		node, err := c.getReachableNode(action.Node)
+		if err != nil {
+			return err
+		}
+		return node.ClusterReplicate(ctx, action.Master).Err()
+	case planner.Reset:
+		node, err := c.getReachableNode(action.Node)
+		if err != nil {
+			return err
+		}
+		return node.ClusterResetSoft(ctx).Err()
+	case planner.AddSlots:
+		node, err := c.getReachableNode(action.Node)
+		if err != nil {
+			return err
+		}
+		var slots []int
+		for _, slot := range action.Slots {
+			slots = append(slots, int(slot))
+		}
+		return node.ClusterAddSlots(ctx, slots...).Err()
+	case planner.MoveSlots:
+		source, err := c.getReachableNode(action.Source)
+		if err != nil {
+			return err
+		}
+		destination, err := c.getReachableNode(action.Destination)
+		if err != nil {
+			return err
+		}
+		for _, slot := range action.Slots {
+			err = c.MoveSlot(ctx, source, destination, int(slot))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case planner.Forget:
+		return c.forgetNodeID(ctx, action.Node)
+	case planner.Failover:
+		node, err := c.getReachableNode(action.Node)
+		if err != nil {
+			return err
+		}
+		return node.ClusterFailover(ctx).Err()
+	}
+	return fmt.Errorf("unknown action %T", action)
+}
+
+func (c *ClusterNodes) getReachableNode(id string) (*Node, error) {
+	node := c.GetNodeByID(id)
+	if node == nil {
+		return nil, fmt.Errorf("node %s is not reachable", id)
+	}
+	return node, nil
+}
+
+// getRebalancePlan turns a rebalance of the planner into a plan on the nodes
+func (c *ClusterNodes) getRebalancePlan(rebalance planner.Rebalance) *RebalancePlan {
+	plan := &RebalancePlan{
+		MaxDeviationPercentage: rebalance.MaxDeviationPercentage,
+	}
+	for _, move := range rebalance.Moves {
+		plan.Moves = append(plan.Moves, SlotMove{
+			Source:      c.GetNodeByID(move.Source),
+			Destination: c.GetNodeByID(move.Destination),
+			Slots:       move.Slots,
+		})
+	}
+	return plan
+}
@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"strings"
+)
+
+// parseInfo returns the fields of the output of the INFO command, mapped by their name
+func parseInfo(info string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(info, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+	return fields
+}
+
+// GetReadiness returns whether the node is ready to serve clients, and why when it is not.
+// A node is not ready while it loads its data set, or while it is a replica which is not in sync with its master.
+// In Cluster mode a node is also not ready while it sees the cluster as failed, or when it is a master without slots,
+// as every command sent to it would be redirected.
+func (n *Node) GetReadiness(ctx context.Context, clusterMode bool) (bool, string, error) {
+	persistence, err := n.Info(ctx, "persistence").Result()
+	if err != nil {
+		return false, "", err
+	}
+	if parseInfo(persistence)["loading"] == "1" {
+		return false, "The node is loading its data set", nil
+	}
+
+	replicationInfo, err := n.GetReplicationInfo(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	if replicationInfo.Role != "master" && !replicationInfo.MasterLinkUp {
+		return false, "The replica is not in sync with its master", nil
+	}
+
+	if !clusterMode {
+		return true, "", nil
+	}
+	clusterInfo, err := n.ClusterInfo(ctx).Result()
+	if err != nil {
+		return false, "", err
+	}
+	if state := parseInfo(clusterInfo)["cluster_state"]; state != "ok" {
+		return false, "The cluster state is " + state, nil
+	}
+	if replicationInfo.Role == "master" && len(n.NodeAttributes.GetSlots()) == 0 {
+		return false, "The master owns no slots", nil
+	}
+	return true, "", nil
+}
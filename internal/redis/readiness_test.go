@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redismock/v8"
+	"testing"
+)
+
+func TestNode_GetReadiness(t *testing.T) {
+	tests := []struct {
+		name        string
+		slots       []int32
+		loading     string
+		replication string
+		clusterMode bool
+		clusterInfo string
+		ready       bool
+	}{
+		{
+			name:        "loading data set",
+			loading:     "1",
+			clusterMode: true,
+			ready:       false,
+		},
+		{
+			name:        "replica without link to master",
+			loading:     "0",
+			replication: "role:slave\r\nmaster_host:10.20.30.40\r\nmaster_link_status:down\r\n",
+			clusterMode: true,
+			ready:       false,
+		},
+		{
+			name:        "replica in sync without cluster",
+			loading:     "0",
+			replication: "role:slave\r\nmaster_host:10.20.30.40\r\nmaster_link_status:up\r\n",
+			clusterMode: false,
+			ready:       true,
+		},
+		{
+			name:        "failed cluster",
+			slots:       []int32{0, 1, 2},
+			loading:     "0",
+			replication: "role:master\r\nmaster_repl_offset:0\r\n",
+			clusterMode: true,
+			clusterInfo: "cluster_state:fail\r\ncluster_slots_assigned:3\r\n",
+			ready:       false,
+		},
+		{
+			name:        "master without slots",
+			loading:     "0",
+			replication: "role:master\r\nmaster_repl_offset:0\r\n",
+			clusterMode: true,
+			clusterInfo: "cluster_state:ok\r\ncluster_slots_assigned:16384\r\n",
+			ready:       false,
+		},
+		{
+			name:        "master with slots",
+			slots:       []int32{0, 1, 2},
+			loading:     "0",
+			replication: "role:master\r\nmaster_repl_offset:0\r\n",
+			clusterMode: true,
+			clusterInfo: "cluster_state:ok\r\ncluster_slots_assigned:16384\r\n",
+			ready:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mock := redismock.NewClientMock()
+			mock.ExpectInfo("persistence").SetVal("# Persistence\r\nloading:" + tt.loading + "\r\n")
+			if tt.replication != "" {
+				mock.ExpectInfo("replication").SetVal("# Replication\r\n" + tt.replication)
+			}
+			if tt.clusterInfo != "" {
+				mock.ExpectClusterInfo().SetVal(tt.clusterInfo)
+			}
+			node := &Node{
+				NodeAttributes: NodeAttributes{
+					ID:    "node-0",
+					flags: []string{"master"},
+					slots: tt.slots,
+				},
+				Client: client,
+			}
+
+			ready, message, err := node.GetReadiness(context.TODO(), tt.clusterMode)
+			if err != nil {
+				t.Fatalf("Failed to get readiness %v", err)
+			}
+			if ready != tt.ready {
+				t.Fatalf("Expected ready to be %v. Got %v with message %q", tt.ready, ready, message)
+			}
+			if !ready && message == "" {
+				t.Fatalf("Expected a message explaining why the node is not ready")
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("Not all expectations were met %v", err)
+			}
+		})
+	}
+}
@@ -3,15 +3,18 @@ package redis
 import (
 	"context"
 	"errors"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
 	"github.com/go-redis/redis/v8"
 	v1 "k8s.io/api/core/v1"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const (
-	TotalRedisSlots = 16384
+	TotalRedisSlots = planner.TotalSlots
 )
 
 func ProcessSlotStrings(slotStrings []string) []int32 {
@@ -33,22 +36,54 @@ func ProcessSlotStrings(slotStrings []string) []int32 {
 	return result
 }
 
+// FormatSlotRanges is the inverse of ProcessSlotStrings.
+// It collapses a list of slots into the range format Redis uses, for example [0 1 2 3 5] becomes ["0-3", "5"]
+func FormatSlotRanges(slots []int32) []string {
+	var result []string
+	sorted := make([]int32, len(slots))
+	copy(sorted, slots)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	for i := 0; i < len(sorted); {
+		start := sorted[i]
+		end := start
+		for i++; i < len(sorted) && sorted[i] <= end+1; i++ {
+			end = sorted[i]
+		}
+		if start == end {
+			result = append(result, strconv.Itoa(int(start)))
+		} else {
+			result = append(result, fmt.Sprintf("%d-%d", start, end))
+		}
+	}
+	return result
+}
+
 // NodeAttributes represents the data returned from the CLUSTER NODES commands.
 // The format returned from Redis contains the fields, split by spaces
 //
 // <id> <ip:port@cport> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> <slot> ... <slot>
 //
 // <id> represents the ID of the node in UUID format
-// <ip:port@cport> part has the IP and port of the redis server, with the gossip port after @
+// <ip:port@cport> part has the IP and port of the redis server, with the gossip port after @.
+// From Redis 7 the announced hostname is added after a comma, for example 10.0.0.1:6379@16379,redis-0.redis-headless.default.svc
 // <flags> is a string of flags separated by comma (,). Useful flags include master|slave|myself. Myself is the indicator that this line is for the calling node
 // <master> represents the node ID that is being replicated, if the node is a slave. if it is not replicating anything it will be replaced by a dash (-)
+// <config-epoch> is the epoch the node used when it last claimed its slots. All nodes should agree on the epoch of each master
 // <slot>... represents slot ranges assigned to this node. The format is ranges, or single numbers. 0-4 represents all slots from 0 to 4. 8 represents the single slot 8
+// Slots which are being moved are shown as [slot->-node-id] on the source node, and [slot-<-node-id] on the destination node
 type NodeAttributes struct {
-	ID    string
-	host  string
-	port  string
-	flags []string
-	slots []int32
+	ID             string
+	host           string
+	port           string
+	hostname       string
+	flags          []string
+	masterID       string
+	configEpoch    int64
+	slots          []int32
+	migratingSlots map[int32]string
+	importingSlots map[int32]string
 }
 
 func NewNodeAttributes(nodeString string) NodeAttributes {
@@ -56,15 +91,53 @@ func NewNodeAttributes(nodeString string) NodeAttributes {
 	friendFields := strings.Split(nodeString, " ")
 	address := strings.Split(friendFields[1], "@")[0]
 	addressParts := strings.Split(address, ":")
+	hostname := ""
+	if addressHostname := strings.SplitN(friendFields[1], ",", 2); len(addressHostname) == 2 {
+		hostname = addressHostname[1]
+	}
+	configEpoch, _ := strconv.ParseInt(friendFields[6], 10, 64)
+	migratingSlots, importingSlots := processOpenSlotStrings(friendFields[8:])
 	return NodeAttributes{
-		ID:    friendFields[0],
-		host:  addressParts[0],
-		port:  addressParts[1],
-		flags: strings.Split(friendFields[2], ","),
-		slots: ProcessSlotStrings(friendFields[8:]),
+		ID:             friendFields[0],
+		host:           addressParts[0],
+		port:           addressParts[1],
+		hostname:       hostname,
+		flags:          strings.Split(friendFields[2], ","),
+		masterID:       friendFields[3],
+		configEpoch:    configEpoch,
+		slots:          ProcessSlotStrings(friendFields[8:]),
+		migratingSlots: migratingSlots,
+		importingSlots: importingSlots,
 	}
 }
 
+// processOpenSlotStrings extracts the slots which are currently being migrated or imported.
+// These are shown in the slot list as [slot->-node-id] for migrating slots, and [slot-<-node-id] for importing slots.
+func processOpenSlotStrings(slotStrings []string) (map[int32]string, map[int32]string) {
+	migrating := map[int32]string{}
+	importing := map[int32]string{}
+	for _, slotString := range slotStrings {
+		if !strings.HasPrefix(slotString, "[") || !strings.HasSuffix(slotString, "]") {
+			continue
+		}
+		openSlot := strings.TrimSuffix(strings.TrimPrefix(slotString, "["), "]")
+		if parts := strings.SplitN(openSlot, "->-", 2); len(parts) == 2 {
+			slot, err := strconv.Atoi(parts[0])
+			if err == nil {
+				migrating[int32(slot)] = parts[1]
+			}
+			continue
+		}
+		if parts := strings.SplitN(openSlot, "-<-", 2); len(parts) == 2 {
+			slot, err := strconv.Atoi(parts[0])
+			if err == nil {
+				importing[int32(slot)] = parts[1]
+			}
+		}
+	}
+	return migrating, importing
+}
+
 func (n *NodeAttributes) HasFlag(flag string) bool {
 	for _, _flag := range n.flags {
 		if _flag == flag {
@@ -82,10 +155,34 @@ func (n *NodeAttributes) GetPort() string {
 	return n.port
 }
 
+// GetHostname returns the hostname the node announces through cluster-announce-hostname, if any
+func (n *NodeAttributes) GetHostname() string {
+	return n.hostname
+}
+
 func (n *NodeAttributes) GetSlots() []int32 {
 	return n.slots
 }
 
+// GetMasterID returns the ID of the master this node is replicating, or "-" if the node is not a replica
+func (n *NodeAttributes) GetMasterID() string {
+	return n.masterID
+}
+
+func (n *NodeAttributes) GetConfigEpoch() int64 {
+	return n.configEpoch
+}
+
+// GetMigratingSlots returns the slots being migrated away from this node, mapped to the destination node ID
+func (n *NodeAttributes) GetMigratingSlots() map[int32]string {
+	return n.migratingSlots
+}
+
+// GetImportingSlots returns the slots being imported into this node, mapped to the source node ID
+func (n *NodeAttributes) GetImportingSlots() map[int32]string {
+	return n.importingSlots
+}
+
 // Node represents a single Redis Node with a client, and a client builder.
 // The client builder is necessary in case we are getting nodes from this node, for example when we load friends.
 // We need a clientBuilder, so we can create the same base client for nodes fetched through this node,
@@ -96,6 +193,11 @@ type Node struct {
 	NodeAttributes NodeAttributes
 	clientBuilder  func(opt *redis.Options) *redis.Client
 	PodDetails     *v1.Pod
+	// HostLabels are the labels of the Kubernetes node the pod is running on.
+	// These are only loaded when slot weights need them to select nodes.
+	HostLabels map[string]string
+	// view is the cluster as seen by the node, when its attributes were last loaded
+	view []NodeAttributes
 }
 
 func NewNode(ctx context.Context, opt *redis.Options, pod *v1.Pod, clientBuilder func(opt *redis.Options) *redis.Client) (*Node, error) {
@@ -106,8 +208,9 @@ func NewNode(ctx context.Context, opt *redis.Options, pod *v1.Pod, clientBuilder
 		NodeAttributes: NodeAttributes{},
 		clientBuilder:  clientBuilder,
 	}
-	attributes, err := node.GetSelfAttributes(ctx)
+	attributes, err := node.loadAttributes(ctx)
 	if err != nil {
+		_ = redisClient.Close()
 		return nil, err
 	}
 	node.NodeAttributes = attributes
@@ -118,7 +221,7 @@ func NewNode(ctx context.Context, opt *redis.Options, pod *v1.Pod, clientBuilder
 
 func (n *Node) ReloadNodeInfo(ctx context.Context) error {
 	oldAttributes := n.NodeAttributes
-	attributes, err := n.GetSelfAttributes(ctx)
+	attributes, err := n.loadAttributes(ctx)
 	if err != nil {
 		return err
 	}
@@ -146,6 +249,40 @@ func (n *Node) GetSelfAttributes(ctx context.Context) (NodeAttributes, error) {
 	return NodeAttributes{}, errors.New("could not find myself in nodes list")
 }
 
+// loadAttributes returns the attributes of the node, and keeps the view of the cluster it got them from,
+// so a topology can be taken without asking every node for its view again.
+func (n *Node) loadAttributes(ctx context.Context) (NodeAttributes, error) {
+	view, err := n.GetClusterView(ctx)
+	if err != nil {
+		return NodeAttributes{}, err
+	}
+	for _, nodeAttributes := range view {
+		if nodeAttributes.HasFlag("myself") {
+			n.view = view
+			return nodeAttributes, nil
+		}
+	}
+	return NodeAttributes{}, errors.New("could not find myself in nodes list")
+}
+
+// GetClusterView returns the attributes of every node in the cluster, as seen by this node.
+// Nodes can disagree on the state of the cluster while changes are still propagating through the gossip protocol,
+// so the view of a single node is not necessarily the view of the whole cluster.
+func (n *Node) GetClusterView(ctx context.Context) ([]NodeAttributes, error) {
+	var result []NodeAttributes
+	nodes, err := n.ClusterNodes(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, nodeString := range strings.Split(nodes, "\n") {
+		if strings.TrimSpace(nodeString) == "" {
+			continue
+		}
+		result = append(result, NewNodeAttributes(nodeString))
+	}
+	return result, nil
+}
+
 func (n *Node) IsMaster() bool {
 	return n.NodeAttributes.HasFlag("master")
 }
@@ -177,15 +314,42 @@ func (n *Node) GetFriends(ctx context.Context) ([]*Node, error) {
 	return result, err
 }
 
+// GetIP returns the IP address of the node.
+// We might connect to the node through its hostname, but Redis only accepts IP addresses for commands like CLUSTER MEET,
+// so we use the IP of the pod when we know it.
+func (n *Node) GetIP() string {
+	if n.PodDetails != nil && n.PodDetails.Status.PodIP != "" {
+		return n.PodDetails.Status.PodIP
+	}
+	return n.NodeAttributes.host
+}
+
 // MeetNode let's the node recognise and connect to another Redis Node
 func (n *Node) MeetNode(ctx context.Context, node *Node) error {
-	//parts := strings.Split(node.Client.Options().Addr, ":")
-	//host := parts[0]
-	//port := parts[1]
-	err := n.ClusterMeet(ctx, node.NodeAttributes.host, node.NodeAttributes.port).Err()
+	err := n.ClusterMeet(ctx, node.GetIP(), node.NodeAttributes.port).Err()
 	return err
 }
 
+// AnnounceAddress makes the node announce the given address to the rest of the cluster, and in redirects to clients.
+// The settings are not persisted, so they need to be set again when the node restarts.
+func (n *Node) AnnounceAddress(ctx context.Context, ip string, port int32, busPort int32) error {
+	settings := []struct {
+		parameter string
+		value     string
+	}{
+		{"cluster-announce-ip", ip},
+		{"cluster-announce-port", strconv.Itoa(int(port))},
+		{"cluster-announce-bus-port", strconv.Itoa(int(busPort))},
+	}
+	for _, setting := range settings {
+		err := n.ConfigSet(ctx, setting.parameter, setting.value).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (n *Node) GetOrdindal() int32 {
 	podParts := strings.Split(n.PodDetails.Name, "-")
 	ordinal, err := strconv.Atoi(podParts[len(podParts)-1])
@@ -195,16 +359,8 @@ func (n *Node) GetOrdindal() int32 {
 	return int32(ordinal)
 }
 
-func (n *Node) NeedsSlotCount(cluster *v1alpha1.RedisCluster) int32 {
-	masters := int(cluster.Spec.Masters)
-	remainder := TotalRedisSlots % masters
-
-	// baseSlotsForNode signifies the amount of slots evenly dividable for nodes
-	baseSlotsPerNode := TotalRedisSlots / masters
-
-	slotsForNode := baseSlotsPerNode
-	if int(n.GetOrdindal()) < remainder && remainder != 0 {
-		slotsForNode += 1
-	}
-	return int32(slotsForNode)
+// NeedsSlotCount returns the amount of slots this node needs when slots are spread evenly across all masters.
+// When slot weights are specified, use planner.SlotQuotas instead, as it needs the weights of all masters.
+func (n *Node) NeedsSlotCount(cluster *v1beta1.RedisCluster) int32 {
+	return planner.EvenSlotCount(n.GetOrdindal(), cluster.Spec.Masters)
 }
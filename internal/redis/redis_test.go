@@ -2,7 +2,7 @@ package redis
 
 import (
 	"context"
-	"github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	"github.com/go-redis/redis/v8"
 	"github.com/go-redis/redismock/v8"
 	v1 "k8s.io/api/core/v1"
@@ -19,6 +19,16 @@ func TestNewNodeAttributes(t *testing.T) {
 	}
 }
 
+func TestNewNodeAttributesLoadsAnnouncedHostname(t *testing.T) {
+	attributes := NewNodeAttributes("9fd8800b31d569538917c0aaeaa5588e2f9c6edf 10.244.0.218:6379@16379,redis-cluster-0.redis-cluster-headless.default.svc myself,master - 0 1652373716000 0 connected")
+	if attributes.host != "10.244.0.218" || attributes.port != "6379" {
+		t.Fatalf("Address not being correctly extracted from node string with hostname")
+	}
+	if attributes.GetHostname() != "redis-cluster-0.redis-cluster-headless.default.svc" {
+		t.Fatalf("Expected hostname to be loaded, got %s", attributes.GetHostname())
+	}
+}
+
 // endregion
 
 // region NodeAttributes
@@ -193,6 +203,60 @@ func TestMeetNodeRunsNodeMeetForNewNode(t *testing.T) {
 	}
 }
 
+func TestMeetNodeUsesPodIPWhenConnectedThroughHostname(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	redisNode := Node{
+		Client: db,
+		NodeAttributes: NodeAttributes{
+			ID:    "123456789",
+			host:  "redis-cluster-0.redis-cluster-headless.default.svc",
+			port:  "6379",
+			flags: []string{"master"},
+		},
+	}
+	mock.ExpectClusterMeet("10.244.0.219", "6379").SetVal("OK")
+	err := redisNode.MeetNode(context.TODO(), &Node{
+		Client: db,
+		NodeAttributes: NodeAttributes{
+			ID:    "23456789",
+			host:  "redis-cluster-1.redis-cluster-headless.default.svc",
+			port:  "6379",
+			flags: []string{"master"},
+		},
+		PodDetails: &v1.Pod{
+			Status: v1.PodStatus{
+				PodIP: "10.244.0.219",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Received error while trying to meet nodes %v", err)
+	}
+	if mock.ExpectationsWereMet() != nil {
+		t.Fatalf("Expected node to be met by its pod IP")
+	}
+}
+
+// endregion
+
+// region AnnounceAddress
+func TestNode_AnnounceAddressSetsAnnounceConfig(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	node := Node{
+		Client: db,
+	}
+	mock.ExpectConfigSet("cluster-announce-ip", "203.0.113.10").SetVal("OK")
+	mock.ExpectConfigSet("cluster-announce-port", "30001").SetVal("OK")
+	mock.ExpectConfigSet("cluster-announce-bus-port", "30002").SetVal("OK")
+	err := node.AnnounceAddress(context.TODO(), "203.0.113.10", 30001, 30002)
+	if err != nil {
+		t.Fatalf("Received error while announcing address %v", err)
+	}
+	if mock.ExpectationsWereMet() != nil {
+		t.Fatalf("Not all of the required Redis commands were run")
+	}
+}
+
 // endregion
 
 // region IsMaster
@@ -281,8 +345,8 @@ func TestNeedsSlotCount(t *testing.T) {
 	//
 	// Example: When we have 11 masters, each node will get 1489 slots.
 	// That leaves 15 slot left which needs to be assigned to the earliest nodes
-	cluster := &v1alpha1.RedisCluster{
-		Spec: v1alpha1.RedisClusterSpec{
+	cluster := &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
 			Masters: 3,
 		},
 	}
@@ -304,3 +368,27 @@ func TestNeedsSlotCount(t *testing.T) {
 		t.Fatalf("Incorrect amount of slots calculated for node %v. Expected 5461. Got %d", node.PodDetails.Name, slotsNeeded)
 	}
 }
+
+func TestNodeAttributes_LoadsOpenSlots(t *testing.T) {
+	attributes := NewNodeAttributes("103791967781b9db4ae663dd060b51c442bd7105 10.244.0.250:6379@16379 myself,master - 0 1652695701569 5 connected 0-9 [10->-9fd8800b31d569538917c0aaeaa5588e2f9c6edf] [11-<-8a99a71a38d099de6862284f5aab9329d796c34f]")
+	if !reflect.DeepEqual(attributes.GetSlots(), []int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Fatalf("Open slots should not be counted as assigned slots. Got %v", attributes.GetSlots())
+	}
+	if attributes.GetMigratingSlots()[10] != "9fd8800b31d569538917c0aaeaa5588e2f9c6edf" {
+		t.Fatalf("Migrating slot not loaded correctly. Got %v", attributes.GetMigratingSlots())
+	}
+	if attributes.GetImportingSlots()[11] != "8a99a71a38d099de6862284f5aab9329d796c34f" {
+		t.Fatalf("Importing slot not loaded correctly. Got %v", attributes.GetImportingSlots())
+	}
+	if attributes.GetConfigEpoch() != 5 {
+		t.Fatalf("Config epoch not loaded correctly. Got %d", attributes.GetConfigEpoch())
+	}
+}
+
+func TestFormatSlotRanges(t *testing.T) {
+	got := FormatSlotRanges([]int32{14, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 11, 12, 16, 17, 18, 19})
+	expected := []string{"0-9", "11-12", "14", "16-19"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected slot ranges %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"context"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/go-redis/redis/v8"
+	v1 "k8s.io/api/core/v1"
+	"strconv"
+	"strings"
+)
+
+// ReplicationInfo represents the replication section of the INFO command, as far as the Operator needs it.
+// Masters report the offset of the replication stream they have produced, replicas the offset they have processed.
+type ReplicationInfo struct {
+	Role string
+	// ReplicationID identifies the history of the data set. Replicas share it with their master once they are in sync.
+	ReplicationID string
+	MasterHost    string
+	MasterLinkUp  bool
+	Offset        int64
+}
+
+// NewReplicationInfo parses the output of INFO replication
+func NewReplicationInfo(info string) ReplicationInfo {
+	fields := parseInfo(info)
+	replicationInfo := ReplicationInfo{
+		Role:          fields["role"],
+		ReplicationID: fields["master_replid"],
+	}
+	if replicationInfo.Role == "master" {
+		replicationInfo.Offset, _ = strconv.ParseInt(fields["master_repl_offset"], 10, 64)
+		return replicationInfo
+	}
+	replicationInfo.MasterHost = fields["master_host"]
+	replicationInfo.MasterLinkUp = fields["master_link_status"] == "up"
+	replicationInfo.Offset, _ = strconv.ParseInt(fields["slave_repl_offset"], 10, 64)
+	return replicationInfo
+}
+
+// ReplicationNode is a Redis node running without cluster support, either as the master or as a replica of the master.
+// The flags of the node reflect its role, so the node can be used wherever only the role of a cluster node matters,
+// like saving snapshots on the masters.
+type ReplicationNode struct {
+	*Node
+	Replication ReplicationInfo
+}
+
+func NewReplicationNode(ctx context.Context, opt *redis.Options, pod *v1.Pod, clientBuilder func(opt *redis.Options) *redis.Client) (*ReplicationNode, error) {
+	node := &ReplicationNode{
+		Node: &Node{
+			Client:        clientBuilder(opt),
+			PodDetails:    pod,
+			clientBuilder: clientBuilder,
+		},
+	}
+	err := node.ReloadReplicationInfo(ctx)
+	if err != nil {
+		_ = node.Close()
+		return nil, err
+	}
+	node.NodeAttributes.host = strings.Split(opt.Addr, ":")[0]
+	node.NodeAttributes.port = strings.Split(opt.Addr, ":")[1]
+	return node, nil
+}
+
+// GetReplicationInfo returns the replication section of INFO for the node.
+// This works with and without cluster support, as cluster replicas replicate their master the same way.
+func (n *Node) GetReplicationInfo(ctx context.Context) (ReplicationInfo, error) {
+	info, err := n.Info(ctx, "replication").Result()
+	if err != nil {
+		return ReplicationInfo{}, err
+	}
+	return NewReplicationInfo(info), nil
+}
+
+func (n *ReplicationNode) ReloadReplicationInfo(ctx context.Context) error {
+	replication, err := n.GetReplicationInfo(ctx)
+	if err != nil {
+		return err
+	}
+	n.Replication = replication
+	n.NodeAttributes.ID = n.PodDetails.Name
+	n.NodeAttributes.flags = []string{"myself", n.Replication.Role}
+	return nil
+}
+
+func (n *ReplicationNode) IsMaster() bool {
+	return n.Replication.Role == "master"
+}
+
+// IsReplicaOf returns whether the node replicates the master on the given host
+func (n *ReplicationNode) IsReplicaOf(host string) bool {
+	return !n.IsMaster() && n.Replication.MasterHost == host
+}
+
+// PromoteToMaster stops the node from replicating, so it starts accepting writes with the data it has replicated so far
+func (n *ReplicationNode) PromoteToMaster(ctx context.Context) error {
+	err := n.SlaveOf(ctx, "NO", "ONE").Err()
+	if err != nil {
+		return err
+	}
+	return n.ReloadReplicationInfo(ctx)
+}
+
+// ReplicateFrom makes the node a replica of the master on the given host.
+// The node drops its own data, and loads the data of the master instead.
+func (n *ReplicationNode) ReplicateFrom(ctx context.Context, host string) error {
+	err := n.SlaveOf(ctx, host, strconv.Itoa(v1beta1.RedisPort)).Err()
+	if err != nil {
+		return err
+	}
+	return n.ReloadReplicationInfo(ctx)
+}
+
+// ReplicationNodes are all the reachable nodes of a cluster in Replication mode
+type ReplicationNodes struct {
+	Nodes []*ReplicationNode
+}
+
+// Close closes the clients of all the nodes
+func (r *ReplicationNodes) Close() {
+	for _, node := range r.Nodes {
+		_ = node.Close()
+	}
+}
+
+// GetNodeForPod returns the node running in the pod with the given name, or nil if the node is not reachable
+func (r *ReplicationNodes) GetNodeForPod(podName string) *ReplicationNode {
+	for _, node := range r.Nodes {
+		if node.PodDetails.Name == podName {
+			return node
+		}
+	}
+	return nil
+}
+
+// ElectMaster returns the node which should be the master, given the pod which currently holds the master role.
+// The current master is kept while it is reachable and still has all the data. Otherwise the replica which has
+// processed the most of the replication stream is promoted, so as little data as possible is lost.
+// Without any replicas, for example when the cluster is first created, the master with the most data is chosen.
+func (r *ReplicationNodes) ElectMaster(currentMaster string) *ReplicationNode {
+	var master, replica *ReplicationNode
+	for _, node := range r.Nodes {
+		if !node.IsMaster() {
+			if replica == nil || node.Replication.Offset > replica.Replication.Offset {
+				replica = node
+			}
+			continue
+		}
+		if master == nil || node.Replication.Offset > master.Replication.Offset {
+			master = node
+		}
+	}
+	current := r.GetNodeForPod(currentMaster)
+	if current != nil && current.IsMaster() {
+		master = current
+	}
+
+	// A master which restarted without its data starts over with a new replication ID at offset 0.
+	// The replicas still hold the data, and would throw it away when they resync from the empty master.
+	if master != nil && (replica == nil || !replica.hasMoreDataThan(master)) {
+		return master
+	}
+	return replica
+}
+
+// hasMoreDataThan returns whether the node holds data the master does not have.
+// Replicas in sync with the master can be ahead of the offset the master reported a moment earlier,
+// so offsets are only compared between different histories.
+func (n *ReplicationNode) hasMoreDataThan(master *ReplicationNode) bool {
+	return n.Replication.ReplicationID != master.Replication.ReplicationID && n.Replication.Offset > master.Replication.Offset
+}
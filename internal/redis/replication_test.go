@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func newReplicationNode(podName string, info ReplicationInfo) *ReplicationNode {
+	return &ReplicationNode{
+		Node: &Node{
+			PodDetails: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: podName,
+				},
+			},
+		},
+		Replication: info,
+	}
+}
+
+func TestNewReplicationInfo(t *testing.T) {
+	master := NewReplicationInfo("# Replication\r\nrole:master\r\nconnected_slaves:1\r\nmaster_replid:abc\r\nmaster_repl_offset:1500\r\n")
+	if master.Role != "master" || master.Offset != 1500 || master.ReplicationID != "abc" {
+		t.Fatalf("Unexpected replication info for master %+v", master)
+	}
+	replica := NewReplicationInfo("# Replication\r\nrole:slave\r\nmaster_host:redis-0.redis-headless.default.svc\r\nmaster_port:6379\r\nmaster_link_status:up\r\nslave_repl_offset:1200\r\nmaster_replid:abc\r\nmaster_repl_offset:1200\r\n")
+	if replica.Role != "slave" || replica.Offset != 1200 || replica.MasterHost != "redis-0.redis-headless.default.svc" || !replica.MasterLinkUp {
+		t.Fatalf("Unexpected replication info for replica %+v", replica)
+	}
+}
+
+func TestNewReplicationNodeLoadsRole(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectInfo("replication").SetVal("# Replication\r\nrole:master\r\nmaster_replid:abc\r\nmaster_repl_offset:0\r\n")
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "redis-cluster-0",
+		},
+	}
+	node, err := NewReplicationNode(context.TODO(), &redis.Options{Addr: "10.0.0.1:6379"}, pod, func(opt *redis.Options) *redis.Client {
+		return client
+	})
+	if err != nil {
+		t.Fatalf("Expected node to be created. Got %v", err)
+	}
+	if !node.IsMaster() || !node.NodeAttributes.HasFlag("master") || node.NodeAttributes.GetHost() != "10.0.0.1" {
+		t.Fatalf("Expected node to be a master on 10.0.0.1. Got %+v", node.NodeAttributes)
+	}
+}
+
+func TestReplicationNode_PromoteToMaster(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	node := newReplicationNode("redis-cluster-1", ReplicationInfo{Role: "slave"})
+	node.Client = client
+	mock.ExpectSlaveOf("NO", "ONE").SetVal("OK")
+	mock.ExpectInfo("replication").SetVal("# Replication\r\nrole:master\r\nmaster_replid:def\r\nmaster_repl_offset:1200\r\n")
+
+	err := node.PromoteToMaster(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected node to be promoted. Got %v", err)
+	}
+	if !node.IsMaster() {
+		t.Fatalf("Expected node to be a master after promotion")
+	}
+	if mock.ExpectationsWereMet() != nil {
+		t.Fatalf("Expected REPLICAOF NO ONE to be sent to the node")
+	}
+}
+
+func TestReplicationNodes_ElectMasterKeepsCurrentMaster(t *testing.T) {
+	nodes := ReplicationNodes{
+		Nodes: []*ReplicationNode{
+			newReplicationNode("redis-cluster-0", ReplicationInfo{Role: "slave", ReplicationID: "abc", Offset: 1600}),
+			newReplicationNode("redis-cluster-1", ReplicationInfo{Role: "master", ReplicationID: "abc", Offset: 1500}),
+		},
+	}
+	// The replica processed writes after the master was asked for its offset, which is no reason to fail over
+	master := nodes.ElectMaster("redis-cluster-1")
+	if master.PodDetails.Name != "redis-cluster-1" {
+		t.Fatalf("Expected current master to be kept. Got %s", master.PodDetails.Name)
+	}
+}
+
+func TestReplicationNodes_ElectMasterPromotesMostUpToDateReplica(t *testing.T) {
+	nodes := ReplicationNodes{
+		Nodes: []*ReplicationNode{
+			newReplicationNode("redis-cluster-1", ReplicationInfo{Role: "slave", ReplicationID: "abc", Offset: 1200}),
+			newReplicationNode("redis-cluster-2", ReplicationInfo{Role: "slave", ReplicationID: "abc", Offset: 1500}),
+		},
+	}
+	// The current master is not reachable
+	master := nodes.ElectMaster("redis-cluster-0")
+	if master.PodDetails.Name != "redis-cluster-2" {
+		t.Fatalf("Expected most up to date replica to be promoted. Got %s", master.PodDetails.Name)
+	}
+}
+
+func TestReplicationNodes_ElectMasterReplacesMasterWhichLostItsData(t *testing.T) {
+	nodes := ReplicationNodes{
+		Nodes: []*ReplicationNode{
+			newReplicationNode("redis-cluster-0", ReplicationInfo{Role: "master", ReplicationID: "new", Offset: 0}),
+			newReplicationNode("redis-cluster-1", ReplicationInfo{Role: "slave", ReplicationID: "abc", Offset: 1500}),
+		},
+	}
+	master := nodes.ElectMaster("redis-cluster-0")
+	if master.PodDetails.Name != "redis-cluster-1" {
+		t.Fatalf("Expected replica holding the data to be promoted. Got %s", master.PodDetails.Name)
+	}
+}
+
+func TestReplicationNodes_ElectMasterForNewCluster(t *testing.T) {
+	nodes := ReplicationNodes{
+		Nodes: []*ReplicationNode{
+			newReplicationNode("redis-cluster-0", ReplicationInfo{Role: "master", ReplicationID: "abc"}),
+			newReplicationNode("redis-cluster-1", ReplicationInfo{Role: "master", ReplicationID: "def"}),
+		},
+	}
+	master := nodes.ElectMaster("")
+	if master.PodDetails.Name != "redis-cluster-0" {
+		t.Fatalf("Expected first node to become master. Got %s", master.PodDetails.Name)
+	}
+}
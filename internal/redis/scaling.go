@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
+	"strconv"
+)
+
+// GetMemoryUsage returns the memory used by the node, and its maxmemory setting, from INFO memory.
+// A maxmemory of 0 means the node can use as much memory as it gets.
+func (n *Node) GetMemoryUsage(ctx context.Context) (int64, int64, error) {
+	info, err := n.Info(ctx, "memory").Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	memory := parseInfo(info)
+	used, err := strconv.ParseInt(memory["used_memory"], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not parse used_memory of node %s: %w", n.NodeAttributes.ID, err)
+	}
+	maxMemory, err := strconv.ParseInt(memory["maxmemory"], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not parse maxmemory of node %s: %w", n.NodeAttributes.ID, err)
+	}
+	return used, maxMemory, nil
+}
+
+// GetMastersMemoryUsage returns the memory used by all masters together, and the maxmemory of a single master.
+// Returns a maxmemory of 0 if any master can use as much memory as it gets,
+// as the utilization of the masters can not be calculated then.
+func (c *ClusterNodes) GetMastersMemoryUsage(ctx context.Context) (int64, int64, error) {
+	totalUsed := int64(0)
+	maxMemory := int64(0)
+	for i, node := range c.GetMasters() {
+		used, nodeMaxMemory, err := node.GetMemoryUsage(ctx)
+		if err != nil {
+			return 0, 0, err
+		}
+		if nodeMaxMemory == 0 {
+			return totalUsed, 0, nil
+		}
+		// All nodes run with the same config, so they normally share the same maxmemory.
+		// If they don't, we take the lowest, so we never underestimate the utilization.
+		if i == 0 || nodeMaxMemory < maxMemory {
+			maxMemory = nodeMaxMemory
+		}
+		totalUsed += used
+	}
+	return totalUsed, maxMemory, nil
+}
+
+// PrepareScaleDown takes a step towards removing the nodes in the pods beyond the nodes the cluster needs.
+// See planner.PlanScaleDown for the steps.
+// Returns true once the pods can be removed without losing slots, or leaving replicas without a master.
+func (c *ClusterNodes) PrepareScaleDown(ctx context.Context, cluster *v1beta1.RedisCluster) (bool, error) {
+	actions := planner.PlanScaleDown(c.GetTopology(), cluster)
+	if len(actions) == 0 {
+		return true, nil
+	}
+	return false, c.Execute(ctx, actions, 0)
+}
@@ -0,0 +1,23 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redismock/v8"
+	"testing"
+)
+
+func TestNode_GetMemoryUsage(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	node := Node{
+		Client: client,
+	}
+	mock.ExpectInfo("memory").SetVal("# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\nmaxmemory:4194304\r\n")
+
+	used, maxMemory, err := node.GetMemoryUsage(context.TODO())
+	if err != nil {
+		t.Fatalf("Failed to get memory usage %v", err)
+	}
+	if used != 1048576 || maxMemory != 4194304 {
+		t.Fatalf("Expected 1048576 bytes used of 4194304. Got %d of %d", used, maxMemory)
+	}
+}
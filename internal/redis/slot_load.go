@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
+	"github.com/go-redis/redis/v8"
+)
+
+// SlotLoads is the load of every slot, in keys or bytes depending on the rebalance strategy
+type SlotLoads map[int32]float64
+
+// GetSlotLoads samples the load of the slots owned by the node.
+// The amount of keys comes from CLUSTER COUNTKEYSINSLOT. The memory of a slot is estimated by sampling MEMORY USAGE
+// for up to sampleSize keys in the slot, and multiplying the average by the amount of keys in the slot.
+// The commands are pipelined, as a master can easily own thousands of slots.
+func (n *Node) GetSlotLoads(ctx context.Context, strategy v1beta1.RebalanceStrategy, sampleSize int) (SlotLoads, error) {
+	slots := n.NodeAttributes.GetSlots()
+	loads := SlotLoads{}
+	if len(slots) == 0 {
+		return loads, nil
+	}
+
+	pipe := n.Client.Pipeline()
+	keyCounts := map[int32]*redis.IntCmd{}
+	for _, slot := range slots {
+		keyCounts[slot] = pipe.ClusterCountKeysInSlot(ctx, int(slot))
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not count keys in slots of node %s: %w", n.NodeAttributes.ID, err)
+	}
+	for slot, cmd := range keyCounts {
+		loads[slot] = float64(cmd.Val())
+	}
+	if strategy != v1beta1.RebalanceStrategyMemory {
+		return loads, nil
+	}
+
+	pipe = n.Client.Pipeline()
+	sampleKeys := map[int32]*redis.StringSliceCmd{}
+	for _, slot := range slots {
+		if loads[slot] == 0 {
+			continue
+		}
+		sampleKeys[slot] = pipe.ClusterGetKeysInSlot(ctx, int(slot), sampleSize)
+	}
+	if len(sampleKeys) == 0 {
+		return loads, nil
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not sample keys in slots of node %s: %w", n.NodeAttributes.ID, err)
+	}
+
+	pipe = n.Client.Pipeline()
+	memoryUsages := map[int32][]*redis.IntCmd{}
+	for slot, cmd := range sampleKeys {
+		for _, key := range cmd.Val() {
+			memoryUsages[slot] = append(memoryUsages[slot], pipe.MemoryUsage(ctx, key))
+		}
+	}
+	// Keys can expire between sampling and measuring them, in which case MEMORY USAGE returns nil
+	_, err = pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("could not sample memory usage in slots of node %s: %w", n.NodeAttributes.ID, err)
+	}
+	for slot := range sampleKeys {
+		sampled := 0
+		total := int64(0)
+		for _, cmd := range memoryUsages[slot] {
+			if cmd.Err() != nil {
+				continue
+			}
+			sampled++
+			total += cmd.Val()
+		}
+		if sampled == 0 {
+			loads[slot] = 0
+			continue
+		}
+		loads[slot] = float64(total) / float64(sampled) * loads[slot]
+	}
+	return loads, nil
+}
+
+// GetSlotLoads samples the load of the slots owned by all masters
+func (c *ClusterNodes) GetSlotLoads(ctx context.Context, strategy v1beta1.RebalanceStrategy, sampleSize int) (SlotLoads, error) {
+	loads := SlotLoads{}
+	for _, node := range c.GetMasters() {
+		nodeLoads, err := node.GetSlotLoads(ctx, strategy, sampleSize)
+		if err != nil {
+			return nil, err
+		}
+		for slot, load := range nodeLoads {
+			loads[slot] = load
+		}
+	}
+	return loads, nil
+}
+
+// PlanRebalance calculates the rebalance plan for the strategy in the rebalance policy of the cluster.
+// Balancing on keys or memory samples the load of every slot first.
+func (c *ClusterNodes) PlanRebalance(ctx context.Context, cluster *v1beta1.RedisCluster) (*RebalancePlan, error) {
+	strategy := cluster.Spec.Rebalance.GetStrategy()
+	if strategy == v1beta1.RebalanceStrategySlots {
+		return c.CalculateRebalance(ctx, cluster), nil
+	}
+	loads, err := c.GetSlotLoads(ctx, strategy, int(cluster.Spec.Rebalance.GetMemorySampleSize()))
+	if err != nil {
+		return nil, err
+	}
+	return c.CalculateLoadRebalance(cluster, loads), nil
+}
+
+// CalculateLoadRebalance plans the slot moves needed for the load of every master to match its share of the total load.
+// See planner.PlanLoadRebalance for how the moves are chosen.
+func (c *ClusterNodes) CalculateLoadRebalance(cluster *v1beta1.RedisCluster, loads SlotLoads) *RebalancePlan {
+	return c.getRebalancePlan(planner.PlanLoadRebalance(c.GetTopology(), cluster, loads))
+}
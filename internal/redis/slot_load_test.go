@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"context"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/go-redis/redismock/v8"
+	"math"
+	"testing"
+)
+
+func TestNode_GetSlotLoadsEstimatesMemoryFromSampledKeys(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectClusterCountKeysInSlot(0).SetVal(4)
+	mock.ExpectClusterCountKeysInSlot(1).SetVal(0)
+	mock.ExpectClusterGetKeysInSlot(0, 2).SetVal([]string{"foo", "bar"})
+	mock.ExpectMemoryUsage("foo").SetVal(100)
+	mock.ExpectMemoryUsage("bar").SetVal(300)
+	node := &Node{
+		NodeAttributes: NodeAttributes{
+			ID:    "node-0",
+			flags: []string{"master"},
+			slots: []int32{0, 1},
+		},
+		Client: client,
+	}
+
+	loads, err := node.GetSlotLoads(context.TODO(), v1beta1.RebalanceStrategyMemory, 2)
+	if err != nil {
+		t.Fatalf("Failed to sample slot loads %v", err)
+	}
+	// The average of the sampled keys is 200 bytes, and the slot holds 4 keys
+	if loads[0] != 800 || loads[1] != 0 {
+		t.Fatalf("Expected slot 0 to use 800 bytes and slot 1 none. Got %v", loads)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Not all expectations were met %v", err)
+	}
+}
+
+func TestClusterNodes_CalculateLoadRebalanceEvensOutLoad(t *testing.T) {
+	clusterNodes := newPinningTestNodes([][]string{{"0-5460"}, {"5461-10922"}, {"10923-16383"}})
+	cluster := &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			Rebalance: v1beta1.RebalancePolicy{
+				Strategy:            v1beta1.RebalanceStrategyKeys,
+				ThresholdPercentage: 5,
+			},
+		},
+	}
+	// The first master owns a hot slot, holding as many keys as 1000 other slots
+	loads := SlotLoads{}
+	for slot := int32(0); slot < TotalRedisSlots; slot++ {
+		loads[slot] = 1
+	}
+	loads[0] = 1000
+
+	plan := clusterNodes.CalculateLoadRebalance(cluster, loads)
+	if len(plan.Moves) == 0 {
+		t.Fatalf("Expected slots to be moved away from the master with the hot slot")
+	}
+	owned := applyPlan(clusterNodes, plan)
+	if !owned["node-0"][0] {
+		t.Fatalf("Expected the hot slot to stay, as moving it would overload another master")
+	}
+	target := 0.0
+	for _, load := range loads {
+		target += load
+	}
+	target /= 3
+	for id, slots := range owned {
+		load := 0.0
+		for slot := range slots {
+			load += loads[slot]
+		}
+		if math.Abs(load-target) > 1 {
+			t.Fatalf("Expected the load of %s to be %.1f. Got %.1f", id, target, load)
+		}
+	}
+
+	// The same cluster with a cold slot is balanced, and needs no moves
+	loads[0] = 1
+	plan = clusterNodes.CalculateLoadRebalance(cluster, loads)
+	if len(plan.Moves) != 0 {
+		t.Fatalf("Expected no moves for a balanced cluster. Got %d moves", len(plan.Moves))
+	}
+}
@@ -0,0 +1,141 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/containersolutions/redis-cluster-operator/api/v1beta1"
+	"github.com/containersolutions/redis-cluster-operator/internal/planner"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strconv"
+	"testing"
+)
+
+func newPinningTestNodes(slotRanges [][]string) ClusterNodes {
+	var nodes []*Node
+	for i := range slotRanges {
+		nodes = append(nodes, &Node{
+			NodeAttributes: NodeAttributes{
+				ID:    fmt.Sprintf("node-%d", i),
+				flags: []string{"master"},
+				slots: ProcessSlotStrings(slotRanges[i]),
+			},
+			PodDetails: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rediscluster-" + strconv.FormatInt(int64(i), 10),
+					Namespace: "default",
+				},
+			},
+		})
+	}
+	return ClusterNodes{
+		Nodes: nodes,
+	}
+}
+
+// applyPlan returns the slots each node owns once the plan has been applied
+func applyPlan(clusterNodes ClusterNodes, plan *RebalancePlan) map[string]map[int32]bool {
+	owned := map[string]map[int32]bool{}
+	for _, node := range clusterNodes.Nodes {
+		owned[node.NodeAttributes.ID] = map[int32]bool{}
+		for _, slot := range node.NodeAttributes.GetSlots() {
+			owned[node.NodeAttributes.ID][slot] = true
+		}
+	}
+	for _, move := range plan.Moves {
+		for _, slot := range move.Slots {
+			delete(owned[move.Source.NodeAttributes.ID], slot)
+			owned[move.Destination.NodeAttributes.ID][slot] = true
+		}
+	}
+	return owned
+}
+
+func TestClusterNodes_PlanSlotAssignmentAssignsPinnedSlotsToTheirMaster(t *testing.T) {
+	clusterNodes := newPinningTestNodes([][]string{{}, {}, {}})
+	actions := planner.PlanSlotAssignment(clusterNodes.GetTopology(), &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			SlotRanges: []v1beta1.SlotRange{
+				{Ordinal: 2, Slots: "0-99"},
+			},
+		},
+	})
+	assignment := map[string][]int32{}
+	assigned := 0
+	for _, action := range actions {
+		addSlots := action.(planner.AddSlots)
+		assignment[addSlots.Node] = addSlots.Slots
+		assigned += len(addSlots.Slots)
+		for _, slot := range addSlots.Slots {
+			if slot < 100 && addSlots.Node != "node-2" {
+				t.Fatalf("Pinned slot %d assigned to %s", slot, addSlots.Node)
+			}
+		}
+	}
+	if assigned != TotalRedisSlots {
+		t.Fatalf("Expected all slots to be assigned, got %d", assigned)
+	}
+	// The pinned slots come on top of the share of the unpinned slots
+	if len(assignment["node-2"]) < len(assignment["node-0"]) {
+		t.Fatalf("Expected the pinned master to get more slots than the others. Got %d slots", len(assignment["node-2"]))
+	}
+}
+
+func TestClusterNodes_CalculateRebalanceMovesPinnedSlotsFirst(t *testing.T) {
+	clusterNodes := newPinningTestNodes([][]string{{"0-5461"}, {"5462-10922"}, {"10923-16383"}})
+	cluster := &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			SlotRanges: []v1beta1.SlotRange{
+				{Ordinal: 2, Slots: "0-99"},
+			},
+		},
+	}
+	quotas := planner.SlotQuotas(clusterNodes.GetTopology(), cluster)
+	plan := clusterNodes.CalculateRebalance(context.TODO(), cluster)
+	if len(plan.Moves) == 0 || plan.Moves[0].Destination.NodeAttributes.ID != "node-2" || len(plan.Moves[0].Slots) != 100 {
+		t.Fatalf("Expected the pinned slots to be moved to node-2 first. Got %v", plan.Moves)
+	}
+
+	owned := applyPlan(clusterNodes, plan)
+	for slot := int32(0); slot < 100; slot++ {
+		if !owned["node-2"][slot] {
+			t.Fatalf("Expected pinned slot %d to end up on node-2", slot)
+		}
+	}
+	for id, quota := range quotas {
+		if len(owned[id]) != int(quota) {
+			t.Fatalf("Expected %s to own %d slots, got %d", id, quota, len(owned[id]))
+		}
+	}
+
+	// Once in place, the pinned slots are never moved away, even though the pinned master owns far more slots than the others
+	cluster.Spec.SlotRanges[0].Slots = "0-8191"
+	clusterNodes = newPinningTestNodes([][]string{{"8192-10922"}, {"10923-13653"}, {"0-8191", "13654-16383"}})
+	plan = clusterNodes.CalculateRebalance(context.TODO(), cluster)
+	if len(plan.Moves) != 0 {
+		t.Fatalf("Expected no moves for a cluster where the unpinned slots are balanced. Got %d moves", len(plan.Moves))
+	}
+}
+
+func TestClusterNodes_CalculateRebalanceKeepsSlotsPinnedToReplicas(t *testing.T) {
+	clusterNodes := newPinningTestNodes([][]string{{"0-5461"}, {"5462-10922"}, {"10923-16383"}, {}})
+	// The pod with ordinal 3 runs a replica, for example after a failover, so its pinned slots stay where they are
+	clusterNodes.Nodes[3].NodeAttributes.flags = []string{"slave"}
+	plan := clusterNodes.CalculateRebalance(context.TODO(), &v1beta1.RedisCluster{
+		Spec: v1beta1.RedisClusterSpec{
+			Masters: 3,
+			SlotRanges: []v1beta1.SlotRange{
+				{Ordinal: 3, Slots: "0-999"},
+			},
+		},
+	})
+	for _, move := range plan.Moves {
+		for _, slot := range move.Slots {
+			if slot < 1000 {
+				t.Fatalf("Expected held slot %d not to be moved", slot)
+			}
+		}
+	}
+}
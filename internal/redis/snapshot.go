@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BackgroundSave starts saving a snapshot of the data of the node to disk
+func (n *Node) BackgroundSave(ctx context.Context) error {
+	err := n.BgSave(ctx).Err()
+	if err != nil && strings.Contains(err.Error(), "already in progress") {
+		// A snapshot which is already being saved is just as good as a new one
+		return nil
+	}
+	return err
+}
+
+// SnapshotInProgress returns whether the node is still saving a snapshot in the background.
+// Returns an error if the last snapshot failed.
+func (n *Node) SnapshotInProgress(ctx context.Context) (bool, error) {
+	info, err := n.Info(ctx, "persistence").Result()
+	if err != nil {
+		return false, err
+	}
+	persistence := parseInfo(info)
+	if persistence["rdb_bgsave_in_progress"] == "1" {
+		return true, nil
+	}
+	if persistence["rdb_last_bgsave_status"] != "ok" {
+		return false, errors.New("last background save failed")
+	}
+	return false, nil
+}
+
+// SnapshotMasters starts saving a snapshot on every master.
+// Replicas hold the same data as their masters, so snapshotting them as well would only use more disk space.
+func (c *ClusterNodes) SnapshotMasters(ctx context.Context) error {
+	for _, node := range c.GetMasters() {
+		err := node.BackgroundSave(ctx)
+		if err != nil {
+			return fmt.Errorf("could not start snapshot on node %s: %w", node.NodeAttributes.ID, err)
+		}
+	}
+	return nil
+}
+
+// SnapshotsInProgress returns whether any of the masters is still saving a snapshot
+func (c *ClusterNodes) SnapshotsInProgress(ctx context.Context) (bool, error) {
+	for _, node := range c.GetMasters() {
+		inProgress, err := node.SnapshotInProgress(ctx)
+		if err != nil {
+			return false, fmt.Errorf("could not check snapshot on node %s: %w", node.NodeAttributes.ID, err)
+		}
+		if inProgress {
+			return true, nil
+		}
+	}
+	return false, nil
+}
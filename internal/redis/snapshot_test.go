@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redismock/v8"
+	"testing"
+)
+
+func TestClusterNodes_SnapshotMastersOnlySavesMasters(t *testing.T) {
+	masterClient, masterMock := redismock.NewClientMock()
+	replicaClient, replicaMock := redismock.NewClientMock()
+	clusterNodes := ClusterNodes{
+		Nodes: []*Node{
+			{
+				Client: masterClient,
+				NodeAttributes: NodeAttributes{
+					ID:    "master",
+					flags: []string{"master"},
+				},
+			},
+			{
+				Client: replicaClient,
+				NodeAttributes: NodeAttributes{
+					ID:    "replica",
+					flags: []string{"slave"},
+				},
+			},
+		},
+	}
+	masterMock.ExpectBgSave().SetErr(errors.New("ERR Background save already in progress"))
+
+	err := clusterNodes.SnapshotMasters(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected a snapshot in progress not to be an error. Got %v", err)
+	}
+	if masterMock.ExpectationsWereMet() != nil {
+		t.Fatalf("Expected master to save a snapshot")
+	}
+	if replicaMock.ExpectationsWereMet() != nil {
+		t.Fatalf("Did not expect replica to save a snapshot")
+	}
+}
+
+func TestNode_SnapshotInProgress(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	node := Node{
+		Client: client,
+	}
+	mock.ExpectInfo("persistence").SetVal("# Persistence\r\nloading:0\r\nrdb_bgsave_in_progress:1\r\nrdb_last_bgsave_status:ok\r\n")
+	mock.ExpectInfo("persistence").SetVal("# Persistence\r\nloading:0\r\nrdb_bgsave_in_progress:0\r\nrdb_last_bgsave_status:ok\r\n")
+	mock.ExpectInfo("persistence").SetVal("# Persistence\r\nloading:0\r\nrdb_bgsave_in_progress:0\r\nrdb_last_bgsave_status:err\r\n")
+
+	inProgress, err := node.SnapshotInProgress(context.TODO())
+	if err != nil || !inProgress {
+		t.Fatalf("Expected snapshot to be in progress. Got %v %v", inProgress, err)
+	}
+	inProgress, err = node.SnapshotInProgress(context.TODO())
+	if err != nil || inProgress {
+		t.Fatalf("Expected snapshot to be finished. Got %v %v", inProgress, err)
+	}
+	_, err = node.SnapshotInProgress(context.TODO())
+	if err == nil {
+		t.Fatalf("Expected failed snapshot to return an error")
+	}
+}
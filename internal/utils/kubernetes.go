@@ -12,6 +12,13 @@ func IsPodReady(pod *v1.Pod) bool {
 	return IsPodReadyConditionTrue(pod.Status)
 }
 
+// IsPodContainersReady returns true if all containers of a pod are ready; false otherwise.
+// Unlike IsPodReady, this ignores the readiness gates of the pod.
+func IsPodContainersReady(pod *v1.Pod) bool {
+	_, condition := GetPodCondition(&pod.Status, v1.ContainersReady)
+	return condition != nil && condition.Status == v1.ConditionTrue
+}
+
 // IsPodReadyConditionTrue returns true if a pod is ready; false otherwise.
 func IsPodReadyConditionTrue(status v1.PodStatus) bool {
 	condition := GetPodReadyCondition(status)
@@ -7,7 +7,13 @@ import (
 
 func MergeContainerPorts(dst []v1.ContainerPort, src []v1.ContainerPort) []v1.ContainerPort {
 	resultMap := map[string]v1.ContainerPort{}
+	// We keep track of the order of the items, so merging the same slices always returns the same result.
+	// Otherwise the order would change between reconciles, and look like a change to the Statefulset.
+	var order []string
 	for _, dstItem := range dst {
+		if _, ok := resultMap[dstItem.Name]; !ok {
+			order = append(order, dstItem.Name)
+		}
 		resultMap[dstItem.Name] = dstItem
 	}
 	for _, srcItem := range src {
@@ -15,19 +21,24 @@ func MergeContainerPorts(dst []v1.ContainerPort, src []v1.ContainerPort) []v1.Co
 			_ = mergo.Merge(&val, srcItem, mergo.WithOverride)
 			resultMap[srcItem.Name] = val
 		} else {
+			order = append(order, srcItem.Name)
 			resultMap[srcItem.Name] = srcItem
 		}
 	}
 	var results []v1.ContainerPort
-	for _, item := range resultMap {
-		results = append(results, item)
+	for _, name := range order {
+		results = append(results, resultMap[name])
 	}
 	return results
 }
 
 func MergeVolumeMounts(dst []v1.VolumeMount, src []v1.VolumeMount) []v1.VolumeMount {
 	resultMap := map[string]v1.VolumeMount{}
+	var order []string
 	for _, dstItem := range dst {
+		if _, ok := resultMap[dstItem.Name]; !ok {
+			order = append(order, dstItem.Name)
+		}
 		resultMap[dstItem.Name] = dstItem
 	}
 	for _, srcItem := range src {
@@ -35,19 +46,24 @@ func MergeVolumeMounts(dst []v1.VolumeMount, src []v1.VolumeMount) []v1.VolumeMo
 			_ = mergo.Merge(&val, srcItem, mergo.WithOverride)
 			resultMap[srcItem.Name] = val
 		} else {
+			order = append(order, srcItem.Name)
 			resultMap[srcItem.Name] = srcItem
 		}
 	}
 	var results []v1.VolumeMount
-	for _, item := range resultMap {
-		results = append(results, item)
+	for _, name := range order {
+		results = append(results, resultMap[name])
 	}
 	return results
 }
 
 func MergeVolumes(dst []v1.Volume, src []v1.Volume) []v1.Volume {
 	resultMap := map[string]v1.Volume{}
+	var order []string
 	for _, dstItem := range dst {
+		if _, ok := resultMap[dstItem.Name]; !ok {
+			order = append(order, dstItem.Name)
+		}
 		resultMap[dstItem.Name] = dstItem
 	}
 	for _, srcItem := range src {
@@ -55,19 +71,24 @@ func MergeVolumes(dst []v1.Volume, src []v1.Volume) []v1.Volume {
 			_ = mergo.Merge(&val, srcItem, mergo.WithOverride)
 			resultMap[srcItem.Name] = val
 		} else {
+			order = append(order, srcItem.Name)
 			resultMap[srcItem.Name] = srcItem
 		}
 	}
 	var results []v1.Volume
-	for _, item := range resultMap {
-		results = append(results, item)
+	for _, name := range order {
+		results = append(results, resultMap[name])
 	}
 	return results
 }
 
 func MergeContainers(dst []v1.Container, src []v1.Container) []v1.Container {
 	resultMap := map[string]v1.Container{}
+	var order []string
 	for _, dstItem := range dst {
+		if _, ok := resultMap[dstItem.Name]; !ok {
+			order = append(order, dstItem.Name)
+		}
 		resultMap[dstItem.Name] = dstItem
 	}
 	for _, srcItem := range src {
@@ -81,12 +102,13 @@ func MergeContainers(dst []v1.Container, src []v1.Container) []v1.Container {
 			val.VolumeMounts = volumeMounts
 			resultMap[srcItem.Name] = val
 		} else {
+			order = append(order, srcItem.Name)
 			resultMap[srcItem.Name] = srcItem
 		}
 	}
 	var results []v1.Container
-	for _, item := range resultMap {
-		results = append(results, item)
+	for _, name := range order {
+		results = append(results, resultMap[name])
 	}
 	return results
 }
@@ -3,6 +3,7 @@ package utils
 import (
 	v1 "k8s.io/api/core/v1"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -197,3 +198,18 @@ func TestMergeContainers(t *testing.T) {
 		t.Fatalf("Metrics volume mount not correctly added")
 	}
 }
+
+func TestMergeContainersKeepsOrder(t *testing.T) {
+	dst := []v1.Container{{Name: "redis"}, {Name: "exporter"}}
+	src := []v1.Container{{Name: "sidecar"}, {Name: "redis", Image: "redis:7.0.5"}, {Name: "another-sidecar"}}
+	for i := 0; i < 10; i++ {
+		merged := MergeContainers(dst, src)
+		var names []string
+		for _, container := range merged {
+			names = append(names, container.Name)
+		}
+		if strings.Join(names, ",") != "redis,exporter,sidecar,another-sidecar" {
+			t.Fatalf("Containers merged in unexpected order %v", names)
+		}
+	}
+}
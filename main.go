@@ -18,20 +18,26 @@ package main
 
 import (
 	"flag"
+	"net"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cachev1alpha1 "github.com/containersolutions/redis-cluster-operator/api/v1alpha1"
+	cachev1beta1 "github.com/containersolutions/redis-cluster-operator/api/v1beta1"
 	"github.com/containersolutions/redis-cluster-operator/controllers"
 	//+kubebuilder:scaffold:imports
 )
@@ -45,6 +51,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(cachev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(cachev1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -52,11 +59,15 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var watchNamespaces string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma separated list of namespaces the operator manages RedisClusters in. "+
+			"Leave empty to manage RedisClusters in all namespaces.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,26 +76,73 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "c88317a7.container-solutions.com",
-	})
+	}
+	var namespaces []string
+	for _, namespace := range strings.Split(watchNamespaces, ",") {
+		if strings.TrimSpace(namespace) != "" {
+			namespaces = append(namespaces, strings.TrimSpace(namespace))
+		}
+	}
+	// We only read the password Secrets of clusters, so we read them straight from the API server,
+	// instead of caching every Secret the Operator can see.
+	options.ClientDisableCacheFor = []client.Object{&v1.Secret{}}
+	if len(namespaces) > 0 {
+		setupLog.Info("watching RedisClusters in namespaces", "namespaces", namespaces)
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+		// Nodes are not namespaced, and caching them would need permissions to watch all nodes.
+		// We only read single nodes now and then, so we read them straight from the API server as well.
+		options.ClientDisableCacheFor = append(options.ClientDisableCacheFor, &v1.Node{})
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.RedisClusterReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	redisClusterReconciler := &controllers.RedisClusterReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("rediscluster-controller"),
+		WatchNamespaces: namespaces,
+	}
+	if err = redisClusterReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "RedisCluster")
 		os.Exit(1)
 	}
+	// The topology API is served next to the metrics, so it is protected by the same auth proxy.
+	// Without a proxy in front, anyone who can reach the metrics could read the topology, so we only serve it on loopback addresses.
+	if isLoopbackAddress(metricsAddr) {
+		if err = mgr.AddMetricsExtraHandler(controllers.TopologyPath, redisClusterReconciler.TopologyHandler()); err != nil {
+			setupLog.Error(err, "unable to add topology API")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("not serving the topology API, as the metrics are not bound to a loopback address behind an auth proxy", "metricsBindAddress", metricsAddr)
+	}
+	if err = (&controllers.RedisClusterOperationReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("redisclusteroperation-controller"),
+		WatchNamespaces: namespaces,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RedisClusterOperation")
+		os.Exit(1)
+	}
+	// The webhooks need certificates, so they can be disabled when running the Operator outside of the cluster
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&cachev1beta1.RedisCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "RedisCluster")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -102,3 +160,16 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// isLoopbackAddress returns whether the bind address only accepts connections from inside the pod
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}